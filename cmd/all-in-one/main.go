@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ethanhosier/web-crawler-coordinator/api"
+	"github.com/ethanhosier/web-crawler-coordinator/reaper"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/ethanhosier/worker-node/setup"
+	"github.com/ethanhosier/worker-node/utils"
+	"github.com/ethanhosier/worker-node/worker_manager"
+	"github.com/joho/godotenv"
+)
+
+const (
+	// reapInterval is how often expired and due tasks are returned to their topics, as the coordinator
+	// does when it runs on its own
+	reapInterval = 5 * time.Second
+
+	defaultCoordinatorListenAddr = ":8080"
+)
+
+// all-in-one runs the coordinator's API, the query server, the reaper and scraper and rag workers in
+// one process until it is stopped, so pages can be scraped, embedded, stored and searched with no other
+// services. The workers are coordinated in memory rather than through Redis, so tasks that haven't
+// finished when the process stops are lost, and they share one store, so it can be a bbolt file.
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Fatalf("Error loading .env file: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	concurrency := 1
+	if concurrencyEnv := os.Getenv("CONCURRENCY"); concurrencyEnv != "" {
+		concurrency = utils.RequiredInt(concurrencyEnv, "CONCURRENCY")
+	}
+
+	coordinatorListenAddr := os.Getenv("COORDINATOR_LISTEN_ADDR")
+	if coordinatorListenAddr == "" {
+		coordinatorListenAddr = defaultCoordinatorListenAddr
+	}
+
+	var (
+		coordinatorClient = coordinator_client.NewMemoryCoordinatorClient()
+		ragClient         = setup.NewRAGClient()
+		store             = setup.NewStore()
+	)
+
+	// A bbolt file is only unlocked for other processes once it is closed
+	if closer, ok := store.(interface{ Close() error }); ok {
+		defer closer.Close()
+	}
+
+	taskReaper := reaper.NewReaper(coordinatorClient, reapInterval, coordinator_client.CoordinatorClientTaskTopicUrls, coordinator_client.CoordinatorClientTaskTopicRag)
+	if maxAttempts := os.Getenv("MAX_ATTEMPTS"); maxAttempts != "" {
+		taskReaper.WithMaxAttempts(utils.RequiredInt(maxAttempts, "MAX_ATTEMPTS"))
+	}
+	go taskReaper.Start(ctx)
+
+	go func() {
+		log.Printf("Starting coordinator server on %s", coordinatorListenAddr)
+		log.Fatal(api.NewServer(coordinatorListenAddr, coordinatorClient, setup.NewBlobStore()).Start())
+	}()
+
+	go func() {
+		log.Fatal(setup.NewQueryServer(ragClient, store).Start())
+	}()
+
+	scraperWorkerManager, closeScraper := setup.NewScraperWorkerManager(coordinatorClient, concurrency, store)
+	defer closeScraper()
+
+	workerManagers := []*worker_manager.WorkerManager{
+		setup.ConfigureWorkerManager(scraperWorkerManager),
+		setup.ConfigureWorkerManager(setup.NewRagWorkerManager(coordinatorClient, ragClient, store)),
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, len(workerManagers))
+	)
+	for _, workerManager := range workerManagers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- workerManager.Start(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	log.Printf("Worker managers stopped")
+}
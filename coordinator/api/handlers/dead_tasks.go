@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+)
+
+type DeadTask struct {
+	TaskID   string                 `json:"task_id"`
+	JobID    string                 `json:"job_id,omitempty"`
+	Params   map[string]interface{} `json:"params"`
+	Attempts int                    `json:"attempts"`
+	Error    string                 `json:"error"`
+	Created  time.Time              `json:"created_at"`
+}
+
+type DeadTasksResponse struct {
+	Topic     string     `json:"topic"`
+	DeadTasks []DeadTask `json:"dead_tasks"`
+}
+
+type RequeueDeadTasksRequest struct {
+	TaskIDs []string `json:"task_ids"`
+}
+
+type RequeueDeadTasksResponse struct {
+	NumRequeued int `json:"num_requeued"`
+}
+
+func DeadTasks(coordinatorClient coordinator_client.CoordinatorClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topic, err := topicFromPath(r)
+		if err != nil {
+			WriteJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		storedDeadTasks, err := coordinatorClient.GetDeadTasks(r.Context(), topic)
+		if err != nil {
+			WriteJSONError(w, "Failed to get dead tasks", http.StatusInternalServerError)
+			return
+		}
+
+		deadTasks := make([]DeadTask, 0, len(storedDeadTasks))
+		for _, deadTask := range storedDeadTasks {
+			if deadTask.Task == nil {
+				continue
+			}
+
+			deadTasks = append(deadTasks, DeadTask{
+				TaskID:   deadTask.Task.ID,
+				JobID:    deadTask.Task.JobId,
+				Params:   deadTask.Task.Params,
+				Attempts: deadTask.Task.Attempts + 1,
+				Error:    deadTask.Error,
+				Created:  deadTask.Created,
+			})
+		}
+
+		WriteJSON(w, DeadTasksResponse{
+			Topic:     topic.String(),
+			DeadTasks: deadTasks,
+		})
+	}
+}
+
+// RequeueDeadTasks puts the dead tasks with the requested ids back on their topic. An empty body
+// or empty list of ids requeues every dead task on the topic.
+func RequeueDeadTasks(coordinatorClient coordinator_client.CoordinatorClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topic, err := topicFromPath(r)
+		if err != nil {
+			WriteJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req RequeueDeadTasksRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				WriteJSONError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		numRequeued, err := coordinatorClient.RequeueDeadTasks(r.Context(), topic, req.TaskIDs)
+		if err != nil {
+			WriteJSONError(w, "Failed to requeue dead tasks", http.StatusInternalServerError)
+			return
+		}
+
+		WriteJSON(w, RequeueDeadTasksResponse{
+			NumRequeued: numRequeued,
+		})
+	}
+}
+
+func topicFromPath(r *http.Request) (coordinator_client.CoordinatorClientTaskTopic, error) {
+	switch topic := coordinator_client.CoordinatorClientTaskTopic(r.PathValue("topic")); topic {
+	case coordinator_client.CoordinatorClientTaskTopicUrls, coordinator_client.CoordinatorClientTaskTopicRag:
+		return topic, nil
+	}
+
+	return "", errors.New("Unknown topic")
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/google/uuid"
+)
+
+const (
+	maxUploadSize  = 64 << 20
+	maxUploadFiles = 20
+
+	// maxUploadMemory is how much of an upload is held in memory, the rest is spooled to disk
+	maxUploadMemory = 8 << 20
+)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type RagWorkerParams struct {
+	URL         string `json:"url"`
+	BlobKey     string `json:"blob_key"`
+	ContentType string `json:"content_type,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+type UploadedDocument struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	BlobKey string `json:"blob_key"`
+}
+
+type UploadDocumentsResponse struct {
+	JobID     string             `json:"job_id"`
+	Documents []UploadedDocument `json:"documents"`
+}
+
+// UploadDocuments takes a multipart upload of files in "file" fields, stores them in the blob store
+// and queues each of them to be embedded. The job tracks each document under its blob:// url. If the
+// upload fails part way, the documents already in the blob store are deleted again.
+func UploadDocuments(coordinatorClient coordinator_client.CoordinatorClient, blobStore blob_store.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+		if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				WriteJSONError(w, fmt.Sprintf("Uploads are limited to %d bytes", maxUploadSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			WriteJSONError(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		files := r.MultipartForm.File["file"]
+		if len(files) == 0 {
+			WriteJSONError(w, "No files provided", http.StatusBadRequest)
+			return
+		}
+
+		if len(files) > maxUploadFiles {
+			WriteJSONError(w, fmt.Sprintf("Maximum number of files is %d", maxUploadFiles), http.StatusBadRequest)
+			return
+		}
+
+		job := &coordinator_client.Job{
+			ID:        uuid.New().String(),
+			CreatedBy: createdBy(r),
+			Created:   time.Now(),
+			Urls:      make(map[string]*coordinator_client.JobUrlProgress, len(files)),
+		}
+
+		tasks := make([]*coordinator_client.Task, 0, len(files))
+		documents := make([]UploadedDocument, 0, len(files))
+
+		for _, file := range files {
+			task, document, err := storeDocument(r, blobStore, job, file)
+			if err != nil {
+				log.Printf("Failed to store document %s of job %s: %v", file.Filename, job.ID, err)
+				deleteDocuments(r.Context(), blobStore, documents)
+				WriteJSONError(w, "Failed to store document", http.StatusInternalServerError)
+				return
+			}
+
+			tasks = append(tasks, task)
+			documents = append(documents, document)
+		}
+
+		if err := coordinatorClient.CreateJob(r.Context(), job); err != nil {
+			log.Printf("Failed to create job %s: %v", job.ID, err)
+			deleteDocuments(r.Context(), blobStore, documents)
+			WriteJSONError(w, "Failed to create job", http.StatusInternalServerError)
+			return
+		}
+
+		if err := coordinatorClient.CreateTasks(r.Context(), coordinator_client.CoordinatorClientTaskTopicRag, tasks); err != nil {
+			log.Printf("Failed to create tasks of job %s: %v", job.ID, err)
+			deleteDocuments(r.Context(), blobStore, documents)
+			WriteJSONError(w, "Failed to create tasks", http.StatusInternalServerError)
+			return
+		}
+
+		WriteJSON(w, UploadDocumentsResponse{
+			JobID:     job.ID,
+			Documents: documents,
+		})
+	}
+}
+
+// storeDocument puts an uploaded file in the blob store and creates the rag task that embeds it. The
+// file is only left in the blob store if it succeeds.
+func storeDocument(r *http.Request, blobStore blob_store.BlobStore, job *coordinator_client.Job, file *multipart.FileHeader) (*coordinator_client.Task, UploadedDocument, error) {
+	var (
+		id          = uuid.New().String()
+		name        = filepath.Base(file.Filename)
+		blobKey     = "documents/" + id + "/" + safeFileName(name)
+		url         = "blob://" + blobKey
+		contentType = file.Header.Get("Content-Type")
+	)
+
+	params := RagWorkerParams{
+		URL:         url,
+		BlobKey:     blobKey,
+		ContentType: contentType,
+		Name:        name,
+	}
+
+	task, err := coordinator_client.NewTask(id, "coordinator-client", params)
+	if err != nil {
+		return nil, UploadedDocument{}, err
+	}
+	task.JobId = job.ID
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, UploadedDocument{}, err
+	}
+	defer f.Close()
+
+	if err := blobStore.Put(r.Context(), blobKey, f, file.Size, contentType); err != nil {
+		return nil, UploadedDocument{}, err
+	}
+	job.Urls[url] = coordinator_client.NewJobUrlProgress(coordinator_client.JobUrlStatusQueued, nil)
+
+	return task, UploadedDocument{ID: id, Name: name, URL: url, BlobKey: blobKey}, nil
+}
+
+// deleteDocuments deletes the blobs of documents whose upload failed, so they aren't left in the blob
+// store without a task to embed them. The request may have been cancelled, so they are deleted anyway.
+func deleteDocuments(ctx context.Context, blobStore blob_store.BlobStore, documents []UploadedDocument) {
+	ctx = context.WithoutCancel(ctx)
+	for _, document := range documents {
+		if err := blobStore.Delete(ctx, document.BlobKey); err != nil {
+			log.Printf("Failed to delete blob %s of a failed upload: %v", document.BlobKey, err)
+		}
+	}
+}
+
+// safeFileName keeps a file's name recognisable in its blob key, including the extension its format
+// may be worked out from, while dropping anything that could be read as part of a path
+func safeFileName(name string) string {
+	safe := unsafeFileNameChars.ReplaceAllString(name, "_")
+	if safe == "" || safe == "." || safe == ".." {
+		return "document"
+	}
+	return safe
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingTasksCoordinatorClient fails to create tasks, as a coordinator that has lost Redis would
+type failingTasksCoordinatorClient struct {
+	*coordinator_client.MockCoordinatorClient
+}
+
+func (c *failingTasksCoordinatorClient) CreateTasks(ctx context.Context, topic coordinator_client.CoordinatorClientTaskTopic, tasks []*coordinator_client.Task) error {
+	return fmt.Errorf("connection refused")
+}
+
+func TestUploadDocumentsDeletesBlobsOnFailure(t *testing.T) {
+	var (
+		dir               = t.TempDir()
+		blobStore         = blob_store.NewLocalBlobStore(dir)
+		coordinatorClient = &failingTasksCoordinatorClient{coordinator_client.NewMockCoordinatorClient()}
+	)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, name := range []string{"one.txt", "two.txt"} {
+		part, err := form.CreateFormFile("file", name)
+		assert.NoError(t, err)
+		_, err = part.Write([]byte("Opening hours are 9 to 5"))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/documents", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	UploadDocuments(coordinatorClient, blobStore)(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	var blobs []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			blobs = append(blobs, path)
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, blobs, "the documents of a failed upload should be deleted")
+}
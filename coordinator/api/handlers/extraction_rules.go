@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+)
+
+// ExtractionRule tells scrapers which parts of a domain's pages hold its content. Rules for a domain
+// also apply to its subdomains unless they have a rule of their own.
+type ExtractionRule struct {
+	Domain           string    `json:"domain"`
+	Selectors        []string  `json:"selectors"`
+	ExcludeSelectors []string  `json:"exclude_selectors"`
+	Updated          time.Time `json:"updated"`
+}
+
+type SetExtractionRuleRequest struct {
+	Selectors        []string `json:"selectors"`
+	ExcludeSelectors []string `json:"exclude_selectors"`
+}
+
+type ExtractionRulesResponse struct {
+	ExtractionRules []ExtractionRule `json:"extraction_rules"`
+}
+
+func GetExtractionRules(coordinatorClient coordinator_client.CoordinatorClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storedRules, err := coordinatorClient.GetExtractionRules(r.Context())
+		if err != nil {
+			WriteJSONError(w, "Failed to get extraction rules", http.StatusInternalServerError)
+			return
+		}
+
+		rules := make([]ExtractionRule, 0, len(storedRules))
+		for _, rule := range storedRules {
+			rules = append(rules, extractionRuleFrom(rule))
+		}
+
+		WriteJSON(w, ExtractionRulesResponse{
+			ExtractionRules: rules,
+		})
+	}
+}
+
+func GetExtractionRule(coordinatorClient coordinator_client.CoordinatorClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := coordinatorClient.GetExtractionRule(r.Context(), domainFromPath(r))
+		if err == coordinator_client.ErrExtractionRuleNotFound {
+			WriteJSONError(w, "Extraction rule not found", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			WriteJSONError(w, "Failed to get extraction rule", http.StatusInternalServerError)
+			return
+		}
+
+		WriteJSON(w, extractionRuleFrom(rule))
+	}
+}
+
+func SetExtractionRule(coordinatorClient coordinator_client.CoordinatorClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SetExtractionRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		selectors, excludeSelectors := cleanSelectors(req.Selectors), cleanSelectors(req.ExcludeSelectors)
+		if len(selectors) == 0 && len(excludeSelectors) == 0 {
+			WriteJSONError(w, "At least one selector or exclude selector is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := validateSelectors(selectors, excludeSelectors); err != nil {
+			WriteJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rule := &coordinator_client.ExtractionRule{
+			Domain:           domainFromPath(r),
+			Selectors:        selectors,
+			ExcludeSelectors: excludeSelectors,
+			Updated:          time.Now(),
+		}
+
+		if err := coordinatorClient.SetExtractionRule(r.Context(), rule); err != nil {
+			WriteJSONError(w, "Failed to set extraction rule", http.StatusInternalServerError)
+			return
+		}
+
+		WriteJSON(w, extractionRuleFrom(rule))
+	}
+}
+
+func DeleteExtractionRule(coordinatorClient coordinator_client.CoordinatorClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := coordinatorClient.DeleteExtractionRule(r.Context(), domainFromPath(r))
+		if err == coordinator_client.ErrExtractionRuleNotFound {
+			WriteJSONError(w, "Extraction rule not found", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			WriteJSONError(w, "Failed to delete extraction rule", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func extractionRuleFrom(rule *coordinator_client.ExtractionRule) ExtractionRule {
+	return ExtractionRule{
+		Domain:           rule.Domain,
+		Selectors:        append([]string{}, rule.Selectors...),
+		ExcludeSelectors: append([]string{}, rule.ExcludeSelectors...),
+		Updated:          rule.Updated,
+	}
+}
+
+func domainFromPath(r *http.Request) string {
+	return strings.ToLower(strings.TrimSpace(r.PathValue("domain")))
+}
+
+func cleanSelectors(selectors []string) []string {
+	cleaned := make([]string, 0, len(selectors))
+	for _, selector := range selectors {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			cleaned = append(cleaned, selector)
+		}
+	}
+	return cleaned
+}
+
+// validateSelectors checks every selector is valid CSS, as scrapers fail every page a selector that
+// isn't is used on
+func validateSelectors(selectorLists ...[]string) error {
+	for _, selectors := range selectorLists {
+		for _, selector := range selectors {
+			if _, err := cascadia.ParseGroup(selector); err != nil {
+				return fmt.Errorf("invalid selector %q: %v", selector, err)
+			}
+		}
+	}
+	return nil
+}
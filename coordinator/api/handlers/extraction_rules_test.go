@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetExtractionRuleInvalidSelector(t *testing.T) {
+	coordinatorClient := coordinator_client.NewMockCoordinatorClient()
+	router := http.NewServeMux()
+	router.HandleFunc("PUT /extraction-rules/{domain}", SetExtractionRule(coordinatorClient))
+
+	body := `{"selectors": ["article"], "exclude_selectors": [".ads", "div[class="]}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/extraction-rules/example.com", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var resp map[string]string
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Contains(t, resp["error"], `"div[class="`)
+
+	_, err := coordinatorClient.GetExtractionRule(context.Background(), "example.com")
+	assert.ErrorIs(t, err, coordinator_client.ErrExtractionRuleNotFound)
+
+	body = `{"selectors": ["article", "div.content > p"], "exclude_selectors": [".ads"]}`
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/extraction-rules/example.com", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestScrapeRagTaskInvalidSelector(t *testing.T) {
+	coordinatorClient := coordinator_client.NewMockCoordinatorClient()
+
+	body := `{"urls": ["https://example.com"], "content_selectors": ["main >"]}`
+	rec := httptest.NewRecorder()
+	ScrapeRagTask(coordinatorClient)(rec, httptest.NewRequest(http.MethodPost, "/scrape-rag-task", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var resp map[string]string
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Contains(t, resp["error"], `"main >"`)
+
+	numTasks, err := coordinatorClient.NumTasks(context.Background(), coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Zero(t, numTasks)
+}
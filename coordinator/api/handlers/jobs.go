@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+)
+
+type JobUrl struct {
+	URL     string    `json:"url"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+type JobResponse struct {
+	ID        string         `json:"id"`
+	CreatedBy string         `json:"created_by"`
+	Created   time.Time      `json:"created"`
+	NumUrls   int            `json:"num_urls"`
+	Summary   map[string]int `json:"summary"`
+	Urls      []JobUrl       `json:"urls"`
+}
+
+func GetJob(coordinatorClient coordinator_client.CoordinatorClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := coordinatorClient.GetJob(r.Context(), r.PathValue("id"))
+		if err == coordinator_client.ErrJobNotFound {
+			WriteJSONError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			WriteJSONError(w, "Failed to get job", http.StatusInternalServerError)
+			return
+		}
+
+		summary := make(map[string]int)
+		urls := make([]JobUrl, 0, len(job.Urls))
+		for url, progress := range job.Urls {
+			summary[string(progress.Status)]++
+			urls = append(urls, JobUrl{
+				URL:     url,
+				Status:  string(progress.Status),
+				Error:   progress.Error,
+				Updated: progress.Updated,
+			})
+		}
+
+		sort.Slice(urls, func(i, j int) bool {
+			return urls[i].URL < urls[j].URL
+		})
+
+		WriteJSON(w, JobResponse{
+			ID:        job.ID,
+			CreatedBy: job.CreatedBy,
+			Created:   job.Created,
+			NumUrls:   len(urls),
+			Summary:   summary,
+			Urls:      urls,
+		})
+	}
+}
@@ -4,23 +4,53 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/ethanhosier/web-crawler-coordinator/coordinator_client"
 	"github.com/ethanhosier/web-crawler-coordinator/utils"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
 	"github.com/google/uuid"
 )
 
 const (
-	maxUrls = 500
+	maxUrls       = 500
+	maxCrawlDepth = 10
+	maxCrawlPages = 5000
 )
 
+var renderModes = []string{"", "auto", "static", "browser"}
+
 type ScraperWorkerParams struct {
-	URL string `json:"url"`
+	URL              string   `json:"url"`
+	MaxDepth         int      `json:"max_depth,omitempty"`
+	MaxPages         int      `json:"max_pages,omitempty"`
+	AllowedHosts     []string `json:"allowed_hosts,omitempty"`
+	RenderMode       string   `json:"render_mode,omitempty"`
+	WaitSelector     string   `json:"wait_selector,omitempty"`
+	ContentSelectors []string `json:"content_selectors,omitempty"`
+	ExcludeSelectors []string `json:"exclude_selectors,omitempty"`
 }
 
+// CreateScrapeRagTaskRequest submits urls to be scraped and indexed. With MaxDepth > 0 each url is
+// crawled, following links on allowed hosts (by default the url's own host) up to MaxDepth hops away
+// and indexing at most MaxPages pages per url.
+//
+// RenderMode is one of auto (the default), static or browser. Auto pages are rendered in a headless
+// browser only if their static html is nearly empty. WaitSelector makes the browser wait for a
+// matching element to be visible rather than for the network to go idle.
+//
+// ContentSelectors pick out the content of each page and ExcludeSelectors remove parts of it. They
+// take precedence over the extraction rule stored for a page's domain.
 type CreateScrapeRagTaskRequest struct {
-	URLs []string `json:"urls"`
+	URLs             []string `json:"urls"`
+	MaxDepth         int      `json:"max_depth,omitempty"`
+	MaxPages         int      `json:"max_pages,omitempty"`
+	AllowedHosts     []string `json:"allowed_hosts,omitempty"`
+	RenderMode       string   `json:"render_mode,omitempty"`
+	WaitSelector     string   `json:"wait_selector,omitempty"`
+	ContentSelectors []string `json:"content_selectors,omitempty"`
+	ExcludeSelectors []string `json:"exclude_selectors,omitempty"`
 }
 
 type CreatedTask struct {
@@ -30,6 +60,7 @@ type CreatedTask struct {
 }
 
 type CreateScrapeRagTaskResponse struct {
+	JobID        string        `json:"job_id"`
 	CreatedTasks []CreatedTask `json:"created_tasks"`
 }
 
@@ -51,11 +82,46 @@ func ScrapeRagTask(coordinatorClient coordinator_client.CoordinatorClient) http.
 			return
 		}
 
+		if req.MaxDepth < 0 || req.MaxDepth > maxCrawlDepth {
+			WriteJSONError(w, fmt.Sprintf("max_depth must be between 0 and %d", maxCrawlDepth), http.StatusBadRequest)
+			return
+		}
+
+		if req.MaxPages < 0 || req.MaxPages > maxCrawlPages {
+			WriteJSONError(w, fmt.Sprintf("max_pages must be between 0 and %d", maxCrawlPages), http.StatusBadRequest)
+			return
+		}
+
+		if !slices.Contains(renderModes, req.RenderMode) {
+			WriteJSONError(w, "render_mode must be one of auto, static or browser", http.StatusBadRequest)
+			return
+		}
+
+		if err := validateSelectors(req.ContentSelectors, req.ExcludeSelectors); err != nil {
+			WriteJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.MaxDepth > 0 && req.MaxPages == 0 {
+			req.MaxPages = maxCrawlPages
+		}
+
+		for i, host := range req.AllowedHosts {
+			req.AllowedHosts[i] = strings.ToLower(strings.TrimSpace(host))
+		}
+
+		job := &coordinator_client.Job{
+			ID:        uuid.New().String(),
+			CreatedBy: createdBy(r),
+			Created:   time.Now(),
+			Urls:      make(map[string]*coordinator_client.JobUrlProgress, len(req.URLs)),
+		}
+
 		tasks := make([]*coordinator_client.Task, 0, len(req.URLs))
 		createdTasks := make([]CreatedTask, 0, len(req.URLs))
 
 		for _, url := range req.URLs {
-			task, createdTask := processURL(url)
+			task, createdTask := processURL(url, job, req)
 			if task != nil {
 				tasks = append(tasks, task)
 			}
@@ -63,6 +129,11 @@ func ScrapeRagTask(coordinatorClient coordinator_client.CoordinatorClient) http.
 			createdTasks = append(createdTasks, createdTask)
 		}
 
+		if err := coordinatorClient.CreateJob(r.Context(), job); err != nil {
+			WriteJSONError(w, "Failed to create job", http.StatusInternalServerError)
+			return
+		}
+
 		err := coordinatorClient.CreateTasks(r.Context(), coordinator_client.CoordinatorClientTaskTopicUrls, tasks)
 		if err != nil {
 			WriteJSONError(w, "Failed to create tasks", http.StatusInternalServerError)
@@ -70,6 +141,7 @@ func ScrapeRagTask(coordinatorClient coordinator_client.CoordinatorClient) http.
 		}
 
 		WriteJSON(w, CreateScrapeRagTaskResponse{
+			JobID:        job.ID,
 			CreatedTasks: createdTasks,
 		})
 	}
@@ -153,7 +225,15 @@ func WriteJSONError(w http.ResponseWriter, message string, statusCode int) {
 	json.NewEncoder(w).Encode(map[string]string{"error": message})
 }
 
-func processURL(url string) (*coordinator_client.Task, CreatedTask) {
+// createdBy returns the id of the authenticated user making the request, if there is one
+func createdBy(r *http.Request) string {
+	if userID, ok := r.Context().Value("USER_ID").(string); ok && userID != "" {
+		return userID
+	}
+	return "coordinator-client"
+}
+
+func processURL(url string, job *coordinator_client.Job, req CreateScrapeRagTaskRequest) (*coordinator_client.Task, CreatedTask) {
 	formattedUrl, err := utils.FormatUrl(url)
 	if err != nil {
 		return nil, CreatedTask{
@@ -164,7 +244,14 @@ func processURL(url string) (*coordinator_client.Task, CreatedTask) {
 	}
 
 	params := ScraperWorkerParams{
-		URL: formattedUrl,
+		URL:              formattedUrl,
+		MaxDepth:         req.MaxDepth,
+		MaxPages:         req.MaxPages,
+		AllowedHosts:     req.AllowedHosts,
+		RenderMode:       req.RenderMode,
+		WaitSelector:     req.WaitSelector,
+		ContentSelectors: req.ContentSelectors,
+		ExcludeSelectors: req.ExcludeSelectors,
 	}
 
 	task, err := coordinator_client.NewTask(uuid.New().String(), "coordinator-client", params)
@@ -175,6 +262,8 @@ func processURL(url string) (*coordinator_client.Task, CreatedTask) {
 			Error: err.Error(),
 		}
 	}
+	task.JobId = job.ID
+	job.Urls[formattedUrl] = coordinator_client.NewJobUrlProgress(coordinator_client.JobUrlStatusQueued, nil)
 
 	return task, CreatedTask{
 		ID:    task.ID,
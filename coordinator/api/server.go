@@ -1,24 +1,26 @@
 package api
 
 import (
-	"context"
 	"net/http"
-	"os"
 
 	"github.com/ethanhosier/web-crawler-coordinator/api/handlers"
-	"github.com/ethanhosier/web-crawler-coordinator/coordinator_client"
-	"github.com/ethanhosier/web-crawler-coordinator/utils"
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
 )
 
 type Server struct {
-	listenAddr string
-	router     *http.ServeMux
+	listenAddr        string
+	router            *http.ServeMux
+	coordinatorClient coordinator_client.CoordinatorClient
+	blobStore         blob_store.BlobStore
 }
 
-func NewServer(listenAddr string) *Server {
+func NewServer(listenAddr string, coordinatorClient coordinator_client.CoordinatorClient, blobStore blob_store.BlobStore) *Server {
 	s := &Server{
-		listenAddr: listenAddr,
-		router:     http.NewServeMux(),
+		listenAddr:        listenAddr,
+		router:            http.NewServeMux(),
+		coordinatorClient: coordinatorClient,
+		blobStore:         blobStore,
 	}
 
 	s.routes()
@@ -28,9 +30,9 @@ func NewServer(listenAddr string) *Server {
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Allow CORS
-		w.Header().Set("Access-Control-Allow-Origin", "*")                            // Frontend URL
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, OPTIONS")   // Allowed methods
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization") // Include Authorization header
+		w.Header().Set("Access-Control-Allow-Origin", "*")                                       // Frontend URL
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS") // Allowed methods
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")            // Include Authorization header
 
 		if r.Method == http.MethodOptions {
 			// Respond to preflight requests
@@ -43,20 +45,22 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 }
 
 func (s *Server) routes() {
-	var (
-		redisAddress  = utils.Required(os.Getenv("REDIS_ADDRESS"), "REDIS_ADDRESS")
-		redisDB       = utils.RequiredInt(os.Getenv("REDIS_DB"), "REDIS_DB")
-		redisPassword = os.Getenv("REDIS_PASSWORD")
-	)
-
-	coordinatorClient := coordinator_client.NewRedisCoordinatorClient(context.Background(), redisAddress, redisPassword, redisDB)
+	coordinatorClient := s.coordinatorClient
 
 	s.router.HandleFunc("GET /ping", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("pong"))
 	})
 
 	s.router.HandleFunc("POST /scrape-rag-task", handlers.ScrapeRagTask(coordinatorClient))
+	s.router.HandleFunc("POST /documents", handlers.UploadDocuments(coordinatorClient, s.blobStore))
 	s.router.HandleFunc("GET /tasks-status", handlers.TasksStatus(coordinatorClient))
+	s.router.HandleFunc("GET /jobs/{id}", handlers.GetJob(coordinatorClient))
+	s.router.HandleFunc("GET /dead-tasks/{topic}", handlers.DeadTasks(coordinatorClient))
+	s.router.HandleFunc("POST /dead-tasks/{topic}/requeue", handlers.RequeueDeadTasks(coordinatorClient))
+	s.router.HandleFunc("GET /extraction-rules", handlers.GetExtractionRules(coordinatorClient))
+	s.router.HandleFunc("GET /extraction-rules/{domain}", handlers.GetExtractionRule(coordinatorClient))
+	s.router.HandleFunc("PUT /extraction-rules/{domain}", handlers.SetExtractionRule(coordinatorClient))
+	s.router.HandleFunc("DELETE /extraction-rules/{domain}", handlers.DeleteExtractionRule(coordinatorClient))
 }
 
 func (s *Server) Start() error {
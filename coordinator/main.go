@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"time"
 
 	"github.com/ethanhosier/web-crawler-coordinator/api"
+	"github.com/ethanhosier/web-crawler-coordinator/reaper"
+	"github.com/ethanhosier/web-crawler-coordinator/utils"
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
 	"github.com/joho/godotenv"
 )
 
+const (
+	reapInterval = 30 * time.Second
+)
+
 // docker run -p 8080:8080 -e REDIS_ADDRESS=host.docker.internal:6379 coordinator
 
 func main() {
@@ -18,7 +29,40 @@ func main() {
 	listenAddr := flag.String("listen", ":80", "HTTP server listen address")
 	flag.Parse()
 
-	server := api.NewServer(*listenAddr)
+	var (
+		redisAddress  = utils.Required(os.Getenv("REDIS_ADDRESS"), "REDIS_ADDRESS")
+		redisDB       = utils.RequiredInt(os.Getenv("REDIS_DB"), "REDIS_DB")
+		redisPassword = os.Getenv("REDIS_PASSWORD")
+	)
+
+	var coordinatorClient coordinator_client.CoordinatorClient
+	switch queueBackend := os.Getenv("QUEUE_BACKEND"); queueBackend {
+	case "", "lists":
+		coordinatorClient = coordinator_client.NewRedisCoordinatorClient(context.Background(), redisAddress, redisPassword, redisDB)
+	case "streams":
+		coordinatorClient = coordinator_client.NewRedisStreamsCoordinatorClient(context.Background(), redisAddress, redisPassword, redisDB)
+	default:
+		log.Fatalf("Unknown queue backend: %s", queueBackend)
+	}
+
+	blobStore, err := blob_store.FromEnv()
+	if err != nil {
+		log.Fatalf("Error creating blob store: %v", err)
+	}
+
+	taskReaper := reaper.NewReaper(
+		coordinatorClient,
+		reapInterval,
+		coordinator_client.CoordinatorClientTaskTopicUrls,
+		coordinator_client.CoordinatorClientTaskTopicRag,
+	)
+	// Tasks that keep killing their worker are dead lettered after as many attempts as the workers allow
+	if maxAttempts := os.Getenv("MAX_ATTEMPTS"); maxAttempts != "" {
+		taskReaper.WithMaxAttempts(utils.RequiredInt(maxAttempts, "MAX_ATTEMPTS"))
+	}
+	go taskReaper.Start(context.Background())
+
+	server := api.NewServer(*listenAddr, coordinatorClient, blobStore)
 	log.Printf("Starting server on %s", *listenAddr)
 	log.Fatal(server.Start())
 }
@@ -0,0 +1,75 @@
+package reaper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+)
+
+// Reaper periodically returns tasks held by workers that died mid-task to their topic, so a worker
+// container being stopped (e.g. on scale-in) doesn't lose the tasks it was processing. It also puts
+// failed tasks whose retry backoff has passed back on their topic. A task whose worker keeps dying is
+// dead lettered once it has been attempted maxAttempts times, rather than being requeued forever.
+type Reaper struct {
+	coordinatorClient coordinator_client.CoordinatorClient
+	interval          time.Duration
+	maxAttempts       int
+	topics            []coordinator_client.CoordinatorClientTaskTopic
+}
+
+func NewReaper(coordinatorClient coordinator_client.CoordinatorClient, interval time.Duration, topics ...coordinator_client.CoordinatorClientTaskTopic) *Reaper {
+	return &Reaper{
+		coordinatorClient: coordinatorClient,
+		interval:          interval,
+		maxAttempts:       coordinator_client.DefaultMaxAttempts,
+		topics:            topics,
+	}
+}
+
+// WithMaxAttempts sets how many times a task is attempted before the reaper dead letters it. It should
+// match the workers' MAX_ATTEMPTS, and <= 0 means tasks are always requeued.
+func (r *Reaper) WithMaxAttempts(maxAttempts int) *Reaper {
+	r.maxAttempts = maxAttempts
+	return r
+}
+
+// Start reaps every interval until ctx is cancelled
+func (r *Reaper) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reap(ctx)
+		}
+	}
+}
+
+func (r *Reaper) reap(ctx context.Context) {
+	for _, topic := range r.topics {
+		numReaped, err := r.coordinatorClient.ReapExpiredTasks(ctx, topic, r.maxAttempts)
+		if err != nil {
+			log.Printf("Failed to reap expired %s tasks: %v", topic, err)
+			continue
+		}
+
+		if numReaped > 0 {
+			log.Printf("Reaped %d expired %s tasks", numReaped, topic)
+		}
+
+		numPromoted, err := r.coordinatorClient.PromoteDelayedTasks(ctx, topic)
+		if err != nil {
+			log.Printf("Failed to promote delayed %s tasks: %v", topic, err)
+			continue
+		}
+
+		if numPromoted > 0 {
+			log.Printf("Requeued %d %s tasks due for retry", numPromoted, topic)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaperRequeuesExpiredTasks(t *testing.T) {
+	var (
+		ctx               = context.Background()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		reaper            = NewReaper(coordinatorClient, time.Second, coordinator_client.CoordinatorClientTaskTopicUrls)
+	)
+
+	task, err := coordinator_client.NewTask("1", "test", map[string]string{"url": "https://ethanhosier.com"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	if err := coordinatorClient.CreateTask(ctx, coordinator_client.CoordinatorClientTaskTopicUrls, task); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	coordinatorClient.SetLeaseDuration(-time.Second)
+	if _, err := coordinatorClient.GetTaskAndSetProcessing(ctx, 0, coordinator_client.CoordinatorClientTaskTopicUrls); err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+
+	reaper.reap(ctx)
+
+	numProcessing, err := coordinatorClient.NumProcessingTasks(ctx, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numProcessing)
+
+	requeuedTask, err := coordinatorClient.GetTask(ctx, 0, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", requeuedTask.ID)
+	assert.Equal(t, 1, requeuedTask.Attempts)
+}
+
+func TestReaperLeavesLeasedTasks(t *testing.T) {
+	var (
+		ctx               = context.Background()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		reaper            = NewReaper(coordinatorClient, time.Second, coordinator_client.CoordinatorClientTaskTopicUrls)
+	)
+
+	task, err := coordinator_client.NewTask("1", "test", map[string]string{"url": "https://ethanhosier.com"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	if err := coordinatorClient.CreateTask(ctx, coordinator_client.CoordinatorClientTaskTopicUrls, task); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	if _, err := coordinatorClient.GetTaskAndSetProcessing(ctx, 0, coordinator_client.CoordinatorClientTaskTopicUrls); err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+
+	reaper.reap(ctx)
+
+	numProcessing, err := coordinatorClient.NumProcessingTasks(ctx, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numProcessing)
+
+	numTasks, err := coordinatorClient.NumTasks(ctx, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numTasks)
+}
+
+func TestReaperPromotesDueRetries(t *testing.T) {
+	var (
+		ctx               = context.Background()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		reaper            = NewReaper(coordinatorClient, time.Second, coordinator_client.CoordinatorClientTaskTopicRag)
+	)
+
+	dueTask, err := coordinator_client.NewTask("due", "test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	notDueTask, err := coordinator_client.NewTask("not-due", "test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	coordinatorClient.RetryTask(ctx, coordinator_client.CoordinatorClientTaskTopicRag, dueTask, time.Now().Add(-time.Second))
+	coordinatorClient.RetryTask(ctx, coordinator_client.CoordinatorClientTaskTopicRag, notDueTask, time.Now().Add(time.Hour))
+
+	reaper.reap(ctx)
+
+	numTasks, err := coordinatorClient.NumTasks(ctx, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numTasks)
+
+	promotedTask, err := coordinatorClient.GetTask(ctx, 0, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+	assert.Equal(t, "due", promotedTask.ID)
+}
+
+func TestReaperDeadLettersTasksOutOfAttempts(t *testing.T) {
+	var (
+		ctx               = context.Background()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		reaper            = NewReaper(coordinatorClient, time.Second, coordinator_client.CoordinatorClientTaskTopicUrls).WithMaxAttempts(2)
+	)
+
+	task, err := coordinator_client.NewTask("1", "test", map[string]string{"url": "https://ethanhosier.com"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	if err := coordinatorClient.CreateTask(ctx, coordinator_client.CoordinatorClientTaskTopicUrls, task); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	// The task kills its worker every time it is taken
+	coordinatorClient.SetLeaseDuration(-time.Second)
+	for range 2 {
+		if _, err := coordinatorClient.GetTaskAndSetProcessing(ctx, 0, coordinator_client.CoordinatorClientTaskTopicUrls); err != nil {
+			t.Fatalf("Failed to get task: %v", err)
+		}
+		reaper.reap(ctx)
+	}
+
+	numTasks, err := coordinatorClient.NumTasks(ctx, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numTasks)
+
+	numProcessing, err := coordinatorClient.NumProcessingTasks(ctx, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numProcessing)
+
+	deadTasks := coordinatorClient.DeadTasks(coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Len(t, deadTasks, 1)
+	assert.Equal(t, "1", deadTasks[0].Task.ID)
+	assert.Equal(t, 1, deadTasks[0].Task.Attempts)
+	assert.Equal(t, coordinator_client.ErrLeaseExpired.Error(), deadTasks[0].Error)
+}
@@ -0,0 +1,41 @@
+package blob_store
+
+import (
+	"context"
+	"io"
+)
+
+// BlobStore keeps raw files, such as uploaded documents, that are too big to be put on a task
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any blob already stored there
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get returns the blob stored under key, which must be closed once it has been read
+	Get(ctx context.Context, key string) (*Blob, error)
+
+	Delete(ctx context.Context, key string) error
+}
+
+// Blob is a stored blob being read
+type Blob struct {
+	io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+var (
+	ErrBlobNotFound   = &BlobStoreBlobNotFound{}
+	ErrInvalidBlobKey = &BlobStoreInvalidBlobKey{}
+)
+
+type BlobStoreBlobNotFound struct{}
+
+func (e *BlobStoreBlobNotFound) Error() string {
+	return "Blob not found"
+}
+
+type BlobStoreInvalidBlobKey struct{}
+
+func (e *BlobStoreInvalidBlobKey) Error() string {
+	return "Invalid blob key"
+}
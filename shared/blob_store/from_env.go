@@ -0,0 +1,37 @@
+package blob_store
+
+import (
+	"fmt"
+	"os"
+)
+
+const defaultBlobDir = "blobs"
+
+// FromEnv creates the blob store configured by the environment, which every service sharing blobs
+// must agree on: the local filesystem under BLOB_DIR by default, for running everything on one machine,
+// or an S3 compatible bucket with BLOB_STORE=s3.
+func FromEnv() (BlobStore, error) {
+	switch blobStore := os.Getenv("BLOB_STORE"); blobStore {
+	case "", "local":
+		blobDir := os.Getenv("BLOB_DIR")
+		if blobDir == "" {
+			blobDir = defaultBlobDir
+		}
+		return NewLocalBlobStore(blobDir), nil
+	case "s3":
+		endpoint, bucket := os.Getenv("S3_ENDPOINT"), os.Getenv("S3_BUCKET")
+		if endpoint == "" || bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required for the s3 blob store")
+		}
+
+		return NewS3BlobStore(
+			endpoint,
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+			bucket,
+			os.Getenv("S3_USE_SSL") != "false",
+		)
+	default:
+		return nil, fmt.Errorf("unknown blob store: %s", blobStore)
+	}
+}
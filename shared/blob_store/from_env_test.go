@@ -0,0 +1,37 @@
+package blob_store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("BLOB_STORE", "")
+	t.Setenv("BLOB_DIR", "")
+	store, err := FromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, NewLocalBlobStore(defaultBlobDir), store)
+
+	dir := t.TempDir()
+	t.Setenv("BLOB_STORE", "local")
+	t.Setenv("BLOB_DIR", dir)
+	store, err = FromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, NewLocalBlobStore(dir), store)
+
+	t.Setenv("BLOB_STORE", "s3")
+	t.Setenv("S3_ENDPOINT", "")
+	t.Setenv("S3_BUCKET", "blobs")
+	_, err = FromEnv()
+	assert.Error(t, err, "an s3 blob store needs an endpoint")
+
+	t.Setenv("S3_ENDPOINT", "localhost:9000")
+	store, err = FromEnv()
+	assert.NoError(t, err)
+	assert.IsType(t, &S3BlobStore{}, store)
+
+	t.Setenv("BLOB_STORE", "ftp")
+	_, err = FromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+package blob_store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// contentTypeSuffix names the file next to each blob that holds its content type
+const contentTypeSuffix = ".content-type"
+
+// LocalBlobStore keeps blobs as files under a directory, with keys as paths relative to it
+type LocalBlobStore struct {
+	dir string
+}
+
+func NewLocalBlobStore(dir string) *LocalBlobStore {
+	return &LocalBlobStore{dir: dir}
+}
+
+func (l *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// The blob is written to a temporary file first so a failed write never leaves a partial blob
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("expected %d bytes for blob %s, got %d", size, key, written)
+	}
+
+	if err := os.WriteFile(path+contentTypeSuffix, []byte(contentType), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *LocalBlobStore) Get(ctx context.Context, key string) (*Blob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	contentType, err := os.ReadFile(path + contentTypeSuffix)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		file.Close()
+		return nil, err
+	}
+
+	return &Blob{ReadCloser: file, ContentType: string(contentType), Size: info.Size()}, nil
+}
+
+func (l *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); errors.Is(err, fs.ErrNotExist) {
+		return ErrBlobNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path + contentTypeSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path returns where the blob for key is kept, refusing keys that would escape the store's directory
+func (l *LocalBlobStore) path(key string) (string, error) {
+	if key == "" || !filepath.IsLocal(key) {
+		return "", ErrInvalidBlobKey
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,81 @@
+package blob_store
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testBlobStore checks the behaviour every BlobStore should have
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+
+	err := store.Put(ctx, "documents/1/report.pdf", strings.NewReader("%PDF-1.4"), 8, "application/pdf")
+	assert.NoError(t, err)
+
+	blob, err := store.Get(ctx, "documents/1/report.pdf")
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(blob)
+	assert.NoError(t, err)
+	assert.NoError(t, blob.Close())
+	assert.Equal(t, "%PDF-1.4", string(body))
+	assert.Equal(t, "application/pdf", blob.ContentType)
+	assert.Equal(t, int64(8), blob.Size)
+
+	// Putting a blob again replaces it
+	err = store.Put(ctx, "documents/1/report.pdf", strings.NewReader("replaced"), 8, "text/plain")
+	assert.NoError(t, err)
+
+	blob, err = store.Get(ctx, "documents/1/report.pdf")
+	assert.NoError(t, err)
+	body, err = io.ReadAll(blob)
+	assert.NoError(t, err)
+	assert.NoError(t, blob.Close())
+	assert.Equal(t, "replaced", string(body))
+	assert.Equal(t, "text/plain", blob.ContentType)
+
+	assert.NoError(t, store.Delete(ctx, "documents/1/report.pdf"))
+
+	_, err = store.Get(ctx, "documents/1/report.pdf")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "documents/1/report.pdf"), ErrBlobNotFound)
+
+	assert.ErrorIs(t, store.Put(ctx, "", strings.NewReader(""), 0, "text/plain"), ErrInvalidBlobKey)
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	testBlobStore(t, NewLocalBlobStore(t.TempDir()))
+}
+
+func TestLocalBlobStoreInvalidKeys(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = NewLocalBlobStore(t.TempDir())
+	)
+
+	for _, key := range []string{"../escape", "/etc/passwd", "documents/../../escape"} {
+		err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain")
+		assert.ErrorIs(t, err, ErrInvalidBlobKey, key)
+
+		_, err = store.Get(ctx, key)
+		assert.ErrorIs(t, err, ErrInvalidBlobKey, key)
+	}
+}
+
+func TestLocalBlobStoreSizeMismatch(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = NewLocalBlobStore(t.TempDir())
+	)
+
+	err := store.Put(ctx, "short", strings.NewReader("abc"), 10, "text/plain")
+	assert.Error(t, err)
+
+	// A failed put doesn't leave a partial blob behind
+	_, err = store.Get(ctx, "short")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+}
@@ -0,0 +1,77 @@
+package blob_store
+
+import (
+	"context"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3BlobStore keeps blobs as objects in a bucket of an S3 compatible store, such as S3 itself or MinIO
+type S3BlobStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3BlobStore connects to the store at endpoint, a host and optional port without a scheme
+func NewS3BlobStore(endpoint string, accessKey string, secretKey string, bucket string, useSSL bool) (*S3BlobStore, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3BlobStore{client: client, bucket: bucket}, nil
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if key == "" {
+		return ErrInvalidBlobKey
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) (*Blob, error) {
+	if key == "" {
+		return nil, ErrInvalidBlobKey
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.blobError(err)
+	}
+
+	// Getting an object doesn't make a request until it is read or stat'd, so a missing blob is only
+	// found out about here
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, s.blobError(err)
+	}
+
+	return &Blob{ReadCloser: object, ContentType: info.ContentType, Size: info.Size}, nil
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return ErrInvalidBlobKey
+	}
+
+	// Removing a missing object succeeds, so it has to be looked for first to match the other stores
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		return s.blobError(err)
+	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3BlobStore) blobError(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrBlobNotFound
+	}
+	return err
+}
@@ -0,0 +1,30 @@
+package blob_store
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestS3BlobStore runs against the S3 compatible store at S3_ENDPOINT, e.g. a local MinIO started with
+// docker run -p 9000:9000 -e MINIO_ROOT_USER=minioadmin -e MINIO_ROOT_PASSWORD=minioadmin minio/minio server /data
+func TestS3BlobStore(t *testing.T) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if os.Getenv("CICD") == "true" || endpoint == "" {
+		t.Skip("Skipping S3 blob store without S3_ENDPOINT")
+	}
+
+	bucket := "test-" + uuid.New().String()
+	store, err := NewS3BlobStore(endpoint, os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), bucket, os.Getenv("S3_USE_SSL") == "true")
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, store.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}))
+	defer store.client.RemoveBucket(ctx, bucket)
+
+	testBlobStore(t, store)
+}
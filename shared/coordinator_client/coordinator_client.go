@@ -0,0 +1,244 @@
+package coordinator_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type Task struct {
+	ID        string                 `json:"id"`
+	JobId     string                 `json:"job_id,omitempty"`
+	CreatedBy string                 `json:"created_by"`
+	Params    map[string]interface{} `json:"params"`
+	Attempts  int                    `json:"attempts,omitempty"`
+
+	// receipt is the id of the stream entry the task was read from, used by the streams client to
+	// acknowledge it. It is never serialised.
+	receipt string
+}
+
+type StoredErrorKind string
+
+const (
+	StoredErrorKindError   StoredErrorKind = "error"
+	StoredErrorKindTimeout StoredErrorKind = "timeout"
+)
+
+type StoredError struct {
+	Error   string                     `json:"error"`
+	Kind    StoredErrorKind            `json:"kind,omitempty"`
+	Task    *Task                      `json:"task"`
+	Topic   CoordinatorClientTaskTopic `json:"topic"`
+	Created time.Time                  `json:"created"`
+}
+
+// NewStoredError records err against task, classifying it as a timeout if the task ran out of time
+func NewStoredError(topic CoordinatorClientTaskTopic, task *Task, err error) *StoredError {
+	kind := StoredErrorKindError
+	if errors.Is(err, ErrTaskTimedOut) || errors.Is(err, context.DeadlineExceeded) {
+		kind = StoredErrorKindTimeout
+	}
+
+	return &StoredError{
+		Error:   err.Error(),
+		Kind:    kind,
+		Task:    task,
+		Topic:   topic,
+		Created: time.Now(),
+	}
+}
+
+func CastParams[T any](params map[string]interface{}) (*T, error) {
+	jsonParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var paramsMap map[string]interface{}
+	if err := json.Unmarshal(jsonParams, &paramsMap); err != nil {
+		return nil, err
+	}
+
+	var paramsT T
+	if err := json.Unmarshal(jsonParams, &paramsT); err != nil {
+		return nil, err
+	}
+
+	return &paramsT, nil
+}
+
+func NewTask(id string, createdBy string, params interface{}) (*Task, error) {
+	jsonParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var paramsMap map[string]interface{}
+	if err := json.Unmarshal(jsonParams, &paramsMap); err != nil {
+		return nil, err
+	}
+
+	return &Task{
+		ID:        id,
+		CreatedBy: createdBy,
+		Params:    paramsMap,
+	}, nil
+}
+
+func (t *Task) toString() (string, error) {
+	json, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return string(json), nil
+}
+
+type CoordinatorClientTaskTopic string
+
+func (c CoordinatorClientTaskTopic) String() string {
+	return string(c)
+}
+
+func (c CoordinatorClientTaskTopic) ProcessingTopicString() string {
+	return "processing_" + string(c)
+}
+
+// StreamTopicString is the stream the streams client keeps the topic's tasks in
+func (c CoordinatorClientTaskTopic) StreamTopicString() string {
+	return "stream_" + string(c)
+}
+
+// LeasesTopicString is the sorted set holding the lease deadline of each task in the processing list
+func (c CoordinatorClientTaskTopic) LeasesTopicString() string {
+	return "leases_" + string(c)
+}
+
+// DelayedTopicString is the sorted set of tasks waiting to be retried, scored by when they are due
+func (c CoordinatorClientTaskTopic) DelayedTopicString() string {
+	return "delayed_" + string(c)
+}
+
+// DeadTopicString is the list of tasks that failed on every attempt they were allowed
+func (c CoordinatorClientTaskTopic) DeadTopicString() string {
+	return "dead_" + string(c)
+}
+
+const (
+	CoordinatorClientTaskTopicUrls CoordinatorClientTaskTopic = "urls"
+	CoordinatorClientTaskTopicRag  CoordinatorClientTaskTopic = "rag"
+)
+
+var (
+	ErrNoTasksToComplete = &CoordinatorClientNoTasksToComplete{}
+	ErrNoTasksCompleted  = &CoordinatorClientNoTasksCompleted{}
+	ErrJobNotFound       = &CoordinatorClientJobNotFound{}
+	ErrTaskTimedOut      = &CoordinatorClientTaskTimedOut{}
+	ErrLeaseExpired      = &CoordinatorClientLeaseExpired{}
+
+	ErrExtractionRuleNotFound = &CoordinatorClientExtractionRuleNotFound{}
+)
+
+type CoordinatorClient interface {
+	CreateTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error
+	CreateTasks(ctx context.Context, topic CoordinatorClientTaskTopic, tasks []*Task) error
+
+	GetTask(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic) (*Task, error)
+	GetTaskAndSetProcessing(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic) (*Task, error)
+	SetProcessed(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error
+	// RequeueTask puts a task taken with GetTaskAndSetProcessing back on the topic unchanged, for when
+	// a worker gives up on it without it failing
+	RequeueTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error
+
+	StoreError(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, err error) error
+	GetErrors(ctx context.Context, topic CoordinatorClientTaskTopic) ([]*StoredError, error)
+
+	// RetryTask schedules task to be put back on the topic once due has passed
+	RetryTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, due time.Time) error
+	// PromoteDelayedTasks puts retries that are due back on the topic and returns how many there were
+	PromoteDelayedTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error)
+
+	// DeadLetterTask moves a task that has used up its retries to the topic's dead letter list
+	DeadLetterTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, err error) error
+	GetDeadTasks(ctx context.Context, topic CoordinatorClientTaskTopic) ([]*StoredError, error)
+	// RequeueDeadTasks puts the dead tasks with the given ids (or all of them if there are none) back
+	// on the topic with their attempts reset, returning how many were requeued
+	RequeueDeadTasks(ctx context.Context, topic CoordinatorClientTaskTopic, taskIds []string) (int, error)
+
+	NumTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error)
+	NumProcessingTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error)
+
+	// ReapExpiredTasks moves tasks whose lease has expired from the processing list back onto the
+	// topic, incrementing their attempt count. Tasks that have been attempted maxAttempts times are dead
+	// lettered with ErrLeaseExpired instead (maxAttempts <= 0 means no limit). It returns the number of
+	// tasks reaped.
+	ReapExpiredTasks(ctx context.Context, topic CoordinatorClientTaskTopic, maxAttempts int) (int, error)
+
+	// MarkVisited records url as part of the crawl identified by crawlId. It returns false if the url
+	// has already been visited or the crawl has reached maxPages (maxPages <= 0 means no limit).
+	MarkVisited(ctx context.Context, crawlId string, url string, maxPages int) (bool, error)
+	// ReserveHost reserves the next slot for a request to host, keeping requests to it at least
+	// interval apart across every worker. It returns how long the caller must wait before sending it.
+	ReserveHost(ctx context.Context, host string, interval time.Duration) (time.Duration, error)
+
+	CreateJob(ctx context.Context, job *Job) error
+	GetJob(ctx context.Context, jobId string) (*Job, error)
+	SetJobUrlProgress(ctx context.Context, jobId string, url string, progress *JobUrlProgress) error
+
+	// SetExtractionRule creates or replaces the extraction rule for rule.Domain
+	SetExtractionRule(ctx context.Context, rule *ExtractionRule) error
+	GetExtractionRule(ctx context.Context, domain string) (*ExtractionRule, error)
+	GetExtractionRules(ctx context.Context) ([]*ExtractionRule, error)
+	DeleteExtractionRule(ctx context.Context, domain string) error
+}
+
+func visitedKey(crawlId string) string {
+	return "visited_" + crawlId
+}
+
+func hostKey(host string) string {
+	return "host_" + host
+}
+
+type CoordinatorClientNoTasksToComplete struct {
+}
+
+func (r *CoordinatorClientNoTasksToComplete) Error() string {
+	return "No tasks to complete"
+}
+
+type CoordinatorClientNoTasksCompleted struct {
+}
+
+func (r *CoordinatorClientNoTasksCompleted) Error() string {
+	return "No tasks completed"
+}
+
+type CoordinatorClientJobNotFound struct {
+}
+
+func (r *CoordinatorClientJobNotFound) Error() string {
+	return "Job not found"
+}
+
+type CoordinatorClientTaskTimedOut struct {
+}
+
+func (r *CoordinatorClientTaskTimedOut) Error() string {
+	return "Task timed out"
+}
+
+type CoordinatorClientLeaseExpired struct {
+}
+
+func (r *CoordinatorClientLeaseExpired) Error() string {
+	return "Task lease expired"
+}
+
+type CoordinatorClientExtractionRuleNotFound struct {
+}
+
+func (r *CoordinatorClientExtractionRuleNotFound) Error() string {
+	return "Extraction rule not found"
+}
@@ -0,0 +1,18 @@
+package coordinator_client
+
+import (
+	"time"
+)
+
+const (
+	extractionRulesKey = "extraction_rules"
+)
+
+// ExtractionRule tells scrapers where the content of a domain's pages is. Selectors pick out the
+// content, and anything matching ExcludeSelectors is removed from it first.
+type ExtractionRule struct {
+	Domain           string    `json:"domain"`
+	Selectors        []string  `json:"selectors,omitempty"`
+	ExcludeSelectors []string  `json:"exclude_selectors,omitempty"`
+	Updated          time.Time `json:"updated"`
+}
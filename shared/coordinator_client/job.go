@@ -0,0 +1,60 @@
+package coordinator_client
+
+import (
+	"time"
+)
+
+const (
+	jobTTL = 7 * 24 * time.Hour
+)
+
+// JobUrlStatus is the stage a url submitted as part of a job has reached in the pipeline
+type JobUrlStatus string
+
+const (
+	JobUrlStatusQueued    JobUrlStatus = "queued"
+	JobUrlStatusScraping  JobUrlStatus = "scraping"
+	JobUrlStatusEmbedding JobUrlStatus = "embedding"
+	JobUrlStatusStored    JobUrlStatus = "stored"
+	JobUrlStatusSkipped   JobUrlStatus = "skipped"
+	// JobUrlStatusRetrying is set when a task fails, and replaced with JobUrlStatusFailed if it has no
+	// attempts left
+	JobUrlStatusRetrying JobUrlStatus = "retrying"
+	JobUrlStatusFailed   JobUrlStatus = "failed"
+)
+
+// Job groups the url and rag tasks created by a single submission. Every task created on behalf of
+// the job carries its id so progress can be reported per url.
+type Job struct {
+	ID        string                     `json:"id"`
+	CreatedBy string                     `json:"created_by"`
+	Created   time.Time                  `json:"created"`
+	Urls      map[string]*JobUrlProgress `json:"urls,omitempty"`
+}
+
+type JobUrlProgress struct {
+	Status  JobUrlStatus `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	Updated time.Time    `json:"updated"`
+}
+
+func NewJobUrlProgress(status JobUrlStatus, err error) *JobUrlProgress {
+	progress := &JobUrlProgress{
+		Status:  status,
+		Updated: time.Now(),
+	}
+
+	if err != nil {
+		progress.Error = err.Error()
+	}
+
+	return progress
+}
+
+func jobKey(jobId string) string {
+	return "job_" + jobId
+}
+
+func jobUrlsKey(jobId string) string {
+	return "job_urls_" + jobId
+}
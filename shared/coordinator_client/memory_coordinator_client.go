@@ -0,0 +1,507 @@
+package coordinator_client
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCoordinatorClient coordinates workers that run in the same process as each other and the
+// coordinator, keeping tasks, jobs and crawl state in memory instead of in Redis. Nothing outlives the
+// process, so tasks that haven't finished when it stops are lost. Visited urls and jobs expire after
+// the same time as they do in Redis.
+type MemoryCoordinatorClient struct {
+	tasks         map[string][]string // topic -> tasks
+	processing    map[string][]string // topic -> processing tasks
+	errors        []*StoredError
+	visited       map[string]map[string]bool            // crawl id -> visited urls
+	visitedExpiry map[string]time.Time                  // crawl id -> when its visited urls expire
+	jobs          map[string]*Job                       // job id -> job without its urls
+	jobUrls       map[string]map[string]*JobUrlProgress // job id -> url -> progress
+	jobExpiry     map[string]time.Time                  // job id -> when the job expires
+	leases        map[string]time.Time                  // processing task -> lease deadline
+	leaseDur      time.Duration
+	delayed       map[string][]*delayedTask  // topic -> tasks waiting to be retried
+	dead          map[string][]*StoredError  // topic -> dead tasks
+	hosts         map[string]time.Time       // host -> time its next request slot opens
+	rules         map[string]*ExtractionRule // domain -> extraction rule
+	mutex         sync.Mutex
+
+	// taskAdded is closed and replaced whenever tasks are put on a topic, waking anyone waiting for one
+	taskAdded chan struct{}
+}
+
+type delayedTask struct {
+	taskString string
+	due        time.Time
+}
+
+func NewMemoryCoordinatorClient() *MemoryCoordinatorClient {
+	return &MemoryCoordinatorClient{
+		tasks:         make(map[string][]string),
+		processing:    make(map[string][]string),
+		delayed:       make(map[string][]*delayedTask),
+		dead:          make(map[string][]*StoredError),
+		errors:        make([]*StoredError, 0),
+		visited:       make(map[string]map[string]bool),
+		visitedExpiry: make(map[string]time.Time),
+		jobs:          make(map[string]*Job),
+		jobUrls:       make(map[string]map[string]*JobUrlProgress),
+		jobExpiry:     make(map[string]time.Time),
+		leases:        make(map[string]time.Time),
+		leaseDur:      LeaseDuration,
+		hosts:         make(map[string]time.Time),
+		rules:         make(map[string]*ExtractionRule),
+		taskAdded:     make(chan struct{}),
+	}
+}
+
+func (m *MemoryCoordinatorClient) CreateTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	return m.CreateTasks(ctx, topic, []*Task{task})
+}
+
+func (m *MemoryCoordinatorClient) CreateTasks(ctx context.Context, topic CoordinatorClientTaskTopic, tasks []*Task) error {
+	taskStrings := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskString, err := task.toString()
+		if err != nil {
+			return err
+		}
+		taskStrings = append(taskStrings, taskString)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.push(topic, taskStrings...)
+	return nil
+}
+
+// push puts tasks on the end of topic and wakes anyone waiting for a task. The lock must be held.
+func (m *MemoryCoordinatorClient) push(topic CoordinatorClientTaskTopic, taskStrings ...string) {
+	if len(taskStrings) == 0 {
+		return
+	}
+
+	m.tasks[topic.String()] = append(m.tasks[topic.String()], taskStrings...)
+
+	close(m.taskAdded)
+	m.taskAdded = make(chan struct{})
+}
+
+func (m *MemoryCoordinatorClient) GetTask(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic) (*Task, error) {
+	return m.popTask(ctx, timeout, topic, func(taskString string) {})
+}
+
+func (m *MemoryCoordinatorClient) GetTaskAndSetProcessing(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic) (*Task, error) {
+	return m.popTask(ctx, timeout, topic, func(taskString string) {
+		processingTopic := topic.ProcessingTopicString()
+		m.processing[processingTopic] = append(m.processing[processingTopic], taskString)
+		m.leases[processingTopic+taskString] = time.Now().Add(m.leaseDur)
+	})
+}
+
+// popTask waits up to timeout for a task on topic and takes the first one off it, calling onPop with
+// the lock held. The lock isn't held while waiting, so the other workers can carry on with their tasks.
+func (m *MemoryCoordinatorClient) popTask(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic, onPop func(taskString string)) (*Task, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		m.mutex.Lock()
+		if tasks := m.tasks[topic.String()]; len(tasks) > 0 {
+			taskString := tasks[0]
+			m.tasks[topic.String()] = tasks[1:]
+			onPop(taskString)
+			m.mutex.Unlock()
+
+			var task Task
+			if err := json.Unmarshal([]byte(taskString), &task); err != nil {
+				return nil, err
+			}
+			return &task, nil
+		}
+		taskAdded := m.taskAdded
+		m.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, ErrNoTasksToComplete
+		case <-taskAdded:
+		}
+	}
+}
+
+func (m *MemoryCoordinatorClient) SetProcessed(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	taskString, err := task.toString()
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.removeProcessing(topic, taskString) {
+		return ErrNoTasksCompleted
+	}
+
+	return nil
+}
+
+func (m *MemoryCoordinatorClient) RequeueTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	taskString, err := task.toString()
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.removeProcessing(topic, taskString) {
+		return ErrNoTasksCompleted
+	}
+
+	m.push(topic, taskString)
+	return nil
+}
+
+// removeProcessing takes a task off the topic's processing list along with its lease, returning
+// whether it was there. The lock must be held.
+func (m *MemoryCoordinatorClient) removeProcessing(topic CoordinatorClientTaskTopic, taskString string) bool {
+	processingTopic := topic.ProcessingTopicString()
+	i := slices.Index(m.processing[processingTopic], taskString)
+	if i < 0 {
+		return false
+	}
+
+	m.processing[processingTopic] = slices.Delete(m.processing[processingTopic], i, i+1)
+	delete(m.leases, processingTopic+taskString)
+	return true
+}
+
+func (m *MemoryCoordinatorClient) StoreError(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, err error) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.errors = append(m.errors, NewStoredError(topic, task, err))
+	return nil
+}
+
+func (m *MemoryCoordinatorClient) NumTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.tasks[topic.String()]), nil
+}
+
+func (m *MemoryCoordinatorClient) NumProcessingTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.processing[topic.ProcessingTopicString()]), nil
+}
+
+func (m *MemoryCoordinatorClient) GetErrors(ctx context.Context, topic CoordinatorClientTaskTopic) ([]*StoredError, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return slices.Clone(m.errors), nil
+}
+
+func (m *MemoryCoordinatorClient) MarkVisited(ctx context.Context, crawlId string, url string, maxPages int) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	key := visitedKey(crawlId)
+	if expiry, exists := m.visitedExpiry[key]; !exists || !expiry.After(now) {
+		m.dropExpired(now)
+		m.visited[key] = make(map[string]bool)
+	}
+
+	if m.visited[key][url] {
+		return false, nil
+	}
+
+	if maxPages > 0 && len(m.visited[key]) >= maxPages {
+		return false, nil
+	}
+
+	m.visited[key][url] = true
+	m.visitedExpiry[key] = now.Add(visitedTTL)
+	return true, nil
+}
+
+func (m *MemoryCoordinatorClient) ReserveHost(ctx context.Context, host string, interval time.Duration) (time.Duration, error) {
+	if interval <= 0 {
+		return 0, nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	slot := m.hosts[host]
+	if slot.Before(now) {
+		slot = now
+	}
+
+	m.hosts[host] = slot.Add(interval)
+	return slot.Sub(now), nil
+}
+
+func (m *MemoryCoordinatorClient) CreateJob(ctx context.Context, job *Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	m.dropExpired(now)
+
+	m.jobs[job.ID] = &Job{
+		ID:        job.ID,
+		CreatedBy: job.CreatedBy,
+		Created:   job.Created,
+	}
+	m.jobExpiry[job.ID] = now.Add(jobTTL)
+
+	if _, exists := m.jobUrls[job.ID]; !exists {
+		m.jobUrls[job.ID] = make(map[string]*JobUrlProgress)
+	}
+	for url, progress := range job.Urls {
+		m.jobUrls[job.ID][url] = progress
+	}
+
+	return nil
+}
+
+func (m *MemoryCoordinatorClient) GetJob(ctx context.Context, jobId string) (*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored, exists := m.jobs[jobId]
+	if !exists || !m.jobExpiry[jobId].After(time.Now()) {
+		return nil, ErrJobNotFound
+	}
+
+	job := *stored
+	job.Urls = make(map[string]*JobUrlProgress, len(m.jobUrls[jobId]))
+	for url, progress := range m.jobUrls[jobId] {
+		job.Urls[url] = progress
+	}
+
+	return &job, nil
+}
+
+func (m *MemoryCoordinatorClient) SetJobUrlProgress(ctx context.Context, jobId string, url string, progress *JobUrlProgress) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.jobUrls[jobId]; !exists {
+		m.jobUrls[jobId] = make(map[string]*JobUrlProgress)
+	}
+	m.jobUrls[jobId][url] = progress
+
+	// Progress set for a job that was never created is still dropped once it would have expired
+	if _, exists := m.jobExpiry[jobId]; !exists {
+		m.jobExpiry[jobId] = time.Now().Add(jobTTL)
+	}
+
+	return nil
+}
+
+// dropExpired forgets the visited urls and jobs that have expired, as Redis would. The lock must be
+// held.
+func (m *MemoryCoordinatorClient) dropExpired(now time.Time) {
+	for key, expiry := range m.visitedExpiry {
+		if !expiry.After(now) {
+			delete(m.visited, key)
+			delete(m.visitedExpiry, key)
+		}
+	}
+
+	for jobId, expiry := range m.jobExpiry {
+		if !expiry.After(now) {
+			delete(m.jobs, jobId)
+			delete(m.jobUrls, jobId)
+			delete(m.jobExpiry, jobId)
+		}
+	}
+}
+
+func (m *MemoryCoordinatorClient) ReapExpiredTasks(ctx context.Context, topic CoordinatorClientTaskTopic, maxAttempts int) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	processingTopic := topic.ProcessingTopicString()
+	remaining := make([]string, 0, len(m.processing[processingTopic]))
+	var requeued []string
+	numDead := 0
+
+	for _, taskString := range m.processing[processingTopic] {
+		deadline, leased := m.leases[processingTopic+taskString]
+		if !leased {
+			m.leases[processingTopic+taskString] = time.Now().Add(m.leaseDur)
+		}
+
+		if !leased || deadline.After(time.Now()) {
+			remaining = append(remaining, taskString)
+			continue
+		}
+
+		var task Task
+		if err := json.Unmarshal([]byte(taskString), &task); err != nil {
+			return 0, err
+		}
+		delete(m.leases, processingTopic+taskString)
+
+		if maxAttempts > 0 && task.Attempts+1 >= maxAttempts {
+			m.dead[topic.String()] = append(m.dead[topic.String()], NewStoredError(topic, &task, ErrLeaseExpired))
+			numDead++
+			continue
+		}
+		task.Attempts++
+
+		requeuedTaskString, err := task.toString()
+		if err != nil {
+			return 0, err
+		}
+		requeued = append(requeued, requeuedTaskString)
+	}
+
+	m.processing[processingTopic] = remaining
+	m.push(topic, requeued...)
+	return len(requeued) + numDead, nil
+}
+
+func (m *MemoryCoordinatorClient) RetryTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, due time.Time) error {
+	taskString, err := task.toString()
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.delayed[topic.String()] = append(m.delayed[topic.String()], &delayedTask{taskString: taskString, due: due})
+	return nil
+}
+
+func (m *MemoryCoordinatorClient) PromoteDelayedTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	remaining := make([]*delayedTask, 0, len(m.delayed[topic.String()]))
+	var promoted []string
+
+	for _, delayed := range m.delayed[topic.String()] {
+		if delayed.due.After(time.Now()) {
+			remaining = append(remaining, delayed)
+			continue
+		}
+
+		promoted = append(promoted, delayed.taskString)
+	}
+
+	m.delayed[topic.String()] = remaining
+	m.push(topic, promoted...)
+	return len(promoted), nil
+}
+
+func (m *MemoryCoordinatorClient) DeadLetterTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, err error) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.dead[topic.String()] = append(m.dead[topic.String()], NewStoredError(topic, task, err))
+	return nil
+}
+
+func (m *MemoryCoordinatorClient) GetDeadTasks(ctx context.Context, topic CoordinatorClientTaskTopic) ([]*StoredError, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return slices.Clone(m.dead[topic.String()]), nil
+}
+
+func (m *MemoryCoordinatorClient) RequeueDeadTasks(ctx context.Context, topic CoordinatorClientTaskTopic, taskIds []string) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	remaining := make([]*StoredError, 0, len(m.dead[topic.String()]))
+	var requeued []string
+
+	for _, deadTask := range m.dead[topic.String()] {
+		if deadTask.Task == nil || (len(taskIds) > 0 && !slices.Contains(taskIds, deadTask.Task.ID)) {
+			remaining = append(remaining, deadTask)
+			continue
+		}
+
+		task := *deadTask.Task
+		task.Attempts = 0
+
+		taskString, err := task.toString()
+		if err != nil {
+			return 0, err
+		}
+
+		requeued = append(requeued, taskString)
+	}
+
+	m.dead[topic.String()] = remaining
+	m.push(topic, requeued...)
+	return len(requeued), nil
+}
+
+func (m *MemoryCoordinatorClient) SetExtractionRule(ctx context.Context, rule *ExtractionRule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored := *rule
+	m.rules[rule.Domain] = &stored
+	return nil
+}
+
+func (m *MemoryCoordinatorClient) GetExtractionRule(ctx context.Context, domain string) (*ExtractionRule, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rule, exists := m.rules[domain]
+	if !exists {
+		return nil, ErrExtractionRuleNotFound
+	}
+
+	stored := *rule
+	return &stored, nil
+}
+
+func (m *MemoryCoordinatorClient) GetExtractionRules(ctx context.Context) ([]*ExtractionRule, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rules := make([]*ExtractionRule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		stored := *rule
+		rules = append(rules, &stored)
+	}
+
+	slices.SortFunc(rules, func(a, b *ExtractionRule) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return rules, nil
+}
+
+func (m *MemoryCoordinatorClient) DeleteExtractionRule(ctx context.Context, domain string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.rules[domain]; !exists {
+		return ErrExtractionRuleNotFound
+	}
+
+	delete(m.rules, domain)
+	return nil
+}
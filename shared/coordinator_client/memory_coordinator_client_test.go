@@ -0,0 +1,68 @@
+package coordinator_client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCoordinatorClientGetTaskWaitsForTask(t *testing.T) {
+	client := NewMemoryCoordinatorClient()
+	ctx := context.Background()
+
+	task, err := NewTask("1", "test", nil)
+	assert.NoError(t, err)
+
+	got := make(chan *Task, 1)
+	go func() {
+		task, err := client.GetTaskAndSetProcessing(ctx, 5*time.Second, CoordinatorClientTaskTopicUrls)
+		assert.NoError(t, err)
+		got <- task
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task))
+
+	select {
+	case gotTask := <-got:
+		assert.Equal(t, "1", gotTask.ID)
+	case <-time.After(time.Second):
+		t.Fatal("Waiting for a task wasn't woken when one was created")
+	}
+
+	numProcessing, err := client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numProcessing)
+
+	_, err = client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.ErrorIs(t, err, ErrNoTasksToComplete)
+}
+
+func TestMemoryCoordinatorClientExpiry(t *testing.T) {
+	client := NewMemoryCoordinatorClient()
+	ctx := context.Background()
+
+	assert.NoError(t, client.CreateJob(ctx, &Job{ID: "job-1", CreatedBy: "test", Created: time.Now()}))
+	visited, err := client.MarkVisited(ctx, "crawl-1", "https://example.com", 0)
+	assert.NoError(t, err)
+	assert.True(t, visited)
+
+	// Expire both, as if their TTL had passed
+	client.mutex.Lock()
+	client.jobExpiry["job-1"] = time.Now().Add(-time.Second)
+	client.visitedExpiry[visitedKey("crawl-1")] = time.Now().Add(-time.Second)
+	client.mutex.Unlock()
+
+	_, err = client.GetJob(ctx, "job-1")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	visited, err = client.MarkVisited(ctx, "crawl-1", "https://example.com", 0)
+	assert.NoError(t, err)
+	assert.True(t, visited, "an expired crawl starts again")
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	assert.NotContains(t, client.jobs, "job-1", "expired jobs are dropped")
+}
@@ -0,0 +1,59 @@
+package coordinator_client
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// MockCoordinatorClient stands in for Redis in tests. It is a MemoryCoordinatorClient with helpers for
+// looking at and changing its state.
+type MockCoordinatorClient struct {
+	*MemoryCoordinatorClient
+}
+
+// NewMockCoordinatorClient creates a new mock coordinator client
+func NewMockCoordinatorClient() *MockCoordinatorClient {
+	return &MockCoordinatorClient{NewMemoryCoordinatorClient()}
+}
+
+// JobUrlProgress returns the last progress set for url in the job, or nil if there is none
+func (m *MockCoordinatorClient) JobUrlProgress(jobId string, url string) *JobUrlProgress {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.jobUrls[jobId][url]
+}
+
+// SetLeaseDuration sets the lease given to tasks taken by GetTaskAndSetProcessing from now on
+func (m *MockCoordinatorClient) SetLeaseDuration(d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.leaseDur = d
+}
+
+// DelayedTasks returns the tasks waiting to be retried on topic
+func (m *MockCoordinatorClient) DelayedTasks(topic CoordinatorClientTaskTopic) []*Task {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tasks := make([]*Task, 0, len(m.delayed[topic.String()]))
+	for _, delayed := range m.delayed[topic.String()] {
+		var task Task
+		if err := json.Unmarshal([]byte(delayed.taskString), &task); err != nil {
+			continue
+		}
+		tasks = append(tasks, &task)
+	}
+
+	return tasks
+}
+
+// DeadTasks returns the tasks dead lettered on topic
+func (m *MockCoordinatorClient) DeadTasks(topic CoordinatorClientTaskTopic) []*StoredError {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return slices.Clone(m.dead[topic.String()])
+}
@@ -0,0 +1,437 @@
+package coordinator_client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockCoordinatorClient(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	// Test CreateTask
+	task, err := NewTask("test-id", "test-data", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	err = client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	// Test GetTask
+	retrievedTask, err := client.GetTask(ctx, 1*time.Second, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+	if retrievedTask.ID != task.ID {
+		t.Errorf("Expected task ID %s, got %s", task.ID, retrievedTask.ID)
+	}
+
+	// Test GetTaskAndSetProcessing
+	task2, err := NewTask("test-id-2", "test-data-2", nil)
+	if err != nil {
+		t.Fatalf("Failed to create second task: %v", err)
+	}
+
+	err = client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task2)
+	if err != nil {
+		t.Fatalf("Failed to create second task: %v", err)
+	}
+
+	processingTask, err := client.GetTaskAndSetProcessing(ctx, 1*time.Second, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get task and set processing: %v", err)
+	}
+	if processingTask.ID != task2.ID {
+		t.Errorf("Expected task ID %s, got %s", task2.ID, processingTask.ID)
+	}
+
+	// Test SetProcessed
+	err = client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, processingTask)
+	if err != nil {
+		t.Fatalf("Failed to set task as processed: %v", err)
+	}
+
+	// Test error cases
+	_, err = client.GetTask(ctx, 1*time.Second, CoordinatorClientTaskTopicUrls)
+	if err != ErrNoTasksToComplete {
+		t.Errorf("Expected ErrNoTasksToComplete, got %v", err)
+	}
+
+	err = client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, task)
+	if err != ErrNoTasksCompleted {
+		t.Errorf("Expected ErrNoTasksCompleted, got %v", err)
+	}
+}
+
+func TestMockCoordinatorClient_CreateTask(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	type testParams struct {
+		Number int    `json:"number"`
+		Name   string `json:"name"`
+	}
+
+	task, err := NewTask("test-id", "test-data", testParams{Number: 1, Name: "a name"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	err = client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	createdTask, err := client.GetTask(ctx, 1*time.Second, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+
+	parsedParams, err := CastParams[testParams](createdTask.Params)
+	if err != nil {
+		t.Fatalf("Failed to parse params: %v", err)
+	}
+
+	assert.Equal(t, parsedParams.Number, 1)
+	assert.Equal(t, parsedParams.Name, "a name")
+
+	t.Logf("createdTask: %+v", createdTask)
+}
+
+func TestMockCoordinatorClient_StoreError(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	params := map[string]string{
+		"url": "https://ethanhosier.com",
+	}
+
+	task, err := NewTask("test-id", "test-data", params)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	client.StoreError(ctx, CoordinatorClientTaskTopicUrls, task, fmt.Errorf("an error"))
+
+	assert.Equal(t, len(client.errors), 1)
+	t.Logf("errors: %+v", client.errors)
+}
+
+func TestMockCoordinatorClient_StoreErrorKind(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	task, err := NewTask("test-id", "test-data", map[string]string{"url": "https://ethanhosier.com"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	client.StoreError(ctx, CoordinatorClientTaskTopicUrls, task, fmt.Errorf("an error"))
+	client.StoreError(ctx, CoordinatorClientTaskTopicUrls, task, fmt.Errorf("%w: %w", ErrTaskTimedOut, context.DeadlineExceeded))
+	client.DeadLetterTask(ctx, CoordinatorClientTaskTopicUrls, task, fmt.Errorf("scraping: %w", context.DeadlineExceeded))
+
+	errs, err := client.GetErrors(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, StoredErrorKindError, errs[0].Kind)
+	assert.Equal(t, StoredErrorKindTimeout, errs[1].Kind)
+
+	dead, err := client.GetDeadTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, StoredErrorKindTimeout, dead[0].Kind)
+}
+
+func TestMockCoordinatorClient_CreateTasks(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	params1 := map[string]string{
+		"url": "https://ethanhosier.com",
+	}
+	task1, err := NewTask("test-id-1", "test-data-1", params1)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	params2 := map[string]string{
+		"url": "https://ethanhosier.com/blog",
+	}
+	task2, err := NewTask("test-id-2", "test-data-2", params2)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	client.CreateTasks(ctx, CoordinatorClientTaskTopicUrls, []*Task{task1, task2})
+
+	assert.Equal(t, len(client.tasks[CoordinatorClientTaskTopicUrls.String()]), 2)
+}
+
+func TestMockCoordinatorClient_NumTasks(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	numTasks, err := client.NumTasks(ctx, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get number of tasks: %v", err)
+	}
+
+	assert.Equal(t, numTasks, 0)
+
+	task1, err := NewTask("test-id-1", "test-data-1", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task1)
+
+	numTasks, err = client.NumTasks(ctx, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get number of tasks: %v", err)
+	}
+
+	assert.Equal(t, numTasks, 1)
+}
+
+func TestMockCoordinatorClient_NumProcessingTasks(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	numProcessingTasks, err := client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get number of processing tasks: %v", err)
+	}
+
+	assert.Equal(t, numProcessingTasks, 0)
+
+	task1, err := NewTask("test-id-1", "test-data-1", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	if err := client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task1); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	numProcessingTasks, err = client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get number of processing tasks: %v", err)
+	}
+
+	assert.Equal(t, numProcessingTasks, 0)
+
+	client.GetTaskAndSetProcessing(ctx, 1*time.Second, CoordinatorClientTaskTopicUrls)
+
+	numProcessingTasks, err = client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get number of processing tasks: %v", err)
+	}
+
+	assert.Equal(t, numProcessingTasks, 1)
+}
+
+func TestMockCoordinatorClient_GetErrors(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	if err := client.StoreError(ctx, CoordinatorClientTaskTopicUrls, nil, fmt.Errorf("an error")); err != nil {
+		t.Fatalf("Failed to store error: %v", err)
+	}
+
+	if err := client.StoreError(ctx, CoordinatorClientTaskTopicUrls, nil, fmt.Errorf("an error 2")); err != nil {
+		t.Fatalf("Failed to store error: %v", err)
+	}
+
+	errors, err := client.GetErrors(ctx, CoordinatorClientTaskTopicUrls)
+	if err != nil {
+		t.Fatalf("Failed to get errors: %v", err)
+	}
+
+	assert.Equal(t, len(errors), 2)
+
+	assert.Equal(t, errors[0].Error, "an error")
+	assert.Equal(t, errors[1].Error, "an error 2")
+}
+
+func TestMockCoordinatorClient_Jobs(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	_, err := client.GetJob(ctx, "job-1")
+	assert.Equal(t, ErrJobNotFound, err)
+
+	job := &Job{
+		ID:        "job-1",
+		CreatedBy: "test",
+		Created:   time.Now(),
+		Urls: map[string]*JobUrlProgress{
+			"https://ethanhosier.com": NewJobUrlProgress(JobUrlStatusQueued, nil),
+		},
+	}
+
+	if err := client.CreateJob(ctx, job); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+
+	storedJob, err := client.GetJob(ctx, "job-1")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+
+	assert.Equal(t, "test", storedJob.CreatedBy)
+	assert.Equal(t, 1, len(storedJob.Urls))
+	assert.Equal(t, JobUrlStatusQueued, storedJob.Urls["https://ethanhosier.com"].Status)
+}
+
+func TestMockCoordinatorClient_RequeueDeadTasks(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	task1, err := NewTask("test-id-1", "test-data-1", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	task1.Attempts = 2
+
+	task2, err := NewTask("test-id-2", "test-data-2", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	client.DeadLetterTask(ctx, CoordinatorClientTaskTopicUrls, task1, fmt.Errorf("an error"))
+	client.DeadLetterTask(ctx, CoordinatorClientTaskTopicUrls, task2, fmt.Errorf("an error 2"))
+
+	deadTasks, err := client.GetDeadTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(deadTasks))
+
+	numRequeued, err := client.RequeueDeadTasks(ctx, CoordinatorClientTaskTopicUrls, []string{"test-id-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numRequeued)
+
+	requeuedTask, err := client.GetTask(ctx, 0, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-id-1", requeuedTask.ID)
+	assert.Equal(t, 0, requeuedTask.Attempts)
+
+	numRequeued, err = client.RequeueDeadTasks(ctx, CoordinatorClientTaskTopicUrls, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numRequeued)
+
+	deadTasks, err = client.GetDeadTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(deadTasks))
+}
+
+func TestMockCoordinatorClient_RequeueTask(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	task, err := NewTask("test-id", "test-data", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	assert.NoError(t, client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task))
+
+	processing, err := client.GetTaskAndSetProcessing(ctx, 0, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.NoError(t, client.RequeueTask(ctx, CoordinatorClientTaskTopicUrls, processing))
+
+	numProcessingTasks, _ := client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicUrls)
+	numTasks, _ := client.NumTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, 0, numProcessingTasks)
+	assert.Equal(t, 1, numTasks)
+
+	err = client.RequeueTask(ctx, CoordinatorClientTaskTopicUrls, processing)
+	assert.Equal(t, ErrNoTasksCompleted, err)
+}
+
+func TestMockCoordinatorClient_MarkVisited(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	visited, err := client.MarkVisited(ctx, "crawl-1", "https://example.com/", 2)
+	assert.NoError(t, err)
+	assert.True(t, visited)
+
+	visited, err = client.MarkVisited(ctx, "crawl-1", "https://example.com/", 2)
+	assert.NoError(t, err)
+	assert.False(t, visited, "already visited url should not be visited again")
+
+	visited, err = client.MarkVisited(ctx, "crawl-1", "https://example.com/about", 2)
+	assert.NoError(t, err)
+	assert.True(t, visited)
+
+	visited, err = client.MarkVisited(ctx, "crawl-1", "https://example.com/blog", 2)
+	assert.NoError(t, err)
+	assert.False(t, visited, "crawl should be limited to max pages")
+
+	visited, err = client.MarkVisited(ctx, "crawl-2", "https://example.com/blog", 0)
+	assert.NoError(t, err)
+	assert.True(t, visited, "crawls should not share visited urls")
+}
+
+func TestMockCoordinatorClient_ReserveHost(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	wait, err := client.ReserveHost(ctx, "example.com", time.Minute)
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+
+	wait, err = client.ReserveHost(ctx, "example.com", time.Minute)
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Minute, wait, float64(time.Second))
+
+	wait, err = client.ReserveHost(ctx, "other.com", time.Minute)
+	assert.NoError(t, err)
+	assert.Zero(t, wait, "hosts should not share slots")
+}
+
+func TestMockCoordinatorClient_SetJobUrlProgress(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	assert.Nil(t, client.JobUrlProgress("job-1", "https://example.com"))
+
+	err := client.SetJobUrlProgress(ctx, "job-1", "https://example.com", NewJobUrlProgress(JobUrlStatusScraping, nil))
+	assert.NoError(t, err)
+
+	err = client.SetJobUrlProgress(ctx, "job-1", "https://example.com", NewJobUrlProgress(JobUrlStatusFailed, fmt.Errorf("an error")))
+	assert.NoError(t, err)
+
+	progress := client.JobUrlProgress("job-1", "https://example.com")
+	assert.Equal(t, JobUrlStatusFailed, progress.Status)
+	assert.Equal(t, "an error", progress.Error)
+}
+
+func TestMockCoordinatorClient_ExtractionRules(t *testing.T) {
+	client := NewMockCoordinatorClient()
+	ctx := context.Background()
+
+	_, err := client.GetExtractionRule(ctx, "example.com")
+	assert.Equal(t, ErrExtractionRuleNotFound, err)
+
+	assert.NoError(t, client.SetExtractionRule(ctx, &ExtractionRule{Domain: "example.com", Selectors: []string{"#content"}}))
+	assert.NoError(t, client.SetExtractionRule(ctx, &ExtractionRule{Domain: "blog.com", ExcludeSelectors: []string{".ads"}}))
+
+	rule, err := client.GetExtractionRule(ctx, "example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"#content"}, rule.Selectors)
+
+	rules, err := client.GetExtractionRules(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "blog.com", rules[0].Domain)
+
+	assert.NoError(t, client.DeleteExtractionRule(ctx, "example.com"))
+	assert.Equal(t, ErrExtractionRuleNotFound, client.DeleteExtractionRule(ctx, "example.com"))
+}
@@ -0,0 +1,590 @@
+package coordinator_client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const visitedTTL = 24 * time.Hour
+
+// LeaseDuration is how long a worker has to finish a task before the reaper assumes it has died and
+// puts the task back on its topic. Tasks must time out well within it, or a task that is still running
+// is run again alongside itself.
+const LeaseDuration = 15 * time.Minute
+
+// DefaultMaxAttempts is how many times a task is attempted, whether it fails or its worker dies, before
+// it is dead lettered
+const DefaultMaxAttempts = 3
+
+// markVisitedScript adds ARGV[1] to the visited set unless it is already present or the set has
+// reached ARGV[2] members. Doing this in a script keeps the page limit exact across workers.
+var markVisitedScript = redis.NewScript(`
+if redis.call("SISMEMBER", KEYS[1], ARGV[1]) == 1 then
+	return 0
+end
+local maxPages = tonumber(ARGV[2])
+if maxPages > 0 and redis.call("SCARD", KEYS[1]) >= maxPages then
+	return 0
+end
+redis.call("SADD", KEYS[1], ARGV[1])
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+return 1
+`)
+
+// reserveHostScript hands out request slots for a host ARGV[1] milliseconds apart. KEYS[1] holds the
+// time the next slot opens, and the script returns how many milliseconds the caller has until its
+// slot. Redis' clock is used so workers with skewed clocks still agree.
+var reserveHostScript = redis.NewScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local interval = tonumber(ARGV[1])
+local slot = tonumber(redis.call("GET", KEYS[1]) or now)
+if slot < now then
+	slot = now
+end
+redis.call("SET", KEYS[1], slot + interval, "PX", slot + interval - now)
+return slot - now
+`)
+
+// requeueScript moves ARGV[1] from the processing list KEYS[2] back onto the topic KEYS[1] as ARGV[2]
+// and drops its lease from KEYS[3]. KEYS[1] can be the dead letter list instead, to give up on a task. Only the caller that removes the entry requeues it, so concurrent
+// reapers can't requeue a task twice, and a task that was processed in the meantime is left alone.
+var requeueScript = redis.NewScript(`
+local removed = redis.call("LREM", KEYS[2], 1, ARGV[1])
+if removed == 1 then
+	redis.call("RPUSH", KEYS[1], ARGV[2])
+end
+redis.call("ZREM", KEYS[3], ARGV[1])
+return removed
+`)
+
+// leaseScript leases ARGV[1] in KEYS[2] until ARGV[2] if it is still in the processing list KEYS[1]
+// and has no lease already, so a task processed since the reaper listed it isn't left with a lease
+var leaseScript = redis.NewScript(`
+if redis.call("LPOS", KEYS[1], ARGV[1]) == false then
+	return 0
+end
+return redis.call("ZADD", KEYS[2], "NX", ARGV[2], ARGV[1])
+`)
+
+// moveScript removes ARGV[1] from KEYS[2] (a sorted set if ARGV[3] is "zset", otherwise a list) and
+// pushes ARGV[2] onto the topic KEYS[1] if it was there, so an entry is only ever moved once
+var moveScript = redis.NewScript(`
+local removed
+if ARGV[3] == "zset" then
+	removed = redis.call("ZREM", KEYS[2], ARGV[1])
+else
+	removed = redis.call("LREM", KEYS[2], 1, ARGV[1])
+end
+if removed == 1 then
+	redis.call("RPUSH", KEYS[1], ARGV[2])
+end
+return removed
+`)
+
+type RedisCoordinatorClient struct {
+	redisClient *redis.Client
+}
+
+func NewRedisCoordinatorClient(ctx context.Context, address string, password string, db int) *RedisCoordinatorClient {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     address,
+		Password: password,
+		DB:       db,
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	})
+
+	if err := redisClient.Ping(context.Background()).Err(); err != nil {
+		panic(err)
+	}
+
+	return &RedisCoordinatorClient{
+		redisClient: redisClient,
+	}
+}
+
+func (r *RedisCoordinatorClient) CreateTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	taskString, err := task.toString()
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.RPush(ctx, topic.String(), taskString).Err()
+}
+
+func (r *RedisCoordinatorClient) CreateTasks(ctx context.Context, topic CoordinatorClientTaskTopic, tasks []*Task) error {
+	taskStrings := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskString, err := task.toString()
+		if err != nil {
+			return err
+		}
+		taskStrings = append(taskStrings, taskString)
+	}
+
+	taskInterfaces := make([]interface{}, len(taskStrings))
+	for i, v := range taskStrings {
+		taskInterfaces[i] = v
+	}
+	return r.redisClient.RPush(ctx, topic.String(), taskInterfaces...).Err()
+}
+
+func (r *RedisCoordinatorClient) GetTask(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic) (*Task, error) {
+	result, err := r.redisClient.BLPop(ctx, timeout, topic.String()).Result()
+	if err == redis.Nil {
+		return nil, ErrNoTasksToComplete
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var task Task
+	err = json.Unmarshal([]byte(result[1]), &task)
+	if err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+func (r *RedisCoordinatorClient) GetTaskAndSetProcessing(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic) (*Task, error) {
+	result, err := r.redisClient.BRPopLPush(ctx, topic.String(), topic.ProcessingTopicString(), timeout).Result()
+	if err == redis.Nil {
+		return nil, ErrNoTasksToComplete
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// If we crash before the lease is added the reaper will still find the task, as it leases any
+	// processing entries that don't have one
+	deadline := time.Now().Add(LeaseDuration)
+	if err := r.redisClient.ZAdd(ctx, topic.LeasesTopicString(), redis.Z{Score: float64(deadline.UnixMilli()), Member: result}).Err(); err != nil {
+		return nil, err
+	}
+
+	var task Task
+	err = json.Unmarshal([]byte(result), &task)
+	if err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+func (r *RedisCoordinatorClient) SetProcessed(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	taskString, err := task.toString()
+	if err != nil {
+		return err
+	}
+
+	pipe := r.redisClient.TxPipeline()
+	cmd := pipe.LRem(ctx, topic.ProcessingTopicString(), 1, taskString)
+	pipe.ZRem(ctx, topic.LeasesTopicString(), taskString)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	if cmd.Val() == 0 {
+		return ErrNoTasksCompleted
+	}
+
+	return nil
+}
+
+func (r *RedisCoordinatorClient) RequeueTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	taskString, err := task.toString()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{topic.String(), topic.ProcessingTopicString(), topic.LeasesTopicString()}
+	requeued, err := requeueScript.Run(ctx, r.redisClient, keys, taskString, taskString).Int()
+	if err != nil {
+		return err
+	}
+
+	if requeued == 0 {
+		return ErrNoTasksCompleted
+	}
+
+	return nil
+}
+
+func (r *RedisCoordinatorClient) StoreError(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, err error) error {
+
+	storedErrorString, err := json.Marshal(NewStoredError(topic, task, err))
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.RPush(ctx, "errors", storedErrorString).Err()
+}
+
+func (r *RedisCoordinatorClient) NumTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	numTasks, err := r.redisClient.LLen(ctx, topic.String()).Result()
+	if err != nil {
+		return 0, err
+	}
+	return int(numTasks), nil
+}
+
+func (r *RedisCoordinatorClient) NumProcessingTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	numProcessingTasks, err := r.redisClient.LLen(ctx, topic.ProcessingTopicString()).Result()
+	if err != nil {
+		return 0, err
+	}
+	return int(numProcessingTasks), nil
+}
+
+func (r *RedisCoordinatorClient) GetErrors(ctx context.Context, topic CoordinatorClientTaskTopic) ([]*StoredError, error) {
+	errors, err := r.redisClient.LRange(ctx, "errors", 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	storedErrors := make([]*StoredError, 0, len(errors))
+	for _, error := range errors {
+		var storedError StoredError
+		err = json.Unmarshal([]byte(error), &storedError)
+		if err != nil {
+			return nil, err
+		}
+		storedErrors = append(storedErrors, &storedError)
+	}
+	return storedErrors, nil
+}
+
+func (r *RedisCoordinatorClient) MarkVisited(ctx context.Context, crawlId string, url string, maxPages int) (bool, error) {
+	added, err := markVisitedScript.Run(ctx, r.redisClient, []string{visitedKey(crawlId)}, url, maxPages, int(visitedTTL.Seconds())).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return added == 1, nil
+}
+
+func (r *RedisCoordinatorClient) ReserveHost(ctx context.Context, host string, interval time.Duration) (time.Duration, error) {
+	if interval <= 0 {
+		return 0, nil
+	}
+
+	wait, err := reserveHostScript.Run(ctx, r.redisClient, []string{hostKey(host)}, interval.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+func (r *RedisCoordinatorClient) SetJobUrlProgress(ctx context.Context, jobId string, url string, progress *JobUrlProgress) error {
+	progressString, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.redisClient.TxPipeline()
+	pipe.HSet(ctx, jobUrlsKey(jobId), url, progressString)
+	pipe.Expire(ctx, jobUrlsKey(jobId), jobTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisCoordinatorClient) CreateJob(ctx context.Context, job *Job) error {
+	jobString, err := json.Marshal(Job{ID: job.ID, CreatedBy: job.CreatedBy, Created: job.Created})
+	if err != nil {
+		return err
+	}
+
+	pipe := r.redisClient.TxPipeline()
+	pipe.Set(ctx, jobKey(job.ID), jobString, jobTTL)
+	for url, progress := range job.Urls {
+		progressString, err := json.Marshal(progress)
+		if err != nil {
+			return err
+		}
+		pipe.HSet(ctx, jobUrlsKey(job.ID), url, progressString)
+	}
+	pipe.Expire(ctx, jobUrlsKey(job.ID), jobTTL)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisCoordinatorClient) GetJob(ctx context.Context, jobId string) (*Job, error) {
+	jobString, err := r.redisClient.Get(ctx, jobKey(jobId)).Result()
+	if err == redis.Nil {
+		return nil, ErrJobNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal([]byte(jobString), &job); err != nil {
+		return nil, err
+	}
+
+	urls, err := r.redisClient.HGetAll(ctx, jobUrlsKey(jobId)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	job.Urls = make(map[string]*JobUrlProgress, len(urls))
+	for url, progressString := range urls {
+		var progress JobUrlProgress
+		if err := json.Unmarshal([]byte(progressString), &progress); err != nil {
+			return nil, err
+		}
+		job.Urls[url] = &progress
+	}
+
+	return &job, nil
+}
+
+func (r *RedisCoordinatorClient) ReapExpiredTasks(ctx context.Context, topic CoordinatorClientTaskTopic, maxAttempts int) (int, error) {
+	processing, err := r.redisClient.LRange(ctx, topic.ProcessingTopicString(), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// Lease any tasks whose worker died before leasing them, without extending existing leases
+	deadline := time.Now().Add(LeaseDuration)
+	for _, taskString := range processing {
+		keys := []string{topic.ProcessingTopicString(), topic.LeasesTopicString()}
+		if err := leaseScript.Run(ctx, r.redisClient, keys, taskString, deadline.UnixMilli()).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	expired, err := r.redisClient.ZRangeByScore(ctx, topic.LeasesTopicString(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	numReaped := 0
+	for _, taskString := range expired {
+		var task Task
+		if err := json.Unmarshal([]byte(taskString), &task); err != nil {
+			return numReaped, err
+		}
+
+		destination, reapedString, err := reapedTaskString(topic, &task, maxAttempts)
+		if err != nil {
+			return numReaped, err
+		}
+
+		keys := []string{destination, topic.ProcessingTopicString(), topic.LeasesTopicString()}
+		removed, err := requeueScript.Run(ctx, r.redisClient, keys, taskString, reapedString).Int()
+		if err != nil {
+			return numReaped, err
+		}
+
+		numReaped += removed
+	}
+
+	return numReaped, nil
+}
+
+// reapedTaskString returns the list a task whose lease expired goes to, and what to push onto it: the
+// topic and the task with its attempt counted, or the dead letter list once it has had maxAttempts
+func reapedTaskString(topic CoordinatorClientTaskTopic, task *Task, maxAttempts int) (string, string, error) {
+	if maxAttempts > 0 && task.Attempts+1 >= maxAttempts {
+		deadTaskString, err := json.Marshal(NewStoredError(topic, task, ErrLeaseExpired))
+		if err != nil {
+			return "", "", err
+		}
+		return topic.DeadTopicString(), string(deadTaskString), nil
+	}
+
+	requeued := *task
+	requeued.Attempts++
+
+	requeuedTaskString, err := requeued.toString()
+	if err != nil {
+		return "", "", err
+	}
+	return topic.String(), requeuedTaskString, nil
+}
+
+func (r *RedisCoordinatorClient) RetryTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, due time.Time) error {
+	taskString, err := task.toString()
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.ZAdd(ctx, topic.DelayedTopicString(), redis.Z{Score: float64(due.UnixMilli()), Member: taskString}).Err()
+}
+
+func (r *RedisCoordinatorClient) DeadLetterTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task, err error) error {
+	deadTaskString, err := json.Marshal(NewStoredError(topic, task, err))
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.RPush(ctx, topic.DeadTopicString(), deadTaskString).Err()
+}
+
+func (r *RedisCoordinatorClient) PromoteDelayedTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	return promoteDelayedTasks(ctx, r.redisClient, moveScript, topic.String(), topic)
+}
+
+// promoteDelayedTasks moves the due retries of topic onto destination with script, which takes the
+// same keys and arguments as moveScript
+func promoteDelayedTasks(ctx context.Context, redisClient *redis.Client, script *redis.Script, destination string, topic CoordinatorClientTaskTopic) (int, error) {
+	due, err := redisClient.ZRangeByScore(ctx, topic.DelayedTopicString(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	numPromoted := 0
+	for _, taskString := range due {
+		keys := []string{destination, topic.DelayedTopicString()}
+		moved, err := script.Run(ctx, redisClient, keys, taskString, taskString, "zset").Int()
+		if err != nil {
+			return numPromoted, err
+		}
+
+		numPromoted += moved
+	}
+
+	return numPromoted, nil
+}
+
+func (r *RedisCoordinatorClient) GetDeadTasks(ctx context.Context, topic CoordinatorClientTaskTopic) ([]*StoredError, error) {
+	deadTaskStrings, err := r.redisClient.LRange(ctx, topic.DeadTopicString(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	deadTasks := make([]*StoredError, 0, len(deadTaskStrings))
+	for _, deadTaskString := range deadTaskStrings {
+		var deadTask StoredError
+		if err := json.Unmarshal([]byte(deadTaskString), &deadTask); err != nil {
+			return nil, err
+		}
+		deadTasks = append(deadTasks, &deadTask)
+	}
+
+	return deadTasks, nil
+}
+
+func (r *RedisCoordinatorClient) RequeueDeadTasks(ctx context.Context, topic CoordinatorClientTaskTopic, taskIds []string) (int, error) {
+	return requeueDeadTasks(ctx, r.redisClient, moveScript, topic.String(), topic, taskIds)
+}
+
+// requeueDeadTasks moves the matching dead tasks of topic onto destination with script, which takes
+// the same keys and arguments as moveScript
+func requeueDeadTasks(ctx context.Context, redisClient *redis.Client, script *redis.Script, destination string, topic CoordinatorClientTaskTopic, taskIds []string) (int, error) {
+	deadTaskStrings, err := redisClient.LRange(ctx, topic.DeadTopicString(), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	numRequeued := 0
+	for _, deadTaskString := range deadTaskStrings {
+		var deadTask StoredError
+		if err := json.Unmarshal([]byte(deadTaskString), &deadTask); err != nil {
+			return numRequeued, err
+		}
+
+		if deadTask.Task == nil || (len(taskIds) > 0 && !slices.Contains(taskIds, deadTask.Task.ID)) {
+			continue
+		}
+
+		deadTask.Task.Attempts = 0
+		taskString, err := deadTask.Task.toString()
+		if err != nil {
+			return numRequeued, err
+		}
+
+		keys := []string{destination, topic.DeadTopicString()}
+		moved, err := script.Run(ctx, redisClient, keys, deadTaskString, taskString, "list").Int()
+		if err != nil {
+			return numRequeued, err
+		}
+
+		numRequeued += moved
+	}
+
+	return numRequeued, nil
+}
+
+func (r *RedisCoordinatorClient) SetExtractionRule(ctx context.Context, rule *ExtractionRule) error {
+	ruleString, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.HSet(ctx, extractionRulesKey, rule.Domain, ruleString).Err()
+}
+
+func (r *RedisCoordinatorClient) GetExtractionRule(ctx context.Context, domain string) (*ExtractionRule, error) {
+	ruleString, err := r.redisClient.HGet(ctx, extractionRulesKey, domain).Result()
+	if err == redis.Nil {
+		return nil, ErrExtractionRuleNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rule ExtractionRule
+	if err := json.Unmarshal([]byte(ruleString), &rule); err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (r *RedisCoordinatorClient) GetExtractionRules(ctx context.Context) ([]*ExtractionRule, error) {
+	ruleStrings, err := r.redisClient.HGetAll(ctx, extractionRulesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*ExtractionRule, 0, len(ruleStrings))
+	for _, ruleString := range ruleStrings {
+		var rule ExtractionRule
+		if err := json.Unmarshal([]byte(ruleString), &rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+
+	slices.SortFunc(rules, func(a, b *ExtractionRule) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return rules, nil
+}
+
+func (r *RedisCoordinatorClient) DeleteExtractionRule(ctx context.Context, domain string) error {
+	deleted, err := r.redisClient.HDel(ctx, extractionRulesKey, domain).Result()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrExtractionRuleNotFound
+	}
+
+	return nil
+}
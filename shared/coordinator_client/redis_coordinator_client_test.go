@@ -2,27 +2,21 @@ package coordinator_client
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestMain(m *testing.M) {
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
-
-	os.Exit(m.Run())
-}
-
 func TestRedisCoordinatorClientCreateTask(t *testing.T) {
 	if os.Getenv("CICD") == "true" {
 		t.Skip("Skipping test in CICD")
@@ -388,3 +382,311 @@ func TestRedisCoordinatorClientGetErrors(t *testing.T) {
 	assert.Equal(t, len(errors), 1)
 	assert.Equal(t, errors[0].Error, "an error")
 }
+
+func TestRedisCoordinatorClientJobs(t *testing.T) {
+	if os.Getenv("CICD") == "true" {
+		t.Skip("Skipping test in CICD")
+	}
+
+	client := NewRedisCoordinatorClient(context.Background(), "localhost:6379", "", 0)
+
+	job := &Job{
+		ID:        uuid.New().String(),
+		CreatedBy: "test",
+		Created:   time.Now(),
+		Urls: map[string]*JobUrlProgress{
+			"https://ethanhosier.com": NewJobUrlProgress(JobUrlStatusQueued, nil),
+		},
+	}
+
+	if err := client.CreateJob(context.Background(), job); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+
+	storedJob, err := client.GetJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+
+	assert.Equal(t, job.CreatedBy, storedJob.CreatedBy)
+	assert.Equal(t, JobUrlStatusQueued, storedJob.Urls["https://ethanhosier.com"].Status)
+
+	_, err = client.GetJob(context.Background(), uuid.New().String())
+	assert.Equal(t, ErrJobNotFound, err)
+}
+
+func TestRedisCoordinatorClientMarkVisited(t *testing.T) {
+	if os.Getenv("CICD") == "true" {
+		t.Skip("Skipping test in CICD")
+	}
+
+	client := NewRedisCoordinatorClient(context.Background(), "localhost:6379", "", 0)
+	crawlId := uuid.New().String()
+
+	visited, err := client.MarkVisited(context.Background(), crawlId, "https://ethanhosier.com/", 1)
+	if err != nil {
+		t.Fatalf("Failed to mark visited: %v", err)
+	}
+	assert.True(t, visited)
+
+	visited, err = client.MarkVisited(context.Background(), crawlId, "https://ethanhosier.com/blog", 1)
+	if err != nil {
+		t.Fatalf("Failed to mark visited: %v", err)
+	}
+	assert.False(t, visited)
+}
+
+func newTestRedisCoordinatorClient(t *testing.T) (*RedisCoordinatorClient, *redis.Client) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	return &RedisCoordinatorClient{redisClient: redisClient}, redisClient
+}
+
+// expireLeases moves the lease deadline of every task processing on topic into the past. Leases are
+// timed with the workers' clocks rather than Redis', so miniredis' clock can't be used to expire them.
+func expireLeases(t *testing.T, redisClient *redis.Client, topic CoordinatorClientTaskTopic) {
+	leased, err := redisClient.ZRange(context.Background(), topic.LeasesTopicString(), 0, -1).Result()
+	assert.NoError(t, err)
+
+	for _, taskString := range leased {
+		expired := redis.Z{Score: float64(time.Now().Add(-time.Second).UnixMilli()), Member: taskString}
+		assert.NoError(t, redisClient.ZAdd(context.Background(), topic.LeasesTopicString(), expired).Err())
+	}
+}
+
+func TestRedisCoordinatorClientReapExpiredTasks(t *testing.T) {
+	client, redisClient := newTestRedisCoordinatorClient(t)
+	ctx := context.Background()
+
+	task1, _ := NewTask("1", "test", map[string]string{"url": "https://example.com/1"})
+	task2, _ := NewTask("2", "test", map[string]string{"url": "https://example.com/2"})
+	assert.NoError(t, client.CreateTasks(ctx, CoordinatorClientTaskTopicUrls, []*Task{task1, task2}))
+
+	expiring, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	expireLeases(t, redisClient, CoordinatorClientTaskTopicUrls)
+
+	running, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+
+	numRequeued, err := client.ReapExpiredTasks(ctx, CoordinatorClientTaskTopicUrls, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numRequeued)
+
+	// Only the task whose lease expired is requeued, with its attempt counted
+	requeued, err := client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, expiring.ID, requeued.ID)
+	assert.Equal(t, 1, requeued.Attempts)
+
+	_, err = client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.ErrorIs(t, err, ErrNoTasksToComplete)
+
+	// The worker that lost the task can no longer finish it, while the other still can
+	assert.ErrorIs(t, client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, expiring), ErrNoTasksCompleted)
+	assert.NoError(t, client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, running))
+
+	numLeases, err := redisClient.ZCard(ctx, CoordinatorClientTaskTopicUrls.LeasesTopicString()).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), numLeases)
+}
+
+func TestRedisCoordinatorClientReapUnleasedTasks(t *testing.T) {
+	client, redisClient := newTestRedisCoordinatorClient(t)
+	ctx := context.Background()
+
+	// A worker that died between taking a task and leasing it leaves it processing without a lease
+	task, _ := NewTask("1", "test", map[string]string{"url": "https://example.com"})
+	taskString, err := task.toString()
+	assert.NoError(t, err)
+	assert.NoError(t, redisClient.RPush(ctx, CoordinatorClientTaskTopicUrls.ProcessingTopicString(), taskString).Err())
+
+	// It is given a lease, rather than being requeued straight away
+	numRequeued, err := client.ReapExpiredTasks(ctx, CoordinatorClientTaskTopicUrls, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numRequeued)
+
+	deadline, err := redisClient.ZScore(ctx, CoordinatorClientTaskTopicUrls.LeasesTopicString(), taskString).Result()
+	assert.NoError(t, err)
+	assert.Greater(t, deadline, float64(time.Now().UnixMilli()))
+
+	// Reaping again leaves the lease as it is
+	numRequeued, err = client.ReapExpiredTasks(ctx, CoordinatorClientTaskTopicUrls, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numRequeued)
+
+	extended, err := redisClient.ZScore(ctx, CoordinatorClientTaskTopicUrls.LeasesTopicString(), taskString).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, deadline, extended)
+
+	expireLeases(t, redisClient, CoordinatorClientTaskTopicUrls)
+	numRequeued, err = client.ReapExpiredTasks(ctx, CoordinatorClientTaskTopicUrls, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numRequeued)
+}
+
+func TestRedisCoordinatorClientReapDeadLettersTasks(t *testing.T) {
+	client, redisClient := newTestRedisCoordinatorClient(t)
+	ctx := context.Background()
+
+	// One task has already killed its worker twice, the other only once
+	failing, _ := NewTask("failing", "test", map[string]string{"url": "https://example.com/1"})
+	failing.Attempts = 2
+	retrying, _ := NewTask("retrying", "test", map[string]string{"url": "https://example.com/2"})
+	retrying.Attempts = 1
+	assert.NoError(t, client.CreateTasks(ctx, CoordinatorClientTaskTopicUrls, []*Task{failing, retrying}))
+
+	for range 2 {
+		_, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+		assert.NoError(t, err)
+	}
+	expireLeases(t, redisClient, CoordinatorClientTaskTopicUrls)
+
+	numReaped, err := client.ReapExpiredTasks(ctx, CoordinatorClientTaskTopicUrls, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, numReaped)
+
+	requeued, err := client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "retrying", requeued.ID)
+	assert.Equal(t, 2, requeued.Attempts)
+
+	_, err = client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.ErrorIs(t, err, ErrNoTasksToComplete)
+
+	deadTasks, err := client.GetDeadTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Len(t, deadTasks, 1)
+	assert.Equal(t, "failing", deadTasks[0].Task.ID)
+	assert.Equal(t, ErrLeaseExpired.Error(), deadTasks[0].Error)
+
+	numLeases, err := redisClient.ZCard(ctx, CoordinatorClientTaskTopicUrls.LeasesTopicString()).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), numLeases)
+}
+
+// TestRedisCoordinatorClientSetProcessedRacesReap finishes tasks as their expired leases are reaped.
+// Whichever gets to a task first wins, so each is either processed or requeued, never both or neither.
+func TestRedisCoordinatorClientSetProcessedRacesReap(t *testing.T) {
+	client, redisClient := newTestRedisCoordinatorClient(t)
+	ctx := context.Background()
+	topic := CoordinatorClientTaskTopicUrls
+
+	for i := 0; i < 20; i++ {
+		task, _ := NewTask(strconv.Itoa(i), "test", map[string]string{"url": "https://example.com"})
+		assert.NoError(t, client.CreateTask(ctx, topic, task))
+
+		processing, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, topic)
+		assert.NoError(t, err)
+		expireLeases(t, redisClient, topic)
+
+		var (
+			wg          sync.WaitGroup
+			processErr  error
+			numRequeued int
+			reapErr     error
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			processErr = client.SetProcessed(ctx, topic, processing)
+		}()
+		go func() {
+			defer wg.Done()
+			numRequeued, reapErr = client.ReapExpiredTasks(ctx, topic, 0)
+		}()
+		wg.Wait()
+
+		assert.NoError(t, reapErr)
+		numTasks, _ := client.NumTasks(ctx, topic)
+		numProcessing, _ := client.NumProcessingTasks(ctx, topic)
+		assert.Equal(t, 0, numProcessing)
+
+		if processErr == nil {
+			assert.Equal(t, 0, numRequeued, "a processed task shouldn't be requeued")
+			assert.Equal(t, 0, numTasks)
+		} else {
+			assert.ErrorIs(t, processErr, ErrNoTasksCompleted)
+			assert.Equal(t, 1, numRequeued)
+			assert.Equal(t, 1, numTasks)
+
+			requeued, err := client.GetTask(ctx, 10*time.Millisecond, topic)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, requeued.Attempts)
+		}
+
+		numLeases, err := redisClient.ZCard(ctx, topic.LeasesTopicString()).Result()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), numLeases, "no lease should outlive its task")
+	}
+}
+
+func TestRedisCoordinatorClientPromoteDelayedTasks(t *testing.T) {
+	client, _ := newTestRedisCoordinatorClient(t)
+	ctx := context.Background()
+
+	dueTask, _ := NewTask("due", "test", map[string]string{"url": "https://example.com/1"})
+	dueTask.Attempts = 1
+	laterTask, _ := NewTask("later", "test", map[string]string{"url": "https://example.com/2"})
+	laterTask.Attempts = 1
+	assert.NoError(t, client.RetryTask(ctx, CoordinatorClientTaskTopicUrls, dueTask, time.Now().Add(-time.Second)))
+	assert.NoError(t, client.RetryTask(ctx, CoordinatorClientTaskTopicUrls, laterTask, time.Now().Add(time.Hour)))
+
+	numPromoted, err := client.PromoteDelayedTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numPromoted)
+
+	promoted, err := client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "due", promoted.ID)
+	assert.Equal(t, 1, promoted.Attempts)
+
+	// The task that isn't due yet stays delayed, and a due task is only promoted once
+	numPromoted, err = client.PromoteDelayedTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numPromoted)
+
+	numTasks, err := client.NumTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numTasks)
+}
+
+func TestRedisCoordinatorClientRequeueDeadTasks(t *testing.T) {
+	client, _ := newTestRedisCoordinatorClient(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"1", "2"} {
+		task, _ := NewTask(id, "test", map[string]string{"url": "https://example.com/" + id})
+		task.Attempts = 2
+		assert.NoError(t, client.DeadLetterTask(ctx, CoordinatorClientTaskTopicUrls, task, errors.New("failed")))
+	}
+
+	deadTasks, err := client.GetDeadTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Len(t, deadTasks, 2)
+	assert.Equal(t, "failed", deadTasks[0].Error)
+
+	// Unknown ids are ignored
+	numRequeued, err := client.RequeueDeadTasks(ctx, CoordinatorClientTaskTopicUrls, []string{"unknown"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numRequeued)
+
+	numRequeued, err = client.RequeueDeadTasks(ctx, CoordinatorClientTaskTopicUrls, []string{"2", "unknown"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numRequeued)
+
+	requeued, err := client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", requeued.ID)
+	assert.Equal(t, 0, requeued.Attempts)
+
+	_, err = client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.ErrorIs(t, err, ErrNoTasksToComplete)
+
+	deadTasks, err = client.GetDeadTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Len(t, deadTasks, 1)
+	assert.Equal(t, "1", deadTasks[0].Task.ID)
+}
@@ -0,0 +1,304 @@
+package coordinator_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// streamsGroup is the consumer group every client reads a topic's stream through, so each entry
+	// is only delivered to one consumer
+	streamsGroup = "workers"
+
+	// streamsReaper is the consumer expired entries are claimed by while they are requeued
+	streamsReaper = "reaper"
+
+	streamsReapBatchSize = 100
+)
+
+var streamsTopics = []CoordinatorClientTaskTopic{
+	CoordinatorClientTaskTopicUrls,
+	CoordinatorClientTaskTopicRag,
+}
+
+// moveToStreamScript is moveScript for streams: it removes ARGV[1] from KEYS[2] (a sorted set if
+// ARGV[3] is "zset", otherwise a list) and adds ARGV[2] to the stream KEYS[1] if it was there
+var moveToStreamScript = redis.NewScript(`
+local removed
+if ARGV[3] == "zset" then
+	removed = redis.call("ZREM", KEYS[2], ARGV[1])
+else
+	removed = redis.call("LREM", KEYS[2], 1, ARGV[1])
+end
+if removed == 1 then
+	redis.call("XADD", KEYS[1], "*", "task", ARGV[2])
+end
+return removed
+`)
+
+// requeueEntryScript acknowledges the entry ARGV[2] of the stream KEYS[1] for group ARGV[1] and, if it
+// was still pending, adds ARGV[3] to the stream in its place. A task acknowledged by its worker in the
+// meantime is left alone.
+var requeueEntryScript = redis.NewScript(`
+local acked = redis.call("XACK", KEYS[1], ARGV[1], ARGV[2])
+if acked == 1 then
+	redis.call("XADD", KEYS[1], "*", "task", ARGV[3])
+	redis.call("XDEL", KEYS[1], ARGV[2])
+end
+return acked
+`)
+
+// deadLetterEntryScript acknowledges and deletes the entry ARGV[2] of the stream KEYS[1] for group
+// ARGV[1] and, if it was still pending, pushes ARGV[3] onto the dead letter list KEYS[2]
+var deadLetterEntryScript = redis.NewScript(`
+local acked = redis.call("XACK", KEYS[1], ARGV[1], ARGV[2])
+if acked == 1 then
+	redis.call("RPUSH", KEYS[2], ARGV[3])
+	redis.call("XDEL", KEYS[1], ARGV[2])
+end
+return acked
+`)
+
+// RedisStreamsCoordinatorClient keeps each topic in a Redis stream read through a consumer group
+// rather than a list. Tasks are acknowledged by the id of the entry they were read from, and each
+// client has its own pending list that the reaper claims expired entries from.
+//
+// Errors, retries, dead tasks, visited pages and jobs are stored the same way as
+// RedisCoordinatorClient.
+type RedisStreamsCoordinatorClient struct {
+	*RedisCoordinatorClient
+	consumer string
+}
+
+func NewRedisStreamsCoordinatorClient(ctx context.Context, address string, password string, db int) *RedisStreamsCoordinatorClient {
+	return newRedisStreamsCoordinatorClient(ctx, NewRedisCoordinatorClient(ctx, address, password, db).redisClient)
+}
+
+func newRedisStreamsCoordinatorClient(ctx context.Context, redisClient *redis.Client) *RedisStreamsCoordinatorClient {
+	for _, topic := range streamsTopics {
+		err := redisClient.XGroupCreateMkStream(ctx, topic.StreamTopicString(), streamsGroup, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			panic(err)
+		}
+	}
+
+	return &RedisStreamsCoordinatorClient{
+		RedisCoordinatorClient: &RedisCoordinatorClient{redisClient: redisClient},
+		consumer:               uuid.New().String(),
+	}
+}
+
+func (r *RedisStreamsCoordinatorClient) CreateTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	return r.CreateTasks(ctx, topic, []*Task{task})
+}
+
+func (r *RedisStreamsCoordinatorClient) CreateTasks(ctx context.Context, topic CoordinatorClientTaskTopic, tasks []*Task) error {
+	pipe := r.redisClient.TxPipeline()
+	for _, task := range tasks {
+		taskString, err := task.toString()
+		if err != nil {
+			return err
+		}
+
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: topic.StreamTopicString(),
+			Values: []string{"task", taskString},
+		})
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisStreamsCoordinatorClient) GetTask(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic) (*Task, error) {
+	task, err := r.readTask(ctx, timeout, topic, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.redisClient.XDel(ctx, topic.StreamTopicString(), task.receipt).Err(); err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (r *RedisStreamsCoordinatorClient) GetTaskAndSetProcessing(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic) (*Task, error) {
+	return r.readTask(ctx, timeout, topic, false)
+}
+
+func (r *RedisStreamsCoordinatorClient) SetProcessed(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	if task.receipt == "" {
+		return ErrNoTasksCompleted
+	}
+
+	pipe := r.redisClient.TxPipeline()
+	cmd := pipe.XAck(ctx, topic.StreamTopicString(), streamsGroup, task.receipt)
+	pipe.XDel(ctx, topic.StreamTopicString(), task.receipt)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	if cmd.Val() == 0 {
+		return ErrNoTasksCompleted
+	}
+
+	return nil
+}
+
+func (r *RedisStreamsCoordinatorClient) RequeueTask(ctx context.Context, topic CoordinatorClientTaskTopic, task *Task) error {
+	if task.receipt == "" {
+		return ErrNoTasksCompleted
+	}
+
+	taskString, err := task.toString()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{topic.StreamTopicString()}
+	requeued, err := requeueEntryScript.Run(ctx, r.redisClient, keys, streamsGroup, task.receipt, taskString).Int()
+	if err != nil {
+		return err
+	}
+
+	if requeued == 0 {
+		return ErrNoTasksCompleted
+	}
+
+	return nil
+}
+
+func (r *RedisStreamsCoordinatorClient) NumTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	numEntries, err := r.redisClient.XLen(ctx, topic.StreamTopicString()).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// Processed entries are deleted, so everything left in the stream is either waiting or pending
+	numProcessingTasks, err := r.NumProcessingTasks(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(numEntries) - numProcessingTasks, nil
+}
+
+func (r *RedisStreamsCoordinatorClient) NumProcessingTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	pending, err := r.redisClient.XPending(ctx, topic.StreamTopicString(), streamsGroup).Result()
+	if err != nil {
+		return 0, err
+	}
+	return int(pending.Count), nil
+}
+
+func (r *RedisStreamsCoordinatorClient) ReapExpiredTasks(ctx context.Context, topic CoordinatorClientTaskTopic, maxAttempts int) (int, error) {
+	numReaped := 0
+	start := "0-0"
+	for {
+		// Claiming resets an entry's idle time, so concurrent reapers never claim the same one
+		messages, next, err := r.redisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   topic.StreamTopicString(),
+			Group:    streamsGroup,
+			Consumer: streamsReaper,
+			MinIdle:  LeaseDuration,
+			Start:    start,
+			Count:    streamsReapBatchSize,
+		}).Result()
+		if err != nil {
+			return numReaped, err
+		}
+
+		for _, message := range messages {
+			if message.Values == nil {
+				// The entry was deleted while it was pending, so there is nothing left to requeue
+				if err := r.redisClient.XAck(ctx, topic.StreamTopicString(), streamsGroup, message.ID).Err(); err != nil {
+					return numReaped, err
+				}
+				continue
+			}
+
+			task, err := taskFromMessage(message)
+			if err != nil {
+				return numReaped, err
+			}
+
+			destination, reapedString, err := reapedTaskString(topic, task, maxAttempts)
+			if err != nil {
+				return numReaped, err
+			}
+
+			script, keys := requeueEntryScript, []string{topic.StreamTopicString()}
+			if destination == topic.DeadTopicString() {
+				script, keys = deadLetterEntryScript, []string{topic.StreamTopicString(), destination}
+			}
+
+			reaped, err := script.Run(ctx, r.redisClient, keys, streamsGroup, message.ID, reapedString).Int()
+			if err != nil {
+				return numReaped, err
+			}
+
+			numReaped += reaped
+		}
+
+		if next == "0-0" || next == "" {
+			return numReaped, nil
+		}
+		start = next
+	}
+}
+
+func (r *RedisStreamsCoordinatorClient) PromoteDelayedTasks(ctx context.Context, topic CoordinatorClientTaskTopic) (int, error) {
+	return promoteDelayedTasks(ctx, r.redisClient, moveToStreamScript, topic.StreamTopicString(), topic)
+}
+
+func (r *RedisStreamsCoordinatorClient) RequeueDeadTasks(ctx context.Context, topic CoordinatorClientTaskTopic, taskIds []string) (int, error) {
+	return requeueDeadTasks(ctx, r.redisClient, moveToStreamScript, topic.StreamTopicString(), topic, taskIds)
+}
+
+// readTask reads the next new entry of the topic's stream for this consumer. Unless noAck is set the
+// entry stays in the consumer's pending list until it is acknowledged.
+func (r *RedisStreamsCoordinatorClient) readTask(ctx context.Context, timeout time.Duration, topic CoordinatorClientTaskTopic, noAck bool) (*Task, error) {
+	streams, err := r.redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    streamsGroup,
+		Consumer: r.consumer,
+		Streams:  []string{topic.StreamTopicString(), ">"},
+		Count:    1,
+		Block:    timeout,
+		NoAck:    noAck,
+	}).Result()
+	if err == redis.Nil {
+		return nil, ErrNoTasksToComplete
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, ErrNoTasksToComplete
+	}
+
+	return taskFromMessage(streams[0].Messages[0])
+}
+
+func taskFromMessage(message redis.XMessage) (*Task, error) {
+	taskString, ok := message.Values["task"].(string)
+	if !ok {
+		return nil, fmt.Errorf("stream entry %s has no task", message.ID)
+	}
+
+	var task Task
+	if err := json.Unmarshal([]byte(taskString), &task); err != nil {
+		return nil, err
+	}
+	task.receipt = message.ID
+
+	return &task, nil
+}
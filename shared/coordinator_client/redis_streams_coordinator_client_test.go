@@ -0,0 +1,268 @@
+package coordinator_client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisStreamsCoordinatorClient(t *testing.T) (*RedisStreamsCoordinatorClient, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	return newRedisStreamsCoordinatorClient(context.Background(), redisClient), mr
+}
+
+func TestRedisStreamsCoordinatorClientProcessTask(t *testing.T) {
+	client, _ := newTestRedisStreamsCoordinatorClient(t)
+	ctx := context.Background()
+
+	task1, _ := NewTask("1", "test", map[string]string{"url": "https://example.com/1"})
+	task2, _ := NewTask("2", "test", map[string]string{"url": "https://example.com/2"})
+	assert.NoError(t, client.CreateTasks(ctx, CoordinatorClientTaskTopicUrls, []*Task{task1, task2}))
+
+	task, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", task.ID)
+
+	numTasks, _ := client.NumTasks(ctx, CoordinatorClientTaskTopicUrls)
+	numProcessingTasks, _ := client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, 1, numTasks)
+	assert.Equal(t, 1, numProcessingTasks)
+
+	// The task is acknowledged by its entry id, so changes to it don't matter
+	task.Params["extra"] = "value"
+	assert.NoError(t, client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, task))
+
+	err = client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, task)
+	assert.True(t, errors.Is(err, ErrNoTasksCompleted))
+
+	numTasks, _ = client.NumTasks(ctx, CoordinatorClientTaskTopicUrls)
+	numProcessingTasks, _ = client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, 1, numTasks)
+	assert.Equal(t, 0, numProcessingTasks)
+
+	// A task that wasn't read from the stream can't be acknowledged
+	err = client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, task2)
+	assert.True(t, errors.Is(err, ErrNoTasksCompleted))
+}
+
+func TestRedisStreamsCoordinatorClientGetTask(t *testing.T) {
+	client, _ := newTestRedisStreamsCoordinatorClient(t)
+	ctx := context.Background()
+
+	_, err := client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicRag)
+	assert.True(t, errors.Is(err, ErrNoTasksToComplete))
+
+	task, _ := NewTask("1", "test", map[string]string{"url": "https://example.com"})
+	assert.NoError(t, client.CreateTask(ctx, CoordinatorClientTaskTopicRag, task))
+
+	got, err := client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", got.ID)
+
+	numTasks, _ := client.NumTasks(ctx, CoordinatorClientTaskTopicRag)
+	numProcessingTasks, _ := client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicRag)
+	assert.Equal(t, 0, numTasks)
+	assert.Equal(t, 0, numProcessingTasks)
+}
+
+func TestRedisStreamsCoordinatorClientConsumers(t *testing.T) {
+	client1, mr := newTestRedisStreamsCoordinatorClient(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	client2 := newRedisStreamsCoordinatorClient(context.Background(), redisClient)
+	ctx := context.Background()
+
+	task1, _ := NewTask("1", "test", map[string]string{"url": "https://example.com/1"})
+	task2, _ := NewTask("2", "test", map[string]string{"url": "https://example.com/2"})
+	assert.NoError(t, client1.CreateTasks(ctx, CoordinatorClientTaskTopicUrls, []*Task{task1, task2}))
+
+	got1, err := client1.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	got2, err := client2.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", got1.ID)
+	assert.Equal(t, "2", got2.ID)
+
+	for _, client := range []*RedisStreamsCoordinatorClient{client1, client2} {
+		pending, err := redisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   CoordinatorClientTaskTopicUrls.StreamTopicString(),
+			Group:    streamsGroup,
+			Consumer: client.consumer,
+			Start:    "-",
+			End:      "+",
+			Count:    10,
+		}).Result()
+		assert.NoError(t, err)
+		assert.Len(t, pending, 1)
+	}
+}
+
+func TestRedisStreamsCoordinatorClientReapExpiredTasks(t *testing.T) {
+	client, mr := newTestRedisStreamsCoordinatorClient(t)
+	ctx := context.Background()
+
+	task, _ := NewTask("1", "test", map[string]string{"url": "https://example.com"})
+	assert.NoError(t, client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task))
+
+	processing, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+
+	numRequeued, err := client.ReapExpiredTasks(ctx, CoordinatorClientTaskTopicUrls, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numRequeued)
+
+	mr.SetTime(time.Now().Add(LeaseDuration + time.Minute))
+
+	numRequeued, err = client.ReapExpiredTasks(ctx, CoordinatorClientTaskTopicUrls, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numRequeued)
+
+	// The worker that lost the task can no longer acknowledge it
+	err = client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, processing)
+	assert.True(t, errors.Is(err, ErrNoTasksCompleted))
+
+	requeued, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", requeued.ID)
+	assert.Equal(t, 1, requeued.Attempts)
+	assert.NoError(t, client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, requeued))
+}
+
+func TestRedisStreamsCoordinatorClientReapDeadLettersTasks(t *testing.T) {
+	client, mr := newTestRedisStreamsCoordinatorClient(t)
+	ctx := context.Background()
+
+	// A task that has already killed its worker twice
+	task, _ := NewTask("1", "test", map[string]string{"url": "https://example.com"})
+	task.Attempts = 2
+	assert.NoError(t, client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task))
+
+	_, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+
+	mr.SetTime(time.Now().Add(LeaseDuration + time.Minute))
+
+	numReaped, err := client.ReapExpiredTasks(ctx, CoordinatorClientTaskTopicUrls, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numReaped)
+
+	numProcessing, err := client.NumProcessingTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numProcessing)
+
+	_, err = client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.ErrorIs(t, err, ErrNoTasksToComplete)
+
+	deadTasks, err := client.GetDeadTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Len(t, deadTasks, 1)
+	assert.Equal(t, "1", deadTasks[0].Task.ID)
+	assert.Equal(t, ErrLeaseExpired.Error(), deadTasks[0].Error)
+}
+
+func TestRedisStreamsCoordinatorClientRetries(t *testing.T) {
+	client, _ := newTestRedisStreamsCoordinatorClient(t)
+	ctx := context.Background()
+
+	task, _ := NewTask("1", "test", map[string]string{"url": "https://example.com"})
+	task.Attempts = 1
+	assert.NoError(t, client.RetryTask(ctx, CoordinatorClientTaskTopicUrls, task, time.Now().Add(-time.Second)))
+
+	numPromoted, err := client.PromoteDelayedTasks(ctx, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numPromoted)
+
+	retried, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, retried.Attempts)
+
+	assert.NoError(t, client.DeadLetterTask(ctx, CoordinatorClientTaskTopicUrls, retried, errors.New("failed")))
+	assert.NoError(t, client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, retried))
+
+	numRequeued, err := client.RequeueDeadTasks(ctx, CoordinatorClientTaskTopicUrls, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, numRequeued)
+
+	requeued, err := client.GetTask(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", requeued.ID)
+	assert.Equal(t, 0, requeued.Attempts)
+}
+
+func TestRedisStreamsCoordinatorClientRequeueTask(t *testing.T) {
+	client, _ := newTestRedisStreamsCoordinatorClient(t)
+	ctx := context.Background()
+
+	task, _ := NewTask("1", "test", map[string]string{"url": "https://example.com"})
+	assert.NoError(t, client.CreateTask(ctx, CoordinatorClientTaskTopicUrls, task))
+
+	processing, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.NoError(t, client.RequeueTask(ctx, CoordinatorClientTaskTopicUrls, processing))
+
+	err = client.SetProcessed(ctx, CoordinatorClientTaskTopicUrls, processing)
+	assert.True(t, errors.Is(err, ErrNoTasksCompleted))
+
+	requeued, err := client.GetTaskAndSetProcessing(ctx, 10*time.Millisecond, CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", requeued.ID)
+	assert.Equal(t, 0, requeued.Attempts)
+}
+
+func TestRedisStreamsCoordinatorClientReserveHost(t *testing.T) {
+	client, mr := newTestRedisStreamsCoordinatorClient(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	mr.SetTime(now)
+
+	for i := 0; i < 3; i++ {
+		wait, err := client.ReserveHost(ctx, "example.com", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(i)*time.Second, wait, "requests should be spaced an interval apart")
+	}
+
+	wait, err := client.ReserveHost(ctx, "other.com", time.Second)
+	assert.NoError(t, err)
+	assert.Zero(t, wait, "hosts should not share slots")
+
+	mr.SetTime(now.Add(5 * time.Second))
+	wait, err = client.ReserveHost(ctx, "example.com", time.Second)
+	assert.NoError(t, err)
+	assert.Zero(t, wait, "slots in the past should not make the caller wait")
+}
+
+func TestRedisStreamsCoordinatorClientExtractionRules(t *testing.T) {
+	client, _ := newTestRedisStreamsCoordinatorClient(t)
+	ctx := context.Background()
+
+	_, err := client.GetExtractionRule(ctx, "example.com")
+	assert.Equal(t, ErrExtractionRuleNotFound, err)
+
+	rule := &ExtractionRule{Domain: "example.com", Selectors: []string{"article"}, ExcludeSelectors: []string{".cookie-banner"}, Updated: time.Now().UTC()}
+	assert.NoError(t, client.SetExtractionRule(ctx, rule))
+	assert.NoError(t, client.SetExtractionRule(ctx, &ExtractionRule{Domain: "blog.com"}))
+
+	storedRule, err := client.GetExtractionRule(ctx, "example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, rule.Selectors, storedRule.Selectors)
+	assert.Equal(t, rule.ExcludeSelectors, storedRule.ExcludeSelectors)
+	assert.True(t, rule.Updated.Equal(storedRule.Updated))
+
+	rules, err := client.GetExtractionRules(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "blog.com", rules[0].Domain)
+	assert.Equal(t, "example.com", rules[1].Domain)
+
+	assert.NoError(t, client.DeleteExtractionRule(ctx, "example.com"))
+	assert.Equal(t, ErrExtractionRuleNotFound, client.DeleteExtractionRule(ctx, "example.com"))
+}
@@ -0,0 +1,97 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is the kind of file a page is
+type Format string
+
+const (
+	FormatHtml     Format = "html"
+	FormatPdf      Format = "pdf"
+	FormatDocx     Format = "docx"
+	FormatText     Format = "text"
+	FormatMarkdown Format = "markdown"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+var mediaTypeFormats = map[string]Format{
+	"text/html":             FormatHtml,
+	"application/xhtml+xml": FormatHtml,
+	"application/pdf":       FormatPdf,
+	"application/x-pdf":     FormatPdf,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": FormatDocx,
+	"text/plain":      FormatText,
+	"text/markdown":   FormatMarkdown,
+	"text/x-markdown": FormatMarkdown,
+}
+
+var extensionFormats = map[string]Format{
+	".html":     FormatHtml,
+	".htm":      FormatHtml,
+	".pdf":      FormatPdf,
+	".docx":     FormatDocx,
+	".txt":      FormatText,
+	".md":       FormatMarkdown,
+	".markdown": FormatMarkdown,
+}
+
+// Document is the content of a page that isn't html
+type Document struct {
+	Markdown string
+	Text     string
+}
+
+// FormatOf works out the format of a page from its Content-Type, falling back to the extension of its
+// url and then to sniffing its body. Servers often send markdown as plain text and documents as
+// generic binary, so for those content types the extension takes precedence.
+func FormatOf(contentType string, pageUrl string, body []byte) (Format, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if format, ok := mediaTypeFormats[mediaType]; ok && format != FormatText {
+		return format, nil
+	}
+
+	if parsedUrl, err := url.Parse(pageUrl); err == nil {
+		if format, ok := extensionFormats[strings.ToLower(path.Ext(parsedUrl.Path))]; ok {
+			return format, nil
+		}
+	}
+
+	if mediaType == "text/plain" {
+		return FormatText, nil
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	if format, ok := mediaTypeFormats[sniffed]; ok {
+		return format, nil
+	}
+
+	return "", fmt.Errorf("%w %s", ErrUnsupportedFormat, contentType)
+}
+
+// ExtractDocument returns the content of a page that isn't html
+func ExtractDocument(format Format, body []byte) (*Document, error) {
+	switch format {
+	case FormatPdf:
+		return pdfDocument(body)
+	case FormatDocx:
+		return docxDocument(body)
+	case FormatText, FormatMarkdown:
+		if !utf8.Valid(body) {
+			return nil, fmt.Errorf("%s is not valid utf-8", format)
+		}
+		text := strings.TrimSpace(string(body))
+		return &Document{Markdown: text, Text: text}, nil
+	default:
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedFormat, format)
+	}
+}
@@ -0,0 +1,130 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPdf builds a pdf with a page for each of pages, written in a standard font
+func testPdf(pages ...string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // The page tree, once the pages are known
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var kids string
+	for _, text := range pages {
+		content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", len(objects)))
+		kids += fmt.Sprintf("%d 0 R ", len(objects))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages))
+
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+	buf.WriteString("%PDF-1.4\n")
+	for i, object := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+// testDocx builds a docx whose document.xml has body as its body
+func testDocx(t *testing.T, body string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	file, err := archive.Create("word/document.xml")
+	assert.NoError(t, err)
+
+	_, err = file.Write([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` + body + `</w:body></w:document>`))
+	assert.NoError(t, err)
+	assert.NoError(t, archive.Close())
+
+	return buf.Bytes()
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		contentType string
+		url         string
+		body        string
+		expected    Format
+	}{
+		{"text/html; charset=utf-8", "https://example.com", "", FormatHtml},
+		{"application/pdf", "https://example.com/download?id=1", "", FormatPdf},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "https://example.com/file", "", FormatDocx},
+		{"text/markdown", "https://example.com/README", "", FormatMarkdown},
+		{"text/plain; charset=utf-8", "https://example.com/notes.txt", "", FormatText},
+		{"text/plain", "https://example.com/README.md", "", FormatMarkdown},
+		{"application/octet-stream", "https://example.com/report.PDF", "", FormatPdf},
+		{"", "https://example.com/", "<!DOCTYPE html><html></html>", FormatHtml},
+		{"application/octet-stream", "https://example.com/file", "%PDF-1.4", FormatPdf},
+	}
+
+	for _, test := range tests {
+		format, err := FormatOf(test.contentType, test.url, []byte(test.body))
+		assert.NoError(t, err, test.url)
+		assert.Equal(t, test.expected, format, test.url)
+	}
+
+	_, err := FormatOf("image/png", "https://example.com/logo", []byte("\x89PNG\r\n\x1a\n"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestExtractDocumentPdf(t *testing.T) {
+	doc, err := ExtractDocument(FormatPdf, testPdf("First page", "Second page"))
+	assert.NoError(t, err)
+	assert.Equal(t, "First page\n\nSecond page", doc.Markdown)
+	assert.Equal(t, doc.Markdown, doc.Text)
+
+	_, err = ExtractDocument(FormatPdf, []byte("%PDF-1.4 truncated"))
+	assert.Error(t, err)
+}
+
+func TestExtractDocumentDocx(t *testing.T) {
+	body := testDocx(t, `
+		<w:p><w:pPr><w:pStyle w:val="Heading2"/></w:pPr><w:r><w:t>Opening hours</w:t></w:r></w:p>
+		<w:p><w:r><w:t xml:space="preserve">Open </w:t></w:r><w:r><w:t>daily</w:t></w:r></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>Mornings</w:t></w:r></w:p>
+		<w:p></w:p>
+	`)
+
+	doc, err := ExtractDocument(FormatDocx, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "## Opening hours\n\nOpen daily\n\n- Mornings", doc.Markdown)
+	assert.Equal(t, "Opening hours\nOpen daily\nMornings", doc.Text)
+
+	_, err = ExtractDocument(FormatDocx, []byte("not a zip"))
+	assert.Error(t, err)
+}
+
+func TestExtractDocumentText(t *testing.T) {
+	doc, err := ExtractDocument(FormatMarkdown, []byte("# Title\n\nSome text\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# Title\n\nSome text", doc.Markdown)
+
+	_, err = ExtractDocument(FormatText, []byte{0xff, 0xfe})
+	assert.Error(t, err)
+
+	_, err = ExtractDocument(FormatHtml, []byte("<html></html>"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,142 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const wordNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+// docxParagraph is a paragraph of a docx, along with what it needs to be written as markdown
+type docxParagraph struct {
+	text     strings.Builder
+	heading  int
+	listItem bool
+}
+
+// docxDocument extracts the paragraphs of a docx, writing headings and list items as markdown
+func docxDocument(body []byte) (*Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docx: %w", err)
+	}
+
+	file, err := archive.Open("word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docx: %w", err)
+	}
+	defer file.Close()
+
+	var (
+		decoder    = xml.NewDecoder(file)
+		paragraph  *docxParagraph
+		inText     bool
+		markdown   []string
+		paragraphs []string
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse docx: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+
+			switch t.Name.Local {
+			case "p":
+				paragraph = &docxParagraph{}
+			case "t":
+				inText = true
+			case "tab":
+				if paragraph != nil {
+					paragraph.text.WriteString("\t")
+				}
+			case "br", "cr":
+				if paragraph != nil {
+					paragraph.text.WriteString("\n")
+				}
+			case "pStyle":
+				if paragraph != nil {
+					paragraph.heading = headingLevel(wordAttr(t, "val"))
+				}
+			case "numPr":
+				if paragraph != nil {
+					paragraph.listItem = true
+				}
+			}
+		case xml.CharData:
+			if inText && paragraph != nil {
+				paragraph.text.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if paragraph == nil {
+					continue
+				}
+
+				if text := strings.TrimSpace(paragraph.text.String()); text != "" {
+					paragraphs = append(paragraphs, text)
+					markdown = append(markdown, paragraph.markdown(text))
+				}
+				paragraph = nil
+			}
+		}
+	}
+
+	return &Document{
+		Markdown: strings.Join(markdown, "\n\n"),
+		Text:     strings.Join(paragraphs, "\n"),
+	}, nil
+}
+
+func (p *docxParagraph) markdown(text string) string {
+	switch {
+	case p.heading > 0:
+		return strings.Repeat("#", p.heading) + " " + text
+	case p.listItem:
+		return "- " + text
+	default:
+		return text
+	}
+}
+
+func wordAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// headingLevel returns the level of the heading a paragraph style is, or 0 if it isn't one
+func headingLevel(style string) int {
+	if style == "Title" {
+		return 1
+	}
+
+	var level int
+	if _, err := fmt.Sscanf(style, "Heading%d", &level); err != nil || level < 1 || level > 6 {
+		return 0
+	}
+	return level
+}
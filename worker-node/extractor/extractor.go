@@ -0,0 +1,225 @@
+package extractor
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// Rule says where a page's content is. Selectors pick out the content, and anything matching
+// ExcludeSelectors is removed before it is extracted. Without selectors, or if none of them match,
+// the content is found by scoring the page.
+type Rule struct {
+	Selectors        []string
+	ExcludeSelectors []string
+}
+
+const (
+	// minParagraphLength is the length below which a block of text isn't counted towards the score of
+	// the element containing it
+	minParagraphLength = 25
+)
+
+var (
+	// nonContent never holds content, whatever the rule
+	nonContent = "script, style, noscript, template, iframe, object, embed, svg, canvas"
+
+	// boilerplate is removed before the page is scored
+	boilerplate = "nav, footer, aside, form, button, dialog, select, [role=navigation], [role=banner], [role=contentinfo], [role=dialog], [role=alertdialog], [aria-hidden=true], [hidden]"
+
+	unlikelyCandidates = regexp.MustCompile(`(?i)cookie|consent|gdpr|banner|popup|modal|newsletter|subscribe|share|social|breadcrumb|sidebar|comment|advert|sponsor|promo|related|footer|header|menu|nav|skip`)
+	maybeCandidate     = regexp.MustCompile(`(?i)article|body|column|content|main|post|entry|story|text`)
+
+	positiveWeight = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	negativeWeight = regexp.MustCompile(`(?i)comment|footer|footnote|masthead|meta|sidebar|sponsor|nav|menu|share|social|cookie|consent|banner|promo|related|widget|advert`)
+)
+
+// Extract returns the html of the main content of page
+func Extract(page string, rule Rule) (string, error) {
+	if err := validateSelectors(rule.Selectors); err != nil {
+		return "", err
+	}
+	if err := validateSelectors(rule.ExcludeSelectors); err != nil {
+		return "", err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		return "", err
+	}
+
+	doc.Find(nonContent).Remove()
+	if len(rule.ExcludeSelectors) > 0 {
+		doc.Find(strings.Join(rule.ExcludeSelectors, ", ")).Remove()
+	}
+
+	if len(rule.Selectors) > 0 {
+		if selected := doc.Find(strings.Join(rule.Selectors, ", ")); selected.Length() > 0 {
+			return outerHtml(outermost(selected))
+		}
+	}
+
+	removeBoilerplate(doc)
+
+	content := topCandidate(doc)
+	if content == nil {
+		content = doc.Find("body")
+	}
+
+	return content.Html()
+}
+
+func validateSelectors(selectors []string) error {
+	for _, selector := range selectors {
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			return fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+	}
+	return nil
+}
+
+// removeBoilerplate strips navigation, footers, cookie banners and the like, judging them by their
+// tag, role and class or id
+func removeBoilerplate(doc *goquery.Document) {
+	doc.Find(boilerplate).Remove()
+
+	// A header inside the content usually holds its title, so only page headers are removed
+	doc.Find("header").Each(func(_ int, s *goquery.Selection) {
+		if s.Closest("article, main").Length() == 0 {
+			s.Remove()
+		}
+	})
+
+	doc.Find("body *").Each(func(_ int, s *goquery.Selection) {
+		if s.Is("article, main") {
+			return
+		}
+
+		matchString := classAndId(s)
+		if unlikelyCandidates.MatchString(matchString) && !maybeCandidate.MatchString(matchString) {
+			s.Remove()
+		}
+	})
+}
+
+// topCandidate scores the elements that contain blocks of text and returns the best one. A block adds
+// to the score of its parent, and half as much to its grandparent, and an element's score is reduced by
+// how much of its text is links.
+func topCandidate(doc *goquery.Document) *goquery.Selection {
+	scores := make(map[*html.Node]float64)
+	var candidates []*goquery.Selection
+
+	addScore := func(s *goquery.Selection, score float64) {
+		if s.Length() == 0 || s.Is("html") {
+			return
+		}
+
+		node := s.Get(0)
+		if _, ok := scores[node]; !ok {
+			scores[node] = initialScore(s)
+			candidates = append(candidates, s)
+		}
+		scores[node] += score
+	}
+
+	doc.Find("p, pre, blockquote, td, div").Each(func(_ int, s *goquery.Selection) {
+		// A div only counts as a block of text if it isn't just a wrapper around other blocks
+		if s.Is("div") && s.Find("p, pre, blockquote, div, table, ul, ol").Length() > 0 {
+			return
+		}
+
+		text := strings.TrimSpace(s.Text())
+		if len(text) < minParagraphLength {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		addScore(s.Parent(), score)
+		addScore(s.Parent().Parent(), score/2)
+	})
+
+	var (
+		top      *goquery.Selection
+		topScore float64
+	)
+	for _, candidate := range candidates {
+		score := scores[candidate.Get(0)] * (1 - linkDensity(candidate))
+		if top == nil || score > topScore {
+			top, topScore = candidate, score
+		}
+	}
+
+	return top
+}
+
+func initialScore(s *goquery.Selection) float64 {
+	var score float64
+
+	switch goquery.NodeName(s) {
+	case "article", "main":
+		score = 10
+	case "div":
+		score = 5
+	case "pre", "td", "blockquote":
+		score = 3
+	case "ol", "ul", "li", "dl", "dd", "dt", "form":
+		score = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score = -5
+	}
+
+	matchString := classAndId(s)
+	if positiveWeight.MatchString(matchString) {
+		score += 25
+	}
+	if negativeWeight.MatchString(matchString) {
+		score -= 25
+	}
+
+	return score
+}
+
+// linkDensity is the fraction of the text in s that is inside links
+func linkDensity(s *goquery.Selection) float64 {
+	textLength := len(strings.TrimSpace(s.Text()))
+	if textLength == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkLength += len(strings.TrimSpace(a.Text()))
+	})
+
+	return float64(linkLength) / float64(textLength)
+}
+
+func classAndId(s *goquery.Selection) string {
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	return class + " " + id
+}
+
+// outermost drops the elements of s that are inside other elements of s, so nothing is extracted twice
+func outermost(s *goquery.Selection) *goquery.Selection {
+	return s.FilterFunction(func(_ int, element *goquery.Selection) bool {
+		return element.ParentsFiltered("*").FilterSelection(s).Length() == 0
+	})
+}
+
+func outerHtml(s *goquery.Selection) (string, error) {
+	var sb strings.Builder
+	for i := range s.Nodes {
+		h, err := goquery.OuterHtml(s.Eq(i))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(h)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,94 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+var article = strings.Repeat("The article has a long paragraph of text, with commas, that makes up its content. ", 4)
+
+var page = `
+	<html><body>
+		<header class="site-header"><a href="/">Home</a><a href="/about">About</a></header>
+		<nav><a href="/blog">Blog</a><a href="/contact">Contact</a></nav>
+		<div id="cookie-banner">We use cookies to improve your experience on this website. Accept all cookies?</div>
+		<div class="layout">
+			<div class="post-body">
+				<h1>Title</h1>
+				<p>` + article + `</p>
+				<p>` + article + `</p>
+				<div class="share-buttons">Share this article on all of your favourite social networks</div>
+			</div>
+			<div class="links">
+				<p><a href="/one">A link to another article that is long enough to count</a></p>
+				<p><a href="/two">A link to yet another article that is long enough to count</a></p>
+			</div>
+		</div>
+		<footer>Copyright 2025, all rights reserved by the owners of this website</footer>
+		<script>track()</script>
+	</body></html>
+`
+
+func textOf(t *testing.T, html string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	assert.NoError(t, err)
+	return doc.Text()
+}
+
+func TestExtractScoresMainContent(t *testing.T) {
+	content, err := Extract(page, Rule{})
+	assert.NoError(t, err)
+
+	text := textOf(t, content)
+	assert.Contains(t, text, "Title")
+	assert.Contains(t, text, article)
+	assert.NotContains(t, text, "Home")
+	assert.NotContains(t, text, "Blog")
+	assert.NotContains(t, text, "cookies")
+	assert.NotContains(t, text, "social networks")
+	assert.NotContains(t, text, "another article")
+	assert.NotContains(t, text, "Copyright")
+	assert.NotContains(t, text, "track()")
+}
+
+func TestExtractFallsBackToBody(t *testing.T) {
+	content, err := Extract("<html><body><main>Hello, world!</main><script>track()</script></body></html>", Rule{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!", textOf(t, content))
+}
+
+func TestExtractSelectors(t *testing.T) {
+	content, err := Extract(page, Rule{Selectors: []string{".layout", ".post-body", "footer"}})
+	assert.NoError(t, err)
+
+	// Selected elements are used whole, and elements inside other selected elements only once
+	text := textOf(t, content)
+	assert.Equal(t, 1, strings.Count(text, "Title"))
+	assert.Contains(t, text, "another article")
+	assert.Contains(t, text, "Copyright")
+	assert.Contains(t, text, "social networks")
+
+	content, err = Extract(page, Rule{Selectors: []string{".post-body"}, ExcludeSelectors: []string{"h1", ".share-buttons"}})
+	assert.NoError(t, err)
+
+	text = textOf(t, content)
+	assert.Contains(t, text, article)
+	assert.NotContains(t, text, "Title")
+	assert.NotContains(t, text, "social networks")
+
+	// Selectors that match nothing fall back to scoring
+	content, err = Extract(page, Rule{Selectors: []string{"#missing"}})
+	assert.NoError(t, err)
+	assert.Contains(t, textOf(t, content), article)
+}
+
+func TestExtractInvalidSelector(t *testing.T) {
+	_, err := Extract(page, Rule{Selectors: []string{"div["}})
+	assert.Error(t, err)
+
+	_, err = Extract(page, Rule{ExcludeSelectors: []string{"div["}})
+	assert.Error(t, err)
+}
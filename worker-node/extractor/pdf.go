@@ -0,0 +1,44 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfDocument extracts the text of each page of a pdf, separating pages with blank lines
+func pdfDocument(body []byte) (doc *Document, err error) {
+	// The pdf reader panics on malformed files rather than returning errors
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("malformed pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+
+	var pages []string
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d of pdf: %w", i, err)
+		}
+
+		if text = strings.TrimSpace(text); text != "" {
+			pages = append(pages, text)
+		}
+	}
+
+	text := strings.Join(pages, "\n\n")
+	return &Document{Markdown: text, Text: text}, nil
+}
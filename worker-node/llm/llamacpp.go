@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// LlamaCppGenerator generates replies with the completion endpoint of a llama.cpp server, or a server
+// compatible with it, running a model locally
+type LlamaCppGenerator struct {
+	baseUrl   string
+	maxTokens int
+	client    *http.Client
+}
+
+type llamaCppRequest struct {
+	Prompt      string   `json:"prompt"`
+	Stream      bool     `json:"stream"`
+	NPredict    int      `json:"n_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	CachePrompt bool     `json:"cache_prompt"`
+}
+
+type llamaCppChunk struct {
+	Content string `json:"content"`
+	Stop    bool   `json:"stop"`
+}
+
+func NewLlamaCppGenerator(baseUrl string) *LlamaCppGenerator {
+	return &LlamaCppGenerator{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		client:  &http.Client{},
+	}
+}
+
+// WithMaxTokens limits how long replies can be
+func (g *LlamaCppGenerator) WithMaxTokens(maxTokens int) *LlamaCppGenerator {
+	g.maxTokens = maxTokens
+	return g
+}
+
+func (g *LlamaCppGenerator) WithHttpClient(client *http.Client) *LlamaCppGenerator {
+	g.client = client
+	return g
+}
+
+func (g *LlamaCppGenerator) Generate(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	body, err := json.Marshal(llamaCppRequest{
+		Prompt:   transcript(messages),
+		Stream:   true,
+		NPredict: g.maxTokens,
+		// The model shouldn't carry on the conversation by writing the user's next message
+		Stop:        []string{"\nUser:"},
+		CachePrompt: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseUrl+"/completion", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+
+	var reply strings.Builder
+	err = readEvents(resp.Body, func(data string) (bool, error) {
+		var chunk llamaCppChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("invalid completion chunk %q: %w", data, err)
+		}
+
+		if chunk.Content != "" {
+			reply.WriteString(chunk.Content)
+			if onDelta != nil {
+				if err := onDelta(chunk.Content); err != nil {
+					return false, err
+				}
+			}
+		}
+
+		return chunk.Stop, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return reply.String(), nil
+}
+
+// transcript writes messages out as a plain conversation for the model to continue as the assistant.
+// The completion endpoint doesn't apply the model's chat template, and this works tolerably with any
+// instruction tuned model.
+func transcript(messages []Message) string {
+	var sb strings.Builder
+	for _, message := range messages {
+		switch message.Role {
+		case RoleSystem:
+			sb.WriteString(message.Content + "\n\n")
+		case RoleUser:
+			sb.WriteString("User: " + message.Content + "\n\n")
+		case RoleAssistant:
+			sb.WriteString("Assistant: " + message.Content + "\n\n")
+		}
+	}
+	sb.WriteString("Assistant:")
+	return sb.String()
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLlamaCppGeneratorGenerate(t *testing.T) {
+	// given
+	var received llamaCppRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/completion", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"content\": \"Open\", \"stop\": false}\n\n")
+		fmt.Fprint(w, "data: {\"content\": \" daily\", \"stop\": false}\n\n")
+		fmt.Fprint(w, "data: {\"content\": \"\", \"stop\": true}\n\n")
+		fmt.Fprint(w, "data: {\"content\": \" ignored\", \"stop\": false}\n\n")
+	}))
+	defer server.Close()
+
+	generator := NewLlamaCppGenerator(server.URL + "/").WithMaxTokens(50)
+
+	// when
+	var deltas []string
+	reply, err := generator.Generate(context.Background(), []Message{
+		{Role: RoleSystem, Content: "Be brief"},
+		{Role: RoleUser, Content: "When is it open?"},
+	}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "Open daily", reply)
+	assert.Equal(t, []string{"Open", " daily"}, deltas)
+
+	assert.Equal(t, "Be brief\n\nUser: When is it open?\n\nAssistant:", received.Prompt)
+	assert.True(t, received.Stream)
+	assert.Equal(t, 50, received.NPredict)
+}
+
+func TestLlamaCppGeneratorGenerateError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model is loading", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := NewLlamaCppGenerator(server.URL).Generate(context.Background(), nil, nil)
+	assert.ErrorContains(t, err, "model is loading")
+}
@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+type Message struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content"`
+}
+
+// Generator generates the reply to a conversation. Generate calls onDelta with each piece of the reply
+// as it is generated, if onDelta isn't nil, and returns the whole reply. An error from onDelta stops
+// generation and is returned.
+type Generator interface {
+	Generate(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error)
+}
+
+// maxEventSize is the longest line of a server-sent event stream that can be read
+const maxEventSize = 1 << 20
+
+// readEvents calls onData with the data of each server-sent event read from r, until r ends or onData
+// says it is done
+func readEvents(r io.Reader, onData func(data string) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxEventSize)
+
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A blank line ends an event, whose data may be split over several lines
+		if line == "" {
+			if len(data) == 0 {
+				continue
+			}
+
+			done, err := onData(strings.Join(data, "\n"))
+			if err != nil || done {
+				return err
+			}
+			data = nil
+			continue
+		}
+
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(data) > 0 {
+		_, err := onData(strings.Join(data, "\n"))
+		return err
+	}
+	return nil
+}
+
+// checkResponse returns an error with the start of the body of a response that wasn't successful
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s returned %s: %s", resp.Request.Method, resp.Request.URL, resp.Status, strings.TrimSpace(string(body)))
+}
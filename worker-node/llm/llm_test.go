@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEvents(t *testing.T) {
+	stream := ": comment\n\ndata: one\n\nevent: message\ndata: two\ndata: lines\n\ndata:three\n\ndata: four\n\ndata: five"
+
+	var events []string
+	err := readEvents(strings.NewReader(stream), func(data string) (bool, error) {
+		events = append(events, data)
+		return data == "four", nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"one", "two\nlines", "three", "four"}, events)
+
+	// An event that isn't followed by a blank line is still read when the stream ends
+	events = nil
+	err = readEvents(strings.NewReader("data: one\n\ndata: two"), func(data string) (bool, error) {
+		events = append(events, data)
+		return false, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, events)
+}
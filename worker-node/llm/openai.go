@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const DefaultOpenAIBaseUrl = "https://api.openai.com/v1"
+
+// OpenAIGenerator generates replies with an OpenAI compatible chat completions API, which most hosted
+// and self hosted model servers provide
+type OpenAIGenerator struct {
+	baseUrl   string
+	apiKey    string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+type openAIRequest struct {
+	Model     string    `json:"model"`
+	Messages  []Message `json:"messages"`
+	Stream    bool      `json:"stream"`
+	MaxTokens int       `json:"max_tokens,omitempty"`
+}
+
+type openAIChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewOpenAIGenerator creates a generator for the API at baseUrl, which includes any version in its path,
+// like DefaultOpenAIBaseUrl. apiKey can be empty for servers that don't need one.
+func NewOpenAIGenerator(baseUrl, apiKey, model string) *OpenAIGenerator {
+	return &OpenAIGenerator{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{},
+	}
+}
+
+// WithMaxTokens limits how long replies can be
+func (g *OpenAIGenerator) WithMaxTokens(maxTokens int) *OpenAIGenerator {
+	g.maxTokens = maxTokens
+	return g
+}
+
+func (g *OpenAIGenerator) WithHttpClient(client *http.Client) *OpenAIGenerator {
+	g.client = client
+	return g
+}
+
+func (g *OpenAIGenerator) Generate(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	body, err := json.Marshal(openAIRequest{
+		Model:     g.model,
+		Messages:  messages,
+		Stream:    true,
+		MaxTokens: g.maxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseUrl+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if g.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+
+	var reply strings.Builder
+	err = readEvents(resp.Body, func(data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("invalid chat completion chunk %q: %w", data, err)
+		}
+
+		if chunk.Error != nil {
+			return false, errors.New(chunk.Error.Message)
+		}
+
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return false, nil
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		reply.WriteString(delta)
+		if onDelta != nil {
+			return false, onDelta(delta)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return reply.String(), nil
+}
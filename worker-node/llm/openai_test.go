@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openAIStub serves chat completions that stream deltas, recording the last request it got
+func openAIStub(t *testing.T, deltas ...string) (*httptest.Server, *openAIRequest, *http.Header) {
+	var (
+		received openAIRequest
+		header   http.Header
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		header = r.Header
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		for _, delta := range deltas {
+			chunk, _ := json.Marshal(map[string]interface{}{"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": delta}}}})
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &received, &header
+}
+
+func TestOpenAIGeneratorGenerate(t *testing.T) {
+	// given
+	server, received, header := openAIStub(t, "Open ", "daily [1]")
+	generator := NewOpenAIGenerator(server.URL+"/v1/", "key", "model").WithMaxTokens(100)
+
+	messages := []Message{{Role: RoleSystem, Content: "Be brief"}, {Role: RoleUser, Content: "When is it open?"}}
+
+	// when
+	var deltas []string
+	reply, err := generator.Generate(context.Background(), messages, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "Open daily [1]", reply)
+	assert.Equal(t, []string{"Open ", "daily [1]"}, deltas)
+
+	assert.Equal(t, "model", received.Model)
+	assert.Equal(t, messages, received.Messages)
+	assert.True(t, received.Stream)
+	assert.Equal(t, 100, received.MaxTokens)
+	assert.Equal(t, "Bearer key", header.Get("Authorization"))
+}
+
+func TestOpenAIGeneratorGenerateStopped(t *testing.T) {
+	server, _, _ := openAIStub(t, "Open ", "daily")
+	stop := errors.New("client went away")
+
+	_, err := NewOpenAIGenerator(server.URL+"/v1", "", "model").Generate(context.Background(), nil, func(delta string) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestOpenAIGeneratorGenerateErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad/chat/completions" {
+			http.Error(w, `{"error": {"message": "invalid api key"}}`, http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, "data: {\"error\": {\"message\": \"overloaded\"}}\n\n")
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAIGenerator(server.URL+"/bad", "", "model").Generate(context.Background(), nil, nil)
+	assert.ErrorContains(t, err, "invalid api key")
+
+	_, err = NewOpenAIGenerator(server.URL+"/streamed", "", "model").Generate(context.Background(), nil, nil)
+	assert.ErrorContains(t, err, "overloaded")
+}
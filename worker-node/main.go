@@ -4,81 +4,71 @@ import (
 	"context"
 	"log"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
 
-	"github.com/ethanhosier/worker-node/coordinator_client"
-	"github.com/ethanhosier/worker-node/ragger"
-	"github.com/ethanhosier/worker-node/scraper"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/ethanhosier/worker-node/setup"
 	"github.com/ethanhosier/worker-node/storage"
 	"github.com/ethanhosier/worker-node/utils"
 	"github.com/ethanhosier/worker-node/worker_manager"
 	"github.com/joho/godotenv"
 )
 
-var (
-	modelPath     = filepath.Join("model", "model.onnx")
-	libraryPath   = filepath.Join("libonnxruntime.so.1.20.1")
-	tokenizerPath = filepath.Join("model", "tokenizer.json")
-)
-
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 
+	workerType := utils.Required(os.Getenv("WORKER_TYPE"), "WORKER_TYPE")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if workerType == "query" {
+		// The query service only reads from storage, so it doesn't need the coordinator
+		log.Fatal(setup.NewQueryServer(setup.NewRAGClient(), setup.NewStore()).Start())
+	}
+
 	redisAddr := utils.Required(os.Getenv("REDIS_ADDR"), "REDIS_ADDR")
 	redisPassword := utils.Required(os.Getenv("REDIS_PASSWORD"), "REDIS_PASSWORD")
 	redisDB := utils.RequiredInt(os.Getenv("REDIS_DB"), "REDIS_DB")
 
 	log.Printf("Redis address: %s, password: %s, db: %d", redisAddr, redisPassword, redisDB)
 
-	workerType := utils.Required(os.Getenv("WORKER_TYPE"), "WORKER_TYPE")
-
-	var (
-		coordinatorClient = coordinator_client.NewRedisCoordinatorClient(
-			context.TODO(),
-			redisAddr,
-			redisPassword,
-			redisDB,
-		)
-	)
+	var coordinatorClient coordinator_client.CoordinatorClient
+	switch queueBackend := os.Getenv("QUEUE_BACKEND"); queueBackend {
+	case "", "lists":
+		coordinatorClient = coordinator_client.NewRedisCoordinatorClient(context.TODO(), redisAddr, redisPassword, redisDB)
+	case "streams":
+		coordinatorClient = coordinator_client.NewRedisStreamsCoordinatorClient(context.TODO(), redisAddr, redisPassword, redisDB)
+	default:
+		log.Fatalf("Unknown queue backend: %s", queueBackend)
+	}
 
+	var workerManager *worker_manager.WorkerManager
 	switch workerType {
 	case "scraper":
 		concurrency := utils.RequiredInt(os.Getenv("CONCURRENCY"), "CONCURRENCY")
 
-		_, errCh := startScraperWorkerManager(coordinatorClient, concurrency)
-		for err := range errCh {
-			log.Fatalf("Error: %v", err)
+		// Without storage every page is fetched in full, rather than only if it changed since it was stored
+		var store storage.Storage
+		if os.Getenv("BOLT_PATH") != "" || os.Getenv("DATABASE_URL") != "" || os.Getenv("SUPABASE_URL") != "" {
+			store = setup.NewStore()
 		}
+
+		var closeScraper func()
+		workerManager, closeScraper = setup.NewScraperWorkerManager(coordinatorClient, concurrency, store)
+		defer closeScraper()
 	case "rag":
-		_, errCh := startRagWorkerManager(coordinatorClient)
-		for err := range errCh {
-			log.Fatalf("Error: %v", err)
-		}
+		workerManager = setup.NewRagWorkerManager(coordinatorClient, setup.NewRAGClient(), setup.NewStore())
 	default:
 		log.Fatalf("Unknown worker type: %s", workerType)
 	}
 
-	select {}
-}
-
-func startScraperWorkerManager(coordinatorClient coordinator_client.CoordinatorClient, concurrency int) (chan<- bool, <-chan error) {
-	var (
-		scraperClient = scraper.NewHttpScraper()
-
-		scraperWorkerManager = worker_manager.NewScraperWorkerManager(context.TODO(), coordinatorClient, scraperClient, concurrency)
-	)
-
-	return scraperWorkerManager.Start()
-}
-
-func startRagWorkerManager(coordinatorClient coordinator_client.CoordinatorClient) (chan<- bool, <-chan error) {
-	var (
-		ragClient        = ragger.NewRAGClient(modelPath, libraryPath, tokenizerPath)
-		store            = storage.NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
-		ragWorkerManager = worker_manager.NewRagWorkerManager(context.TODO(), coordinatorClient, ragClient, store, 1)
-	)
+	if err := setup.ConfigureWorkerManager(workerManager).Start(ctx); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
-	return ragWorkerManager.Start()
+	log.Printf("Worker manager stopped")
 }
@@ -0,0 +1,136 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethanhosier/worker-node/llm"
+)
+
+const (
+	// maxContextLength is roughly how many characters of chunks a prompt is given, which keeps it well
+	// inside the context window of small local models
+	maxContextLength = 12000
+
+	systemPrompt = "You answer questions using only the numbered sources you are given. After each statement, " +
+		"cite the sources it is based on by their numbers in square brackets, like [1] or [1][2]. If the " +
+		"sources don't answer the question, say that you don't know rather than guessing."
+
+	// noSourcesAnswer is the answer when nothing relevant to a question is stored, which isn't worth
+	// asking the model about
+	noSourcesAnswer = "I couldn't find anything to answer that with."
+)
+
+// AskRequest is a question, in Query, to answer from the chunks a retrieval for it finds
+type AskRequest struct {
+	RetrieveRequest
+}
+
+// Citation is a source an answer can cite by its number
+type Citation struct {
+	Number      int    `json:"number"`
+	RagSourceId int    `json:"rag_source_id"`
+	Url         string `json:"url"`
+	Name        string `json:"name,omitempty"`
+}
+
+// Prompt is the conversation an answer is generated from, along with the sources it can cite
+type Prompt struct {
+	Messages  []llm.Message
+	Citations []Citation
+}
+
+type Answer struct {
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
+}
+
+type Asker struct {
+	searcher  *Searcher
+	generator llm.Generator
+}
+
+func NewAsker(searcher *Searcher, generator llm.Generator) *Asker {
+	return &Asker{searcher: searcher, generator: generator}
+}
+
+// Prompt retrieves the chunks relevant to a question and builds the prompt for answering it from them.
+// Each source the chunks are from is given a number the answer can cite it by, in the order of their
+// best chunks.
+func (a *Asker) Prompt(ctx context.Context, req AskRequest) (*Prompt, error) {
+	results, err := a.searcher.Retrieve(ctx, req.RetrieveRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		citations []Citation
+		numbers   = make(map[int]int)            // Rag source id -> citation number
+		chunks    = make(map[int][]SearchResult) // Citation number -> chunks
+		length    int
+	)
+
+	for _, result := range results {
+		if length+len(result.Text) > maxContextLength && length > 0 {
+			break
+		}
+		length += len(result.Text)
+
+		number, ok := numbers[result.RagSourceId]
+		if !ok {
+			number = len(citations) + 1
+			numbers[result.RagSourceId] = number
+			citations = append(citations, Citation{
+				Number:      number,
+				RagSourceId: result.RagSourceId,
+				Url:         result.SourceUrl,
+				Name:        result.SourceName,
+			})
+		}
+		chunks[number] = append(chunks[number], result.SearchResult)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Sources:\n\n")
+	for _, citation := range citations {
+		// A source's chunks are given in the order they appear in it, so they read as they were written
+		sourceChunks := chunks[citation.Number]
+		sort.Slice(sourceChunks, func(i, j int) bool { return sourceChunks[i].PosInSource < sourceChunks[j].PosInSource })
+
+		fmt.Fprintf(&sb, "[%d] %s\n", citation.Number, citation.Url)
+		for _, chunk := range sourceChunks {
+			sb.WriteString(chunk.Text + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Question: " + strings.TrimSpace(req.Query))
+
+	return &Prompt{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: systemPrompt},
+			{Role: llm.RoleUser, Content: sb.String()},
+		},
+		Citations: citations,
+	}, nil
+}
+
+// Answer generates the answer to a prompt, passing each piece of it to onDelta as it is generated if
+// onDelta isn't nil
+func (a *Asker) Answer(ctx context.Context, prompt *Prompt, onDelta func(delta string) error) (string, error) {
+	if len(prompt.Citations) == 0 {
+		if onDelta != nil {
+			if err := onDelta(noSourcesAnswer); err != nil {
+				return "", err
+			}
+		}
+		return noSourcesAnswer, nil
+	}
+
+	answer, err := a.generator.Generate(ctx, prompt.Messages, onDelta)
+	if err != nil {
+		return "", fmt.Errorf("error generating answer: %w", err)
+	}
+	return answer, nil
+}
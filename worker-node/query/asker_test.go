@@ -0,0 +1,132 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethanhosier/worker-node/llm"
+	"github.com/ethanhosier/worker-node/ragger"
+	"github.com/ethanhosier/worker-node/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// llmStub serves an OpenAI compatible chat completions API that streams deltas, returning a generator
+// for it and the messages of the last request it got
+func llmStub(t *testing.T, deltas ...string) (llm.Generator, *[]llm.Message) {
+	var received []llm.Message
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []llm.Message `json:"messages"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		received = req.Messages
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range deltas {
+			chunk, _ := json.Marshal(map[string]interface{}{"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": delta}}}})
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+
+	return llm.NewOpenAIGenerator(server.URL, "", "model"), &received
+}
+
+// askStore stores two sources, the first with two chunks that are stored out of order
+func askStore(t *testing.T) *storage.MemoryStorage {
+	var (
+		ctx   = context.Background()
+		store = storage.NewMemoryStorage()
+	)
+
+	_, err := storage.StoreAll(ctx, store,
+		storage.RagSource{ID: 1, URL: "https://example.com/hours", Name: "Hours"},
+		storage.RagSource{ID: 2, URL: "https://example.com/contact"},
+	)
+	assert.NoError(t, err)
+
+	_, err = storage.StoreAll(ctx, store,
+		storage.RagChunk{ID: 1, RagSourceId: 1, Text: "We are open daily.", PosInSource: 1, Embedding: []float32{1, 0}},
+		storage.RagChunk{ID: 2, RagSourceId: 2, Text: "Call us any time.", PosInSource: 0, Embedding: []float32{1, 1}},
+		storage.RagChunk{ID: 3, RagSourceId: 1, Text: "Opening hours", PosInSource: 0, Embedding: []float32{0, 1}},
+	)
+	assert.NoError(t, err)
+
+	return store
+}
+
+func TestAskerPrompt(t *testing.T) {
+	// given
+	var (
+		ragClient    = ragger.NewMockRagClient()
+		generator, _ = llmStub(t)
+		asker        = NewAsker(NewSearcher(ragClient, askStore(t)), generator)
+	)
+	ragClient.SetEmbeddingsFor("When are you open?", []float32{1, 0})
+
+	// when
+	prompt, err := asker.Prompt(context.Background(), AskRequest{RetrieveRequest{SearchRequest: SearchRequest{Query: "When are you open?"}}})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []Citation{
+		{Number: 1, RagSourceId: 1, Url: "https://example.com/hours", Name: "Hours"},
+		{Number: 2, RagSourceId: 2, Url: "https://example.com/contact"},
+	}, prompt.Citations)
+
+	assert.Len(t, prompt.Messages, 2)
+	assert.Equal(t, llm.RoleSystem, prompt.Messages[0].Role)
+	assert.Equal(t, llm.Message{
+		Role: llm.RoleUser,
+		Content: "Sources:\n\n" +
+			"[1] https://example.com/hours\nOpening hours\nWe are open daily.\n\n" +
+			"[2] https://example.com/contact\nCall us any time.\n\n" +
+			"Question: When are you open?",
+	}, prompt.Messages[1])
+}
+
+func TestAskerAnswer(t *testing.T) {
+	var (
+		ragClient           = ragger.NewMockRagClient()
+		generator, received = llmStub(t, "Daily ", "[1]")
+		asker               = NewAsker(NewSearcher(ragClient, askStore(t)), generator)
+	)
+	ragClient.SetEmbeddingsFor("When are you open?", []float32{1, 0})
+
+	prompt, err := asker.Prompt(context.Background(), AskRequest{RetrieveRequest{SearchRequest: SearchRequest{Query: "When are you open?"}}})
+	assert.NoError(t, err)
+
+	answer, err := asker.Answer(context.Background(), prompt, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Daily [1]", answer)
+	assert.Equal(t, prompt.Messages, *received)
+}
+
+func TestAskerAnswerNoSources(t *testing.T) {
+	var (
+		ragClient           = ragger.NewMockRagClient()
+		generator, received = llmStub(t, "Made up")
+		asker               = NewAsker(NewSearcher(ragClient, storage.NewMemoryStorage()), generator)
+	)
+	ragClient.SetEmbeddingsFor("When are you open?", []float32{1, 0})
+
+	prompt, err := asker.Prompt(context.Background(), AskRequest{RetrieveRequest{SearchRequest: SearchRequest{Query: "When are you open?"}}})
+	assert.NoError(t, err)
+	assert.Empty(t, prompt.Citations)
+
+	var deltas []string
+	answer, err := asker.Answer(context.Background(), prompt, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, noSourcesAnswer, answer)
+	assert.Equal(t, []string{noSourcesAnswer}, deltas)
+	assert.Nil(t, *received)
+}
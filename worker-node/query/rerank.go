@@ -0,0 +1,94 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ethanhosier/worker-node/ragger"
+)
+
+// DefaultRerankTopN is how many of the best results of a search are reranked if a request doesn't say.
+// Cross-encoders read every (query, chunk) pair, so this is kept small.
+const DefaultRerankTopN = 50
+
+type QueryRerankingUnavailable struct{}
+
+func (e *QueryRerankingUnavailable) Error() string {
+	return "reranking isn't available"
+}
+
+type QueryInvalidRerankTopN struct{}
+
+func (e *QueryInvalidRerankTopN) Error() string {
+	return fmt.Sprintf("rerank_top_n must be between 1 and %d", MaxTopK)
+}
+
+var (
+	ErrRerankingUnavailable = &QueryRerankingUnavailable{}
+	ErrInvalidRerankTopN    = &QueryInvalidRerankTopN{}
+)
+
+// candidateCount returns how many results a request's search should find before they are cut down to
+// its top k, which is more than the top k if they are to be reranked
+func (s *Searcher) candidateCount(req SearchRequest, topK int) (int, error) {
+	if !req.Rerank {
+		return topK, nil
+	}
+
+	if s.reranker == nil {
+		return 0, ErrRerankingUnavailable
+	}
+
+	topN := req.RerankTopN
+	if topN == 0 {
+		topN = DefaultRerankTopN
+	}
+	if topN < 0 || topN > MaxTopK {
+		return 0, ErrInvalidRerankTopN
+	}
+
+	return max(topN, topK), nil
+}
+
+// rerank orders items by the reranker's scores for their texts against query, and returns the topK best
+// along with their scores
+func rerank[T any](ctx context.Context, reranker ragger.Reranker, query string, items []T, text func(T) string, topK int) ([]T, []float64, error) {
+	if len(items) == 0 {
+		return items, nil, nil
+	}
+
+	texts := make([]string, len(items))
+	for i, item := range items {
+		texts[i] = text(item)
+	}
+
+	scores, err := reranker.Rerank(ctx, query, texts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reranking: %w", err)
+	}
+
+	if len(scores) != len(items) {
+		return nil, nil, fmt.Errorf("reranker returned %d scores for %d texts", len(scores), len(items))
+	}
+
+	// Stable, so items the reranker can't tell apart stay in the order the search put them in
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	if len(order) > topK {
+		order = order[:topK]
+	}
+
+	reranked := make([]T, len(order))
+	rerankScores := make([]float64, len(order))
+	for i, index := range order {
+		reranked[i] = items[index]
+		rerankScores[i] = float64(scores[index])
+	}
+
+	return reranked, rerankScores, nil
+}
@@ -0,0 +1,106 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethanhosier/worker-node/ragger"
+	"github.com/ethanhosier/worker-node/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearcherSearchRerank(t *testing.T) {
+	// given
+	var (
+		ragClient = ragger.NewMockRagClient()
+		reranker  = ragger.NewMockReranker()
+		searcher  = NewSearcher(ragClient, testStore(t)).WithReranker(reranker)
+	)
+	ragClient.SetEmbeddingsFor("opening hours", []float32{1, 0, 0})
+	reranker.SetScoreFor("opening hours", "Open daily", -2)
+	reranker.SetScoreFor("opening hours", "Closed on Sundays", 5)
+
+	// when
+	results, err := searcher.Search(context.Background(), SearchRequest{Query: "opening hours", TopK: 1, Rerank: true})
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	// The less similar chunk is found as a candidate even though only one result was asked for, then
+	// reranked above the other
+	assert.Equal(t, 2, results[0].ChunkId)
+	assert.InDelta(t, 0.7071, results[0].Score, 1e-4)
+	assert.Equal(t, 5.0, *results[0].RerankScore)
+	assert.Equal(t, 1, reranker.RerankCallCount)
+}
+
+func TestSearcherRetrieveRerank(t *testing.T) {
+	// given
+	var (
+		ragClient = ragger.NewMockRagClient()
+		reranker  = ragger.NewMockReranker()
+		searcher  = NewSearcher(ragClient, productStore(t)).WithReranker(reranker)
+	)
+	ragClient.SetEmbeddingsFor("XJ-9000 price", []float32{1, 0, 0})
+	reranker.SetScoreFor("XJ-9000 price", "Our prices are the lowest around", 1)
+	reranker.SetScoreFor("XJ-9000 price", "Free delivery on every order", 3)
+	reranker.SetScoreFor("XJ-9000 price", "The XJ-9000 costs 99 pounds", 2)
+
+	// when
+	results, err := searcher.Retrieve(context.Background(), RetrieveRequest{
+		SearchRequest: SearchRequest{Query: "XJ-9000 price", TopK: 2, Rerank: true, RerankTopN: 3},
+	})
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, 2, results[0].ChunkId)
+	assert.Equal(t, 3.0, *results[0].RerankScore)
+	assert.Equal(t, 2, results[0].VectorRank)
+	assert.Equal(t, 3, results[1].ChunkId)
+	assert.Equal(t, 1, results[1].KeywordRank)
+}
+
+func TestSearcherRerankTopN(t *testing.T) {
+	ragClient := ragger.NewMockRagClient()
+	ragClient.SetEmbeddingsFor("opening hours", []float32{1, 0, 0})
+	reranker := ragger.NewMockReranker()
+	reranker.SetScoreFor("opening hours", "Open daily", 1)
+	searcher := NewSearcher(ragClient, testStore(t)).WithReranker(reranker)
+
+	// Only the most similar chunk is reranked, so the other one needs no score
+	results, err := searcher.Search(context.Background(), SearchRequest{Query: "opening hours", Rerank: true, RerankTopN: 1, TopK: 1})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].ChunkId)
+
+	for _, topN := range []int{-1, MaxTopK + 1} {
+		_, err = searcher.Search(context.Background(), SearchRequest{Query: "opening hours", Rerank: true, RerankTopN: topN})
+		assert.ErrorIs(t, err, ErrInvalidRerankTopN)
+	}
+}
+
+func TestSearcherRerankErrors(t *testing.T) {
+	ragClient := ragger.NewMockRagClient()
+	ragClient.SetEmbeddingsFor("opening hours", []float32{1, 0, 0})
+
+	_, err := NewSearcher(ragClient, testStore(t)).Search(context.Background(), SearchRequest{Query: "opening hours", Rerank: true})
+	assert.ErrorIs(t, err, ErrRerankingUnavailable)
+
+	_, err = NewSearcher(ragClient, testStore(t)).Retrieve(context.Background(), RetrieveRequest{SearchRequest: SearchRequest{Query: "opening hours", Rerank: true}})
+	assert.ErrorIs(t, err, ErrRerankingUnavailable)
+
+	reranker := ragger.NewMockReranker()
+	reranker.RerankError = errors.New("model failed")
+	_, err = NewSearcher(ragClient, testStore(t)).WithReranker(reranker).Search(context.Background(), SearchRequest{Query: "opening hours", Rerank: true})
+	assert.ErrorIs(t, err, reranker.RerankError)
+
+	// Nothing is found, so there is nothing to rerank
+	reranker.RerankCallCount = 0
+	results, err := NewSearcher(ragClient, storage.NewMemoryStorage()).WithReranker(reranker).Search(context.Background(), SearchRequest{Query: "opening hours", Rerank: true})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Equal(t, 0, reranker.RerankCallCount)
+}
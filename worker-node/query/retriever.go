@@ -0,0 +1,170 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ethanhosier/worker-node/storage"
+)
+
+const (
+	// rrfK stops the first few ranks of either ranking from drowning out the rest, at the value from
+	// the paper that introduced reciprocal rank fusion
+	rrfK = 60
+
+	// candidateMultiplier is how many more chunks than were asked for each ranking is made from, so a
+	// chunk ranked low by one search but high by the other can still make the top k
+	candidateMultiplier = 4
+)
+
+type QueryInvalidWeights struct{}
+
+func (e *QueryInvalidWeights) Error() string {
+	return "weights can't be negative, and at least one must be positive"
+}
+
+var ErrInvalidWeights = &QueryInvalidWeights{}
+
+// Weights are how much the embedding similarity and keyword rankings each count towards a retrieval's
+// ranking
+type Weights struct {
+	Vector  float64 `json:"vector"`
+	Keyword float64 `json:"keyword"`
+}
+
+var DefaultWeights = Weights{Vector: 1, Keyword: 1}
+
+// RetrieveRequest is a search that ranks chunks by both their embeddings' similarity to the query and
+// how well their text matches its words. Weights defaults to DefaultWeights.
+type RetrieveRequest struct {
+	SearchRequest
+	Weights *Weights `json:"weights,omitempty"`
+}
+
+// RetrieveResult is a chunk found by a retrieval, scored by its fused rank, along with its place in each
+// of the rankings that found it, starting from 1
+type RetrieveResult struct {
+	SearchResult
+	VectorRank  int `json:"vector_rank,omitempty"`
+	KeywordRank int `json:"keyword_rank,omitempty"`
+}
+
+// fusedChunk is a chunk found by either ranking of a retrieval
+type fusedChunk struct {
+	chunk       storage.RagChunk
+	score       float64
+	vectorRank  int
+	keywordRank int
+}
+
+// Retrieve returns the chunks that best match the request's query, best first. The embedding similarity
+// and keyword rankings are combined with weighted reciprocal rank fusion, so a chunk's score is the sum
+// over the rankings it is in of weight / (60 + rank). Keyword matching finds exact terms, like product
+// codes, that embeddings miss.
+func (s *Searcher) Retrieve(ctx context.Context, req RetrieveRequest) ([]RetrieveResult, error) {
+	query, topK, err := req.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	weights := DefaultWeights
+	if req.Weights != nil {
+		weights = *req.Weights
+	}
+	if weights.Vector < 0 || weights.Keyword < 0 || weights.Vector+weights.Keyword == 0 {
+		return nil, ErrInvalidWeights
+	}
+
+	count, err := s.candidateCount(req.SearchRequest, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		candidates = count * candidateMultiplier
+		filter     = req.filter()
+		fused      = make(map[int]*fusedChunk)
+	)
+
+	fuse := func(chunk storage.RagChunk, weight float64, rank int) *fusedChunk {
+		f, ok := fused[chunk.ID]
+		if !ok {
+			f = &fusedChunk{chunk: chunk}
+			fused[chunk.ID] = f
+		}
+		f.score += weight / float64(rrfK+rank)
+		return f
+	}
+
+	// A ranking with no weight can't change the order, so it isn't searched for
+	if weights.Vector > 0 {
+		embedding, err := s.ragClient.EmbeddingsFor(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("error embedding query: %w", err)
+		}
+
+		matches, err := storage.SimilaritySearch[storage.RagChunk](ctx, s.store, embedding, candidates, filter)
+		if err != nil {
+			return nil, fmt.Errorf("error searching chunks: %w", err)
+		}
+
+		for i, match := range matches {
+			fuse(match.Item, weights.Vector, i+1).vectorRank = i + 1
+		}
+	}
+
+	if weights.Keyword > 0 {
+		matches, err := storage.KeywordSearch[storage.RagChunk](ctx, s.store, query, candidates, filter)
+		if err != nil {
+			return nil, fmt.Errorf("error searching chunk keywords: %w", err)
+		}
+
+		for i, match := range matches {
+			fuse(match.Item, weights.Keyword, i+1).keywordRank = i + 1
+		}
+	}
+
+	ranked := make([]*fusedChunk, 0, len(fused))
+	for _, f := range fused {
+		ranked = append(ranked, f)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].chunk.ID < ranked[j].chunk.ID
+	})
+
+	var rerankScores []float64
+	if req.Rerank {
+		if len(ranked) > count {
+			ranked = ranked[:count]
+		}
+
+		ranked, rerankScores, err = rerank(ctx, s.reranker, query, ranked, func(f *fusedChunk) string { return f.chunk.Text }, topK)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(ranked) > topK {
+		ranked = ranked[:topK]
+	}
+
+	sources := make(sourceCache)
+
+	results := make([]RetrieveResult, len(ranked))
+	for i, f := range ranked {
+		result, err := s.result(ctx, sources, f.chunk, f.score)
+		if err != nil {
+			return nil, err
+		}
+
+		if rerankScores != nil {
+			result.RerankScore = &rerankScores[i]
+		}
+
+		results[i] = RetrieveResult{SearchResult: result, VectorRank: f.vectorRank, KeywordRank: f.keywordRank}
+	}
+
+	return results, nil
+}
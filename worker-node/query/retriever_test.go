@@ -0,0 +1,90 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethanhosier/worker-node/ragger"
+	"github.com/ethanhosier/worker-node/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// productStore stores chunks where the one mentioning a product code is the least similar by embedding
+func productStore(t *testing.T) *storage.MemoryStorage {
+	var (
+		ctx   = context.Background()
+		store = storage.NewMemoryStorage()
+	)
+
+	_, err := storage.Store(ctx, store, storage.RagSource{ID: 1, URL: "https://example.com", JobId: "job-1"})
+	assert.NoError(t, err)
+
+	_, err = storage.StoreAll(ctx, store,
+		storage.RagChunk{ID: 1, RagSourceId: 1, Text: "Our prices are the lowest around", Embedding: []float32{1, 0, 0}},
+		storage.RagChunk{ID: 2, RagSourceId: 1, Text: "Free delivery on every order", Embedding: []float32{1, 1, 0}},
+		storage.RagChunk{ID: 3, RagSourceId: 1, Text: "The XJ-9000 costs 99 pounds", Embedding: []float32{0, 1, 1}},
+	)
+	assert.NoError(t, err)
+
+	return store
+}
+
+func TestSearcherRetrieve(t *testing.T) {
+	// given
+	var (
+		ragClient = ragger.NewMockRagClient()
+		searcher  = NewSearcher(ragClient, productStore(t))
+	)
+	ragClient.SetEmbeddingsFor("XJ-9000 price", []float32{1, 0, 0})
+
+	// when
+	results, err := searcher.Retrieve(context.Background(), RetrieveRequest{SearchRequest: SearchRequest{Query: "XJ-9000 price"}})
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+
+	// The exact match is only ranked last by embedding, but first by keyword
+	assert.Equal(t, 3, results[0].ChunkId)
+	assert.Equal(t, 3, results[0].VectorRank)
+	assert.Equal(t, 1, results[0].KeywordRank)
+	assert.InDelta(t, 1.0/63+1.0/61, results[0].Score, 1e-9)
+	assert.Equal(t, "https://example.com", results[0].SourceUrl)
+
+	assert.Equal(t, 1, results[1].ChunkId)
+	assert.Equal(t, 1, results[1].VectorRank)
+	assert.Equal(t, 0, results[1].KeywordRank)
+}
+
+func TestSearcherRetrieveWeights(t *testing.T) {
+	ragClient := ragger.NewMockRagClient()
+	ragClient.SetEmbeddingsFor("XJ-9000 price", []float32{1, 0, 0})
+	searcher := NewSearcher(ragClient, productStore(t))
+
+	// Ranks only make a small difference to fused scores, so outweighing a keyword match takes a lot
+	results, err := searcher.Retrieve(context.Background(), RetrieveRequest{
+		SearchRequest: SearchRequest{Query: "XJ-9000 price"},
+		Weights:       &Weights{Vector: 40, Keyword: 1},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, results[0].ChunkId)
+
+	// Only the keyword ranking is used, so the query isn't embedded
+	ragClient.EmbeddingsCallCount = 0
+	results, err = searcher.Retrieve(context.Background(), RetrieveRequest{
+		SearchRequest: SearchRequest{Query: "XJ-9000 price"},
+		Weights:       &Weights{Keyword: 1},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 3, results[0].ChunkId)
+	assert.Equal(t, 0, ragClient.EmbeddingsCallCount)
+
+	for _, weights := range []Weights{{}, {Vector: -1, Keyword: 2}} {
+		_, err = searcher.Retrieve(context.Background(), RetrieveRequest{
+			SearchRequest: SearchRequest{Query: "XJ-9000 price"},
+			Weights:       &weights,
+		})
+		assert.ErrorIs(t, err, ErrInvalidWeights)
+	}
+}
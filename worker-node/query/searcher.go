@@ -0,0 +1,180 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethanhosier/worker-node/ragger"
+	"github.com/ethanhosier/worker-node/storage"
+)
+
+const (
+	DefaultTopK = 10
+	MaxTopK     = 100
+)
+
+type QueryEmptyQuery struct{}
+
+func (e *QueryEmptyQuery) Error() string {
+	return "query is empty"
+}
+
+type QueryInvalidTopK struct{}
+
+func (e *QueryInvalidTopK) Error() string {
+	return fmt.Sprintf("top_k must be between 1 and %d", MaxTopK)
+}
+
+var (
+	ErrEmptyQuery  = &QueryEmptyQuery{}
+	ErrInvalidTopK = &QueryInvalidTopK{}
+)
+
+// SearchRequest is a query for the TopK chunks most similar to Query. Chunks can be limited to those of
+// some sources, of the sources stored by a job, or of the sources stored for a user. With Rerank, the
+// best RerankTopN chunks are reordered by the searcher's reranker before the top k are taken.
+type SearchRequest struct {
+	Query      string `json:"query"`
+	TopK       int    `json:"top_k,omitempty"`
+	SourceIds  []int  `json:"source_ids,omitempty"`
+	SourceUrl  string `json:"source_url,omitempty"`
+	JobId      string `json:"job_id,omitempty"`
+	UserId     string `json:"user_id,omitempty"`
+	Rerank     bool   `json:"rerank,omitempty"`
+	RerankTopN int    `json:"rerank_top_n,omitempty"`
+}
+
+// SearchResult is a chunk found by a search. Score is from the search that found it, and RerankScore
+// is set if results were reranked, which they are then ordered by.
+type SearchResult struct {
+	ChunkId     int      `json:"chunk_id"`
+	RagSourceId int      `json:"rag_source_id"`
+	SourceUrl   string   `json:"source_url"`
+	SourceName  string   `json:"source_name,omitempty"`
+	Text        string   `json:"text"`
+	PosInSource int      `json:"pos_in_source"`
+	Score       float64  `json:"score"`
+	RerankScore *float64 `json:"rerank_score,omitempty"`
+}
+
+type Searcher struct {
+	ragClient ragger.Ragger
+	store     storage.Storage
+	reranker  ragger.Reranker
+}
+
+// NewSearcher creates a searcher over the chunks in store that embeds queries with ragClient, which must
+// use the same model the chunks were embedded with
+func NewSearcher(ragClient ragger.Ragger, store storage.Storage) *Searcher {
+	return &Searcher{ragClient: ragClient, store: store}
+}
+
+// WithReranker lets requests have their results reranked, which they can't be without one
+func (s *Searcher) WithReranker(reranker ragger.Reranker) *Searcher {
+	s.reranker = reranker
+	return s
+}
+
+// Search returns the chunks most similar to the request's query, best first, scored by their cosine
+// similarity to it
+func (s *Searcher) Search(ctx context.Context, req SearchRequest) ([]SearchResult, error) {
+	query, topK, err := req.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.candidateCount(req, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	embedding, err := s.ragClient.EmbeddingsFor(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error embedding query: %w", err)
+	}
+
+	matches, err := storage.SimilaritySearch[storage.RagChunk](ctx, s.store, embedding, candidates, req.filter())
+	if err != nil {
+		return nil, fmt.Errorf("error searching chunks: %w", err)
+	}
+
+	var rerankScores []float64
+	if req.Rerank {
+		matches, rerankScores, err = rerank(ctx, s.reranker, query, matches, func(match storage.Similar[storage.RagChunk]) string { return match.Item.Text }, topK)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sources := make(sourceCache)
+
+	results := make([]SearchResult, len(matches))
+	for i, match := range matches {
+		results[i], err = s.result(ctx, sources, match.Item, match.Similarity)
+		if err != nil {
+			return nil, err
+		}
+
+		if rerankScores != nil {
+			results[i].RerankScore = &rerankScores[i]
+		}
+	}
+
+	return results, nil
+}
+
+// validate returns the request's trimmed query and the number of results it is for
+func (req SearchRequest) validate() (string, int, error) {
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return "", 0, ErrEmptyQuery
+	}
+
+	topK := req.TopK
+	if topK == 0 {
+		topK = DefaultTopK
+	}
+	if topK < 0 || topK > MaxTopK {
+		return "", 0, ErrInvalidTopK
+	}
+
+	return query, topK, nil
+}
+
+func (req SearchRequest) filter() storage.SourceFilter {
+	return storage.SourceFilter{
+		RagSourceIds: req.SourceIds,
+		Url:          req.SourceUrl,
+		JobId:        req.JobId,
+		CreatedBy:    req.UserId,
+	}
+}
+
+// sourceCache holds the sources of a search's results by id. Results usually come from a handful of
+// sources, so each is only fetched once.
+type sourceCache map[int]*storage.RagSource
+
+// result makes the search result for a chunk, with its source's url and name
+func (s *Searcher) result(ctx context.Context, sources sourceCache, chunk storage.RagChunk, score float64) (SearchResult, error) {
+	source, ok := sources[chunk.RagSourceId]
+	if !ok {
+		var err error
+		source, err = storage.Get[storage.RagSource](ctx, s.store, strconv.Itoa(chunk.RagSourceId))
+		if err != nil {
+			return SearchResult{}, fmt.Errorf("error getting rag source %d: %w", chunk.RagSourceId, err)
+		}
+		sources[chunk.RagSourceId] = source
+	}
+
+	return SearchResult{
+		ChunkId:     chunk.ID,
+		RagSourceId: chunk.RagSourceId,
+		SourceUrl:   source.URL,
+		SourceName:  source.Name,
+		Text:        chunk.Text,
+		PosInSource: chunk.PosInSource,
+		Score:       score,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethanhosier/worker-node/ragger"
+	"github.com/ethanhosier/worker-node/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore stores two sources from different jobs and users, each with a chunk
+func testStore(t *testing.T) *storage.MemoryStorage {
+	var (
+		ctx   = context.Background()
+		store = storage.NewMemoryStorage()
+	)
+
+	_, err := storage.StoreAll(ctx, store,
+		storage.RagSource{ID: 1, URL: "https://example.com", Name: "Example", JobId: "job-1", CreatedBy: "user-1"},
+		storage.RagSource{ID: 2, URL: "https://example.org", JobId: "job-2", CreatedBy: "user-2"},
+	)
+	assert.NoError(t, err)
+
+	_, err = storage.StoreAll(ctx, store,
+		storage.RagChunk{ID: 1, RagSourceId: 1, Text: "Open daily", PosInSource: 3, Embedding: []float32{1, 0, 0}},
+		storage.RagChunk{ID: 2, RagSourceId: 2, Text: "Closed on Sundays", PosInSource: 0, Embedding: []float32{1, 1, 0}},
+	)
+	assert.NoError(t, err)
+
+	return store
+}
+
+func TestSearcherSearch(t *testing.T) {
+	// given
+	var (
+		ragClient = ragger.NewMockRagClient()
+		searcher  = NewSearcher(ragClient, testStore(t))
+	)
+	ragClient.SetEmbeddingsFor("opening hours", []float32{1, 0, 0})
+
+	// when
+	results, err := searcher.Search(context.Background(), SearchRequest{Query: " opening hours "})
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, SearchResult{
+		ChunkId:     1,
+		RagSourceId: 1,
+		SourceUrl:   "https://example.com",
+		SourceName:  "Example",
+		Text:        "Open daily",
+		PosInSource: 3,
+		Score:       1,
+	}, results[0])
+	assert.Equal(t, "https://example.org", results[1].SourceUrl)
+	assert.InDelta(t, 0.7071, results[1].Score, 1e-4)
+}
+
+func TestSearcherSearchFilters(t *testing.T) {
+	ragClient := ragger.NewMockRagClient()
+	ragClient.SetEmbeddingsFor("opening hours", []float32{1, 0, 0})
+	searcher := NewSearcher(ragClient, testStore(t))
+
+	for _, req := range []SearchRequest{
+		{Query: "opening hours", SourceIds: []int{2}},
+		{Query: "opening hours", SourceUrl: "https://example.org"},
+		{Query: "opening hours", JobId: "job-2"},
+		{Query: "opening hours", UserId: "user-2"},
+	} {
+		results, err := searcher.Search(context.Background(), req)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1, "%+v", req)
+		assert.Equal(t, 2, results[0].ChunkId, "%+v", req)
+	}
+
+	results, err := searcher.Search(context.Background(), SearchRequest{Query: "opening hours", TopK: 1})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].ChunkId)
+}
+
+func TestSearcherSearchInvalid(t *testing.T) {
+	searcher := NewSearcher(ragger.NewMockRagClient(), storage.NewMemoryStorage())
+
+	_, err := searcher.Search(context.Background(), SearchRequest{Query: "  "})
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+
+	_, err = searcher.Search(context.Background(), SearchRequest{Query: "opening hours", TopK: MaxTopK + 1})
+	assert.ErrorIs(t, err, ErrInvalidTopK)
+
+	_, err = searcher.Search(context.Background(), SearchRequest{Query: "opening hours", TopK: -1})
+	assert.ErrorIs(t, err, ErrInvalidTopK)
+}
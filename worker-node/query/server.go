@@ -0,0 +1,207 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxRequestSize is far more than any search request needs
+const maxRequestSize = 1 << 20
+
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+}
+
+type RetrieveResponse struct {
+	Results []RetrieveResult `json:"results"`
+}
+
+type Server struct {
+	listenAddr string
+	router     *http.ServeMux
+	searcher   *Searcher
+	asker      *Asker
+}
+
+func NewServer(listenAddr string, searcher *Searcher) *Server {
+	s := &Server{
+		listenAddr: listenAddr,
+		router:     http.NewServeMux(),
+		searcher:   searcher,
+	}
+
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	s.router.HandleFunc("GET /ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+
+	s.router.HandleFunc("POST /search", s.search)
+	s.router.HandleFunc("POST /retrieve", s.retrieve)
+	s.router.HandleFunc("POST /ask", s.ask)
+}
+
+// WithAsker lets the server answer questions, without which /ask isn't available
+func (s *Server) WithAsker(asker *Asker) *Server {
+	s.asker = asker
+	return s
+}
+
+func (s *Server) Start() error {
+	return http.ListenAndServe(s.listenAddr, s.router)
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+	var req SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	results, err := s.searcher.Search(r.Context(), req)
+	if isBadRequest(err) {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to search for %q: %v", req.Query, err)
+		writeJSONError(w, "Failed to search", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, SearchResponse{Results: results})
+}
+
+func (s *Server) retrieve(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+	var req RetrieveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	results, err := s.searcher.Retrieve(r.Context(), req)
+	if isBadRequest(err) {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve for %q: %v", req.Query, err)
+		writeJSONError(w, "Failed to retrieve", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, RetrieveResponse{Results: results})
+}
+
+// ask answers a question from the stored chunks. The answer is streamed as server-sent events: a
+// citations event with the sources the answer can cite, delta events with each piece of the answer as
+// it is generated, and finally a done event with the whole answer, or an error event. Clients that only
+// accept application/json get the answer once it has been generated instead.
+func (s *Server) ask(w http.ResponseWriter, r *http.Request) {
+	if s.asker == nil {
+		writeJSONError(w, "No generation backend is configured", http.StatusNotImplemented)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+	var req AskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	prompt, err := s.asker.Prompt(r.Context(), req)
+	if isBadRequest(err) {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve for %q: %v", req.Query, err)
+		writeJSONError(w, "Failed to retrieve", http.StatusInternalServerError)
+		return
+	}
+
+	if acceptsOnlyJSON(r) {
+		answer, err := s.asker.Answer(r.Context(), prompt, nil)
+		if err != nil {
+			log.Printf("Failed to answer %q: %v", req.Query, err)
+			writeJSONError(w, "Failed to generate answer", http.StatusBadGateway)
+			return
+		}
+
+		writeJSON(w, Answer{Answer: answer, Citations: prompt.Citations})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	if err := writeEvent(w, "citations", prompt.Citations); err != nil {
+		return
+	}
+
+	answer, err := s.asker.Answer(r.Context(), prompt, func(delta string) error {
+		return writeEvent(w, "delta", map[string]string{"text": delta})
+	})
+	if err != nil {
+		// Once streaming has started the status can't be changed, so the error is an event
+		log.Printf("Failed to answer %q: %v", req.Query, err)
+		writeEvent(w, "error", map[string]string{"error": "Failed to generate answer"})
+		return
+	}
+
+	writeEvent(w, "done", Answer{Answer: answer, Citations: prompt.Citations})
+}
+
+// acceptsOnlyJSON checks if a client asked for JSON rather than a stream of events
+func acceptsOnlyJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/event-stream")
+}
+
+// writeEvent writes a server-sent event with data as JSON and flushes it to the client
+func writeEvent(w http.ResponseWriter, event string, data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, jsonData); err != nil {
+		return err
+	}
+
+	if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// isBadRequest checks if err is the fault of the request rather than the server
+func isBadRequest(err error) bool {
+	return errors.Is(err, ErrEmptyQuery) || errors.Is(err, ErrInvalidTopK) || errors.Is(err, ErrInvalidWeights) ||
+		errors.Is(err, ErrRerankingUnavailable) || errors.Is(err, ErrInvalidRerankTopN)
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeJSONError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
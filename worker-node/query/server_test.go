@@ -0,0 +1,124 @@
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethanhosier/worker-node/ragger"
+	"github.com/ethanhosier/worker-node/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerSearch(t *testing.T) {
+	// given
+	var (
+		ragClient = ragger.NewMockRagClient()
+		server    = NewServer(":0", NewSearcher(ragClient, testStore(t)))
+	)
+	ragClient.SetEmbeddingsFor("opening hours", []float32{1, 0, 0})
+
+	// when
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/search", strings.NewReader(`{"query": "opening hours", "top_k": 5, "job_id": "job-1"}`)))
+
+	// then
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp SearchResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Len(t, resp.Results, 1)
+	assert.Equal(t, "https://example.com", resp.Results[0].SourceUrl)
+	assert.Equal(t, "Open daily", resp.Results[0].Text)
+	assert.Equal(t, 3, resp.Results[0].PosInSource)
+	assert.Equal(t, float64(1), resp.Results[0].Score)
+}
+
+func TestServerSearchBadRequest(t *testing.T) {
+	server := NewServer(":0", NewSearcher(ragger.NewMockRagClient(), storage.NewMemoryStorage()))
+
+	for _, body := range []string{`not json`, `{"query": ""}`, `{"query": "opening hours", "top_k": 1000}`, `{"query": "opening hours", "rerank": true}`} {
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/search", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestServerRetrieve(t *testing.T) {
+	var (
+		ragClient = ragger.NewMockRagClient()
+		server    = NewServer(":0", NewSearcher(ragClient, productStore(t)))
+	)
+	ragClient.SetEmbeddingsFor("XJ-9000 price", []float32{1, 0, 0})
+
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/retrieve", strings.NewReader(`{"query": "XJ-9000 price", "top_k": 1, "weights": {"vector": 1, "keyword": 2}}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp RetrieveResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Len(t, resp.Results, 1)
+	assert.Equal(t, 3, resp.Results[0].ChunkId)
+	assert.Equal(t, 1, resp.Results[0].KeywordRank)
+
+	rec = httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/retrieve", strings.NewReader(`{"query": "XJ-9000 price", "weights": {"vector": 0, "keyword": 0}}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServerAsk(t *testing.T) {
+	// given
+	var (
+		ragClient    = ragger.NewMockRagClient()
+		generator, _ = llmStub(t, "Daily ", "[1]")
+		searcher     = NewSearcher(ragClient, askStore(t))
+		server       = NewServer(":0", searcher).WithAsker(NewAsker(searcher, generator))
+	)
+	ragClient.SetEmbeddingsFor("When are you open?", []float32{1, 0})
+
+	// when
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ask", strings.NewReader(`{"query": "When are you open?", "top_k": 1}`)))
+
+	// then
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	assert.Equal(t, ""+
+		"event: citations\ndata: [{\"number\":1,\"rag_source_id\":1,\"url\":\"https://example.com/hours\",\"name\":\"Hours\"}]\n\n"+
+		"event: delta\ndata: {\"text\":\"Daily \"}\n\n"+
+		"event: delta\ndata: {\"text\":\"[1]\"}\n\n"+
+		"event: done\ndata: {\"answer\":\"Daily [1]\",\"citations\":[{\"number\":1,\"rag_source_id\":1,\"url\":\"https://example.com/hours\",\"name\":\"Hours\"}]}\n\n",
+		rec.Body.String())
+}
+
+func TestServerAskJSON(t *testing.T) {
+	var (
+		ragClient    = ragger.NewMockRagClient()
+		generator, _ = llmStub(t, "Daily ", "[1]")
+		searcher     = NewSearcher(ragClient, askStore(t))
+		server       = NewServer(":0", searcher).WithAsker(NewAsker(searcher, generator))
+	)
+	ragClient.SetEmbeddingsFor("When are you open?", []float32{1, 0})
+
+	req := httptest.NewRequest(http.MethodPost, "/ask", strings.NewReader(`{"query": "When are you open?"}`))
+	req.Header.Set("Accept", "application/json")
+
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var answer Answer
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&answer))
+	assert.Equal(t, "Daily [1]", answer.Answer)
+	assert.Len(t, answer.Citations, 2)
+}
+
+func TestServerAskNotConfigured(t *testing.T) {
+	server := NewServer(":0", NewSearcher(ragger.NewMockRagClient(), storage.NewMemoryStorage()))
+
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ask", strings.NewReader(`{"query": "When are you open?"}`)))
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
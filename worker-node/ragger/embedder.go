@@ -1,6 +1,7 @@
 package ragger
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -44,7 +45,9 @@ func NewEmbedder(modelPath string, libraryPath string, tok *tokenizer.Tokenizer)
 	}, nil
 }
 
-func (e *Embedder) EmbedAll(texts []string) ([][]float32, error) {
+// EmbedAll embeds texts in a single batch. Inference itself can't be interrupted, so ctx is checked
+// between tokenizing each text and before inference starts.
+func (e *Embedder) EmbedAll(ctx context.Context, texts []string) ([][]float32, error) {
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Printf("Panic occurred in EmbedAll. Number of texts: %d\nFirst text: %q\n", len(texts), texts[0])
@@ -65,6 +68,10 @@ func (e *Embedder) EmbedAll(texts []string) ([][]float32, error) {
 	encodings := make([]*tokenizer.Encoding, len(texts))
 
 	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		encoding, err := e.tokenizer.Encode(tokenizer.NewSingleEncodeInput(tokenizer.NewInputSequence(text)), true)
 		if err != nil {
 			return nil, fmt.Errorf("failed to encode text: %v", err)
@@ -126,6 +133,10 @@ func (e *Embedder) EmbedAll(texts []string) ([][]float32, error) {
 		return nil, fmt.Errorf("failed to create output tensor: %v", err)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	err = e.session.Run([]onnxruntime_go.Value{inputIdsTensor, attentionMaskTensor},
 		[]onnxruntime_go.Value{outputTensor})
 	if err != nil {
@@ -147,7 +158,7 @@ func (e *Embedder) EmbedAll(texts []string) ([][]float32, error) {
 	return toReturn, nil
 }
 
-func (e *Embedder) Embed(text string) ([]float32, error) {
+func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Printf("Panic occurred in Embed. Input text: %q\n", text)
@@ -155,6 +166,10 @@ func (e *Embedder) Embed(text string) ([]float32, error) {
 		}
 	}()
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Tokenize input
 	encodeInput := tokenizer.NewSingleEncodeInput(tokenizer.NewInputSequence(text))
 	encoding, err := e.tokenizer.Encode(encodeInput, true)
@@ -1,6 +1,7 @@
 package ragger
 
 import (
+	"context"
 	"math/rand"
 	"path/filepath"
 	"testing"
@@ -48,7 +49,7 @@ func TestEmbedder(t *testing.T) {
 	setupTestEmbedder(t)
 
 	for text, expected := range embeddingMap {
-		embedding, err := testEmbedder.Embed(text)
+		embedding, err := testEmbedder.Embed(context.Background(), text)
 		if err != nil {
 			t.Fatalf("Failed to embed text: %v", err)
 		}
@@ -65,7 +66,7 @@ func TestEmbedAll(t *testing.T) {
 		texts = append(texts, k)
 	}
 
-	embeddings, err := testEmbedder.EmbedAll(texts)
+	embeddings, err := testEmbedder.EmbedAll(context.Background(), texts)
 	if err != nil {
 		t.Fatalf("Failed to embed all texts: %v", err)
 	}
@@ -84,13 +85,13 @@ func TestEmbedAllAndEmbedAreSame(t *testing.T) {
 		allStrs = append(allStrs, randomString(rand.Intn(100)))
 	}
 
-	embeddings, err := testEmbedder.EmbedAll(allStrs)
+	embeddings, err := testEmbedder.EmbedAll(context.Background(), allStrs)
 	if err != nil {
 		t.Fatalf("Failed to embed all texts: %v", err)
 	}
 
 	for i, embedding := range embeddings {
-		embedding2, err := testEmbedder.Embed(allStrs[i])
+		embedding2, err := testEmbedder.Embed(context.Background(), allStrs[i])
 		if err != nil {
 			t.Fatalf("Failed to embed text: %v", err)
 		}
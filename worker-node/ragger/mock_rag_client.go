@@ -1,6 +1,7 @@
 package ragger
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -53,8 +54,11 @@ func (m *MockRagClient) SetEmbeddingsForAll(input []string, embeddings [][]float
 	m.EmbeddingsForAllMap[embeddingsForAllKey(input)] = embeddings
 }
 
-func (m *MockRagClient) ChunksFrom(text string) ([]string, error) {
+func (m *MockRagClient) ChunksFrom(ctx context.Context, text string) ([]string, error) {
 	m.ChunksCallCount++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if m.ChunksError != nil {
 		return nil, m.ChunksError
 	}
@@ -65,8 +69,11 @@ func (m *MockRagClient) ChunksFrom(text string) ([]string, error) {
 	return chunks, nil
 }
 
-func (m *MockRagClient) ContactsFrom(text string) ([]Contact, error) {
+func (m *MockRagClient) ContactsFrom(ctx context.Context, text string) ([]Contact, error) {
 	m.ContactsCallCount++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if m.ContactsError != nil {
 		return nil, m.ContactsError
 	}
@@ -77,8 +84,11 @@ func (m *MockRagClient) ContactsFrom(text string) ([]Contact, error) {
 	return contacts, nil
 }
 
-func (m *MockRagClient) EmbeddingsFor(text string) ([]float32, error) {
+func (m *MockRagClient) EmbeddingsFor(ctx context.Context, text string) ([]float32, error) {
 	m.EmbeddingsCallCount++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if m.EmbeddingsError != nil {
 		return nil, m.EmbeddingsError
 	}
@@ -89,8 +99,11 @@ func (m *MockRagClient) EmbeddingsFor(text string) ([]float32, error) {
 	return embeddings, nil
 }
 
-func (m *MockRagClient) EmbeddingsForAll(texts []string) ([][]float32, error) {
+func (m *MockRagClient) EmbeddingsForAll(ctx context.Context, texts []string) ([][]float32, error) {
 	m.EmbeddingsForAllCallCount++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if m.EmbeddingsForAllError != nil {
 		return nil, m.EmbeddingsForAllError
 	}
@@ -1,6 +1,7 @@
 package ragger
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -13,7 +14,7 @@ func TestMockRagClient(t *testing.T) {
 		expectedChunks := []string{"chunk1", "chunk2"}
 
 		t.Run("returns error when no chunks configured", func(t *testing.T) {
-			_, err := mock.ChunksFrom(testInput)
+			_, err := mock.ChunksFrom(context.Background(), testInput)
 			if err == nil {
 				t.Error("expected error when no chunks configured")
 			}
@@ -21,7 +22,7 @@ func TestMockRagClient(t *testing.T) {
 
 		t.Run("returns configured chunks for input", func(t *testing.T) {
 			mock.SetChunksFor(testInput, expectedChunks)
-			chunks, err := mock.ChunksFrom(testInput)
+			chunks, err := mock.ChunksFrom(context.Background(), testInput)
 
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
@@ -33,7 +34,7 @@ func TestMockRagClient(t *testing.T) {
 
 		t.Run("returns configured error", func(t *testing.T) {
 			mock.ChunksError = fmt.Errorf("test error")
-			_, err := mock.ChunksFrom(testInput)
+			_, err := mock.ChunksFrom(context.Background(), testInput)
 
 			if err == nil {
 				t.Error("expected error to be returned")
@@ -45,7 +46,7 @@ func TestMockRagClient(t *testing.T) {
 			mock.SetChunksFor(testInput, expectedChunks)
 
 			initialCount := mock.ChunksCallCount
-			_, _ = mock.ChunksFrom(testInput)
+			_, _ = mock.ChunksFrom(context.Background(), testInput)
 
 			if mock.ChunksCallCount != initialCount+1 {
 				t.Errorf("call count not incremented")
@@ -63,7 +64,7 @@ func TestMockRagClient(t *testing.T) {
 		}}
 
 		t.Run("returns error when no contacts configured", func(t *testing.T) {
-			_, err := mock.ContactsFrom(testInput)
+			_, err := mock.ContactsFrom(context.Background(), testInput)
 			if err == nil {
 				t.Error("expected error when no contacts configured")
 			}
@@ -71,7 +72,7 @@ func TestMockRagClient(t *testing.T) {
 
 		t.Run("returns configured contacts for input", func(t *testing.T) {
 			mock.SetContactsFor(testInput, expectedContacts)
-			contacts, err := mock.ContactsFrom(testInput)
+			contacts, err := mock.ContactsFrom(context.Background(), testInput)
 
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
@@ -83,7 +84,7 @@ func TestMockRagClient(t *testing.T) {
 
 		t.Run("returns configured error", func(t *testing.T) {
 			mock.ContactsError = fmt.Errorf("test error")
-			_, err := mock.ContactsFrom(testInput)
+			_, err := mock.ContactsFrom(context.Background(), testInput)
 
 			if err == nil {
 				t.Error("expected error to be returned")
@@ -95,7 +96,7 @@ func TestMockRagClient(t *testing.T) {
 			mock.SetContactsFor(testInput, expectedContacts)
 
 			initialCount := mock.ContactsCallCount
-			_, _ = mock.ContactsFrom(testInput)
+			_, _ = mock.ContactsFrom(context.Background(), testInput)
 
 			if mock.ContactsCallCount != initialCount+1 {
 				t.Errorf("call count not incremented")
@@ -109,7 +110,7 @@ func TestMockRagClient(t *testing.T) {
 		expectedEmbeddings := []float32{0.1, 0.2, 0.3}
 
 		t.Run("returns error when no embeddings configured", func(t *testing.T) {
-			_, err := mock.EmbeddingsFor(testInput)
+			_, err := mock.EmbeddingsFor(context.Background(), testInput)
 			if err == nil {
 				t.Error("expected error when no embeddings configured")
 			}
@@ -117,7 +118,7 @@ func TestMockRagClient(t *testing.T) {
 
 		t.Run("returns configured embeddings for input", func(t *testing.T) {
 			mock.SetEmbeddingsFor(testInput, expectedEmbeddings)
-			embeddings, err := mock.EmbeddingsFor(testInput)
+			embeddings, err := mock.EmbeddingsFor(context.Background(), testInput)
 
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
@@ -129,7 +130,7 @@ func TestMockRagClient(t *testing.T) {
 
 		t.Run("returns configured error", func(t *testing.T) {
 			mock.EmbeddingsError = fmt.Errorf("test error")
-			_, err := mock.EmbeddingsFor(testInput)
+			_, err := mock.EmbeddingsFor(context.Background(), testInput)
 
 			if err == nil {
 				t.Error("expected error to be returned")
@@ -141,7 +142,7 @@ func TestMockRagClient(t *testing.T) {
 			mock.SetEmbeddingsFor(testInput, expectedEmbeddings)
 
 			initialCount := mock.EmbeddingsCallCount
-			_, _ = mock.EmbeddingsFor(testInput)
+			_, _ = mock.EmbeddingsFor(context.Background(), testInput)
 
 			if mock.EmbeddingsCallCount != initialCount+1 {
 				t.Errorf("call count not incremented")
@@ -156,7 +157,7 @@ func TestMockRagClient_EmbeddingsForAll(t *testing.T) {
 	expectedEmbeddings := [][]float32{{0.1, 0.2, 0.3}, {0.4, 0.5, 0.6}}
 
 	mock.SetEmbeddingsForAll(testInput, expectedEmbeddings)
-	embeddings, err := mock.EmbeddingsForAll(testInput)
+	embeddings, err := mock.EmbeddingsForAll(context.Background(), testInput)
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
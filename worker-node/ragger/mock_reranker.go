@@ -0,0 +1,51 @@
+package ragger
+
+import (
+	"context"
+	"fmt"
+)
+
+// MockReranker implements the Reranker interface for testing purposes
+type MockReranker struct {
+	// Maps a query to the score of each text for it
+	ScoresMap map[string]map[string]float32
+	// Error state
+	RerankError error
+	// Call counter for verification
+	RerankCallCount int
+}
+
+// NewMockReranker creates a new instance of MockReranker
+func NewMockReranker() *MockReranker {
+	return &MockReranker{
+		ScoresMap: make(map[string]map[string]float32),
+	}
+}
+
+// SetScoreFor sets the score to return for text when reranking for query
+func (m *MockReranker) SetScoreFor(query string, text string, score float32) {
+	if m.ScoresMap[query] == nil {
+		m.ScoresMap[query] = make(map[string]float32)
+	}
+	m.ScoresMap[query][text] = score
+}
+
+func (m *MockReranker) Rerank(ctx context.Context, query string, texts []string) ([]float32, error) {
+	m.RerankCallCount++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.RerankError != nil {
+		return nil, m.RerankError
+	}
+
+	scores := make([]float32, len(texts))
+	for i, text := range texts {
+		score, ok := m.ScoresMap[query][text]
+		if !ok {
+			return nil, fmt.Errorf("no score configured for query %s and text: %s", query, text)
+		}
+		scores[i] = score
+	}
+	return scores, nil
+}
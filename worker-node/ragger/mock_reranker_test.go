@@ -0,0 +1,29 @@
+package ragger
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockReranker(t *testing.T) {
+	mock := NewMockReranker()
+
+	_, err := mock.Rerank(context.Background(), "query", []string{"text"})
+	assert.Error(t, err)
+
+	mock.SetScoreFor("query", "one", 1)
+	mock.SetScoreFor("query", "two", -2)
+
+	scores, err := mock.Rerank(context.Background(), "query", []string{"two", "one"})
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{-2, 1}, scores)
+
+	mock.RerankError = fmt.Errorf("test error")
+	_, err = mock.Rerank(context.Background(), "query", []string{"one"})
+	assert.Error(t, err)
+
+	assert.Equal(t, 3, mock.RerankCallCount)
+}
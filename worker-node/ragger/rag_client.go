@@ -1,6 +1,7 @@
 package ragger
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"regexp"
@@ -39,19 +40,26 @@ func NewRAGClient(modelPath string, libraryPath string, tokenizerPath string) *R
 	}
 }
 
-func (c *RAGClient) ChunksFrom(text string) ([]string, error) {
+func (c *RAGClient) ChunksFrom(ctx context.Context, text string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return c.chunker.Chunk(text)
 }
 
-func (c *RAGClient) EmbeddingsFor(text string) ([]float32, error) {
-	return c.embedder.Embed(text)
+func (c *RAGClient) EmbeddingsFor(ctx context.Context, text string) ([]float32, error) {
+	return c.embedder.Embed(ctx, text)
 }
 
-func (c *RAGClient) EmbeddingsForAll(texts []string) ([][]float32, error) {
-	return c.embedder.EmbedAll(texts)
+func (c *RAGClient) EmbeddingsForAll(ctx context.Context, texts []string) ([][]float32, error) {
+	return c.embedder.EmbedAll(ctx, texts)
 }
 
-func (c *RAGClient) ContactsFrom(text string) ([]Contact, error) {
+func (c *RAGClient) ContactsFrom(ctx context.Context, text string) ([]Contact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	var result []Contact
 	defer func() {
 		if r := recover(); r != nil {
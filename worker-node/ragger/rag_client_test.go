@@ -1,6 +1,7 @@
 package ragger
 
 import (
+	"context"
 	"strings"
 	"testing"
 
@@ -14,7 +15,7 @@ func TestContactsFrom(t *testing.T) {
 My website is https://www.example.com.
 Alternatively, reach out to jane_doe123@example.org for further details.`
 
-	contacts, err := client.ContactsFrom(text)
+	contacts, err := client.ContactsFrom(context.Background(), text)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -65,7 +66,7 @@ func TestContactsFrom_NoContacts(t *testing.T) {
 
 	text := "Hello there my name is Ethan"
 
-	contacts, err := client.ContactsFrom(text)
+	contacts, err := client.ContactsFrom(context.Background(), text)
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(contacts))
 }
@@ -75,7 +76,7 @@ func TestChunksFrom(t *testing.T) {
 
 	text := "Hello there my name is Ethan"
 
-	chunks, err := client.ChunksFrom(text)
+	chunks, err := client.ChunksFrom(context.Background(), text)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(chunks))
 }
@@ -85,7 +86,7 @@ func TestEmbeddingsFor(t *testing.T) {
 
 	text := "Hello there my name is Ethan"
 
-	embeddings, err := client.EmbeddingsFor(text)
+	embeddings, err := client.EmbeddingsFor(context.Background(), text)
 	assert.NoError(t, err)
 	assert.Equal(t, 384, len(embeddings))
 }
@@ -95,7 +96,7 @@ func TestEmbeddingsForAll(t *testing.T) {
 
 	texts := []string{"Hello there my name is Ethan", "Hello there my name is Ethan"}
 
-	embeddings, err := client.EmbeddingsForAll(texts)
+	embeddings, err := client.EmbeddingsForAll(context.Background(), texts)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(embeddings))
 	assert.Equal(t, 384, len(embeddings[0]))
@@ -1,11 +1,13 @@
 package ragger
 
+import "context"
+
 type Ragger interface {
-	ChunksFrom(text string) ([]string, error)
-	ContactsFrom(text string) ([]Contact, error)
+	ChunksFrom(ctx context.Context, text string) ([]string, error)
+	ContactsFrom(ctx context.Context, text string) ([]Contact, error)
 
-	EmbeddingsFor(text string) ([]float32, error)
-	EmbeddingsForAll(texts []string) ([][]float32, error)
+	EmbeddingsFor(ctx context.Context, text string) ([]float32, error)
+	EmbeddingsForAll(ctx context.Context, texts []string) ([][]float32, error)
 }
 
 type ContactType string
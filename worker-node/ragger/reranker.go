@@ -0,0 +1,143 @@
+package ragger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/yalue/onnxruntime_go"
+)
+
+// Reranker scores how relevant each of texts is to query. Scores are only comparable with the other
+// scores for the same query, and higher is more relevant.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, texts []string) ([]float32, error)
+}
+
+// CrossEncoder is a Reranker that runs a cross-encoder, a model that reads the query and a text
+// together and outputs a single relevance logit, like ms-marco-MiniLM-L-6-v2. It is slower than
+// comparing embeddings but much more accurate, so it is used to reorder the best few results of a
+// search.
+type CrossEncoder struct {
+	tokenizer *tokenizer.Tokenizer
+	session   *onnxruntime_go.DynamicAdvancedSession
+	modelPath string
+}
+
+// NewCrossEncoder loads a cross-encoder model, which takes its own tokenizer. The onnxruntime
+// environment is shared with the Embedder, so it is only initialised if the Embedder hasn't already.
+func NewCrossEncoder(modelPath string, libraryPath string, tok *tokenizer.Tokenizer) (*CrossEncoder, error) {
+	if !onnxruntime_go.IsInitialized() {
+		onnxruntime_go.SetSharedLibraryPath(libraryPath)
+		if err := onnxruntime_go.InitializeEnvironment(); err != nil {
+			return nil, fmt.Errorf("failed to initialize environment: %v", err)
+		}
+	}
+
+	session, err := onnxruntime_go.NewDynamicAdvancedSession(
+		modelPath,
+		[]string{"input_ids", "attention_mask", "token_type_ids"},
+		[]string{"logits"},
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	return &CrossEncoder{
+		tokenizer: tok,
+		session:   session,
+		modelPath: modelPath,
+	}, nil
+}
+
+// Rerank scores each (query, text) pair in a single batch. Pairs longer than the model can take are
+// cut short, which only loses the end of the text as queries are short. Inference itself can't be
+// interrupted, so ctx is checked between tokenizing each pair and before inference starts.
+func (c *CrossEncoder) Rerank(ctx context.Context, query string, texts []string) ([]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	var (
+		batchSize = int64(len(texts))
+		encodings = make([]*tokenizer.Encoding, len(texts))
+		seqLength = 0
+	)
+
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		encoding, err := c.tokenizer.Encode(tokenizer.NewDualEncodeInput(tokenizer.NewInputSequence(query), tokenizer.NewInputSequence(text)), true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode pair: %v", err)
+		}
+
+		encodings[i] = truncatePair(encoding)
+		if len(encodings[i].Ids) > seqLength {
+			seqLength = len(encodings[i].Ids)
+		}
+	}
+
+	// Shorter pairs are padded with zeros, which the attention mask tells the model to ignore
+	inputIdsTensor, err := onnxruntime_go.NewEmptyTensor[int64](onnxruntime_go.NewShape(batchSize, int64(seqLength)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input_ids tensor: %v", err)
+	}
+	defer inputIdsTensor.Destroy()
+
+	attentionMaskTensor, err := onnxruntime_go.NewEmptyTensor[int64](onnxruntime_go.NewShape(batchSize, int64(seqLength)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attention_mask tensor: %v", err)
+	}
+	defer attentionMaskTensor.Destroy()
+
+	tokenTypeIdsTensor, err := onnxruntime_go.NewEmptyTensor[int64](onnxruntime_go.NewShape(batchSize, int64(seqLength)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token_type_ids tensor: %v", err)
+	}
+	defer tokenTypeIdsTensor.Destroy()
+
+	for i, encoding := range encodings {
+		offset := i * seqLength
+		copy(inputIdsTensor.GetData()[offset:], toInt64(encoding.Ids))
+		copy(attentionMaskTensor.GetData()[offset:], toInt64(encoding.AttentionMask))
+		copy(tokenTypeIdsTensor.GetData()[offset:], toInt64(encoding.TypeIds))
+	}
+
+	outputTensor, err := onnxruntime_go.NewEmptyTensor[float32](onnxruntime_go.NewShape(batchSize, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output tensor: %v", err)
+	}
+	defer outputTensor.Destroy()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	err = c.session.Run([]onnxruntime_go.Value{inputIdsTensor, attentionMaskTensor, tokenTypeIdsTensor},
+		[]onnxruntime_go.Value{outputTensor})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run inference: %v", err)
+	}
+
+	scores := make([]float32, batchSize)
+	copy(scores, outputTensor.GetData())
+	return scores, nil
+}
+
+// truncatePair cuts an encoded pair down to tensorMaxTokens, keeping the separator token that ends it
+func truncatePair(encoding *tokenizer.Encoding) *tokenizer.Encoding {
+	if len(encoding.Ids) <= tensorMaxTokens {
+		return encoding
+	}
+
+	last := len(encoding.Ids) - 1
+	truncated := *encoding
+	truncated.Ids = append(append([]int{}, encoding.Ids[:tensorMaxTokens-1]...), encoding.Ids[last])
+	truncated.AttentionMask = append(append([]int{}, encoding.AttentionMask[:tensorMaxTokens-1]...), encoding.AttentionMask[last])
+	truncated.TypeIds = append(append([]int{}, encoding.TypeIds[:tensorMaxTokens-1]...), encoding.TypeIds[last])
+	return &truncated
+}
@@ -0,0 +1,69 @@
+package ragger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/pretrained"
+)
+
+var (
+	crossEncoderModelPath     = filepath.Join("..", "model", "reranker", "model.onnx")
+	crossEncoderTokenizerPath = filepath.Join("..", "model", "reranker", "tokenizer.json")
+)
+
+func TestTruncatePair(t *testing.T) {
+	tok, err := pretrained.FromFile(tokenizerPath)
+	if err != nil {
+		t.Fatalf("failed to load tokenizer: %v", err)
+	}
+
+	encoding, err := tok.Encode(tokenizer.NewDualEncodeInput(
+		tokenizer.NewInputSequence("opening hours"),
+		tokenizer.NewInputSequence(strings.Repeat("we are open every day of the week ", 100)),
+	), true)
+	assert.NoError(t, err)
+	assert.Greater(t, len(encoding.Ids), tensorMaxTokens)
+
+	truncated := truncatePair(encoding)
+	assert.Len(t, truncated.Ids, tensorMaxTokens)
+	assert.Len(t, truncated.AttentionMask, tensorMaxTokens)
+	assert.Len(t, truncated.TypeIds, tensorMaxTokens)
+	assert.Equal(t, encoding.Ids[len(encoding.Ids)-1], truncated.Ids[tensorMaxTokens-1])
+	assert.Equal(t, encoding.Ids[:tensorMaxTokens-1], truncated.Ids[:tensorMaxTokens-1])
+
+	short, err := tok.Encode(tokenizer.NewDualEncodeInput(tokenizer.NewInputSequence("a"), tokenizer.NewInputSequence("b")), true)
+	assert.NoError(t, err)
+	assert.Same(t, short, truncatePair(short))
+}
+
+// TestCrossEncoderRerank needs a cross-encoder, like ms-marco-MiniLM-L-6-v2 exported to ONNX, in
+// model/reranker
+func TestCrossEncoderRerank(t *testing.T) {
+	if _, err := os.Stat(crossEncoderModelPath); err != nil {
+		t.Skip("No cross-encoder model in model/reranker")
+	}
+
+	tok, err := pretrained.FromFile(crossEncoderTokenizerPath)
+	if err != nil {
+		t.Fatalf("failed to load tokenizer: %v", err)
+	}
+
+	crossEncoder, err := NewCrossEncoder(crossEncoderModelPath, libraryPath, tok)
+	if err != nil {
+		t.Fatalf("Failed to create cross-encoder: %v", err)
+	}
+
+	scores, err := crossEncoder.Rerank(context.Background(), "When is the shop open?", []string{
+		"The shop is open from 9am to 5pm every day.",
+		"Our company was founded in 1998.",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, scores, 2)
+	assert.Greater(t, scores[0], scores[1])
+}
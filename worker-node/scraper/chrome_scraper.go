@@ -0,0 +1,231 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/ethanhosier/worker-node/utils"
+)
+
+const (
+	// maxRenderWait caps how long a page is given to go quiet or show the element being waited for,
+	// as pages that poll or stream never go quiet. The page is read as it is once it passes.
+	maxRenderWait = 10 * time.Second
+)
+
+// Renderer is a Scraper that renders pages in a browser, running their JavaScript before reading them
+type Renderer interface {
+	Scraper
+
+	// Render returns the html inside tag once the page has rendered. If waitSelector is set that is
+	// once an element matching it is visible, otherwise once the page's network has gone idle.
+	Render(ctx context.Context, url string, tag string, waitSelector string) (*string, error)
+}
+
+// ChromeScraper renders pages in a local headless Chromium driven over the DevTools protocol. The
+// browser is started on first use and every page is rendered in its own tab.
+type ChromeScraper struct {
+	execPath   string
+	politeness *politeness
+
+	startOnce   sync.Once
+	startErr    error
+	browserCtx  context.Context
+	stopBrowser context.CancelFunc
+}
+
+// NewChromeScraper creates a scraper that runs the Chromium at execPath, or finds one on the PATH if
+// execPath is empty
+func NewChromeScraper(execPath string) *ChromeScraper {
+	return &ChromeScraper{
+		execPath:   execPath,
+		politeness: newPoliteness(http.DefaultClient),
+	}
+}
+
+// WithUserAgent sets the User-Agent the browser sends, which is also the name robots.txt rules are
+// matched against. It has no effect once the browser has started.
+func (c *ChromeScraper) WithUserAgent(userAgent string) *ChromeScraper {
+	c.politeness.setUserAgent(userAgent)
+	return c
+}
+
+// WithHostLimiter sets the limiter that spaces out page loads from each host
+func (c *ChromeScraper) WithHostLimiter(hostLimiter HostLimiter) *ChromeScraper {
+	c.politeness.hostLimiter = hostLimiter
+	return c
+}
+
+// WithHostInterval sets the minimum time between page loads from a host. A longer Crawl-delay in the
+// host's robots.txt takes precedence.
+func (c *ChromeScraper) WithHostInterval(hostInterval time.Duration) *ChromeScraper {
+	c.politeness.hostInterval = hostInterval
+	return c
+}
+
+func (c *ChromeScraper) HtmlFrom(ctx context.Context, url string) (*string, error) {
+	return c.Render(ctx, url, "html", "")
+}
+
+func (c *ChromeScraper) HtmlFromTag(ctx context.Context, url string, tag string) (*string, error) {
+	return c.Render(ctx, url, tag, "")
+}
+
+// Fetch renders url, so the page is always html
+func (c *ChromeScraper) Fetch(ctx context.Context, url string) (*Page, error) {
+	html, location, err := c.render(ctx, url, "html", "")
+	if err != nil {
+		return nil, err
+	}
+	return &Page{Url: location, ContentType: "text/html; charset=utf-8", Body: []byte(*html)}, nil
+}
+
+// FetchIfModified renders url like Fetch. Page loads can't be made conditional, so the page is always
+// returned.
+func (c *ChromeScraper) FetchIfModified(ctx context.Context, url string, validators Validators) (*Page, error) {
+	return c.Fetch(ctx, url)
+}
+
+func (c *ChromeScraper) Render(ctx context.Context, url string, tag string, waitSelector string) (*string, error) {
+	html, _, err := c.render(ctx, url, tag, waitSelector)
+	return html, err
+}
+
+// render is Render, but also returns the url the page ended up at after any redirects
+func (c *ChromeScraper) render(ctx context.Context, url string, tag string, waitSelector string) (*string, string, error) {
+	formattedUrl, err := utils.FormatUrl(url)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to format url %s: %w", url, err)
+	}
+
+	if err := c.start(); err != nil {
+		return nil, "", err
+	}
+
+	if err := c.politeness.wait(ctx, formattedUrl); err != nil {
+		return nil, "", err
+	}
+
+	tabCtx, closeTab := chromedp.NewContext(c.browserCtx)
+	defer closeTab()
+
+	// The tab lives under the browser's context, so cancelling ctx has to be passed on to it
+	stop := context.AfterFunc(ctx, closeTab)
+	defer stop()
+
+	var html, location string
+	err = chromedp.Run(tabCtx,
+		page.SetLifecycleEventsEnabled(true),
+		load(formattedUrl, waitSelector),
+		chromedp.Location(&location),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if ctx.Err() != nil {
+		return nil, "", fmt.Errorf("failed to render %s: %w", formattedUrl, ctx.Err())
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to render %s: %w", formattedUrl, err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, "", err
+	}
+
+	tagHtml, err := doc.Find(tag).Html()
+	if err != nil {
+		return nil, "", err
+	}
+	return &tagHtml, location, nil
+}
+
+// Close shuts the browser down
+func (c *ChromeScraper) Close() {
+	if c.stopBrowser != nil {
+		c.stopBrowser()
+	}
+}
+
+func (c *ChromeScraper) start() error {
+	c.startOnce.Do(func() {
+		opts := append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.UserAgent(c.politeness.userAgent),
+			// Containers run as root, where Chromium refuses to start sandboxed
+			chromedp.NoSandbox,
+		)
+		if c.execPath != "" {
+			opts = append(opts, chromedp.ExecPath(c.execPath))
+		}
+
+		allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+		browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+
+		c.browserCtx = browserCtx
+		c.stopBrowser = func() {
+			cancelBrowser()
+			cancelAlloc()
+		}
+
+		// Running nothing starts the browser, so a missing executable is reported here
+		if err := chromedp.Run(browserCtx); err != nil {
+			c.startErr = fmt.Errorf("failed to start chromium: %w", err)
+		}
+	})
+
+	return c.startErr
+}
+
+// load navigates the tab to url and waits for an element matching waitSelector to be visible, or for
+// the page's network to go idle if there is no selector
+func load(url string, waitSelector string) chromedp.ActionFunc {
+	return func(ctx context.Context) error {
+		idle := make(chan cdp.LoaderID, 16)
+		chromedp.ListenTarget(ctx, func(ev any) {
+			if e, ok := ev.(*page.EventLifecycleEvent); ok && e.Name == "networkIdle" {
+				select {
+				case idle <- e.LoaderID:
+				default:
+				}
+			}
+		})
+
+		_, loaderId, errorText, err := page.Navigate(url).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if errorText != "" {
+			return fmt.Errorf("navigation failed: %s", errorText)
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, maxRenderWait)
+		defer cancel()
+
+		if waitSelector != "" {
+			if err := chromedp.WaitVisible(waitSelector, chromedp.ByQuery).Do(waitCtx); err != nil && waitCtx.Err() == nil {
+				return err
+			}
+			return ctx.Err()
+		}
+
+		// Lifecycle events are sent for every document the tab loads, including the blank page it
+		// starts on, so only the idle event of the document just navigated to counts
+		for {
+			select {
+			case id := <-idle:
+				if id == loaderId {
+					return nil
+				}
+			case <-waitCtx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const clientRenderedPage = `<html><body><main></main><script>
+setTimeout(() => {
+	document.querySelector("main").innerHTML = "<p id='content'>Rendered</p>";
+}, 100);
+</script></body></html>`
+
+func newTestChromeScraper(t *testing.T) *ChromeScraper {
+	for _, name := range []string{"google-chrome", "chromium", "chromium-browser", "headless-shell"} {
+		if _, err := exec.LookPath(name); err == nil {
+			chromeScraper := NewChromeScraper("").WithHostInterval(0)
+			t.Cleanup(chromeScraper.Close)
+			return chromeScraper
+		}
+	}
+
+	t.Skip("Skipping Chrome scraper as no Chrome is installed")
+	return nil
+}
+
+func TestChromeScraperRender(t *testing.T) {
+	chromeScraper := newTestChromeScraper(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(clientRenderedPage))
+	}))
+	defer server.Close()
+
+	html, err := chromeScraper.HtmlFromTag(context.Background(), server.URL, "main")
+	assert.NoError(t, err)
+	assert.Equal(t, `<p id="content">Rendered</p>`, *html)
+
+	html, err = chromeScraper.Render(context.Background(), server.URL, "main", "#content")
+	assert.NoError(t, err)
+	assert.Equal(t, `<p id="content">Rendered</p>`, *html)
+}
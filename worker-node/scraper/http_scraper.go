@@ -1,21 +1,56 @@
 package scraper
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/ethanhosier/worker-node/utils"
 )
 
+// maxFetchSize is the largest response body Fetch reads. Documents are parsed in memory, so bigger
+// ones are refused rather than truncated.
+const maxFetchSize = 32 << 20
+
 type HttpScraper struct {
+	client     *http.Client
+	politeness *politeness
 }
 
+// NewHttpScraper creates a scraper that follows robots.txt and spaces out requests to each host. The
+// spacing is only enforced within this process unless a shared HostLimiter is set.
 func NewHttpScraper() *HttpScraper {
-	return &HttpScraper{}
+	return &HttpScraper{
+		client:     http.DefaultClient,
+		politeness: newPoliteness(http.DefaultClient),
+	}
+}
+
+// WithUserAgent sets the User-Agent sent with requests, which is also the name robots.txt rules are
+// matched against
+func (h *HttpScraper) WithUserAgent(userAgent string) *HttpScraper {
+	h.politeness.setUserAgent(userAgent)
+	return h
 }
 
-func (h *HttpScraper) HtmlFrom(url string) (*string, error) {
+// WithHostLimiter sets the limiter that spaces out requests to each host, e.g. a coordinator client
+// so that the limit holds across every worker
+func (h *HttpScraper) WithHostLimiter(hostLimiter HostLimiter) *HttpScraper {
+	h.politeness.hostLimiter = hostLimiter
+	return h
+}
+
+// WithHostInterval sets the minimum time between requests to a host. A longer Crawl-delay in the
+// host's robots.txt takes precedence.
+func (h *HttpScraper) WithHostInterval(hostInterval time.Duration) *HttpScraper {
+	h.politeness.hostInterval = hostInterval
+	return h
+}
+
+func (h *HttpScraper) HtmlFrom(ctx context.Context, url string) (*string, error) {
 
 	formattedUrl, err := utils.FormatUrl(url)
 	if err != nil {
@@ -23,12 +58,16 @@ func (h *HttpScraper) HtmlFrom(url string) (*string, error) {
 	}
 
 	// Make HTTP GET request
-	resp, err := http.Get(formattedUrl)
+	resp, err := h.get(ctx, formattedUrl, Validators{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to make http get request for url %s: %w", formattedUrl, err)
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(formattedUrl, resp); err != nil {
+		return nil, err
+	}
+
 	// Create a goquery document from the HTTP response
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
@@ -43,19 +82,23 @@ func (h *HttpScraper) HtmlFrom(url string) (*string, error) {
 	return &html, nil
 }
 
-func (h *HttpScraper) HtmlFromTag(url string, tag string) (*string, error) {
+func (h *HttpScraper) HtmlFromTag(ctx context.Context, url string, tag string) (*string, error) {
 	formattedUrl, err := utils.FormatUrl(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to format url %s: %w", url, err)
 	}
 
 	// Make HTTP GET request directly (don't reuse HtmlFrom to avoid double parsing)
-	resp, err := http.Get(formattedUrl)
+	resp, err := h.get(ctx, formattedUrl, Validators{})
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(formattedUrl, resp); err != nil {
+		return nil, err
+	}
+
 	// Create a goquery document from the HTTP response
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
@@ -69,3 +112,83 @@ func (h *HttpScraper) HtmlFromTag(url string, tag string) (*string, error) {
 	}
 	return &html, nil
 }
+
+func (h *HttpScraper) Fetch(ctx context.Context, url string) (*Page, error) {
+	return h.FetchIfModified(ctx, url, Validators{})
+}
+
+// FetchIfModified sends validators as If-None-Match and If-Modified-Since, so an unchanged page costs
+// the server a 304 rather than the whole page. Only a successful response is returned as the page, so
+// an error page is never stored in place of the content it stands in for.
+func (h *HttpScraper) FetchIfModified(ctx context.Context, url string, validators Validators) (*Page, error) {
+	formattedUrl, err := utils.FormatUrl(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to format url %s: %w", url, err)
+	}
+
+	resp, err := h.get(ctx, formattedUrl, validators)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make http get request for url %s: %w", formattedUrl, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, fmt.Errorf("%w: %s", ErrNotModified, formattedUrl)
+	}
+
+	if err := checkStatus(formattedUrl, resp); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for url %s: %w", formattedUrl, err)
+	}
+	if len(body) > maxFetchSize {
+		return nil, fmt.Errorf("response for url %s is larger than %d bytes", formattedUrl, maxFetchSize)
+	}
+
+	return &Page{
+		Url:          resp.Request.URL.String(),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// checkStatus returns an error for a response that isn't a success. 4xx responses other than 429 are
+// ErrClientError, as asking again won't change them.
+func checkStatus(url string, resp *http.Response) error {
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s returned %s", ErrClientError, url, resp.Status)
+	default:
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+}
+
+// get makes a GET request that is cancelled along with ctx, including while the body is being read.
+// The request is only made if robots.txt allows it, once the host's next request slot has come up. It
+// is conditional on any validators that are set.
+func (h *HttpScraper) get(ctx context.Context, url string, validators Validators) (*http.Response, error) {
+	if err := h.politeness.wait(ctx, url); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", h.politeness.userAgent)
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	return h.client.Do(req)
+}
@@ -1,11 +1,17 @@
 package scraper
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMain(m *testing.M) {
@@ -23,7 +29,7 @@ func TestHttpScraper(t *testing.T) {
 
 	scraper := NewHttpScraper()
 
-	html, err := scraper.HtmlFrom("https://www.imperial.ac.uk/study/courses/undergraduate/computing-meng/")
+	html, err := scraper.HtmlFrom(context.Background(), "https://www.imperial.ac.uk/study/courses/undergraduate/computing-meng/")
 	if err != nil {
 		t.Fatalf("Error getting HTML: %v", err)
 	}
@@ -38,10 +44,155 @@ func TestHttpScraperHtmlFromTag(t *testing.T) {
 
 	scraper := NewHttpScraper()
 
-	html, err := scraper.HtmlFromTag("https://www.imperial.ac.uk/study/courses/undergraduate/computing-meng/", "main")
+	html, err := scraper.HtmlFromTag(context.Background(), "https://www.imperial.ac.uk/study/courses/undergraduate/computing-meng/", "main")
 	if err != nil {
 		t.Fatalf("Error getting HTML: %v", err)
 	}
 
 	t.Logf("HTML: %v", *html)
 }
+
+func TestHttpScraperCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := NewHttpScraper().HtmlFrom(ctx, server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got: %v", err)
+	}
+}
+
+func TestHttpScraperFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	page, err := NewHttpScraper().Fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", page.ContentType)
+	assert.Equal(t, []byte("%PDF-1.4"), page.Body)
+}
+
+func TestHttpScraperFetchRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/docs" {
+			http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+			return
+		}
+		w.Write([]byte(`<html><body><a href="intro">Intro</a></body></html>`))
+	}))
+	defer server.Close()
+
+	page, err := NewHttpScraper().WithHostInterval(0).Fetch(context.Background(), server.URL+"/docs")
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/docs/", page.Url)
+}
+
+func TestHttpScraperFetchIfModified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.Write([]byte("<html><body>Changed</body></html>"))
+	}))
+	defer server.Close()
+
+	_, err := NewHttpScraper().FetchIfModified(context.Background(), server.URL, Validators{ETag: `"v1"`})
+	assert.ErrorIs(t, err, ErrNotModified)
+
+	page, err := NewHttpScraper().FetchIfModified(context.Background(), server.URL, Validators{ETag: `"v0"`})
+	assert.NoError(t, err)
+	assert.Equal(t, `"v2"`, page.ETag)
+	assert.Equal(t, "Wed, 21 Oct 2015 07:28:00 GMT", page.LastModified)
+	assert.Equal(t, []byte("<html><body>Changed</body></html>"), page.Body)
+}
+
+func TestHttpScraperFetchStatus(t *testing.T) {
+	tests := []struct {
+		status      int
+		clientError bool
+	}{
+		{http.StatusNotFound, true},
+		{http.StatusGone, true},
+		{http.StatusForbidden, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusServiceUnavailable, false},
+		{http.StatusInternalServerError, false},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte("<html><body>Something went wrong</body></html>"))
+			}))
+			defer server.Close()
+
+			page, err := NewHttpScraper().FetchIfModified(context.Background(), server.URL, Validators{})
+			assert.Error(t, err)
+			assert.Nil(t, page)
+			assert.Equal(t, test.clientError, errors.Is(err, ErrClientError))
+		})
+	}
+}
+
+func TestHttpScraperRobots(t *testing.T) {
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Write([]byte("<html><body><main>Hello</main></body></html>"))
+	}))
+	defer server.Close()
+
+	scraper := NewHttpScraper().WithUserAgent("test-crawler/1.0").WithHostInterval(0)
+
+	_, err := scraper.HtmlFrom(context.Background(), server.URL+"/private/page")
+	if !errors.Is(err, ErrDisallowedByRobots) {
+		t.Fatalf("Expected disallowed by robots.txt, got: %v", err)
+	}
+
+	html, err := scraper.HtmlFromTag(context.Background(), server.URL+"/public", "main")
+	if err != nil {
+		t.Fatalf("Error getting HTML: %v", err)
+	}
+
+	assert.Equal(t, "Hello", *html)
+	assert.Equal(t, []string{"test-crawler/1.0", "test-crawler/1.0"}, userAgents, "robots.txt should only be fetched once")
+}
+
+func TestHttpScraperHostInterval(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	scraper := NewHttpScraper().WithHostInterval(200 * time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := scraper.HtmlFrom(context.Background(), server.URL); err != nil {
+			t.Fatalf("Error getting HTML: %v", err)
+		}
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond, "requests to a host should be spaced out")
+}
@@ -1,30 +1,57 @@
 package scraper
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type MockScraper struct {
-	htmlContent map[string]string
+	htmlContent  map[string]string
+	contentTypes map[string]string
+	validators   map[string]Validators
 }
 
 func NewMockScraper() *MockScraper {
 	return &MockScraper{
-		htmlContent: make(map[string]string),
+		htmlContent:  make(map[string]string),
+		contentTypes: make(map[string]string),
+		validators:   make(map[string]Validators),
 	}
 }
 
 // SetHtmlContent sets the HTML content to be returned for a specific URL
 func (m *MockScraper) SetHtmlContent(url string, content string) {
+	m.SetContent(url, "text/html; charset=utf-8", content)
+}
+
+// SetContent sets the content, and the content type it is fetched with, for a specific URL
+func (m *MockScraper) SetContent(url string, contentType string, content string) {
 	m.htmlContent[url] = content
+	m.contentTypes[url] = contentType
+}
+
+// SetValidators sets the validators a URL is fetched with, which FetchIfModified treats it as unchanged
+// for
+func (m *MockScraper) SetValidators(url string, etag string, lastModified string) {
+	m.validators[url] = Validators{ETag: etag, LastModified: lastModified}
 }
 
-func (m *MockScraper) HtmlFrom(url string) (*string, error) {
+func (m *MockScraper) HtmlFrom(ctx context.Context, url string) (*string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if content, exists := m.htmlContent[url]; exists {
 		return &content, nil
 	}
 	return nil, fmt.Errorf("no mock content set for URL: %s", url)
 }
 
-func (m *MockScraper) HtmlFromTag(url string, tag string) (*string, error) {
+func (m *MockScraper) HtmlFromTag(ctx context.Context, url string, tag string) (*string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// For simplicity, we'll return the entire mock content
 	// In a more sophisticated implementation, you could store and return tag-specific content
 	if content, exists := m.htmlContent[url]; exists {
@@ -32,3 +59,28 @@ func (m *MockScraper) HtmlFromTag(url string, tag string) (*string, error) {
 	}
 	return nil, fmt.Errorf("no mock content set for URL: %s", url)
 }
+
+func (m *MockScraper) Fetch(ctx context.Context, url string) (*Page, error) {
+	content, err := m.HtmlFrom(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	validators := m.validators[url]
+	return &Page{Url: url, ContentType: m.contentTypes[url], Body: []byte(*content), ETag: validators.ETag, LastModified: validators.LastModified}, nil
+}
+
+// FetchIfModified returns ErrNotModified if validators match the ones set for url
+func (m *MockScraper) FetchIfModified(ctx context.Context, url string, validators Validators) (*Page, error) {
+	if current, ok := m.validators[url]; ok && !validators.IsZero() && validators == current {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotModified, url)
+	}
+	return m.Fetch(ctx, url)
+}
+
+// Render returns the mock content for url, so a MockScraper can also stand in for a Renderer
+func (m *MockScraper) Render(ctx context.Context, url string, tag string, waitSelector string) (*string, error) {
+	return m.HtmlFromTag(ctx, url, tag)
+}
@@ -1,6 +1,8 @@
 package scraper
 
 import (
+	"context"
+	"errors"
 	"testing"
 )
 
@@ -12,7 +14,7 @@ func TestMockScraper(t *testing.T) {
 
 		mock.SetHtmlContent(testURL, expectedContent)
 
-		result, err := mock.HtmlFrom(testURL)
+		result, err := mock.HtmlFrom(context.Background(), testURL)
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
 		}
@@ -25,7 +27,7 @@ func TestMockScraper(t *testing.T) {
 		mock := NewMockScraper()
 		testURL := "http://example.com"
 
-		_, err := mock.HtmlFrom(testURL)
+		_, err := mock.HtmlFrom(context.Background(), testURL)
 		if err == nil {
 			t.Error("Expected error for unset URL, got nil")
 		}
@@ -38,7 +40,7 @@ func TestMockScraper(t *testing.T) {
 
 		mock.SetHtmlContent(testURL, expectedContent)
 
-		result, err := mock.HtmlFromTag(testURL, "div")
+		result, err := mock.HtmlFromTag(context.Background(), testURL, "div")
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
 		}
@@ -51,9 +53,30 @@ func TestMockScraper(t *testing.T) {
 		mock := NewMockScraper()
 		testURL := "http://example.com"
 
-		_, err := mock.HtmlFromTag(testURL, "div")
+		_, err := mock.HtmlFromTag(context.Background(), testURL, "div")
 		if err == nil {
 			t.Error("Expected error for unset URL, got nil")
 		}
 	})
+
+	t.Run("FetchIfModified returns ErrNotModified for matching validators", func(t *testing.T) {
+		mock := NewMockScraper()
+		testURL := "http://example.com"
+
+		mock.SetHtmlContent(testURL, "<div>Test Content</div>")
+		mock.SetValidators(testURL, `"v1"`, "")
+
+		_, err := mock.FetchIfModified(context.Background(), testURL, Validators{ETag: `"v1"`})
+		if !errors.Is(err, ErrNotModified) {
+			t.Errorf("Expected ErrNotModified, got %v", err)
+		}
+
+		page, err := mock.FetchIfModified(context.Background(), testURL, Validators{ETag: `"v0"`})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if page.ETag != `"v1"` {
+			t.Errorf("Expected ETag %q, got %q", `"v1"`, page.ETag)
+		}
+	})
 }
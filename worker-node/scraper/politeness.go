@@ -0,0 +1,114 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultUserAgent = "web-crawler/1.0"
+
+	// defaultHostInterval is the minimum time between requests to one host when its robots.txt
+	// doesn't ask for a longer crawl delay
+	defaultHostInterval = time.Second
+)
+
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// HostLimiter hands out request slots per host. It is satisfied by the coordinator clients, which
+// share the slots between every worker.
+type HostLimiter interface {
+	// ReserveHost reserves the next request to host, keeping requests at least interval apart, and
+	// returns how long the caller must wait before making it
+	ReserveHost(ctx context.Context, host string, interval time.Duration) (time.Duration, error)
+}
+
+// politeness decides whether and when a page may be requested, following the host's robots.txt and
+// spacing out requests to it
+type politeness struct {
+	client       *http.Client
+	userAgent    string
+	robots       *robotsCache
+	hostLimiter  HostLimiter
+	hostInterval time.Duration
+}
+
+func newPoliteness(client *http.Client) *politeness {
+	return &politeness{
+		client:       client,
+		userAgent:    DefaultUserAgent,
+		robots:       newRobotsCache(client, DefaultUserAgent),
+		hostLimiter:  newLocalHostLimiter(),
+		hostInterval: defaultHostInterval,
+	}
+}
+
+func (p *politeness) setUserAgent(userAgent string) {
+	p.userAgent = userAgent
+	p.robots = newRobotsCache(p.client, userAgent)
+}
+
+// wait returns once rawUrl may be requested, or with ErrDisallowedByRobots if it may not be
+func (p *politeness) wait(ctx context.Context, rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	rules, err := p.robots.rulesFor(ctx, u.Scheme, u.Host)
+	if err != nil {
+		return err
+	}
+
+	if !rules.allowed(u.EscapedPath()) {
+		return fmt.Errorf("%w: %s", ErrDisallowedByRobots, rawUrl)
+	}
+
+	wait, err := p.hostLimiter.ReserveHost(ctx, u.Host, max(p.hostInterval, rules.crawlDelay))
+	if err != nil {
+		return fmt.Errorf("failed to reserve request to %s: %w", u.Host, err)
+	}
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// localHostLimiter spaces out requests made by this process only
+type localHostLimiter struct {
+	slots map[string]time.Time // host -> time its next request slot opens
+	mutex sync.Mutex
+}
+
+func newLocalHostLimiter() *localHostLimiter {
+	return &localHostLimiter{slots: make(map[string]time.Time)}
+}
+
+func (l *localHostLimiter) ReserveHost(ctx context.Context, host string, interval time.Duration) (time.Duration, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	slot := l.slots[host]
+	if slot.Before(now) {
+		slot = now
+	}
+
+	l.slots[host] = slot.Add(interval)
+	return slot.Sub(now), nil
+}
@@ -0,0 +1,226 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	robotsTTL = time.Hour
+
+	// maxRobotsSize is how much of a robots.txt file is read, matching the 500KiB limit in RFC 9309
+	maxRobotsSize = 500 * 1024
+)
+
+// robotsRules are the rules in a robots.txt file that apply to our user agent
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+}
+
+var (
+	allowAll    = &robotsRules{}
+	disallowAll = &robotsRules{disallow: []string{"/"}}
+)
+
+// allowed reports whether path may be fetched. The longest matching rule wins and allow wins ties.
+func (r *robotsRules) allowed(path string) bool {
+	longestAllow, longestDisallow := -1, -1
+
+	for _, pattern := range r.allow {
+		if len(pattern) > longestAllow && robotsPatternMatches(pattern, path) {
+			longestAllow = len(pattern)
+		}
+	}
+
+	for _, pattern := range r.disallow {
+		if len(pattern) > longestDisallow && robotsPatternMatches(pattern, path) {
+			longestDisallow = len(pattern)
+		}
+	}
+
+	return longestAllow >= longestDisallow
+}
+
+// robotsPatternMatches matches path against a robots.txt path pattern, where * matches any sequence
+// of characters and a trailing $ anchors the pattern to the end of the path
+func robotsPatternMatches(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	if len(parts) == 1 {
+		return !anchored || path == parts[0]
+	}
+	path = path[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, part)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(part):]
+	}
+
+	if anchored {
+		return strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path, last)
+}
+
+// parseRobots parses a robots.txt file and returns the rules of the group that best matches
+// userAgent, falling back to the * group. Rules are grouped by consecutive user-agent lines.
+func parseRobots(body io.Reader, userAgent string) *robotsRules {
+	agent := strings.ToLower(productToken(userAgent))
+
+	var (
+		matched, wildcard *robotsRules
+		group             *robotsRules
+		groupMatches      bool
+		groupIsWildcard   bool
+		inAgents          bool
+	)
+
+	scanner := bufio.NewScanner(io.LimitReader(body, maxRobotsSize))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !inAgents {
+				group = &robotsRules{}
+				groupMatches, groupIsWildcard = false, false
+				inAgents = true
+			}
+
+			name := strings.ToLower(value)
+			if name == "*" && wildcard == nil {
+				wildcard, groupIsWildcard = group, true
+			} else if name != "*" && agent != "" && strings.HasPrefix(agent, name) && matched == nil {
+				matched, groupMatches = group, true
+			}
+			continue
+		}
+
+		inAgents = false
+		if group == nil || (!groupMatches && !groupIsWildcard) {
+			continue
+		}
+
+		switch key {
+		case "allow":
+			if value != "" {
+				group.allow = append(group.allow, value)
+			}
+		case "disallow":
+			if value != "" {
+				group.disallow = append(group.disallow, value)
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				group.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	if matched != nil {
+		return matched
+	}
+	if wildcard != nil {
+		return wildcard
+	}
+	return allowAll
+}
+
+// productToken returns the name robots.txt groups are matched against, e.g. "webcrawler" for
+// "webcrawler/1.0 (+https://example.com)"
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), " ")
+	token, _, _ = strings.Cut(token, "/")
+	return token
+}
+
+type robotsEntry struct {
+	rules   *robotsRules
+	fetched time.Time
+}
+
+// robotsCache fetches robots.txt once per host and keeps the parsed rules for robotsTTL
+type robotsCache struct {
+	client    *http.Client
+	userAgent string
+	entries   map[string]*robotsEntry // scheme://host -> rules
+	mutex     sync.Mutex
+}
+
+func newRobotsCache(client *http.Client, userAgent string) *robotsCache {
+	return &robotsCache{
+		client:    client,
+		userAgent: userAgent,
+		entries:   make(map[string]*robotsEntry),
+	}
+}
+
+func (c *robotsCache) rulesFor(ctx context.Context, scheme string, host string) (*robotsRules, error) {
+	origin := scheme + "://" + host
+
+	c.mutex.Lock()
+	entry, ok := c.entries[origin]
+	c.mutex.Unlock()
+
+	if ok && time.Since(entry.fetched) < robotsTTL {
+		return entry.rules, nil
+	}
+
+	rules, err := c.fetch(ctx, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.entries[origin] = &robotsEntry{rules: rules, fetched: time.Now()}
+	c.mutex.Unlock()
+
+	return rules, nil
+}
+
+// fetch downloads and parses the robots.txt of origin. A missing file allows everything, and a file
+// that can't be read because of a server error disallows everything until it is fetched again.
+func (c *robotsCache) fetch(ctx context.Context, origin string) (*robotsRules, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch robots.txt for %s: %w", origin, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return parseRobots(resp.Body, c.userAgent), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return allowAll, nil
+	default:
+		return disallowAll, nil
+	}
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRobots = `
+# Comments and blank lines are ignored
+
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Crawl-delay: 2
+
+User-agent: other-crawler
+User-agent: web-crawler
+Disallow: /search$
+Disallow: /*.pdf$
+Allow: /search/help
+Crawl-delay: 0.5
+`
+
+func TestParseRobots(t *testing.T) {
+	rules := parseRobots(strings.NewReader(testRobots), "web-crawler/1.0 (+https://example.com)")
+
+	assert.Equal(t, 500*time.Millisecond, rules.crawlDelay)
+	assert.True(t, rules.allowed("/private"), "the wildcard group should not apply when a group names us")
+	assert.False(t, rules.allowed("/search"))
+	assert.True(t, rules.allowed("/search/results"))
+	assert.False(t, rules.allowed("/files/report.pdf"))
+	assert.True(t, rules.allowed("/files/report.pdf.html"))
+
+	rules = parseRobots(strings.NewReader(testRobots), "somebody-else")
+
+	assert.Equal(t, 2*time.Second, rules.crawlDelay)
+	assert.False(t, rules.allowed("/private/page"))
+	assert.True(t, rules.allowed("/private/public/page"), "the longest matching rule should win")
+	assert.True(t, rules.allowed("/"))
+}
+
+func TestParseRobotsEmpty(t *testing.T) {
+	rules := parseRobots(strings.NewReader(""), DefaultUserAgent)
+
+	assert.True(t, rules.allowed("/anything"))
+	assert.Zero(t, rules.crawlDelay)
+}
+
+func TestRobotsPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish$", "/fish", true},
+		{"/fish$", "/fish/", false},
+		{"/*.php", "/index.php?page=1", true},
+		{"/*.php$", "/a.php/b.php", true},
+		{"/*.php$", "/a.php/b", false},
+		{"/a*b*c", "/a-c-b", false},
+		{"/a*", "/a", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, robotsPatternMatches(tt.pattern, tt.path), "%s against %s", tt.pattern, tt.path)
+	}
+}
@@ -1,6 +1,49 @@
 package scraper
 
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNotModified is returned by FetchIfModified when a page hasn't changed
+	ErrNotModified = errors.New("not modified")
+
+	// ErrClientError is returned when a page can't be fetched because of the request, like a 404 or a
+	// 403, which trying again won't change. Other failed responses, like a 429 or a 503, may succeed
+	// later, so are returned as other errors.
+	ErrClientError = errors.New("client error")
+)
+
 type Scraper interface {
-	HtmlFrom(url string) (*string, error)
-	HtmlFromTag(url string, tag string) (*string, error)
+	HtmlFrom(ctx context.Context, url string) (*string, error)
+	HtmlFromTag(ctx context.Context, url string, tag string) (*string, error)
+
+	// Fetch returns the url's response body as it is, for pages that might not be html
+	Fetch(ctx context.Context, url string) (*Page, error)
+
+	// FetchIfModified is Fetch, but returns ErrNotModified rather than the page if the server says it
+	// hasn't changed since the version validators are from
+	FetchIfModified(ctx context.Context, url string, validators Validators) (*Page, error)
+}
+
+// Page is the response to a fetched url. Url is where the page was served from, after any redirects,
+// so relative links in it resolve against it. ETag and LastModified are its validators, if the server
+// sent any.
+type Page struct {
+	Url          string
+	ContentType  string
+	Body         []byte
+	ETag         string
+	LastModified string
+}
+
+// Validators identify a version of a page, as the ETag and Last-Modified headers it was served with
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
 }
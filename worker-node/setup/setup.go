@@ -0,0 +1,241 @@
+package setup
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/ethanhosier/worker-node/llm"
+	"github.com/ethanhosier/worker-node/query"
+	"github.com/ethanhosier/worker-node/ragger"
+	"github.com/ethanhosier/worker-node/scraper"
+	"github.com/ethanhosier/worker-node/storage"
+	"github.com/ethanhosier/worker-node/utils"
+	"github.com/ethanhosier/worker-node/worker_manager"
+	"github.com/sugarme/tokenizer/pretrained"
+)
+
+// The model files are looked for relative to the working directory, where the images put them
+var (
+	modelPath     = filepath.Join("model", "model.onnx")
+	libraryPath   = filepath.Join("libonnxruntime.so.1.20.1")
+	tokenizerPath = filepath.Join("model", "tokenizer.json")
+)
+
+// NewRAGClient loads the embedding model
+func NewRAGClient() *ragger.RAGClient {
+	return ragger.NewRAGClient(modelPath, libraryPath, tokenizerPath)
+}
+
+// ConfigureWorkerManager applies the settings every worker manager takes from the environment
+func ConfigureWorkerManager(workerManager *worker_manager.WorkerManager) *worker_manager.WorkerManager {
+	if shutdownGracePeriod := os.Getenv("SHUTDOWN_GRACE_PERIOD"); shutdownGracePeriod != "" {
+		gracePeriod, err := time.ParseDuration(shutdownGracePeriod)
+		if err != nil {
+			log.Fatalf("Invalid SHUTDOWN_GRACE_PERIOD: %v", err)
+		}
+		workerManager.WithShutdownGracePeriod(gracePeriod)
+	}
+
+	if taskTimeoutEnv := os.Getenv("TASK_TIMEOUT"); taskTimeoutEnv != "" {
+		taskTimeout, err := time.ParseDuration(taskTimeoutEnv)
+		if err != nil {
+			log.Fatalf("Invalid TASK_TIMEOUT: %v", err)
+		}
+		if taskTimeout > worker_manager.MaxTaskTimeout {
+			log.Fatalf("Invalid TASK_TIMEOUT: it can be at most %s, as tasks are only leased for %s", worker_manager.MaxTaskTimeout, coordinator_client.LeaseDuration)
+		}
+		workerManager.WithTaskTimeout(taskTimeout)
+	}
+
+	// The retry settings left unset keep the defaults for the manager's topic
+	retryPolicy := workerManager.RetryPolicy()
+	if maxAttempts := os.Getenv("MAX_ATTEMPTS"); maxAttempts != "" {
+		retryPolicy.MaxAttempts = utils.RequiredInt(maxAttempts, "MAX_ATTEMPTS")
+		if retryPolicy.MaxAttempts < 1 {
+			log.Fatalf("Invalid MAX_ATTEMPTS: a task must be attempted at least once")
+		}
+	}
+	if backoffBase := os.Getenv("RETRY_BACKOFF_BASE"); backoffBase != "" {
+		base, err := time.ParseDuration(backoffBase)
+		if err != nil {
+			log.Fatalf("Invalid RETRY_BACKOFF_BASE: %v", err)
+		}
+		retryPolicy.BackoffBase = base
+	}
+	if backoffCap := os.Getenv("RETRY_BACKOFF_CAP"); backoffCap != "" {
+		maxBackoff, err := time.ParseDuration(backoffCap)
+		if err != nil {
+			log.Fatalf("Invalid RETRY_BACKOFF_CAP: %v", err)
+		}
+		retryPolicy.BackoffCap = maxBackoff
+	}
+	if retryPolicy.BackoffBase > retryPolicy.BackoffCap {
+		log.Fatalf("Invalid retry backoff: the base %s is longer than the cap %s", retryPolicy.BackoffBase, retryPolicy.BackoffCap)
+	}
+	workerManager.WithRetryPolicy(retryPolicy)
+
+	return workerManager
+}
+
+// NewScraperWorkerManager creates the scraper worker manager, along with a function that shuts down the
+// headless browser if BROWSER_RENDERING is enabled. Pages stored before are looked up in store, if it
+// isn't nil, so they are only fetched in full if they have changed.
+func NewScraperWorkerManager(coordinatorClient coordinator_client.CoordinatorClient, concurrency int, store storage.Storage) (*worker_manager.WorkerManager, func()) {
+	var (
+		scraperClient = scraper.NewHttpScraper().WithHostLimiter(coordinatorClient)
+		workerManager = worker_manager.NewScraperWorkerManager(context.TODO(), coordinatorClient, scraperClient, concurrency)
+		closeScraper  = func() {}
+	)
+
+	// Large pages are only put in a blob store that has been chosen, as the default local one isn't
+	// shared with rag workers on other hosts, which couldn't read them
+	if os.Getenv("BLOB_STORE") != "" || os.Getenv("MAX_INLINE_PAYLOAD") != "" {
+		workerManager.WithBlobStore(NewBlobStore())
+	}
+
+	if maxInlinePayload := os.Getenv("MAX_INLINE_PAYLOAD"); maxInlinePayload != "" {
+		workerManager.WithMaxInlinePayload(utils.RequiredInt(maxInlinePayload, "MAX_INLINE_PAYLOAD"))
+	}
+
+	if store != nil {
+		workerManager.WithStore(store)
+	}
+
+	var chromeScraper *scraper.ChromeScraper
+	if os.Getenv("BROWSER_RENDERING") == "true" {
+		chromeScraper = scraper.NewChromeScraper(os.Getenv("CHROME_PATH")).WithHostLimiter(coordinatorClient)
+		workerManager.WithRenderer(chromeScraper)
+		closeScraper = chromeScraper.Close
+	}
+
+	if userAgent := os.Getenv("USER_AGENT"); userAgent != "" {
+		scraperClient.WithUserAgent(userAgent)
+		if chromeScraper != nil {
+			chromeScraper.WithUserAgent(userAgent)
+		}
+	}
+
+	if hostIntervalEnv := os.Getenv("HOST_REQUEST_INTERVAL"); hostIntervalEnv != "" {
+		hostInterval, err := time.ParseDuration(hostIntervalEnv)
+		if err != nil {
+			log.Fatalf("Invalid HOST_REQUEST_INTERVAL: %v", err)
+		}
+		scraperClient.WithHostInterval(hostInterval)
+		if chromeScraper != nil {
+			chromeScraper.WithHostInterval(hostInterval)
+		}
+	}
+
+	return workerManager, closeScraper
+}
+
+// NewRagWorkerManager creates the rag worker manager, which stores what it embeds in store
+func NewRagWorkerManager(coordinatorClient coordinator_client.CoordinatorClient, ragClient ragger.Ragger, store storage.Storage) *worker_manager.WorkerManager {
+	return worker_manager.NewRagWorkerManager(context.TODO(), coordinatorClient, ragClient, store, 1).WithBlobStore(NewBlobStore())
+}
+
+// NewQueryServer creates the server for searching the chunks in store, listening on LISTEN_ADDR
+func NewQueryServer(ragClient ragger.Ragger, store storage.Storage) *query.Server {
+	listenAddr := os.Getenv("LISTEN_ADDR")
+	if listenAddr == "" {
+		listenAddr = ":80"
+	}
+
+	searcher := query.NewSearcher(ragClient, store)
+	if reranker := newReranker(); reranker != nil {
+		searcher.WithReranker(reranker)
+	}
+
+	server := query.NewServer(listenAddr, searcher)
+	if generator := newGenerator(); generator != nil {
+		server.WithAsker(query.NewAsker(searcher, generator))
+	}
+
+	log.Printf("Starting query server on %s", listenAddr)
+	return server
+}
+
+// newReranker loads the cross-encoder search results can be reranked with from RERANKER_MODEL_PATH and
+// RERANKER_TOKENIZER_PATH. Without one, requests can't ask for reranking.
+func newReranker() ragger.Reranker {
+	rerankerModelPath := os.Getenv("RERANKER_MODEL_PATH")
+	if rerankerModelPath == "" {
+		return nil
+	}
+
+	tok, err := pretrained.FromFile(utils.Required(os.Getenv("RERANKER_TOKENIZER_PATH"), "RERANKER_TOKENIZER_PATH"))
+	if err != nil {
+		log.Fatalf("Error loading reranker tokenizer: %v", err)
+	}
+
+	reranker, err := ragger.NewCrossEncoder(rerankerModelPath, libraryPath, tok)
+	if err != nil {
+		log.Fatalf("Error loading reranker: %v", err)
+	}
+	return reranker
+}
+
+// newGenerator creates the generation backend questions are answered with, chosen by LLM_BACKEND: an
+// OpenAI compatible API, or a local llama.cpp server. Without one the query server only searches.
+func newGenerator() llm.Generator {
+	var maxTokens int
+	if maxTokensEnv := os.Getenv("LLM_MAX_TOKENS"); maxTokensEnv != "" {
+		maxTokens = utils.RequiredInt(maxTokensEnv, "LLM_MAX_TOKENS")
+	}
+
+	switch backend := os.Getenv("LLM_BACKEND"); backend {
+	case "":
+		return nil
+	case "openai":
+		baseUrl := os.Getenv("LLM_BASE_URL")
+		if baseUrl == "" {
+			baseUrl = llm.DefaultOpenAIBaseUrl
+		}
+		return llm.NewOpenAIGenerator(baseUrl, os.Getenv("LLM_API_KEY"), utils.Required(os.Getenv("LLM_MODEL"), "LLM_MODEL")).WithMaxTokens(maxTokens)
+	case "llamacpp":
+		return llm.NewLlamaCppGenerator(utils.Required(os.Getenv("LLM_BASE_URL"), "LLM_BASE_URL")).WithMaxTokens(maxTokens)
+	default:
+		log.Fatalf("Unknown LLM backend: %s", backend)
+		return nil
+	}
+}
+
+// NewStore connects to the database rag sources and chunks are stored in: a bbolt file at BOLT_PATH if
+// it is set, for running on one machine with no database, Postgres at DATABASE_URL, for self-hosted
+// deployments, or Supabase otherwise. A bbolt file can only be opened by one process at a time, so it
+// is for the all-in-one binary, which runs every worker in one process.
+func NewStore() storage.Storage {
+	if boltPath := os.Getenv("BOLT_PATH"); boltPath != "" {
+		boltStorage, err := storage.NewBoltStorage(boltPath)
+		if err != nil {
+			log.Fatalf("Error opening bbolt storage: %v", err)
+		}
+		return boltStorage
+	}
+
+	if databaseUrl := os.Getenv("DATABASE_URL"); databaseUrl != "" {
+		postgresStorage, err := storage.NewPostgresStorage(context.Background(), databaseUrl)
+		if err != nil {
+			log.Fatalf("Error connecting to Postgres: %v", err)
+		}
+		return postgresStorage
+	}
+
+	return storage.NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
+}
+
+// NewBlobStore creates the store uploaded documents and large pages are kept in, which must be shared
+// with the coordinator and the other workers (see blob_store.FromEnv). Scraper workers only put large
+// pages in it if BLOB_STORE or MAX_INLINE_PAYLOAD is set.
+func NewBlobStore() blob_store.BlobStore {
+	blobStore, err := blob_store.FromEnv()
+	if err != nil {
+		log.Fatalf("Error creating blob store: %v", err)
+	}
+	return blobStore
+}
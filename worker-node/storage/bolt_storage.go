@@ -0,0 +1,595 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStorage stores to a single bbolt file, so the all-in-one binary, which runs everything in one
+// process, can scrape, embed, store and search with no other services, and keep what it stored across
+// restarts. Only that process can have the file open. Each table is a bucket of its items as JSON,
+// keyed by id, and numeric ids come from the bucket's sequence. The embeddings and keyword indexed
+// text of the items are also indexed in memory for searching, and the indexes are rebuilt from the
+// file when it is opened.
+type BoltStorage struct {
+	db *bolt.DB
+
+	mu       sync.RWMutex // Held for writing while the file is written, so the indexes change with it
+	vectors  map[StorageTableName]*vectorIndex
+	keywords map[StorageTableName]*keywordIndex
+}
+
+// boltItem identifies an item written by a bolt transaction
+type boltItem struct {
+	table StorageTableName
+	id    string
+}
+
+// boltWriter makes writes in a bolt transaction, keeping the items it changes so the indexes can be
+// updated once the transaction is committed
+type boltWriter struct {
+	tx      *bolt.Tx
+	changed map[boltItem]map[string]interface{} // The items as they are now, nil for those deleted
+}
+
+// NewBoltStorage opens the bbolt file at path, creating it if it doesn't exist. Only one process can
+// have the file open at a time.
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	s := &BoltStorage{
+		db:       db,
+		vectors:  make(map[StorageTableName]*vectorIndex),
+		keywords: make(map[StorageTableName]*keywordIndex, len(keywordFields)),
+	}
+	for table := range keywordFields {
+		s.keywords[table] = newKeywordIndex()
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return b.ForEach(func(k, v []byte) error {
+				item, err := decodeItem(v)
+				if err != nil {
+					return fmt.Errorf("failed to read %s %s: %w", name, k, err)
+				}
+				s.index(StorageTableName(name), string(k), item)
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close closes the file
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltStorage) store(ctx context.Context, table StorageTableName, data interface{}) (interface{}, error) {
+	stored, err := s.storeAll(ctx, table, []interface{}{data})
+	if err != nil {
+		return nil, err
+	}
+	return stored[0], nil
+}
+
+func (s *BoltStorage) storeAll(ctx context.Context, table StorageTableName, data []interface{}) ([]interface{}, error) {
+	result := make([]interface{}, len(data))
+	err := s.write(ctx, func(w *boltWriter) error {
+		for i, item := range data {
+			itemMap, err := toMap(item)
+			if err != nil {
+				return err
+			}
+
+			if err := w.insert(table, item, itemMap); err != nil {
+				return err
+			}
+			result[i] = itemMap
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// upsert stores the items in one bolt transaction, merging each into the stored item with the same
+// values of the conflict keys if there is one, as update does. Like Postgres, it only upserts on the id
+// or a unique key.
+func (s *BoltStorage) upsert(ctx context.Context, table StorageTableName, data []interface{}, conflictKeys []string) ([]interface{}, error) {
+	if err := checkConflictKeys(table, conflictKeys); err != nil {
+		return nil, err
+	}
+
+	result := make([]interface{}, len(data))
+	err := s.write(ctx, func(w *boltWriter) error {
+		for i, item := range data {
+			itemMap, err := toMap(item)
+			if err != nil {
+				return err
+			}
+
+			if fields, ok := conflictFields(itemMap, conflictKeys); ok {
+				ids, err := w.matching(table, fields)
+				if err != nil {
+					return err
+				}
+				if len(ids) > 1 {
+					return fmt.Errorf("%d items of %s have the same %v", len(ids), table, conflictKeys)
+				}
+				if len(ids) == 1 {
+					updated, err := w.patch(table, ids[0], itemMap)
+					if err != nil {
+						return err
+					}
+					result[i] = updated
+					continue
+				}
+			}
+
+			if err := w.insert(table, item, itemMap); err != nil {
+				return err
+			}
+			result[i] = itemMap
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// update merges the fields of data into the stored item, like a PATCH, keeping its id
+func (s *BoltStorage) update(ctx context.Context, table StorageTableName, id string, data interface{}) (interface{}, error) {
+	dataMap, err := toMap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated map[string]interface{}
+	err = s.write(ctx, func(w *boltWriter) error {
+		var err error
+		updated, err = w.patch(table, id, dataMap)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *BoltStorage) deleteWhere(ctx context.Context, table StorageTableName, matchingFields map[string]string) error {
+	if len(matchingFields) == 0 {
+		return fmt.Errorf("deleting from %s needs at least one field to match", table)
+	}
+
+	return s.write(ctx, func(w *boltWriter) error {
+		return w.deleteWhere(table, matchingFields)
+	})
+}
+
+// reserveIds takes ids from the sequence of the table's bucket
+func (s *BoltStorage) reserveIds(ctx context.Context, table StorageTableName, count int) ([]int, error) {
+	var ids []int
+	err := s.write(ctx, func(w *boltWriter) error {
+		var err error
+		ids, err = w.reserveIds(table, count)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// apply makes a transaction's writes in one bolt transaction, which bolt rolls back if one fails
+func (s *BoltStorage) apply(ctx context.Context, ops []op) error {
+	return s.write(ctx, func(w *boltWriter) error {
+		for _, o := range ops {
+			switch o.Kind {
+			case opKindInsert:
+				for _, row := range o.Rows {
+					if err := w.put(o.Table, row); err != nil {
+						return err
+					}
+				}
+			case opKindUpdate:
+				if _, err := w.patch(o.Table, o.Id, o.Data); err != nil {
+					return fmt.Errorf("failed to update %s %s: %w", o.Table, o.Id, err)
+				}
+			case opKindDelete:
+				if err := w.deleteWhere(o.Table, o.Match); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown kind of write %s", o.Kind)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStorage) get(ctx context.Context, table StorageTableName, id string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var item map[string]interface{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		item, err = getItem(tx, table, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// getAll returns the items whose fields have the matching values, in order of id
+func (s *BoltStorage) getAll(ctx context.Context, table StorageTableName, matchingFields map[string]string) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []interface{}{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(table))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			item, err := decodeItem(v)
+			if err != nil {
+				return err
+			}
+			if fieldsMatch(item, matchingFields) {
+				result = append(result, item)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return idLess(result[i].(map[string]interface{})["id"], result[j].(map[string]interface{})["id"])
+	})
+	return result, nil
+}
+
+// similaritySearch compares embedding with every embedding in the table's vector index
+func (s *BoltStorage) similaritySearch(ctx context.Context, table StorageTableName, embedding []float32, count int, filter SourceFilter) ([]similarItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, ok := s.vectors[table]
+	if !ok {
+		return []similarItem{}, nil
+	}
+
+	similarities, err := index.search(embedding)
+	if err != nil {
+		return nil, err
+	}
+
+	items, scores, err := s.best(table, similarities, count, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]similarItem, len(items))
+	for i, item := range items {
+		result[i] = similarItem{data: item, similarity: scores[i]}
+	}
+	return result, nil
+}
+
+// keywordSearch scores the items of the table against query with its keyword index
+func (s *BoltStorage) keywordSearch(ctx context.Context, table StorageTableName, query string, count int, filter SourceFilter) ([]keywordItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, ok := s.keywords[table]
+	if !ok {
+		return nil, fmt.Errorf("table %s has no keyword index", table)
+	}
+
+	items, scores, err := s.best(table, index.search(query), count, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]keywordItem, len(items))
+	for i, item := range items {
+		result[i] = keywordItem{data: item, score: scores[i]}
+	}
+	return result, nil
+}
+
+// best returns the count items of the table with the highest scores that pass the filter, without
+// their embeddings, along with their scores. Items with the same score are ordered by id.
+func (s *BoltStorage) best(table StorageTableName, scores map[string]float64, count int, filter SourceFilter) ([]interface{}, []float64, error) {
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return idLess(ids[i], ids[j])
+	})
+
+	var (
+		items     []interface{}
+		itemScore []float64
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		sourceOf := func(id int) (map[string]interface{}, bool) {
+			source, err := getItem(tx, StorageTableNameRagSources, strconv.Itoa(id))
+			return source, err == nil
+		}
+
+		for _, id := range ids {
+			if len(items) == count {
+				break
+			}
+
+			item, err := getItem(tx, table, id)
+			if err != nil {
+				return err
+			}
+			if !filter.matches(item, sourceOf) {
+				continue
+			}
+
+			items = append(items, withoutEmbedding(item))
+			itemScore = append(itemScore, scores[id])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, itemScore, nil
+}
+
+// write runs fn in a bolt transaction, and indexes the items it changed once it is committed
+func (s *BoltStorage) write(ctx context.Context, fn func(w *boltWriter) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := &boltWriter{changed: make(map[boltItem]map[string]interface{})}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		w.tx = tx
+		return fn(w)
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, item := range w.changed {
+		s.index(key.table, key.id, item)
+	}
+	return nil
+}
+
+// index updates the indexes of the table for an item, which is nil if it was deleted. s.mu must be
+// held, unless the storage is being opened.
+func (s *BoltStorage) index(table StorageTableName, id string, item map[string]interface{}) {
+	embedding, hasEmbedding := embeddingOf(item["embedding"])
+	if hasEmbedding {
+		if s.vectors[table] == nil {
+			s.vectors[table] = newVectorIndex()
+		}
+		s.vectors[table].add(id, embedding)
+	} else if index, ok := s.vectors[table]; ok {
+		index.remove(id)
+	}
+
+	if index, ok := s.keywords[table]; ok {
+		if item == nil {
+			index.remove(id)
+		} else {
+			text, _ := item[keywordFields[table]].(string)
+			index.add(id, text)
+		}
+	}
+}
+
+func (w *boltWriter) bucket(table StorageTableName) (*bolt.Bucket, error) {
+	return w.tx.CreateBucketIfNotExists([]byte(table))
+}
+
+// put stores an item, replacing any with the same id. Numeric ids past the bucket's sequence move it
+// on, so they aren't given out again.
+func (w *boltWriter) put(table StorageTableName, item map[string]interface{}) error {
+	b, err := w.bucket(table)
+	if err != nil {
+		return err
+	}
+
+	if item["id"] == nil {
+		return fmt.Errorf("%s item has no id", table)
+	}
+	id := fmt.Sprint(item["id"])
+
+	if n, ok := intOf(item["id"]); ok && uint64(n) > b.Sequence() {
+		if err := b.SetSequence(uint64(n)); err != nil {
+			return err
+		}
+	}
+
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s %s: %w", table, id, err)
+	}
+	if err := b.Put([]byte(id), encoded); err != nil {
+		return err
+	}
+
+	w.changed[boltItem{table, id}] = item
+	return nil
+}
+
+// insert stores an item, giving it the next id of the table's bucket if its id is a number and it
+// doesn't have one, or a UUID otherwise
+func (w *boltWriter) insert(table StorageTableName, item interface{}, itemMap map[string]interface{}) error {
+	if itemMap["id"] == nil {
+		if originalID, ok := getOriginalIDType(item); ok && isNumberType(originalID) {
+			ids, err := w.reserveIds(table, 1)
+			if err != nil {
+				return err
+			}
+			itemMap["id"] = ids[0]
+		} else {
+			itemMap["id"] = uuid.New().String()
+		}
+	}
+
+	return w.put(table, itemMap)
+}
+
+// patch merges the fields of dataMap into a stored item
+func (w *boltWriter) patch(table StorageTableName, id string, dataMap map[string]interface{}) (map[string]interface{}, error) {
+	item, err := getItem(w.tx, table, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range dataMap {
+		if k != "id" {
+			item[k] = v
+		}
+	}
+
+	if err := w.put(table, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// deleteWhere deletes the items whose fields all have the matching values
+func (w *boltWriter) deleteWhere(table StorageTableName, matchingFields map[string]string) error {
+	if len(matchingFields) == 0 {
+		return fmt.Errorf("deleting from %s needs at least one field to match", table)
+	}
+
+	b := w.tx.Bucket([]byte(table))
+	if b == nil {
+		return nil
+	}
+
+	// Items can't be deleted while the bucket is being iterated over
+	ids, err := w.matching(table, matchingFields)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := b.Delete([]byte(id)); err != nil {
+			return err
+		}
+		w.changed[boltItem{table, id}] = nil
+	}
+	return nil
+}
+
+// matching returns the ids of the items whose fields all have the matching values
+func (w *boltWriter) matching(table StorageTableName, matchingFields map[string]string) ([]string, error) {
+	b := w.tx.Bucket([]byte(table))
+	if b == nil {
+		return nil, nil
+	}
+
+	var ids []string
+	err := b.ForEach(func(k, v []byte) error {
+		item, err := decodeItem(v)
+		if err != nil {
+			return err
+		}
+		if fieldsMatch(item, matchingFields) {
+			ids = append(ids, string(k))
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func (w *boltWriter) reserveIds(table StorageTableName, count int) ([]int, error) {
+	b, err := w.bucket(table)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, count)
+	for i := range ids {
+		id, err := b.NextSequence()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = int(id)
+	}
+	return ids, nil
+}
+
+func getItem(tx *bolt.Tx, table StorageTableName, id string) (map[string]interface{}, error) {
+	b := tx.Bucket([]byte(table))
+	if b == nil {
+		return nil, fmt.Errorf("item not found")
+	}
+
+	encoded := b.Get([]byte(id))
+	if encoded == nil {
+		return nil, fmt.Errorf("item not found")
+	}
+	return decodeItem(encoded)
+}
+
+// decodeItem unmarshals a stored item, keeping an integer id as an int as toMap does
+func decodeItem(encoded []byte) (map[string]interface{}, error) {
+	var item map[string]interface{}
+	if err := json.Unmarshal(encoded, &item); err != nil {
+		return nil, err
+	}
+
+	if id, ok := item["id"].(float64); ok && id == float64(int(id)) {
+		item["id"] = int(id)
+	}
+	return item, nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBoltStorage(t *testing.T, path string) *BoltStorage {
+	storage, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func TestBoltStorage(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = newTestBoltStorage(t, filepath.Join(t.TempDir(), "storage.db"))
+	)
+	defer storage.Close()
+
+	source, err := Store(ctx, storage, RagSource{URL: "https://example.com", JobId: "job"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, source.ID)
+	sourceId := strconv.Itoa(source.ID)
+
+	chunks, err := StoreAll(ctx, storage,
+		RagChunk{RagSourceId: source.ID, Text: "Open daily", Embedding: []float32{1, 0}},
+		RagChunk{RagSourceId: source.ID, Text: "Closed on Sundays", PosInSource: 1, Embedding: []float32{0, 1}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, []int{chunks[0].ID, chunks[1].ID})
+
+	got, err := Get[RagChunk](ctx, storage, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, chunks[1], *got)
+
+	_, err = Get[RagChunk](ctx, storage, "3")
+	assert.Error(t, err)
+
+	// Numeric fields can be matched
+	all, err := GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": sourceId})
+	assert.NoError(t, err)
+	assert.Equal(t, chunks, all)
+
+	similar, err := SimilaritySearch[RagChunk](ctx, storage, []float32{0.1, 1}, 1, SourceFilter{JobId: "job"})
+	assert.NoError(t, err)
+	if assert.Len(t, similar, 1) {
+		assert.Equal(t, 2, similar[0].Item.ID)
+		assert.Nil(t, similar[0].Item.Embedding)
+	}
+
+	similar, err = SimilaritySearch[RagChunk](ctx, storage, []float32{0.1, 1}, 1, SourceFilter{JobId: "other"})
+	assert.NoError(t, err)
+	assert.Empty(t, similar)
+
+	matches, err := KeywordSearch[RagChunk](ctx, storage, "sundays", 10, SourceFilter{RagSourceIds: []int{source.ID}})
+	assert.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, 2, matches[0].Item.ID)
+	}
+
+	updated, err := Update(ctx, storage, "2", RagChunk{RagSourceId: source.ID, Text: "Closed on Mondays", PosInSource: 1, Embedding: []float32{1, 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.ID)
+	assert.Equal(t, "Closed on Mondays", updated.Text)
+
+	matches, err = KeywordSearch[RagChunk](ctx, storage, "sundays", 10, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+
+	err = DeleteWhere[RagChunk](ctx, storage, map[string]string{"rag_source_id": sourceId})
+	assert.NoError(t, err)
+
+	all, err = GetAll[RagChunk](ctx, storage, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+
+	similar, err = SimilaritySearch[RagChunk](ctx, storage, []float32{0, 1}, 10, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, similar)
+}
+
+func TestBoltStorageReopen(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		path    = filepath.Join(t.TempDir(), "storage.db")
+		storage = newTestBoltStorage(t, path)
+	)
+
+	request, err := Store(ctx, storage, NewAgentRequest("test", map[string]interface{}{"test": "test"}))
+	assert.NoError(t, err)
+
+	_, err = StoreAll(ctx, storage,
+		RagChunk{ID: 5, RagSourceId: 1, Text: "Open daily", Embedding: []float32{1, 0}},
+		RagChunk{RagSourceId: 1, Text: "Closed on Sundays", Embedding: []float32{0, 1}},
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Close())
+
+	storage = newTestBoltStorage(t, path)
+	defer storage.Close()
+
+	got, err := Get[AgentRequest](ctx, storage, request.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, request, got)
+
+	// The indexes are rebuilt from the file
+	similar, err := SimilaritySearch[RagChunk](ctx, storage, []float32{0, 1}, 1, SourceFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, similar, 1) {
+		assert.Equal(t, 6, similar[0].Item.ID)
+	}
+
+	matches, err := KeywordSearch[RagChunk](ctx, storage, "daily", 10, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+
+	// Ids carry on from the ones stored, rather than starting again
+	chunk, err := Store(ctx, storage, RagChunk{RagSourceId: 1, Text: "Opening hours"})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, chunk.ID)
+}
+
+func TestBoltStorageInTransaction(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = newTestBoltStorage(t, filepath.Join(t.TempDir(), "storage.db"))
+		fnErr   = errors.New("failed to embed")
+	)
+	defer storage.Close()
+
+	err := InTransaction(ctx, storage, func(tx Storage) error {
+		source, err := Store(ctx, tx, RagSource{URL: "https://example.com"})
+		if err != nil {
+			return err
+		}
+
+		_, err = StoreAll(ctx, tx, RagChunk{RagSourceId: source.ID, Text: "Open daily", Embedding: []float32{1, 0}})
+		return err
+	})
+	assert.NoError(t, err)
+
+	// Nothing is written if applying the transaction fails part way
+	err = InTransaction(ctx, storage, func(tx Storage) error {
+		if err := DeleteWhere[RagChunk](ctx, tx, map[string]string{"rag_source_id": "1"}); err != nil {
+			return err
+		}
+		_, err := Update(ctx, tx, "2", RagSource{Name: "Missing"})
+		return err
+	})
+	assert.Error(t, err)
+
+	err = InTransaction(ctx, storage, func(tx Storage) error {
+		_, err := Store(ctx, tx, RagSource{URL: "https://example.com/other"})
+		if err != nil {
+			return err
+		}
+		return fnErr
+	})
+	assert.ErrorIs(t, err, fnErr)
+
+	sources, err := GetAll[RagSource](ctx, storage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1)
+
+	similar, err := SimilaritySearch[RagChunk](ctx, storage, []float32{1, 0}, 10, SourceFilter{Url: "https://example.com"})
+	assert.NoError(t, err)
+	assert.Len(t, similar, 1)
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters, at their usual values
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// keywordFields are the fields MemoryStorage and BoltStorage keep keyword indexes of, by table
+var keywordFields = map[StorageTableName]string{
+	StorageTableNameRagChunks: "text",
+}
+
+// keywordIndex is an inverted index that scores documents against a query with BM25
+type keywordIndex struct {
+	postings    map[string]map[string]int // Term -> document ID -> number of times the term appears
+	lengths     map[string]int            // Document ID -> number of terms
+	totalLength int
+}
+
+func newKeywordIndex() *keywordIndex {
+	return &keywordIndex{
+		postings: make(map[string]map[string]int),
+		lengths:  make(map[string]int),
+	}
+}
+
+// keywordTerms splits text into lower case runs of letters and digits, so a product code like
+// "AB-1234" is the terms "ab" and "1234", as it is for Postgres's simple text search configuration
+func keywordTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// add indexes text under id, replacing whatever id was indexed with before
+func (i *keywordIndex) add(id string, text string) {
+	i.remove(id)
+
+	terms := keywordTerms(text)
+	for _, term := range terms {
+		if i.postings[term] == nil {
+			i.postings[term] = make(map[string]int)
+		}
+		i.postings[term][id]++
+	}
+
+	i.lengths[id] = len(terms)
+	i.totalLength += len(terms)
+}
+
+func (i *keywordIndex) remove(id string) {
+	length, ok := i.lengths[id]
+	if !ok {
+		return
+	}
+
+	for term, documents := range i.postings {
+		delete(documents, id)
+		if len(documents) == 0 {
+			delete(i.postings, term)
+		}
+	}
+
+	delete(i.lengths, id)
+	i.totalLength -= length
+}
+
+// search returns the BM25 score of every document containing any of the terms of query
+func (i *keywordIndex) search(query string) map[string]float64 {
+	scores := make(map[string]float64)
+	if len(i.lengths) == 0 {
+		return scores
+	}
+
+	var (
+		numDocuments  = float64(len(i.lengths))
+		averageLength = float64(i.totalLength) / numDocuments
+		seen          = make(map[string]bool)
+	)
+
+	for _, term := range keywordTerms(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		documents := i.postings[term]
+		if len(documents) == 0 {
+			continue
+		}
+
+		idf := math.Log(1 + (numDocuments-float64(len(documents))+0.5)/(float64(len(documents))+0.5))
+		for id, frequency := range documents {
+			tf := float64(frequency)
+			norm := 1 - bm25B + bm25B*float64(i.lengths[id])/averageLength
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	return scores
+}
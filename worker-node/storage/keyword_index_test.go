@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeywordTerms(t *testing.T) {
+	assert.Equal(t, []string{"order", "ab", "1234", "café"}, keywordTerms("Order AB-1234, Café!"))
+	assert.Empty(t, keywordTerms(" -- "))
+}
+
+func TestKeywordIndexSearch(t *testing.T) {
+	index := newKeywordIndex()
+	index.add("1", "The XJ-9000 vacuum cleaner")
+	index.add("2", "A vacuum cleaner with a long cable and a large bag")
+	index.add("3", "Opening hours")
+
+	scores := index.search("xj 9000 vacuum")
+	assert.Len(t, scores, 2)
+	assert.Greater(t, scores["1"], scores["2"])
+
+	// Rare terms count for more than common ones
+	assert.Greater(t, index.search("xj")["1"], index.search("vacuum")["1"])
+
+	// Repeating a query term doesn't count it twice
+	assert.Equal(t, index.search("hours")["3"], index.search("hours hours")["3"])
+
+	assert.Empty(t, index.search("delivery"))
+}
+
+func TestKeywordIndexAddReplaces(t *testing.T) {
+	index := newKeywordIndex()
+	index.add("1", "vacuum cleaner")
+	index.add("1", "opening hours")
+
+	assert.Empty(t, index.search("vacuum"))
+	assert.Len(t, index.search("hours"), 1)
+	assert.Equal(t, 2, index.totalLength)
+
+	index.remove("1")
+	assert.Empty(t, index.search("hours"))
+	assert.Empty(t, index.postings)
+	assert.Equal(t, 0, index.totalLength)
+}
@@ -1,109 +1,334 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"math"
 	"reflect"
+	"slices"
+	"sort"
 	"strconv"
 	"sync"
 
 	"github.com/google/uuid"
 )
 
+// MemoryStorage keeps items in maps, for tests and for running without a database. It behaves as
+// Supabase does: numeric ids come from a sequence for each table, fields are matched whatever their
+// type, and items are listed in order of id.
 type MemoryStorage struct {
-	data map[StorageTableName]map[string]interface{} // Table -> ID -> Data
-	mu   sync.RWMutex                                // For concurrent access
+	data      map[StorageTableName]map[string]interface{} // Table -> ID -> Data
+	sequences map[StorageTableName]int                    // Table -> last numeric id given out or stored
+	indexes   map[StorageTableName]*keywordIndex          // Keyword indexes of the tables in keywordFields
+	mu        sync.RWMutex                                // Held for writing by every write
 }
 
 func NewMemoryStorage() *MemoryStorage {
+	indexes := make(map[StorageTableName]*keywordIndex, len(keywordFields))
+	for table := range keywordFields {
+		indexes[table] = newKeywordIndex()
+	}
+
 	return &MemoryStorage{
-		data: make(map[StorageTableName]map[string]interface{}),
+		data:      make(map[StorageTableName]map[string]interface{}),
+		sequences: make(map[StorageTableName]int),
+		indexes:   indexes,
 	}
 }
 
-func (s *MemoryStorage) store(table StorageTableName, data interface{}) (interface{}, error) {
-	// Check for nil data
-	if data == nil {
-		return nil, fmt.Errorf("data cannot be nil")
+func (s *MemoryStorage) store(ctx context.Context, table StorageTableName, data interface{}) (interface{}, error) {
+	stored, err := s.storeAll(ctx, table, []interface{}{data})
+	if err != nil {
+		return nil, err
 	}
+	return stored[0], nil
+}
 
-	var dataMap map[string]interface{}
+// storeAll stores the items while holding the lock, so they are seen all at once, or none of them if
+// one can't be stored. Items without an id are given the table's next numeric id if their id is a
+// number, or a UUID otherwise.
+func (s *MemoryStorage) storeAll(ctx context.Context, table StorageTableName, data []interface{}) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	// Try to convert data to map[string]interface{}
-	switch v := data.(type) {
-	case map[string]interface{}:
-		dataMap = v
-	default:
-		// Convert struct to map using reflection
-		bytes, err := json.Marshal(data)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal struct to json: %w", err)
-		}
+	dataMaps, err := toItemMaps(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]interface{}, len(data))
+	for i, dataMap := range dataMaps {
+		s.insert(table, data[i], dataMap)
+		result[i] = dataMap
+	}
 
-		if err := json.Unmarshal(bytes, &dataMap); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal json to map: %w", err)
+	return result, nil
+}
+
+// toItemMaps converts items to the maps they are stored as, checking their ids can be stored
+func toItemMaps(data []interface{}) ([]map[string]interface{}, error) {
+	dataMaps := make([]map[string]interface{}, len(data))
+	for i, item := range data {
+		dataMap, err := toMap(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store item: %w", err)
 		}
 
-		// Preserve original integer type for ID field
-		if originalID, ok := getOriginalIDType(data); ok && isNumberType(originalID) {
-			if idFloat, ok := dataMap["id"].(float64); ok {
-				dataMap["id"] = int(idFloat)
-			}
+		switch dataMap["id"].(type) {
+		case nil, string, int:
+		default:
+			return nil, fmt.Errorf("failed to store item: ID must be a string or number")
 		}
+		dataMaps[i] = dataMap
 	}
+	return dataMaps, nil
+}
 
-	// If ID is empty, generate a random UUID
+// insert stores an item, giving it the table's next numeric id if its id is a number and it doesn't
+// have one, or a UUID otherwise. s.mu must be held.
+func (s *MemoryStorage) insert(table StorageTableName, item interface{}, dataMap map[string]interface{}) {
 	if dataMap["id"] == nil {
-		// Check if the original data had a numeric ID type
-		if originalID, ok := getOriginalIDType(data); ok && isNumberType(originalID) {
-			// Generate random number between 1 and 1000000 for numeric IDs
-			dataMap["id"] = rand.Intn(1000000) + 1
+		if originalID, ok := getOriginalIDType(item); ok && isNumberType(originalID) {
+			dataMap["id"] = s.nextId(table)
 		} else {
-			// Default to UUID string if not numeric
 			dataMap["id"] = uuid.New().String()
 		}
 	}
 
-	// Get the ID as string or number
-	id, ok := dataMap["id"].(string)
-	if !ok {
-		// Try as number
-		if numID, ok := dataMap["id"].(int); ok {
-			id = strconv.Itoa(numID)
-		} else {
-			return nil, fmt.Errorf("ID must be a string or number")
+	s.put(table, fmt.Sprint(dataMap["id"]), dataMap)
+}
+
+// upsert stores the items while holding the lock, merging each into the stored item with the same
+// values of the conflict keys if there is one, as update does. Like Postgres, it only upserts on the id
+// or a unique key.
+func (s *MemoryStorage) upsert(ctx context.Context, table StorageTableName, data []interface{}, conflictKeys []string) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := checkConflictKeys(table, conflictKeys); err != nil {
+		return nil, err
+	}
+
+	dataMaps, err := toItemMaps(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]interface{}, len(data))
+	for i, dataMap := range dataMaps {
+		if fields, ok := conflictFields(dataMap, conflictKeys); ok {
+			ids := s.matching(table, fields)
+			if len(ids) > 1 {
+				return nil, fmt.Errorf("%d items of %s have the same %v", len(ids), table, conflictKeys)
+			}
+			if len(ids) == 1 {
+				updated, err := s.patch(table, ids[0], dataMap)
+				if err != nil {
+					return nil, err
+				}
+				result[i] = updated
+				continue
+			}
 		}
+
+		s.insert(table, data[i], dataMap)
+		result[i] = dataMap
+	}
+
+	return result, nil
+}
+
+// update merges the fields of data into the stored item, like a PATCH, keeping its id
+func (s *MemoryStorage) update(ctx context.Context, table StorageTableName, id string, data interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dataMap, err := toMap(data)
+	if err != nil {
+		return nil, err
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.patch(table, id, dataMap)
+}
+
+// deleteWhere deletes the items whose fields all have the matching values
+func (s *MemoryStorage) deleteWhere(ctx context.Context, table StorageTableName, matchingFields map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if len(matchingFields) == 0 {
+		return fmt.Errorf("deleting from %s needs at least one field to match", table)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range s.matching(table, matchingFields) {
+		s.remove(table, id)
+	}
+	return nil
+}
+
+// reserveIds takes ids from the table's sequence
+func (s *MemoryStorage) reserveIds(ctx context.Context, table StorageTableName, count int) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, count)
+	for i := range ids {
+		ids[i] = s.nextId(table)
+	}
+	return ids, nil
+}
+
+// nextId takes the next id from the table's sequence. s.mu must be held.
+func (s *MemoryStorage) nextId(table StorageTableName) int {
+	s.sequences[table]++
+	return s.sequences[table]
+}
+
+// apply makes a transaction's writes while holding the lock, so they are seen all at once. If one
+// fails, the items the others changed are put back as they were.
+func (s *MemoryStorage) apply(ctx context.Context, ops []op) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The items as they were before the transaction, nil for those that didn't exist
+	type itemKey struct {
+		table StorageTableName
+		id    string
+	}
+	before := make(map[itemKey]interface{})
+	touch := func(table StorageTableName, id string) {
+		if _, ok := before[itemKey{table, id}]; !ok {
+			before[itemKey{table, id}] = s.data[table][id]
+		}
+	}
+
+	err := func() error {
+		for _, o := range ops {
+			switch o.Kind {
+			case opKindInsert:
+				for _, row := range o.Rows {
+					id := fmt.Sprint(row["id"])
+					touch(o.Table, id)
+					s.put(o.Table, id, row)
+				}
+			case opKindUpdate:
+				touch(o.Table, o.Id)
+				if _, err := s.patch(o.Table, o.Id, o.Data); err != nil {
+					return fmt.Errorf("failed to update %s %s: %w", o.Table, o.Id, err)
+				}
+			case opKindDelete:
+				for _, id := range s.matching(o.Table, o.Match) {
+					touch(o.Table, id)
+					s.remove(o.Table, id)
+				}
+			default:
+				return fmt.Errorf("unknown kind of write %s", o.Kind)
+			}
+		}
+		return nil
+	}()
+
+	if err != nil {
+		for key, item := range before {
+			if item == nil {
+				s.remove(key.table, key.id)
+			} else {
+				s.put(key.table, key.id, item.(map[string]interface{}))
+			}
+		}
+	}
+	return err
+}
+
+// put stores an item, replacing any with the same id. s.mu must be held.
+func (s *MemoryStorage) put(table StorageTableName, id string, item map[string]interface{}) {
 	// Initialize table if it doesn't exist
 	if s.data[table] == nil {
 		s.data[table] = make(map[string]interface{})
 	}
 
-	// Store the data
-	s.data[table][id] = dataMap
+	s.data[table][id] = item
 
-	return dataMap, nil
+	// Stored ids move the sequence past them, so they aren't given out again
+	if n, ok := item["id"].(int); ok && n > s.sequences[table] {
+		s.sequences[table] = n
+	}
+
+	if index, ok := s.indexes[table]; ok {
+		text, _ := item[keywordFields[table]].(string)
+		index.add(id, text)
+	}
 }
 
-func (s *MemoryStorage) storeAll(table StorageTableName, data []interface{}) ([]interface{}, error) {
-	// Initialize result slice
-	result := make([]interface{}, 0, len(data))
+// patch merges the fields of dataMap into a stored item. s.mu must be held.
+func (s *MemoryStorage) patch(table StorageTableName, id string, dataMap map[string]interface{}) (map[string]interface{}, error) {
+	item, ok := s.data[table][id].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("item not found")
+	}
 
-	// Process each item
-	for _, item := range data {
-		storedData, err := s.store(table, item)
-		if err != nil {
-			return nil, fmt.Errorf("failed to store item: %w", err)
-		}
-		result = append(result, storedData)
+	updated := make(map[string]interface{}, len(item)+len(dataMap))
+	for k, v := range item {
+		updated[k] = v
 	}
+	for k, v := range dataMap {
+		updated[k] = v
+	}
+	updated["id"] = item["id"]
 
-	return result, nil
+	s.put(table, id, updated)
+	return updated, nil
+}
+
+// remove deletes an item if it exists. s.mu must be held.
+func (s *MemoryStorage) remove(table StorageTableName, id string) {
+	delete(s.data[table], id)
+	if index, ok := s.indexes[table]; ok {
+		index.remove(id)
+	}
 }
 
-func (s *MemoryStorage) get(table StorageTableName, id string) (interface{}, error) {
+// matching returns the ids of the items whose fields all have the matching values. s.mu must be held.
+func (s *MemoryStorage) matching(table StorageTableName, matchingFields map[string]string) []string {
+	var ids []string
+	for id, item := range s.data[table] {
+		if itemMap, ok := item.(map[string]interface{}); ok && fieldsMatch(itemMap, matchingFields) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (s *MemoryStorage) get(ctx context.Context, table StorageTableName, id string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -114,45 +339,306 @@ func (s *MemoryStorage) get(table StorageTableName, id string) (interface{}, err
 	return item, nil
 }
 
-func (s *MemoryStorage) getAll(table StorageTableName, matchingFields map[string]string) ([]interface{}, error) {
+// getAll returns the items whose fields all have the matching values, in order of id
+func (s *MemoryStorage) getAll(ctx context.Context, table StorageTableName, matchingFields map[string]string) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var result []interface{}
-	for _, item := range s.data[table] {
-		// Convert item to map[string]interface{}
+	ids := s.matching(table, matchingFields)
+	sort.Slice(ids, func(i, j int) bool { return idLess(ids[i], ids[j]) })
+
+	result := make([]interface{}, len(ids))
+	for i, id := range ids {
+		result[i] = s.data[table][id]
+	}
+	return result, nil
+}
+
+// similaritySearch compares embedding with the embedding of every item in the table
+func (s *MemoryStorage) similaritySearch(ctx context.Context, table StorageTableName, embedding []float32, count int, filter SourceFilter) ([]similarItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []similarItem
+	for id, item := range s.data[table] {
 		itemMap, ok := item.(map[string]interface{})
+		if !ok || !s.matchesSourceFilter(itemMap, filter) {
+			continue
+		}
+
+		itemEmbedding, ok := embeddingOf(itemMap["embedding"])
 		if !ok {
 			continue
 		}
 
-		// Check if all matching fields match
-		matches := true
-		for field, value := range matchingFields {
-			itemValue, exists := itemMap[field]
-			if !exists {
-				matches = false
-				break
-			}
-			// Convert itemValue to string for comparison
-			itemValueStr, ok := itemValue.(string)
-			if !ok {
-				matches = false
-				break
-			}
-			if itemValueStr != value {
-				matches = false
-				break
-			}
+		if len(itemEmbedding) != len(embedding) {
+			return nil, fmt.Errorf("item %s has a %d dimensional embedding, searched for %d dimensions", id, len(itemEmbedding), len(embedding))
+		}
+
+		result = append(result, similarItem{data: withoutEmbedding(itemMap), similarity: cosineSimilarity(embedding, itemEmbedding)})
+	}
+
+	// Items that are as similar as each other are ordered by id, so results don't depend on map order
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].similarity != result[j].similarity {
+			return result[i].similarity > result[j].similarity
+		}
+		return idLess(idOf(result[i].data), idOf(result[j].data))
+	})
+
+	if len(result) > count {
+		result = result[:count]
+	}
+	return result, nil
+}
+
+// keywordSearch scores the items of the table against query with its keyword index
+func (s *MemoryStorage) keywordSearch(ctx context.Context, table StorageTableName, query string, count int, filter SourceFilter) ([]keywordItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, ok := s.indexes[table]
+	if !ok {
+		return nil, fmt.Errorf("table %s has no keyword index", table)
+	}
+
+	var result []keywordItem
+	for id, score := range index.search(query) {
+		itemMap, ok := s.data[table][id].(map[string]interface{})
+		if !ok || !s.matchesSourceFilter(itemMap, filter) {
+			continue
 		}
 
-		if matches {
-			result = append(result, item)
+		result = append(result, keywordItem{data: withoutEmbedding(itemMap), score: score})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].score != result[j].score {
+			return result[i].score > result[j].score
 		}
+		return idLess(idOf(result[i].data), idOf(result[j].data))
+	})
+
+	if len(result) > count {
+		result = result[:count]
 	}
 	return result, nil
 }
 
+// toMap converts a struct to the map it is stored as, keeping integer ids as ints
+func toMap(data interface{}) (map[string]interface{}, error) {
+	// Check for nil data
+	if data == nil {
+		return nil, fmt.Errorf("data cannot be nil")
+	}
+
+	if dataMap, ok := data.(map[string]interface{}); ok {
+		return dataMap, nil
+	}
+
+	// Convert struct to map using reflection
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal struct to json: %w", err)
+	}
+
+	var dataMap map[string]interface{}
+	if err := json.Unmarshal(bytes, &dataMap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal json to map: %w", err)
+	}
+
+	// Preserve original integer type for ID field
+	if originalID, ok := getOriginalIDType(data); ok && isNumberType(originalID) {
+		if idFloat, ok := dataMap["id"].(float64); ok {
+			dataMap["id"] = int(idFloat)
+		}
+	}
+
+	return dataMap, nil
+}
+
+// fieldsMatch checks that every field of an item has its matching value, comparing numbers by how
+// they are written
+func fieldsMatch(itemMap map[string]interface{}, matchingFields map[string]string) bool {
+	for field, value := range matchingFields {
+		itemValue, exists := itemMap[field]
+		if !exists || itemValue == nil {
+			return false
+		}
+
+		if fieldString(itemValue) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldString writes a field's value as it is matched, with numbers written as they are in JSON
+func fieldString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// conflictFields returns the values of an item's conflict keys as fields to match, or false if it
+// doesn't have them all, in which case it can't conflict with a stored item
+// uniqueKeys are the keys other than the id that each table has a unique index on, which upserts can
+// conflict on as well as the id. There aren't any yet.
+var uniqueKeys = map[StorageTableName][][]string{}
+
+// checkConflictKeys returns an error unless the conflict keys are the id or a unique key of table, as
+// upserting on any others could match more than one item
+func checkConflictKeys(table StorageTableName, conflictKeys []string) error {
+	keys := slices.Sorted(slices.Values(conflictKeys))
+	if slices.Equal(keys, []string{"id"}) {
+		return nil
+	}
+
+	for _, uniqueKey := range uniqueKeys[table] {
+		if slices.Equal(keys, slices.Sorted(slices.Values(uniqueKey))) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s has no unique key %v to upsert on", table, conflictKeys)
+}
+
+func conflictFields(item map[string]interface{}, conflictKeys []string) (map[string]string, bool) {
+	fields := make(map[string]string, len(conflictKeys))
+	for _, key := range conflictKeys {
+		if item[key] == nil {
+			return nil, false
+		}
+		fields[key] = fieldString(item[key])
+	}
+	return fields, true
+}
+
+// withoutEmbedding copies an item without its embedding, as search results don't include them
+func withoutEmbedding(itemMap map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(itemMap))
+	for k, v := range itemMap {
+		if k != "embedding" {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+func idOf(data interface{}) string {
+	return fmt.Sprint(data.(map[string]interface{})["id"])
+}
+
+// idLess orders ids numerically if they are both numbers, and as strings otherwise
+func idLess(a, b interface{}) bool {
+	aString, bString := fmt.Sprint(a), fmt.Sprint(b)
+
+	aInt, aErr := strconv.Atoi(aString)
+	bInt, bErr := strconv.Atoi(bString)
+	if aErr == nil && bErr == nil {
+		return aInt < bInt
+	}
+	return aString < bString
+}
+
+// matchesSourceFilter checks the rag source of an item against filter. s.mu must be held.
+func (s *MemoryStorage) matchesSourceFilter(itemMap map[string]interface{}, filter SourceFilter) bool {
+	return filter.matches(itemMap, func(id int) (map[string]interface{}, bool) {
+		source, ok := s.data[StorageTableNameRagSources][strconv.Itoa(id)].(map[string]interface{})
+		return source, ok
+	})
+}
+
+// matches checks the rag source of an item against the filter, looking the source up with sourceOf
+// only if the filter needs its fields
+func (f SourceFilter) matches(itemMap map[string]interface{}, sourceOf func(id int) (map[string]interface{}, bool)) bool {
+	if len(f.RagSourceIds) == 0 && f.Url == "" && f.JobId == "" && f.CreatedBy == "" {
+		return true
+	}
+
+	ragSourceId, ok := intOf(itemMap["rag_source_id"])
+	if !ok {
+		return false
+	}
+
+	if len(f.RagSourceIds) > 0 && !slices.Contains(f.RagSourceIds, ragSourceId) {
+		return false
+	}
+
+	if f.Url == "" && f.JobId == "" && f.CreatedBy == "" {
+		return true
+	}
+
+	source, ok := sourceOf(ragSourceId)
+	if !ok {
+		return false
+	}
+
+	for field, value := range map[string]string{"url": f.Url, "job_id": f.JobId, "created_by": f.CreatedBy} {
+		if sourceValue, _ := source[field].(string); value != "" && sourceValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// embeddingOf converts a stored embedding, which is a []interface{} if it was stored as part of a struct
+func embeddingOf(v interface{}) ([]float32, bool) {
+	switch e := v.(type) {
+	case []float32:
+		return e, true
+	case []interface{}:
+		embedding := make([]float32, len(e))
+		for i, x := range e {
+			f, ok := x.(float64)
+			if !ok {
+				return nil, false
+			}
+			embedding[i] = float32(f)
+		}
+		return embedding, true
+	}
+	return nil, false
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// intOf converts a stored number, which is a float64 if it was stored as part of a struct
+func intOf(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
 // Helper function to check original ID type
 func getOriginalIDType(data interface{}) (interface{}, bool) {
 	val := reflect.ValueOf(data)
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -27,7 +28,7 @@ func TestMemoryStorage_Store(t *testing.T) {
 			Endpoint: "test-endpoint",
 		}
 
-		result, err := storage.store(req.TableName(), req)
+		result, err := storage.store(context.Background(), req.TableName(), req)
 		assert.NoError(t, err)
 
 		res, err := parseResult[AgentRequest](result)
@@ -41,7 +42,7 @@ func TestMemoryStorage_Store(t *testing.T) {
 			Endpoint: "test-endpoint",
 		}
 
-		result, err := storage.store(req.TableName(), req)
+		result, err := storage.store(context.Background(), req.TableName(), req)
 		assert.NoError(t, err)
 
 		res, err := parseResult[AgentRequest](result)
@@ -51,7 +52,7 @@ func TestMemoryStorage_Store(t *testing.T) {
 	})
 
 	t.Run("handles nil data", func(t *testing.T) {
-		_, err := storage.store(StorageTableNameAgentRequests, nil)
+		_, err := storage.store(context.Background(), StorageTableNameAgentRequests, nil)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "data cannot be nil")
 	})
@@ -66,7 +67,7 @@ func TestMemoryStorage_Store(t *testing.T) {
 			Endpoint: "test-endpoint",
 		}
 
-		result, err := storage.store(StorageTableNameAgentRequests, req)
+		result, err := storage.store(context.Background(), StorageTableNameAgentRequests, req)
 		assert.NoError(t, err)
 
 		res, ok := result.(map[string]interface{})
@@ -84,7 +85,7 @@ func TestMemoryStorage_Store(t *testing.T) {
 			Endpoint: "test-endpoint",
 		}
 
-		result, err := storage.store(StorageTableNameAgentRequests, req)
+		result, err := storage.store(context.Background(), StorageTableNameAgentRequests, req)
 		assert.NoError(t, err)
 
 		res, ok := result.(map[string]interface{})
@@ -108,7 +109,7 @@ func TestMemoryStorage_Store(t *testing.T) {
 			Endpoint: "test-endpoint",
 		}
 
-		result, err := storage.store(StorageTableNameAgentRequests, req)
+		result, err := storage.store(context.Background(), StorageTableNameAgentRequests, req)
 		assert.NoError(t, err)
 
 		res, ok := result.(map[string]interface{})
@@ -128,11 +129,11 @@ func TestMemoryStorage_Get(t *testing.T) {
 		}
 
 		// Store the data first
-		_, err := storage.store(req.TableName(), req)
+		_, err := storage.store(context.Background(), req.TableName(), req)
 		assert.NoError(t, err)
 
 		// Retrieve the data
-		result, err := storage.get(req.TableName(), req.ID)
+		result, err := storage.get(context.Background(), req.TableName(), req.ID)
 		assert.NoError(t, err)
 
 		res, err := parseResult[AgentRequest](result)
@@ -143,7 +144,7 @@ func TestMemoryStorage_Get(t *testing.T) {
 	})
 
 	t.Run("returns error for non-existent ID", func(t *testing.T) {
-		_, err := storage.get(StorageTableNameAgentRequests, "non-existent-id")
+		_, err := storage.get(context.Background(), StorageTableNameAgentRequests, "non-existent-id")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "item not found")
 	})
@@ -158,7 +159,7 @@ func TestMemoryStorage_StoreAll(t *testing.T) {
 			AgentRequest{Endpoint: "endpoint2"}, // No ID, should be generated
 		}
 
-		results, err := storage.storeAll(StorageTableNameAgentRequests, reqs)
+		results, err := storage.storeAll(context.Background(), StorageTableNameAgentRequests, reqs)
 		assert.NoError(t, err)
 		assert.Len(t, results, 2)
 
@@ -186,7 +187,7 @@ func TestMemoryStorage_StoreAll(t *testing.T) {
 			NumericIDStruct{Endpoint: "endpoint2"}, // No ID, should generate numeric
 		}
 
-		results, err := storage.storeAll(StorageTableNameAgentRequests, reqs)
+		results, err := storage.storeAll(context.Background(), StorageTableNameAgentRequests, reqs)
 		assert.NoError(t, err)
 		assert.Len(t, results, 2)
 
@@ -218,12 +219,12 @@ func TestMemoryStorage_GetAll(t *testing.T) {
 	}
 
 	for _, req := range reqs {
-		_, err := storage.store(req.TableName(), req)
+		_, err := storage.store(context.Background(), req.TableName(), req)
 		assert.NoError(t, err)
 	}
 
 	t.Run("retrieves all matching records", func(t *testing.T) {
-		results, err := storage.getAll(StorageTableNameAgentRequests, map[string]string{
+		results, err := storage.getAll(context.Background(), StorageTableNameAgentRequests, map[string]string{
 			"endpoint": "endpoint1",
 		})
 		assert.NoError(t, err)
@@ -237,10 +238,47 @@ func TestMemoryStorage_GetAll(t *testing.T) {
 	})
 
 	t.Run("returns empty slice for no matches", func(t *testing.T) {
-		results, err := storage.getAll(StorageTableNameAgentRequests, map[string]string{
+		results, err := storage.getAll(context.Background(), StorageTableNameAgentRequests, map[string]string{
 			"endpoint": "non-existent",
 		})
 		assert.NoError(t, err)
 		assert.Empty(t, results)
 	})
 }
+
+func TestMemoryStorage_SimilaritySearch(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	for _, chunk := range []RagChunk{
+		{ID: 2, RagSourceId: 1, Text: "b", Embedding: []float32{0, 1}},
+		{ID: 1, RagSourceId: 1, Text: "a", Embedding: []float32{1, 0}},
+		{ID: 3, RagSourceId: 1, Text: "c", Embedding: []float32{0, 0}},
+	} {
+		_, err := storage.store(context.Background(), chunk.TableName(), chunk)
+		assert.NoError(t, err)
+	}
+
+	t.Run("orders equally similar items by id", func(t *testing.T) {
+		results, err := storage.similaritySearch(context.Background(), StorageTableNameRagChunks, []float32{1, 1}, 10, SourceFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+
+		for i, text := range []string{"a", "b", "c"} {
+			res, err := parseResult[RagChunk](results[i].data)
+			assert.NoError(t, err)
+			assert.Equal(t, text, res.Text)
+		}
+		assert.Equal(t, float64(0), results[2].similarity)
+	})
+
+	t.Run("rejects embeddings of another size", func(t *testing.T) {
+		_, err := storage.similaritySearch(context.Background(), StorageTableNameRagChunks, []float32{1, 1, 1}, 10, SourceFilter{})
+		assert.Error(t, err)
+	})
+
+	t.Run("returns nothing for an empty table", func(t *testing.T) {
+		results, err := storage.similaritySearch(context.Background(), StorageTableNameRagContacts, []float32{1, 1}, 10, SourceFilter{})
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+}
@@ -0,0 +1,62 @@
+-- The tables PostgresStorage stores to, as they are in Supabase once the files in sql/ have been run
+
+create extension if not exists vector;
+
+create table agent_requests (
+  id uuid primary key default gen_random_uuid(),
+  created_at timestamptz not null default now(),
+  endpoint text not null,
+  metadata jsonb
+);
+
+create table agent_events (
+  id bigint generated by default as identity primary key,
+  created_at timestamptz not null default now(),
+  request_id text not null,
+  type text not null,
+  metadata jsonb
+);
+
+create index agent_events_request_id_idx on agent_events (request_id);
+
+create table rag_sources (
+  id bigint generated by default as identity primary key,
+  created_at timestamptz not null default now(),
+  url text not null,
+  name text not null default '',
+  type text not null default '',
+  job_id text,
+  created_by text,
+  content_hash text not null default '',
+  etag text,
+  last_modified text
+);
+
+create index rag_sources_url_idx on rag_sources (url);
+
+create table rag_chunks (
+  id bigint generated by default as identity primary key,
+  rag_source_id bigint not null references rag_sources (id) on delete cascade,
+  text text not null,
+  pos_in_source int not null default 0,
+  embedding vector(384),
+  -- The simple configuration doesn't stem or drop stop words, so codes and names match exactly
+  fts tsvector generated always as (to_tsvector('simple', text)) stored
+);
+
+create index rag_chunks_rag_source_id_idx on rag_chunks (rag_source_id);
+create index rag_chunks_embedding_idx on rag_chunks using hnsw (embedding vector_cosine_ops);
+create index rag_chunks_fts_idx on rag_chunks using gin (fts);
+
+create table rag_contacts (
+  id bigint generated by default as identity primary key,
+  rag_source_id bigint not null references rag_sources (id) on delete cascade,
+  context text not null default '',
+  contact text not null,
+  pos_in_source int not null default 0,
+  contact_type text not null default '',
+  embedding vector(384)
+);
+
+create index rag_contacts_rag_source_id_idx on rag_contacts (rag_source_id);
+create index rag_contacts_embedding_idx on rag_contacts using hnsw (embedding vector_cosine_ops);
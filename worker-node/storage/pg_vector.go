@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// vectorCodec encodes []float32 as pgvector's vector type, and decodes vectors into []float32, so
+// embeddings are sent and read as floats rather than as JSON or strings. In the binary format a vector
+// is its dimension and an unused field, as 16 bit ints, and then its values as 32 bit floats.
+type vectorCodec struct{}
+
+// registerVector makes conn send and read vectors with vectorCodec. pgvector's type has a different oid
+// in each database, so it is looked up by name.
+func registerVector(ctx context.Context, conn *pgx.Conn) error {
+	var oid uint32
+	if err := conn.QueryRow(ctx, "select 'vector'::regtype::oid").Scan(&oid); err != nil {
+		return fmt.Errorf("failed to look up the vector type: %w", err)
+	}
+
+	conn.TypeMap().RegisterType(&pgtype.Type{Name: "vector", OID: oid, Codec: vectorCodec{}})
+	return nil
+}
+
+func (vectorCodec) FormatSupported(format int16) bool {
+	return format == pgtype.BinaryFormatCode || format == pgtype.TextFormatCode
+}
+
+func (vectorCodec) PreferredFormat() int16 {
+	return pgtype.BinaryFormatCode
+}
+
+func (vectorCodec) PlanEncode(m *pgtype.Map, oid uint32, format int16, value any) pgtype.EncodePlan {
+	if _, ok := value.([]float32); !ok {
+		return nil
+	}
+
+	switch format {
+	case pgtype.BinaryFormatCode:
+		return encodePlanVectorBinary{}
+	case pgtype.TextFormatCode:
+		return encodePlanVectorText{}
+	}
+	return nil
+}
+
+type encodePlanVectorBinary struct{}
+
+func (encodePlanVectorBinary) Encode(value any, buf []byte) ([]byte, error) {
+	vector := value.([]float32)
+	if vector == nil {
+		return nil, nil
+	}
+	if len(vector) > math.MaxUint16 {
+		return nil, fmt.Errorf("vector has %d dimensions, more than a vector can have", len(vector))
+	}
+
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(vector)))
+	buf = binary.BigEndian.AppendUint16(buf, 0)
+	for _, v := range vector {
+		buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(v))
+	}
+	return buf, nil
+}
+
+type encodePlanVectorText struct{}
+
+func (encodePlanVectorText) Encode(value any, buf []byte) ([]byte, error) {
+	vector := value.([]float32)
+	if vector == nil {
+		return nil, nil
+	}
+
+	buf = append(buf, '[')
+	for i, v := range vector {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendFloat(buf, float64(v), 'g', -1, 32)
+	}
+	return append(buf, ']'), nil
+}
+
+func (vectorCodec) PlanScan(m *pgtype.Map, oid uint32, format int16, target any) pgtype.ScanPlan {
+	if _, ok := target.(*[]float32); !ok {
+		return nil
+	}
+	return scanPlanVector{format: format}
+}
+
+type scanPlanVector struct {
+	format int16
+}
+
+func (p scanPlanVector) Scan(src []byte, target any) error {
+	vector, err := decodeVector(p.format, src)
+	if err != nil {
+		return err
+	}
+	*target.(*[]float32) = vector
+	return nil
+}
+
+func (vectorCodec) DecodeDatabaseSQLValue(m *pgtype.Map, oid uint32, format int16, src []byte) (driver.Value, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	vector, err := decodeVector(format, src)
+	if err != nil {
+		return nil, err
+	}
+	text, err := encodePlanVectorText{}.Encode(vector, nil)
+	return string(text), err
+}
+
+func (vectorCodec) DecodeValue(m *pgtype.Map, oid uint32, format int16, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+	return decodeVector(format, src)
+}
+
+func decodeVector(format int16, src []byte) ([]float32, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	if format == pgtype.TextFormatCode {
+		return parseVectorText(string(src))
+	}
+
+	if len(src) < 4 {
+		return nil, fmt.Errorf("vector is %d bytes, too short for its header", len(src))
+	}
+
+	dim := int(binary.BigEndian.Uint16(src))
+	if len(src) != 4+4*dim {
+		return nil, fmt.Errorf("vector of %d dimensions is %d bytes", dim, len(src))
+	}
+
+	vector := make([]float32, dim)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.BigEndian.Uint32(src[4+4*i:]))
+	}
+	return vector, nil
+}
+
+// parseVectorText parses a vector written as text, like [1,2.5,3]
+func parseVectorText(s string) ([]float32, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("vector %q isn't in brackets", s)
+	}
+
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return []float32{}, nil
+	}
+
+	parts := strings.Split(s, ",")
+	vector := make([]float32, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse vector value %q: %w", part, err)
+		}
+		vector[i] = float32(v)
+	}
+	return vector, nil
+}
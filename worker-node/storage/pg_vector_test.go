@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVectorCodec(t *testing.T) {
+	var (
+		m      = pgtype.NewMap()
+		vector = []float32{1, -2.5, 0.125}
+	)
+
+	for _, format := range []int16{pgtype.BinaryFormatCode, pgtype.TextFormatCode} {
+		plan := vectorCodec{}.PlanEncode(m, 0, format, vector)
+		if !assert.NotNil(t, plan) {
+			continue
+		}
+
+		encoded, err := plan.Encode(vector, nil)
+		assert.NoError(t, err)
+
+		decoded, err := vectorCodec{}.DecodeValue(m, 0, format, encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, vector, decoded)
+
+		var scanned []float32
+		err = vectorCodec{}.PlanScan(m, 0, format, &scanned).Scan(encoded, &scanned)
+		assert.NoError(t, err)
+		assert.Equal(t, vector, scanned)
+	}
+
+	// The dimension, an unused field, and then the values
+	encoded, err := encodePlanVectorBinary{}.Encode([]float32{1}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 0, 0, 0x3f, 0x80, 0, 0}, encoded)
+
+	_, err = decodeVector(pgtype.BinaryFormatCode, []byte{0, 2, 0, 0, 0x3f, 0x80, 0, 0})
+	assert.Error(t, err)
+}
+
+func TestParseVectorText(t *testing.T) {
+	vector, err := parseVectorText("[1, 2.5,-3]")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1, 2.5, -3}, vector)
+
+	vector, err = parseVectorText("[]")
+	assert.NoError(t, err)
+	assert.Empty(t, vector)
+
+	_, err = parseVectorText("1,2")
+	assert.Error(t, err)
+
+	_, err = parseVectorText("[1,a]")
+	assert.Error(t, err)
+}
@@ -0,0 +1,840 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLockKey is the advisory lock held while migrating, so workers that start at the same time
+// don't apply a migration twice
+const migrationLockKey = 7_245_018_113
+
+// PostgresStorage stores to a Postgres database with the pgvector extension directly, rather than
+// through PostgREST like SupabaseStorage. Embeddings are sent and read as native vectors, and storeAll
+// copies its items in with COPY. The tables are created by the migrations in migrations/.
+type PostgresStorage struct {
+	pool *pgxpool.Pool
+
+	mu     sync.Mutex
+	tables map[StorageTableName][]column // The columns of each table, looked up when first used
+}
+
+// column is a column of a table that can be written to, with the name of its type, like int8 or vector
+type column struct {
+	name     string
+	typeName string
+}
+
+// querier runs statements on either the pool or a transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// NewPostgresStorage connects to the database at connString, a postgres:// url or key=value string,
+// and applies any migrations it hasn't had yet
+func NewPostgresStorage(ctx context.Context, connString string) (*PostgresStorage, error) {
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse postgres connection string: %w", err)
+	}
+
+	// The vector type only exists once the first migration has run, so the pool's connections, which
+	// look it up, are made after migrating
+	conn, err := pgx.ConnectConfig(ctx, config.ConnConfig.Copy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	err = migrate(ctx, conn)
+	conn.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config.AfterConnect = registerVector
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	return &PostgresStorage{
+		pool:   pool,
+		tables: make(map[StorageTableName][]column),
+	}, nil
+}
+
+// Close closes the storage's connections
+func (s *PostgresStorage) Close() {
+	s.pool.Close()
+}
+
+// migrate applies the migrations in migrations/ that the database hasn't had, in order of their file
+// names, each in its own transaction. The migrations that have been applied are kept in
+// schema_migrations.
+func migrate(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, "select pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "select pg_advisory_unlock($1)", migrationLockKey)
+
+	_, err := conn.Exec(ctx, `create table if not exists schema_migrations (
+		version text primary key,
+		applied_at timestamptz not null default now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := conn.Query(ctx, "select version from schema_migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	applied, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	// ReadDir returns the files sorted by name
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		version := strings.TrimSuffix(entry.Name(), ".sql")
+		if slices.Contains(applied, version) {
+			continue
+		}
+
+		migration, err := fs.ReadFile(migrations, "migrations/"+entry.Name())
+		if err != nil {
+			return err
+		}
+
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			// Without arguments the migration is sent as a simple query, so it can have many statements
+			if _, err := tx.Exec(ctx, string(migration)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "insert into schema_migrations (version) values ($1)", version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) store(ctx context.Context, table StorageTableName, data interface{}) (interface{}, error) {
+	row, err := toMap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	names := sortedKeys(row)
+	values, err := columnValues(table, columns, names, row)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholders := make([]string, len(names))
+	for i := range names {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+
+	sql := fmt.Sprintf("insert into %s (%s) values (%s) returning %s",
+		quote(string(table)), quoteAll(names), strings.Join(placeholders, ", "), selectList(columns, true))
+	return s.queryOne(ctx, s.pool, table, sql, values...)
+}
+
+// storeAll copies the items into the table with COPY, so a page's chunks are stored in one round trip.
+// COPY can't return the rows it makes, so items without ids are given them first, and the stored items
+// are read back by id.
+func (s *PostgresStorage) storeAll(ctx context.Context, table StorageTableName, data []interface{}) ([]interface{}, error) {
+	if len(data) == 0 {
+		return []interface{}{}, nil
+	}
+
+	rows := make([]map[string]interface{}, len(data))
+	var needIds []int // Indexes of the rows that need a numeric id
+	for i, item := range data {
+		row, err := toMap(item)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+
+		if row["id"] != nil {
+			continue
+		}
+
+		if originalID, ok := getOriginalIDType(item); ok && isNumberType(originalID) {
+			needIds = append(needIds, i)
+		} else {
+			row["id"] = uuid.New().String()
+		}
+	}
+
+	if len(needIds) > 0 {
+		ids, err := s.reserveIds(ctx, table, len(needIds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve ids: %w", err)
+		}
+		for i, index := range needIds {
+			rows[index]["id"] = ids[i]
+		}
+	}
+
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = fmt.Sprint(row["id"])
+	}
+
+	var stored []interface{}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := s.insertRows(ctx, tx, table, rows); err != nil {
+			return err
+		}
+
+		var err error
+		stored, err = s.getByIds(ctx, tx, table, ids)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// upsert inserts each item with on conflict do update in one Postgres transaction, so an item with the
+// same values of the conflict keys as a stored one sets the columns it has, keeping the stored id. The
+// conflict keys must have a unique index, as they do for the id.
+func (s *PostgresStorage) upsert(ctx context.Context, table StorageTableName, data []interface{}, conflictKeys []string) ([]interface{}, error) {
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range conflictKeys {
+		if !hasColumn(columns, key) {
+			return nil, fmt.Errorf("%s has no column %s", table, key)
+		}
+	}
+
+	conflicting := make(map[string]bool, len(conflictKeys))
+	for _, key := range conflictKeys {
+		conflicting[key] = true
+	}
+
+	result := make([]interface{}, len(data))
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for i, item := range data {
+			row, err := toMap(item)
+			if err != nil {
+				return err
+			}
+
+			names := sortedKeys(row)
+			values, err := columnValues(table, columns, names, row)
+			if err != nil {
+				return err
+			}
+
+			placeholders := make([]string, len(names))
+			var sets []string
+			for j, name := range names {
+				placeholders[j] = "$" + strconv.Itoa(j+1)
+				if name != "id" && !conflicting[name] {
+					sets = append(sets, fmt.Sprintf("%s = excluded.%s", quote(name), quote(name)))
+				}
+			}
+			if len(sets) == 0 {
+				// do nothing wouldn't return the stored row, so a key is set to itself instead
+				sets = append(sets, fmt.Sprintf("%s = excluded.%s", quote(conflictKeys[0]), quote(conflictKeys[0])))
+			}
+
+			sql := fmt.Sprintf("insert into %s (%s) values (%s) on conflict (%s) do update set %s returning %s",
+				quote(string(table)), quoteAll(names), strings.Join(placeholders, ", "), quoteAll(conflictKeys),
+				strings.Join(sets, ", "), selectList(columns, true))
+			result[i], err = s.queryOne(ctx, tx, table, sql, values...)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// update sets the columns in data, like a PATCH, keeping its id
+func (s *PostgresStorage) update(ctx context.Context, table StorageTableName, id string, data interface{}) (interface{}, error) {
+	dataMap, err := toMap(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateRow(ctx, s.pool, table, id, dataMap)
+}
+
+func (s *PostgresStorage) deleteWhere(ctx context.Context, table StorageTableName, matchingFields map[string]string) error {
+	return s.deleteMatching(ctx, s.pool, table, matchingFields)
+}
+
+// reserveIds takes ids from the sequence of the table's id column
+func (s *PostgresStorage) reserveIds(ctx context.Context, table StorageTableName, count int) ([]int, error) {
+	rows, err := s.pool.Query(ctx, "select nextval(pg_get_serial_sequence($1, 'id')) from generate_series(1, $2)", string(table), count)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]int, len(ids))
+	for i, id := range ids {
+		ret[i] = int(id)
+	}
+	return ret, nil
+}
+
+// apply makes a transaction's writes in a Postgres transaction
+func (s *PostgresStorage) apply(ctx context.Context, ops []op) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, op := range ops {
+			var err error
+			switch op.Kind {
+			case opKindInsert:
+				err = s.insertRows(ctx, tx, op.Table, op.Rows)
+			case opKindUpdate:
+				_, err = s.updateRow(ctx, tx, op.Table, op.Id, op.Data)
+			case opKindDelete:
+				err = s.deleteMatching(ctx, tx, op.Table, op.Match)
+			default:
+				err = fmt.Errorf("unknown kind of write %s", op.Kind)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *PostgresStorage) get(ctx context.Context, table StorageTableName, id string) (interface{}, error) {
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	sql := fmt.Sprintf("select %s from %s where id = %s", selectList(columns, true), quote(string(table)), textParam(1, columns, "id"))
+	return s.queryOne(ctx, s.pool, table, sql, id)
+}
+
+// getAll returns the items whose fields have the matching values, in order of id. Values are compared
+// as the type of their column, so numeric fields like rag_source_id can be matched.
+func (s *PostgresStorage) getAll(ctx context.Context, table StorageTableName, matchingFields map[string]string) ([]interface{}, error) {
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := whereMatching(table, columns, matchingFields)
+	if err != nil {
+		return nil, err
+	}
+
+	sql := fmt.Sprintf("select %s from %s%s order by id", selectList(columns, true), quote(string(table)), where)
+	items, _, err := s.query(ctx, s.pool, sql, args...)
+	return items, err
+}
+
+// similaritySearch orders the table by the cosine distance of its embeddings to embedding, which the
+// hnsw indexes made by the migrations speed up
+func (s *PostgresStorage) similaritySearch(ctx context.Context, table StorageTableName, embedding []float32, count int, filter SourceFilter) ([]similarItem, error) {
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if !hasColumn(columns, "embedding") || !hasColumn(columns, "rag_source_id") {
+		return nil, fmt.Errorf("table %s has no embeddings to search", table)
+	}
+
+	args := []any{embedding, count}
+	sql := fmt.Sprintf(`select %s, 1 - (embedding <=> $1) as similarity
+		from %s
+		where embedding is not null%s
+		order by embedding <=> $1
+		limit $2`, selectList(columns, false), quote(string(table)), filter.sql(&args))
+
+	items, scores, err := s.query(ctx, s.pool, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]similarItem, len(items))
+	for i, item := range items {
+		results[i] = similarItem{data: item, similarity: scores[i]}
+	}
+	return results, nil
+}
+
+// keywordSearch ranks the table's rows by their fts column against query, as keyword_match_rag_chunks in
+// sql/keyword_match.sql does for Supabase
+func (s *PostgresStorage) keywordSearch(ctx context.Context, table StorageTableName, query string, count int, filter SourceFilter) ([]keywordItem, error) {
+	if _, ok := keywordFields[table]; !ok {
+		return nil, fmt.Errorf("table %s has no keyword index", table)
+	}
+
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	// Any of the words can match, as in BM25, rather than all of them as plainto_tsquery would need
+	args := []any{query, count}
+	sql := fmt.Sprintf(`with query as (
+			select nullif(replace(plainto_tsquery('simple', $1)::text, ' & ', ' | '), '')::tsquery as q
+		)
+		select %s, ts_rank_cd(fts, query.q, 1)::float8 as score
+		from %s, query
+		where fts @@ query.q%s
+		order by score desc, id
+		limit $2`, selectList(columns, false), quote(string(table)), filter.sql(&args))
+
+	items, scores, err := s.query(ctx, s.pool, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]keywordItem, len(items))
+	for i, item := range items {
+		results[i] = keywordItem{data: item, score: scores[i]}
+	}
+	return results, nil
+}
+
+// sql returns the conditions that limit a search to the filter's rag sources, adding their arguments
+// to args
+func (f SourceFilter) sql(args *[]any) string {
+	var (
+		conditions       []string
+		sourceConditions []string
+	)
+
+	param := func(value any) string {
+		*args = append(*args, value)
+		return "$" + strconv.Itoa(len(*args))
+	}
+
+	if len(f.RagSourceIds) > 0 {
+		conditions = append(conditions, "rag_source_id = any("+param(f.RagSourceIds)+"::bigint[])")
+	}
+	for _, field := range [][2]string{{"url", f.Url}, {"job_id", f.JobId}, {"created_by", f.CreatedBy}} {
+		if field[1] != "" {
+			sourceConditions = append(sourceConditions, field[0]+" = "+param(field[1])+"::text")
+		}
+	}
+
+	if len(sourceConditions) > 0 {
+		conditions = append(conditions, "rag_source_id in (select id from rag_sources where "+strings.Join(sourceConditions, " and ")+")")
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " and " + strings.Join(conditions, " and ")
+}
+
+// insertRows copies rows into the table. Rows are copied in groups with the same columns, so columns a
+// row leaves out get their defaults rather than nulls.
+func (s *PostgresStorage) insertRows(ctx context.Context, db querier, table StorageTableName, rows []map[string]interface{}) error {
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	var (
+		groups = make(map[string][][]any)
+		names  = make(map[string][]string)
+		order  []string
+	)
+	for _, row := range rows {
+		rowNames := sortedKeys(row)
+		key := strings.Join(rowNames, ",")
+
+		values, err := columnValues(table, columns, rowNames, row)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := groups[key]; !ok {
+			names[key] = rowNames
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], values)
+	}
+
+	for _, key := range order {
+		if _, err := db.CopyFrom(ctx, pgx.Identifier{string(table)}, names[key], pgx.CopyFromRows(groups[key])); err != nil {
+			return fmt.Errorf("failed to copy into %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) updateRow(ctx context.Context, db querier, table StorageTableName, id string, data map[string]interface{}) (interface{}, error) {
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range sortedKeys(data) {
+		if name != "id" {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		sql := fmt.Sprintf("select %s from %s where id = %s", selectList(columns, true), quote(string(table)), textParam(1, columns, "id"))
+		return s.queryOne(ctx, db, table, sql, id)
+	}
+
+	values, err := columnValues(table, columns, names, data)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]string, len(names))
+	for i, name := range names {
+		assignments[i] = fmt.Sprintf("%s = $%d", quote(name), i+1)
+	}
+
+	sql := fmt.Sprintf("update %s set %s where id = %s returning %s",
+		quote(string(table)), strings.Join(assignments, ", "), textParam(len(names)+1, columns, "id"), selectList(columns, true))
+	return s.queryOne(ctx, db, table, sql, append(values, id)...)
+}
+
+func (s *PostgresStorage) deleteMatching(ctx context.Context, db querier, table StorageTableName, matchingFields map[string]string) error {
+	if len(matchingFields) == 0 {
+		return fmt.Errorf("deleting from %s needs at least one field to match", table)
+	}
+
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	where, args, err := whereMatching(table, columns, matchingFields)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, "delete from "+quote(string(table))+where, args...)
+	return err
+}
+
+// getByIds returns the items with ids, in the same order
+func (s *PostgresStorage) getByIds(ctx context.Context, db querier, table StorageTableName, ids []string) ([]interface{}, error) {
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	idType := pgx.Identifier{typeOf(columns, "id")}.Sanitize()
+	sql := fmt.Sprintf("select %s from %s where id = any($1::text[]::%s[])", selectList(columns, true), quote(string(table)), idType)
+	items, _, err := s.query(ctx, db, sql, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		byId[idOf(item)] = item
+	}
+
+	ret := make([]interface{}, len(ids))
+	for i, id := range ids {
+		item, ok := byId[id]
+		if !ok {
+			return nil, fmt.Errorf("%s %s wasn't stored", table, id)
+		}
+		ret[i] = item
+	}
+	return ret, nil
+}
+
+// queryOne returns the single item sql selects, or an error if there isn't one
+func (s *PostgresStorage) queryOne(ctx context.Context, db querier, table StorageTableName, sql string, args ...any) (interface{}, error) {
+	items, _, err := s.query(ctx, db, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s %v not found", table, args[len(args)-1])
+	}
+	return items[0], nil
+}
+
+// query returns the rows sql selects as maps of their columns. The value of a similarity or score
+// column is returned separately, rather than in the row.
+func (s *PostgresStorage) query(ctx context.Context, db querier, sql string, args ...any) ([]interface{}, []float64, error) {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var (
+		fields = rows.FieldDescriptions()
+		items  = []interface{}{}
+		scores []float64
+	)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		item := make(map[string]interface{}, len(values))
+		for i, value := range values {
+			switch name := fields[i].Name; name {
+			case "similarity", "score":
+				score, _ := value.(float64)
+				scores = append(scores, score)
+			default:
+				item[name] = fromPostgres(value)
+			}
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return items, scores, nil
+}
+
+// columns returns the columns of the table that can be written to, looking them up the first time
+func (s *PostgresStorage) columns(ctx context.Context, table StorageTableName) ([]column, error) {
+	s.mu.Lock()
+	columns, ok := s.tables[table]
+	s.mu.Unlock()
+	if ok {
+		return columns, nil
+	}
+
+	rows, err := s.pool.Query(ctx, `select column_name, udt_name
+		from information_schema.columns
+		where table_schema = current_schema() and table_name = $1 and is_generated = 'NEVER'
+		order by ordinal_position`, string(table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the columns of %s: %w", table, err)
+	}
+
+	columns, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (column, error) {
+		var c column
+		err := row.Scan(&c.name, &c.typeName)
+		return c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the columns of %s: %w", table, err)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s doesn't exist", table)
+	}
+
+	s.mu.Lock()
+	s.tables[table] = columns
+	s.mu.Unlock()
+	return columns, nil
+}
+
+// whereMatching returns the where clause that matches the fields, and its arguments
+func whereMatching(table StorageTableName, columns []column, matchingFields map[string]string) (string, []any, error) {
+	if len(matchingFields) == 0 {
+		return "", nil, nil
+	}
+
+	var (
+		names      = sortedKeys(matchingFields)
+		conditions = make([]string, len(names))
+		args       = make([]any, len(names))
+	)
+	for i, name := range names {
+		if !hasColumn(columns, name) {
+			return "", nil, fmt.Errorf("table %s has no column %s", table, name)
+		}
+		conditions[i] = quote(name) + " = " + textParam(i+1, columns, name)
+		args[i] = matchingFields[name]
+	}
+	return " where " + strings.Join(conditions, " and "), args, nil
+}
+
+// textParam is the placeholder for a value given as text and cast to the column's type, so the
+// column's indexes can still be used
+func textParam(n int, columns []column, name string) string {
+	return fmt.Sprintf("$%d::text::%s", n, pgx.Identifier{typeOf(columns, name)}.Sanitize())
+}
+
+// selectList lists the columns to select, which leaves out the embedding unless withEmbedding
+func selectList(columns []column, withEmbedding bool) string {
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if withEmbedding || c.name != "embedding" {
+			names = append(names, c.name)
+		}
+	}
+	return quoteAll(names)
+}
+
+// columnValues converts the values of a row's named columns, as they are after toMap, to the types pgx
+// sends for their columns' types
+func columnValues(table StorageTableName, columns []column, names []string, row map[string]interface{}) ([]any, error) {
+	values := make([]any, len(names))
+	for i, name := range names {
+		if !hasColumn(columns, name) {
+			return nil, fmt.Errorf("table %s has no column %s", table, name)
+		}
+
+		value, err := toPostgres(typeOf(columns, name), row[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s.%s: %w", table, name, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// toPostgres converts a value decoded from JSON to the type pgx sends for a column of typeName
+func toPostgres(typeName string, v interface{}) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch typeName {
+	case "int2", "int4", "int8":
+		switch n := v.(type) {
+		case int:
+			return int64(n), nil
+		case float64:
+			if n != math.Trunc(n) {
+				return nil, fmt.Errorf("%v isn't an integer", n)
+			}
+			return int64(n), nil
+		case string:
+			return strconv.ParseInt(n, 10, 64)
+		}
+	case "float4", "float8":
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case "vector":
+		if embedding, ok := embeddingOf(v); ok {
+			return embedding, nil
+		}
+	case "json", "jsonb":
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(encoded), nil
+	case "uuid":
+		if s, ok := v.(string); ok {
+			var id pgtype.UUID
+			err := id.Scan(s)
+			return id, err
+		}
+	case "timestamptz", "timestamp":
+		if s, ok := v.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	default:
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("can't store %T as %s", v, typeName)
+}
+
+// fromPostgres converts a value read by pgx to one that marshals to JSON as the stored types expect
+func fromPostgres(v any) interface{} {
+	switch value := v.(type) {
+	case [16]byte:
+		return uuid.UUID(value).String()
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	}
+	return v
+}
+
+func hasColumn(columns []column, name string) bool {
+	return typeOf(columns, name) != ""
+}
+
+func typeOf(columns []column, name string) string {
+	for _, c := range columns {
+		if c.name == name {
+			return c.typeName
+		}
+	}
+	return ""
+}
+
+func quote(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
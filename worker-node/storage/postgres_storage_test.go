@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPostgresStorage connects to the database at DATABASE_URL, skipping the test if there isn't one
+func newTestPostgresStorage(t *testing.T) *PostgresStorage {
+	if os.Getenv("CICD") == "true" || os.Getenv("DATABASE_URL") == "" {
+		t.Skip("Skipping test without a Postgres database")
+	}
+
+	storage, err := NewPostgresStorage(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(storage.Close)
+	return storage
+}
+
+// oneHot is an embedding of the model's dimension that is 1 at i and 0 everywhere else
+func oneHot(i int) []float32 {
+	embedding := make([]float32, 384)
+	embedding[i] = 1
+	return embedding
+}
+
+func TestPostgresStorage(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = newTestPostgresStorage(t)
+	)
+
+	source, err := Store(ctx, storage, RagSource{URL: "https://example.com/postgres", Name: "Example", Type: RagSourceTypeWebsite, JobId: "job"})
+	assert.NoError(t, err)
+	assert.NotZero(t, source.ID)
+	sourceId := strconv.Itoa(source.ID)
+	t.Cleanup(func() { DeleteWhere[RagSource](ctx, storage, map[string]string{"id": sourceId}) })
+
+	chunks, err := StoreAll(ctx, storage,
+		RagChunk{RagSourceId: source.ID, Text: "Open daily", PosInSource: 0, Embedding: oneHot(0)},
+		RagChunk{RagSourceId: source.ID, Text: "Closed on Sundays", PosInSource: 1, Embedding: oneHot(1)},
+	)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "Closed on Sundays", chunks[1].Text)
+	assert.Equal(t, oneHot(1), chunks[1].Embedding)
+
+	got, err := Get[RagChunk](ctx, storage, strconv.Itoa(chunks[0].ID))
+	assert.NoError(t, err)
+	assert.Equal(t, chunks[0], *got)
+
+	// Numeric fields can be matched
+	all, err := GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": sourceId})
+	assert.NoError(t, err)
+	assert.Equal(t, chunks, all)
+
+	similar, err := SimilaritySearch[RagChunk](ctx, storage, oneHot(1), 1, SourceFilter{JobId: "job", RagSourceIds: []int{source.ID}})
+	assert.NoError(t, err)
+	if assert.Len(t, similar, 1) {
+		assert.Equal(t, chunks[1].ID, similar[0].Item.ID)
+		assert.InDelta(t, 1, similar[0].Similarity, 1e-6)
+		assert.Nil(t, similar[0].Item.Embedding)
+	}
+
+	matches, err := KeywordSearch[RagChunk](ctx, storage, "sundays", 10, SourceFilter{Url: source.URL})
+	assert.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, chunks[1].ID, matches[0].Item.ID)
+	}
+
+	updated, err := Update(ctx, storage, sourceId, RagSource{ContentHash: "hash"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", updated.ContentHash)
+	assert.Equal(t, source.URL, updated.URL)
+
+	err = DeleteWhere[RagChunk](ctx, storage, map[string]string{"rag_source_id": sourceId})
+	assert.NoError(t, err)
+
+	all, err = GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": sourceId})
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestPostgresStorageInTransaction(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = newTestPostgresStorage(t)
+	)
+
+	var sourceId string
+	err := InTransaction(ctx, storage, func(tx Storage) error {
+		source, err := Store(ctx, tx, RagSource{URL: "https://example.com/postgres-transaction"})
+		if err != nil {
+			return err
+		}
+		sourceId = strconv.Itoa(source.ID)
+
+		_, err = StoreAll(ctx, tx,
+			RagContact{RagSourceId: source.ID, Contact: "hello@example.com", ContactType: "email", Embedding: oneHot(2)},
+		)
+		return err
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() { DeleteWhere[RagSource](ctx, storage, map[string]string{"id": sourceId}) })
+
+	contacts, err := GetAll[RagContact](ctx, storage, map[string]string{"rag_source_id": sourceId})
+	assert.NoError(t, err)
+	if assert.Len(t, contacts, 1) {
+		assert.Equal(t, "hello@example.com", contacts[0].Contact)
+		assert.Equal(t, oneHot(2), contacts[0].Embedding)
+	}
+}
+
+func TestPostgresStorageAgentRequest(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = newTestPostgresStorage(t)
+	)
+
+	request, err := Store(ctx, storage, NewAgentRequest("test", map[string]string{"test": "test"}))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, request.ID)
+	t.Cleanup(func() { DeleteWhere[AgentRequest](ctx, storage, map[string]string{"id": request.ID}) })
+
+	got, err := Get[AgentRequest](ctx, storage, request.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"test": "test"}, got.Metadata)
+}
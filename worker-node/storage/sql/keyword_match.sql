@@ -0,0 +1,44 @@
+-- Full text search over rag_chunks for SupabaseStorage's keywordSearch. Run this in the Supabase SQL
+-- editor after sql/match.sql, which defines rag_source_matches.
+
+-- The simple configuration doesn't stem or drop stop words, so codes and names match exactly
+alter table rag_chunks add column if not exists fts tsvector
+  generated always as (to_tsvector('simple', text)) stored;
+
+create index if not exists rag_chunks_fts_idx on rag_chunks using gin (fts);
+
+create or replace function keyword_match_rag_chunks(
+  query_text text,
+  match_count int,
+  filter_rag_source_ids bigint[] default null,
+  filter_source_url text default null,
+  filter_job_id text default null,
+  filter_created_by text default null
+)
+returns table (
+  id bigint,
+  rag_source_id bigint,
+  text text,
+  pos_in_source int,
+  score float
+)
+language sql stable
+as $$
+  -- Any of the words can match, as in BM25, rather than all of them as plainto_tsquery would need
+  with query as (
+    select nullif(replace(plainto_tsquery('simple', query_text)::text, ' & ', ' | '), '')::tsquery as q
+  )
+  select
+    rag_chunks.id,
+    rag_chunks.rag_source_id,
+    rag_chunks.text,
+    rag_chunks.pos_in_source,
+    ts_rank_cd(rag_chunks.fts, query.q, 1) as score
+  from rag_chunks, query
+  where rag_chunks.fts @@ query.q
+    and rag_chunks.rag_source_id in (
+      select rag_source_matches(filter_rag_source_ids, filter_source_url, filter_job_id, filter_created_by)
+    )
+  order by score desc, rag_chunks.id
+  limit match_count;
+$$;
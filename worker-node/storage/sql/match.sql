@@ -0,0 +1,96 @@
+-- Similarity search over the tables with embeddings, for SupabaseStorage's similaritySearch. Run this
+-- in the Supabase SQL editor after the rag tables have been created.
+
+alter table rag_sources add column if not exists created_by text;
+
+create index if not exists rag_chunks_embedding_idx on rag_chunks
+  using hnsw (embedding vector_cosine_ops);
+
+create index if not exists rag_contacts_embedding_idx on rag_contacts
+  using hnsw (embedding vector_cosine_ops);
+
+-- rag_source_matches returns the ids of the rag sources that pass a filter, with null filters ignored
+create or replace function rag_source_matches(
+  filter_rag_source_ids bigint[],
+  filter_source_url text,
+  filter_job_id text,
+  filter_created_by text
+)
+returns setof bigint
+language sql stable
+as $$
+  select id
+  from rag_sources
+  where (filter_rag_source_ids is null or id = any(filter_rag_source_ids))
+    and (filter_source_url is null or url = filter_source_url)
+    and (filter_job_id is null or job_id = filter_job_id)
+    and (filter_created_by is null or created_by = filter_created_by);
+$$;
+
+drop function if exists match_rag_chunks(vector, int, bigint[], text, text, text);
+
+create or replace function match_rag_chunks(
+  query_embedding vector(384),
+  match_count int,
+  filter_rag_source_ids bigint[] default null,
+  filter_source_url text default null,
+  filter_job_id text default null,
+  filter_created_by text default null
+)
+returns table (
+  id bigint,
+  rag_source_id bigint,
+  text text,
+  pos_in_source int,
+  similarity float
+)
+language sql stable
+as $$
+  select
+    rag_chunks.id,
+    rag_chunks.rag_source_id,
+    rag_chunks.text,
+    rag_chunks.pos_in_source,
+    1 - (rag_chunks.embedding <=> query_embedding) as similarity
+  from rag_chunks
+  where rag_chunks.rag_source_id in (
+    select rag_source_matches(filter_rag_source_ids, filter_source_url, filter_job_id, filter_created_by)
+  )
+  order by rag_chunks.embedding <=> query_embedding
+  limit match_count;
+$$;
+
+create or replace function match_rag_contacts(
+  query_embedding vector(384),
+  match_count int,
+  filter_rag_source_ids bigint[] default null,
+  filter_source_url text default null,
+  filter_job_id text default null,
+  filter_created_by text default null
+)
+returns table (
+  id bigint,
+  rag_source_id bigint,
+  context text,
+  contact text,
+  pos_in_source int,
+  contact_type text,
+  similarity float
+)
+language sql stable
+as $$
+  select
+    rag_contacts.id,
+    rag_contacts.rag_source_id,
+    rag_contacts.context,
+    rag_contacts.contact,
+    rag_contacts.pos_in_source,
+    rag_contacts.contact_type,
+    1 - (rag_contacts.embedding <=> query_embedding) as similarity
+  from rag_contacts
+  where rag_contacts.rag_source_id in (
+    select rag_source_matches(filter_rag_source_ids, filter_source_url, filter_job_id, filter_created_by)
+  )
+  order by rag_contacts.embedding <=> query_embedding
+  limit match_count;
+$$;
@@ -0,0 +1,13 @@
+-- Columns for re-crawling sources in place rather than storing them again. Run this in the Supabase
+-- SQL editor after the rag tables have been created.
+
+alter table rag_sources add column if not exists content_hash text not null default '';
+alter table rag_sources add column if not exists etag text;
+alter table rag_sources add column if not exists last_modified text;
+
+-- The rag worker looks up a url's source before storing it
+create index if not exists rag_sources_url_idx on rag_sources (url);
+
+-- A source's chunks and contacts are deleted when it is re-crawled and has changed
+create index if not exists rag_chunks_rag_source_id_idx on rag_chunks (rag_source_id);
+create index if not exists rag_contacts_rag_source_id_idx on rag_contacts (rag_source_id);
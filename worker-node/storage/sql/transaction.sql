@@ -0,0 +1,75 @@
+-- Functions behind SupabaseStorage's transactions. PostgREST runs each request in its own transaction,
+-- so a transaction's writes are staged in Go and made by a single call to apply_writes. Run this in
+-- the Supabase SQL editor after the rag tables have been created.
+
+-- reserve_ids takes id_count ids from the sequence of a table's id column, for the items a transaction
+-- stores, so that later writes in the transaction can refer to them
+create or replace function reserve_ids(table_name text, id_count int)
+returns setof bigint
+language sql volatile
+as $$
+  select nextval(pg_get_serial_sequence(table_name, 'id'))
+  from generate_series(1, id_count);
+$$;
+
+-- apply_writes makes every write in ops, or none of them if one fails. Each op is one of
+--   {"kind": "insert", "table": ..., "rows": [{column: value, ...}, ...]}
+--   {"kind": "update", "table": ..., "id": ..., "data": {column: value, ...}}
+--   {"kind": "delete", "table": ..., "match": {column: value, ...}}
+-- Only the columns given are written, so the rest keep their defaults or stored values.
+create or replace function apply_writes(ops jsonb)
+returns void
+language plpgsql volatile
+as $$
+declare
+  op jsonb;
+  row_data jsonb;
+  columns text;
+  affected int;
+begin
+  for op in select * from jsonb_array_elements(ops) loop
+    case op->>'kind'
+    when 'insert' then
+      for row_data in select * from jsonb_array_elements(op->'rows') loop
+        select string_agg(quote_ident(key), ', ') into columns from jsonb_object_keys(row_data) as key;
+        execute format(
+          'insert into %I (%s) select %s from jsonb_populate_record(null::%I, $1)',
+          op->>'table', columns, columns, op->>'table'
+        ) using row_data;
+      end loop;
+
+    when 'update' then
+      select string_agg(quote_ident(key), ', ') into columns
+      from jsonb_object_keys(op->'data') as key
+      where key <> 'id';
+
+      if columns is not null then
+        execute format(
+          'update %I set (%s) = (select %s from jsonb_populate_record(null::%I, $1)) where id::text = $2',
+          op->>'table', columns, columns, op->>'table'
+        ) using op->'data', op->>'id';
+        get diagnostics affected = row_count;
+
+        if affected = 0 then
+          raise exception '% % not found', op->>'table', op->>'id';
+        end if;
+      end if;
+
+    when 'delete' then
+      if op->'match' is null or op->'match' = '{}'::jsonb then
+        raise exception 'deleting from % needs at least one field to match', op->>'table';
+      end if;
+
+      execute format(
+        'delete from %I t where not exists (
+           select 1 from jsonb_each_text($1) m where to_jsonb(t)->>m.key is distinct from m.value
+         )',
+        op->>'table'
+      ) using op->'match';
+
+    else
+      raise exception 'unknown kind of write %', op->>'kind';
+    end case;
+  end loop;
+end;
+$$;
@@ -0,0 +1,41 @@
+-- upsert_rows, behind SupabaseStorage's upserts. PostgREST's upserts can only be told which columns
+-- conflict through a query parameter the client can't set, so they are made by this function instead.
+-- Run this in the Supabase SQL editor after the rag tables have been created.
+
+-- upsert_rows inserts each of rows into a table, or, if a stored row has the same values of
+-- conflict_keys, sets the columns the row has on it, keeping its id. conflict_keys must have a unique
+-- index. The stored rows are returned in the same order.
+create or replace function upsert_rows(table_name text, rows jsonb, conflict_keys text[])
+returns setof jsonb
+language plpgsql volatile
+as $$
+declare
+  row_data jsonb;
+  columns text;
+  sets text;
+  stored jsonb;
+begin
+  for row_data in select * from jsonb_array_elements(rows) loop
+    select string_agg(quote_ident(key), ', ') into columns from jsonb_object_keys(row_data) as key;
+
+    select string_agg(format('%I = excluded.%I', key, key), ', ') into sets
+    from jsonb_object_keys(row_data) as key
+    where key <> 'id' and key <> all(conflict_keys);
+
+    -- do nothing wouldn't return the stored row, so a key is set to itself instead
+    if sets is null then
+      sets := format('%I = excluded.%I', conflict_keys[1], conflict_keys[1]);
+    end if;
+
+    execute format(
+      'insert into %I as t (%s) select %s from jsonb_populate_record(null::%I, $1)
+       on conflict (%s) do update set %s
+       returning to_jsonb(t)',
+      table_name, columns, columns, table_name,
+      (select string_agg(quote_ident(key), ', ') from unnest(conflict_keys) as key), sets
+    ) using row_data into stored;
+
+    return next stored;
+  end loop;
+end;
+$$;
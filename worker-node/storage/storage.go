@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -17,17 +18,62 @@ const (
 )
 
 type Storage interface {
-	store(table StorageTableName, data interface{}) (interface{}, error)
-	storeAll(table StorageTableName, data []interface{}) ([]interface{}, error)
+	store(ctx context.Context, table StorageTableName, data interface{}) (interface{}, error)
+	storeAll(ctx context.Context, table StorageTableName, data []interface{}) ([]interface{}, error)
 
-	get(table StorageTableName, id string) (interface{}, error)
-	getAll(table StorageTableName, matchingFields map[string]string) ([]interface{}, error)
+	update(ctx context.Context, table StorageTableName, id string, data interface{}) (interface{}, error)
+	upsert(ctx context.Context, table StorageTableName, data []interface{}, conflictKeys []string) ([]interface{}, error)
+	deleteWhere(ctx context.Context, table StorageTableName, matchingFields map[string]string) error
+
+	// reserveIds returns count unused numeric ids for items of table, which transactions store new
+	// items with, and apply makes all of a transaction's writes or none of them
+	reserveIds(ctx context.Context, table StorageTableName, count int) ([]int, error)
+	apply(ctx context.Context, ops []op) error
+
+	get(ctx context.Context, table StorageTableName, id string) (interface{}, error)
+	getAll(ctx context.Context, table StorageTableName, matchingFields map[string]string) ([]interface{}, error)
+
+	similaritySearch(ctx context.Context, table StorageTableName, embedding []float32, count int, filter SourceFilter) ([]similarItem, error)
+	keywordSearch(ctx context.Context, table StorageTableName, query string, count int, filter SourceFilter) ([]keywordItem, error)
+}
+
+// SourceFilter limits a similarity search to the items of some rag sources. Fields left empty don't
+// filter.
+type SourceFilter struct {
+	RagSourceIds []int
+	Url          string
+	JobId        string
+	CreatedBy    string
+}
+
+// Similar is an item found by a similarity search, along with its cosine similarity to the embedding
+// searched for
+type Similar[T StorageType] struct {
+	Item       T
+	Similarity float64
+}
+
+type similarItem struct {
+	data       interface{}
+	similarity float64
+}
+
+// KeywordMatch is an item found by a keyword search, along with how well its text matches the query.
+// Scores are only comparable between the items of one search.
+type KeywordMatch[T StorageType] struct {
+	Item  T
+	Score float64
+}
+
+type keywordItem struct {
+	data  interface{}
+	score float64
 }
 
-func Get[T StorageType](storage Storage, id string) (*T, error) {
+func Get[T StorageType](ctx context.Context, storage Storage, id string) (*T, error) {
 	var t T
 
-	data, err := storage.get(t.TableName(), id)
+	data, err := storage.get(ctx, t.TableName(), id)
 	if err != nil {
 		return nil, err
 	}
@@ -46,9 +92,9 @@ func Get[T StorageType](storage Storage, id string) (*T, error) {
 	return ret, nil
 }
 
-func GetAll[T StorageType](storage Storage, matchingFields map[string]string) ([]T, error) {
+func GetAll[T StorageType](ctx context.Context, storage Storage, matchingFields map[string]string) ([]T, error) {
 	var t T
-	data, err := storage.getAll(t.TableName(), matchingFields)
+	data, err := storage.getAll(ctx, t.TableName(), matchingFields)
 	if err != nil {
 		return nil, err
 	}
@@ -69,10 +115,10 @@ func GetAll[T StorageType](storage Storage, matchingFields map[string]string) ([
 	return ret, nil
 }
 
-func Store[T StorageType](storage Storage, data T) (*T, error) {
+func Store[T StorageType](ctx context.Context, storage Storage, data T) (*T, error) {
 	var t T
 
-	d, err := storage.store(t.TableName(), data)
+	d, err := storage.store(ctx, t.TableName(), data)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +137,7 @@ func Store[T StorageType](storage Storage, data T) (*T, error) {
 	return ret, nil
 }
 
-func StoreAll[T StorageType](storage Storage, data ...T) ([]T, error) {
+func StoreAll[T StorageType](ctx context.Context, storage Storage, data ...T) ([]T, error) {
 	var t T
 
 	table := t.TableName()
@@ -102,7 +148,68 @@ func StoreAll[T StorageType](storage Storage, data ...T) ([]T, error) {
 		converted[i] = v
 	}
 
-	ds, err := storage.storeAll(table, converted)
+	ds, err := storage.storeAll(ctx, table, converted)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]T, len(ds))
+	for i, d := range ds {
+		jsonData, err := json.Marshal(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal data to JSON: %v", err)
+		}
+
+		err = json.Unmarshal(jsonData, &ret[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data into type %v: %v", reflect.TypeOf(t), err)
+		}
+	}
+
+	return ret, nil
+}
+
+// Update sets the fields of the item with id to those of data and returns the updated item. Fields
+// left out of data's JSON, like empty omitempty fields, keep their stored values.
+func Update[T StorageType](ctx context.Context, storage Storage, id string, data T) (*T, error) {
+	var t T
+
+	d, err := storage.update(ctx, t.TableName(), id, data)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data to JSON: %v", err)
+	}
+
+	ret := new(T)
+	err = json.Unmarshal(jsonData, ret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data into type %v: %v", reflect.TypeOf(t), err)
+	}
+
+	return ret, nil
+}
+
+// Upsert stores each item of data, or, if an item with the same values of the conflict keys is already
+// stored, updates that item with its fields as Update does, and returns the stored items. The conflict
+// keys default to id. Items without a value for every conflict key are always stored. The conflict keys
+// must be the id or have a unique index, or the upsert fails.
+func Upsert[T StorageType](ctx context.Context, storage Storage, conflictKeys []string, data ...T) ([]T, error) {
+	var t T
+
+	if len(conflictKeys) == 0 {
+		conflictKeys = []string{"id"}
+	}
+
+	converted := make([]interface{}, len(data))
+	for i, v := range data {
+		converted[i] = v
+	}
+
+	ds, err := storage.upsert(ctx, t.TableName(), converted, conflictKeys)
 	if err != nil {
 		return nil, err
 	}
@@ -122,3 +229,71 @@ func StoreAll[T StorageType](storage Storage, data ...T) ([]T, error) {
 
 	return ret, nil
 }
+
+// Delete deletes the item of T with id. Deleting an item that isn't stored isn't an error, so a
+// takedown can be retried.
+func Delete[T StorageType](ctx context.Context, storage Storage, id string) error {
+	var t T
+	return storage.deleteWhere(ctx, t.TableName(), map[string]string{"id": id})
+}
+
+// DeleteWhere deletes every item of T whose fields have the matching values, like the chunks of a rag
+// source by rag_source_id. At least one field must be given.
+func DeleteWhere[T StorageType](ctx context.Context, storage Storage, matchingFields map[string]string) error {
+	var t T
+	return storage.deleteWhere(ctx, t.TableName(), matchingFields)
+}
+
+// SimilaritySearch returns the count items of T whose embeddings are most similar to embedding, most
+// similar first. T must be stored with an embedding and a rag source id, like RagChunk and RagContact.
+// The items' embeddings aren't returned.
+func SimilaritySearch[T StorageType](ctx context.Context, storage Storage, embedding []float32, count int, filter SourceFilter) ([]Similar[T], error) {
+	var t T
+	data, err := storage.similaritySearch(ctx, t.TableName(), embedding, count, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Similar[T], len(data))
+	for i, d := range data {
+		jsonData, err := json.Marshal(d.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal data to JSON: %v", err)
+		}
+
+		err = json.Unmarshal(jsonData, &ret[i].Item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data into type %v: %v", reflect.TypeOf(t), err)
+		}
+		ret[i].Similarity = d.similarity
+	}
+
+	return ret, nil
+}
+
+// KeywordSearch returns the count items of T whose text best matches the words of query, best first.
+// Items match if they contain any of the words, and rank higher the more of them, and the rarer ones,
+// they contain. Only RagChunk is indexed. The items' embeddings aren't returned.
+func KeywordSearch[T StorageType](ctx context.Context, storage Storage, query string, count int, filter SourceFilter) ([]KeywordMatch[T], error) {
+	var t T
+	data, err := storage.keywordSearch(ctx, t.TableName(), query, count, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]KeywordMatch[T], len(data))
+	for i, d := range data {
+		jsonData, err := json.Marshal(d.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal data to JSON: %v", err)
+		}
+
+		err = json.Unmarshal(jsonData, &ret[i].Item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data into type %v: %v", reflect.TypeOf(t), err)
+		}
+		ret[i].Score = d.score
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,442 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStorageContract checks the behaviour the rest of the worker relies on from every Storage. Each
+// case gets its own storage from newStorage. Cases only read back the items they stored, by their
+// rag source, so they can run against a database that is shared with other tests.
+func testStorageContract(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+
+	t.Run("store assigns ids", func(t *testing.T) {
+		storage := newStorage(t)
+
+		first := newContractSource(t, storage)
+		second := newContractSource(t, storage)
+		assert.NotZero(t, first.ID)
+		assert.Greater(t, second.ID, first.ID)
+
+		request, err := Store(ctx, storage, NewAgentRequest("contract", map[string]interface{}{"test": "test"}))
+		assert.NoError(t, err)
+		t.Cleanup(func() { DeleteWhere[AgentRequest](ctx, storage, map[string]string{"id": request.ID}) })
+		_, err = uuid.Parse(request.ID)
+		assert.NoError(t, err)
+
+		got, err := Get[AgentRequest](ctx, storage, request.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, request.Endpoint, got.Endpoint)
+		assert.Equal(t, map[string]interface{}{"test": "test"}, got.Metadata)
+	})
+
+	t.Run("store keeps given ids", func(t *testing.T) {
+		storage := newStorage(t)
+
+		ids, err := storage.reserveIds(ctx, StorageTableNameRagSources, 2)
+		assert.NoError(t, err)
+		assert.Len(t, ids, 2)
+		assert.NotEqual(t, ids[0], ids[1])
+
+		source, err := Store(ctx, storage, RagSource{ID: ids[1], URL: contractUrl()})
+		assert.NoError(t, err)
+		t.Cleanup(func() { DeleteWhere[RagSource](ctx, storage, map[string]string{"id": strconv.Itoa(ids[1])}) })
+		assert.Equal(t, ids[1], source.ID)
+
+		// Reserved ids aren't given out again
+		other := newContractSource(t, storage)
+		assert.NotContains(t, ids, other.ID)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+
+		got, err := Get[RagSource](ctx, storage, strconv.Itoa(source.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, source, *got)
+
+		ids, err := storage.reserveIds(ctx, StorageTableNameRagSources, 1)
+		assert.NoError(t, err)
+		_, err = Get[RagSource](ctx, storage, strconv.Itoa(ids[0]))
+		assert.Error(t, err)
+	})
+
+	t.Run("store all and get all", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+		other := newContractSource(t, storage)
+
+		chunks := storeContractChunks(t, storage, source, "Open daily", "Closed on Sundays", "Open late on Fridays")
+		storeContractChunks(t, storage, other, "Opening hours")
+		for i, chunk := range chunks {
+			assert.Equal(t, i, chunk.PosInSource)
+			assert.Equal(t, contractEmbedding(i), chunk.Embedding)
+		}
+
+		// Numeric fields are matched, and items come in order of id
+		all, err := GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": strconv.Itoa(source.ID)})
+		assert.NoError(t, err)
+		assert.Equal(t, chunks, all)
+
+		all, err = GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": strconv.Itoa(source.ID), "pos_in_source": "1"})
+		assert.NoError(t, err)
+		assert.Equal(t, chunks[1:2], all)
+
+		sources, err := GetAll[RagSource](ctx, storage, map[string]string{"url": source.URL})
+		assert.NoError(t, err)
+		assert.Equal(t, []RagSource{source}, sources)
+
+		sources, err = GetAll[RagSource](ctx, storage, map[string]string{"url": contractUrl()})
+		assert.NoError(t, err)
+		assert.Empty(t, sources)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+		id := strconv.Itoa(source.ID)
+
+		updated, err := Update(ctx, storage, id, RagSource{URL: source.URL, Name: "Renamed", ContentHash: "hash"})
+		assert.NoError(t, err)
+		assert.Equal(t, source.ID, updated.ID)
+		assert.Equal(t, "Renamed", updated.Name)
+		assert.Equal(t, "hash", updated.ContentHash)
+
+		// Empty omitempty fields keep their stored values
+		assert.Equal(t, source.JobId, updated.JobId)
+
+		got, err := Get[RagSource](ctx, storage, id)
+		assert.NoError(t, err)
+		assert.Equal(t, *updated, *got)
+
+		ids, err := storage.reserveIds(ctx, StorageTableNameRagSources, 1)
+		assert.NoError(t, err)
+		_, err = Update(ctx, storage, strconv.Itoa(ids[0]), RagSource{URL: source.URL})
+		assert.Error(t, err)
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+
+		upserted, err := Upsert(ctx, storage, nil,
+			RagSource{ID: source.ID, URL: source.URL, Name: "Renamed", Type: source.Type, ContentHash: "hash"},
+			RagSource{URL: contractUrl(), Name: "New", Type: RagSourceTypeWebsite, JobId: "job"},
+		)
+		assert.NoError(t, err)
+		if !assert.Len(t, upserted, 2) {
+			return
+		}
+		t.Cleanup(func() {
+			Delete[RagSource](ctx, storage, strconv.Itoa(upserted[1].ID))
+		})
+
+		// The stored source is updated, keeping the fields left empty
+		assert.Equal(t, source.ID, upserted[0].ID)
+		assert.Equal(t, "Renamed", upserted[0].Name)
+		assert.Equal(t, "hash", upserted[0].ContentHash)
+		assert.Equal(t, source.JobId, upserted[0].JobId)
+
+		// The new one is stored with an id of its own
+		assert.NotZero(t, upserted[1].ID)
+		assert.NotEqual(t, source.ID, upserted[1].ID)
+		assert.Equal(t, "New", upserted[1].Name)
+
+		for _, want := range upserted {
+			got, err := Get[RagSource](ctx, storage, strconv.Itoa(want.ID))
+			assert.NoError(t, err)
+			assert.Equal(t, want, *got)
+		}
+	})
+
+	t.Run("upsert only on unique keys", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+
+		// rag_sources.url has no unique index, so more than one source could have the url
+		_, err := Upsert(ctx, storage, []string{"url"}, RagSource{URL: source.URL, Name: "Renamed", Type: source.Type})
+		assert.Error(t, err)
+
+		got, err := GetAll[RagSource](ctx, storage, map[string]string{"url": source.URL})
+		assert.NoError(t, err)
+		assert.Equal(t, []RagSource{source}, got)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+		other := newContractSource(t, storage)
+
+		assert.NoError(t, Delete[RagSource](ctx, storage, strconv.Itoa(source.ID)))
+
+		_, err := Get[RagSource](ctx, storage, strconv.Itoa(source.ID))
+		assert.Error(t, err)
+
+		got, err := Get[RagSource](ctx, storage, strconv.Itoa(other.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, other, *got)
+
+		// Deleting it again isn't an error
+		assert.NoError(t, Delete[RagSource](ctx, storage, strconv.Itoa(source.ID)))
+	})
+
+	t.Run("delete where", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+		other := newContractSource(t, storage)
+
+		storeContractChunks(t, storage, source, "Open daily", "Closed on Sundays")
+		otherChunks := storeContractChunks(t, storage, other, "Open late on Fridays")
+
+		err := DeleteWhere[RagChunk](ctx, storage, map[string]string{"rag_source_id": strconv.Itoa(source.ID)})
+		assert.NoError(t, err)
+
+		all, err := GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": strconv.Itoa(source.ID)})
+		assert.NoError(t, err)
+		assert.Empty(t, all)
+
+		all, err = GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": strconv.Itoa(other.ID)})
+		assert.NoError(t, err)
+		assert.Equal(t, otherChunks, all)
+
+		// Deleted items aren't found by searches either
+		matches, err := KeywordSearch[RagChunk](ctx, storage, "open", 10, SourceFilter{RagSourceIds: []int{source.ID, other.ID}})
+		assert.NoError(t, err)
+		if assert.Len(t, matches, 1) {
+			assert.Equal(t, otherChunks[0].ID, matches[0].Item.ID)
+		}
+
+		assert.Error(t, DeleteWhere[RagChunk](ctx, storage, nil))
+	})
+
+	t.Run("similarity search", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+		other := newContractSource(t, storage)
+
+		chunks := storeContractChunks(t, storage, source, "Open daily", "Closed on Sundays", "Open late on Fridays")
+		storeContractChunks(t, storage, other, "Opening hours", "Closed on Sundays")
+
+		query := contractEmbedding(1)
+		query[2] = 0.5
+
+		similar, err := SimilaritySearch[RagChunk](ctx, storage, query, 2, SourceFilter{RagSourceIds: []int{source.ID}})
+		assert.NoError(t, err)
+		if assert.Len(t, similar, 2) {
+			assert.Equal(t, chunks[1].ID, similar[0].Item.ID)
+			assert.Equal(t, chunks[2].ID, similar[1].Item.ID)
+			assert.Greater(t, similar[0].Similarity, similar[1].Similarity)
+			assert.Equal(t, "Closed on Sundays", similar[0].Item.Text)
+			assert.Nil(t, similar[0].Item.Embedding)
+		}
+
+		similar, err = SimilaritySearch[RagChunk](ctx, storage, query, 10, SourceFilter{Url: other.URL})
+		assert.NoError(t, err)
+		assert.Len(t, similar, 2)
+		for _, s := range similar {
+			assert.Equal(t, other.ID, s.Item.RagSourceId)
+		}
+	})
+
+	t.Run("keyword search", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+		other := newContractSource(t, storage)
+
+		chunks := storeContractChunks(t, storage, source, "Open daily", "Closed on Sundays", "Order AB-1234 before Sunday")
+		storeContractChunks(t, storage, other, "Closed on Sundays")
+
+		matches, err := KeywordSearch[RagChunk](ctx, storage, "ab-1234 sundays", 10, SourceFilter{RagSourceIds: []int{source.ID}})
+		assert.NoError(t, err)
+		if assert.Len(t, matches, 2) {
+			assert.ElementsMatch(t, []int{chunks[1].ID, chunks[2].ID}, []int{matches[0].Item.ID, matches[1].Item.ID})
+			assert.Nil(t, matches[0].Item.Embedding)
+		}
+
+		matches, err = KeywordSearch[RagChunk](ctx, storage, "sundays", 10, SourceFilter{Url: other.URL})
+		assert.NoError(t, err)
+		if assert.Len(t, matches, 1) {
+			assert.Equal(t, other.ID, matches[0].Item.RagSourceId)
+		}
+
+		matches, err = KeywordSearch[RagChunk](ctx, storage, "delivery", 10, SourceFilter{RagSourceIds: []int{source.ID}})
+		assert.NoError(t, err)
+		assert.Empty(t, matches)
+	})
+
+	t.Run("in transaction", func(t *testing.T) {
+		storage := newStorage(t)
+		source := newContractSource(t, storage)
+		storeContractChunks(t, storage, source, "Open daily")
+		sourceId := strconv.Itoa(source.ID)
+
+		err := InTransaction(ctx, storage, func(tx Storage) error {
+			if _, err := Update(ctx, tx, sourceId, RagSource{URL: source.URL, ContentHash: "new"}); err != nil {
+				return err
+			}
+			if err := DeleteWhere[RagChunk](ctx, tx, map[string]string{"rag_source_id": sourceId}); err != nil {
+				return err
+			}
+			_, err := StoreAll(ctx, tx, RagChunk{RagSourceId: source.ID, Text: "Closed on Sundays", Embedding: contractEmbedding(0)})
+			return err
+		})
+		assert.NoError(t, err)
+
+		got, err := Get[RagSource](ctx, storage, sourceId)
+		assert.NoError(t, err)
+		assert.Equal(t, "new", got.ContentHash)
+
+		all, err := GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": sourceId})
+		assert.NoError(t, err)
+		if assert.Len(t, all, 1) {
+			assert.Equal(t, "Closed on Sundays", all[0].Text)
+		}
+
+		// A failed transaction makes none of its writes
+		fnErr := errors.New("failed to embed")
+		err = InTransaction(ctx, storage, func(tx Storage) error {
+			if err := DeleteWhere[RagChunk](ctx, tx, map[string]string{"rag_source_id": sourceId}); err != nil {
+				return err
+			}
+			return fnErr
+		})
+		assert.ErrorIs(t, err, fnErr)
+
+		ids, err := storage.reserveIds(ctx, StorageTableNameRagSources, 1)
+		assert.NoError(t, err)
+		err = InTransaction(ctx, storage, func(tx Storage) error {
+			if err := DeleteWhere[RagChunk](ctx, tx, map[string]string{"rag_source_id": sourceId}); err != nil {
+				return err
+			}
+			_, err := Update(ctx, tx, strconv.Itoa(ids[0]), RagSource{URL: source.URL})
+			return err
+		})
+		assert.Error(t, err)
+
+		after, err := GetAll[RagChunk](ctx, storage, map[string]string{"rag_source_id": sourceId})
+		assert.NoError(t, err)
+		assert.Equal(t, all, after)
+
+		// Upserts can't be staged
+		err = InTransaction(ctx, storage, func(tx Storage) error {
+			_, err := Upsert(ctx, tx, nil, source)
+			return err
+		})
+		assert.ErrorIs(t, err, errUpsertInTransaction)
+	})
+
+	t.Run("concurrent stores", func(t *testing.T) {
+		storage := newStorage(t)
+
+		var (
+			wg      sync.WaitGroup
+			sources = make([]*RagSource, 20)
+			errs    = make([]error, 20)
+		)
+		for i := range sources {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sources[i], errs[i] = Store(ctx, storage, RagSource{URL: contractUrl()})
+			}()
+		}
+		wg.Wait()
+
+		for i, source := range sources {
+			if !assert.NoError(t, errs[i]) {
+				return
+			}
+			id := strconv.Itoa(source.ID)
+			t.Cleanup(func() { DeleteWhere[RagSource](ctx, storage, map[string]string{"id": id}) })
+		}
+
+		ids := make(map[int]bool)
+		for _, source := range sources {
+			ids[source.ID] = true
+
+			got, err := Get[RagSource](ctx, storage, strconv.Itoa(source.ID))
+			assert.NoError(t, err)
+			assert.Equal(t, source.URL, got.URL)
+		}
+		assert.Len(t, ids, 20)
+	})
+}
+
+// contractUrl is a url no other test stores a source for
+func contractUrl() string {
+	return "https://example.com/" + uuid.New().String()
+}
+
+// contractEmbedding is an embedding of the model's dimension that is 1 at i and 0 everywhere else
+func contractEmbedding(i int) []float32 {
+	embedding := make([]float32, 384)
+	embedding[i] = 1
+	return embedding
+}
+
+// newContractSource stores a source with a url of its own, which is deleted along with its chunks
+// when the test finishes
+func newContractSource(t *testing.T, storage Storage) RagSource {
+	ctx := context.Background()
+
+	source, err := Store(ctx, storage, RagSource{URL: contractUrl(), Name: "Example", Type: RagSourceTypeWebsite, JobId: "job"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := strconv.Itoa(source.ID)
+	t.Cleanup(func() {
+		DeleteWhere[RagChunk](ctx, storage, map[string]string{"rag_source_id": id})
+		DeleteWhere[RagSource](ctx, storage, map[string]string{"id": id})
+	})
+	return *source
+}
+
+// storeContractChunks stores a chunk of the source for each text, with the embedding for its position
+func storeContractChunks(t *testing.T, storage Storage, source RagSource, texts ...string) []RagChunk {
+	chunks := make([]RagChunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = RagChunk{RagSourceId: source.ID, Text: text, PosInSource: i, Embedding: contractEmbedding(i)}
+	}
+
+	stored, err := StoreAll(context.Background(), storage, chunks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestMemoryStorageContract(t *testing.T) {
+	testStorageContract(t, func(t *testing.T) Storage {
+		return NewMemoryStorage()
+	})
+}
+
+func TestBoltStorageContract(t *testing.T) {
+	testStorageContract(t, func(t *testing.T) Storage {
+		storage := newTestBoltStorage(t, filepath.Join(t.TempDir(), "storage.db"))
+		t.Cleanup(func() { storage.Close() })
+		return storage
+	})
+}
+
+func TestPostgresStorageContract(t *testing.T) {
+	testStorageContract(t, func(t *testing.T) Storage {
+		return newTestPostgresStorage(t)
+	})
+}
+
+func TestSupabaseStorageContract(t *testing.T) {
+	testStorageContract(t, func(t *testing.T) Storage {
+		return newTestSupabaseStorage(t)
+	})
+}
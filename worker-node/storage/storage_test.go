@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -10,7 +11,7 @@ func TestStorage_Store(t *testing.T) {
 	storage := NewMemoryStorage()
 
 	req := AgentRequest{ID: "id1", Endpoint: "endpoint1"}
-	data, err := Store(storage, req)
+	data, err := Store(context.Background(), storage, req)
 	assert.NoError(t, err)
 	assert.Equal(t, req.ID, data.ID)
 	assert.Equal(t, req.Endpoint, data.Endpoint)
@@ -20,9 +21,9 @@ func TestStorage_Get(t *testing.T) {
 	storage := NewMemoryStorage()
 
 	req := AgentRequest{ID: "id1", Endpoint: "endpoint1"}
-	storage.store(req.TableName(), req)
+	storage.store(context.Background(), req.TableName(), req)
 
-	res, err := Get[AgentRequest](storage, "id1")
+	res, err := Get[AgentRequest](context.Background(), storage, "id1")
 	assert.NoError(t, err)
 	assert.Equal(t, "endpoint1", res.Endpoint)
 	assert.Equal(t, "id1", res.ID)
@@ -32,10 +33,10 @@ func TestStorage_GetNoId(t *testing.T) {
 	storage := NewMemoryStorage()
 
 	req := AgentRequest{Endpoint: "endpoint1"}
-	data, err := Store(storage, req)
+	data, err := Store(context.Background(), storage, req)
 	assert.NoError(t, err)
 
-	res, err := Get[AgentRequest](storage, data.ID)
+	res, err := Get[AgentRequest](context.Background(), storage, data.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, "endpoint1", res.Endpoint)
 	assert.Equal(t, data.ID, res.ID)
@@ -50,7 +51,7 @@ func TestStorage_StoreAll(t *testing.T) {
 		{ID: "id3", Endpoint: "endpoint1"},
 	}
 
-	data, err := StoreAll(storage, reqs...)
+	data, err := StoreAll(context.Background(), storage, reqs...)
 	assert.NoError(t, err)
 
 	for i, req := range data {
@@ -68,13 +69,161 @@ func TestStorage_GetAll(t *testing.T) {
 		{ID: "id3", Endpoint: "endpoint1"},
 	}
 
-	_, err := StoreAll(storage, reqs...)
+	_, err := StoreAll(context.Background(), storage, reqs...)
 	assert.NoError(t, err)
 
-	res, err := GetAll[AgentRequest](storage, map[string]string{"endpoint": "endpoint1"})
+	res, err := GetAll[AgentRequest](context.Background(), storage, map[string]string{"endpoint": "endpoint1"})
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(res))
 
 	assert.Equal(t, reqs[0].ID, res[0].ID)
 	assert.Equal(t, reqs[2].ID, res[1].ID)
 }
+
+func TestStorage_Update(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	source, err := Store(context.Background(), storage, RagSource{ID: 1, URL: "https://example.com", Name: "Example", ContentHash: "abc"})
+	assert.NoError(t, err)
+
+	updated, err := Update(context.Background(), storage, "1", RagSource{URL: "https://example.com", Name: "Renamed", JobId: "job-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, source.ID, updated.ID)
+	assert.Equal(t, "Renamed", updated.Name)
+	assert.Equal(t, "job-2", updated.JobId)
+	assert.Equal(t, "", updated.ContentHash)
+
+	res, err := Get[RagSource](context.Background(), storage, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, *updated, *res)
+
+	_, err = Update(context.Background(), storage, "2", RagSource{URL: "https://example.org"})
+	assert.Error(t, err)
+}
+
+func TestStorage_Delete(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	_, err := StoreAll(context.Background(), storage,
+		AgentRequest{ID: "id1", Endpoint: "endpoint1"},
+		AgentRequest{ID: "id2", Endpoint: "endpoint2"},
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, Delete[AgentRequest](context.Background(), storage, "id1"))
+
+	_, err = Get[AgentRequest](context.Background(), storage, "id1")
+	assert.Error(t, err)
+	_, err = Get[AgentRequest](context.Background(), storage, "id2")
+	assert.NoError(t, err)
+
+	assert.NoError(t, Delete[AgentRequest](context.Background(), storage, "id1"))
+}
+
+func TestStorage_DeleteWhere(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	_, err := StoreAll(context.Background(), storage,
+		RagChunk{ID: 1, RagSourceId: 1, Text: "Open daily"},
+		RagChunk{ID: 2, RagSourceId: 2, Text: "Closed on Sundays"},
+		RagChunk{ID: 3, RagSourceId: 1, Text: "Open late on Fridays"},
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, DeleteWhere[RagChunk](context.Background(), storage, map[string]string{"rag_source_id": "1"}))
+
+	_, err = Get[RagChunk](context.Background(), storage, "1")
+	assert.Error(t, err)
+	_, err = Get[RagChunk](context.Background(), storage, "2")
+	assert.NoError(t, err)
+
+	// Deleted chunks are no longer found by keyword either
+	matches, err := KeywordSearch[RagChunk](context.Background(), storage, "open", 10, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+
+	assert.Error(t, DeleteWhere[RagChunk](context.Background(), storage, nil))
+}
+
+func TestStorage_SimilaritySearch(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	sources, err := StoreAll(ctx, storage,
+		RagSource{ID: 1, URL: "https://example.com", JobId: "job-1", CreatedBy: "user-1"},
+		RagSource{ID: 2, URL: "https://example.org", JobId: "job-2", CreatedBy: "user-2"},
+	)
+	assert.NoError(t, err)
+
+	_, err = StoreAll(ctx, storage,
+		RagChunk{ID: 1, RagSourceId: sources[0].ID, Text: "same", Embedding: []float32{1, 0}},
+		RagChunk{ID: 2, RagSourceId: sources[0].ID, Text: "close", Embedding: []float32{1, 1}},
+		RagChunk{ID: 3, RagSourceId: sources[1].ID, Text: "opposite", Embedding: []float32{-1, 0}},
+	)
+	assert.NoError(t, err)
+
+	res, err := SimilaritySearch[RagChunk](ctx, storage, []float32{2, 0}, 10, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, res, 3)
+	assert.Equal(t, "same", res[0].Item.Text)
+	assert.InDelta(t, 1, res[0].Similarity, 1e-6)
+	assert.Equal(t, "close", res[1].Item.Text)
+	assert.InDelta(t, 0.7071, res[1].Similarity, 1e-4)
+	assert.Equal(t, "opposite", res[2].Item.Text)
+	assert.InDelta(t, -1, res[2].Similarity, 1e-6)
+	assert.Nil(t, res[0].Item.Embedding)
+
+	res, err = SimilaritySearch[RagChunk](ctx, storage, []float32{2, 0}, 1, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "same", res[0].Item.Text)
+
+	for _, filter := range []SourceFilter{
+		{RagSourceIds: []int{2}},
+		{Url: "https://example.org"},
+		{JobId: "job-2"},
+		{CreatedBy: "user-2"},
+	} {
+		res, err = SimilaritySearch[RagChunk](ctx, storage, []float32{2, 0}, 10, filter)
+		assert.NoError(t, err)
+		assert.Len(t, res, 1, "%+v", filter)
+		assert.Equal(t, "opposite", res[0].Item.Text, "%+v", filter)
+	}
+
+	res, err = SimilaritySearch[RagChunk](ctx, storage, []float32{2, 0}, 10, SourceFilter{JobId: "job-1", CreatedBy: "user-2"})
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestStorage_KeywordSearch(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	_, err := StoreAll(ctx, storage,
+		RagSource{ID: 1, URL: "https://example.com", JobId: "job-1"},
+		RagSource{ID: 2, URL: "https://example.org", JobId: "job-2"},
+	)
+	assert.NoError(t, err)
+
+	_, err = StoreAll(ctx, storage,
+		RagChunk{ID: 1, RagSourceId: 1, Text: "The XJ-9000 vacuum cleaner", Embedding: []float32{1, 0}},
+		RagChunk{ID: 2, RagSourceId: 1, Text: "A vacuum cleaner with a long cable and a large bag", Embedding: []float32{0, 1}},
+		RagChunk{ID: 3, RagSourceId: 2, Text: "Spare bags for the XJ-9000", Embedding: []float32{1, 1}},
+	)
+	assert.NoError(t, err)
+
+	res, err := KeywordSearch[RagChunk](ctx, storage, "xj-9000 vacuum", 10, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, res, 3)
+	assert.Equal(t, 1, res[0].Item.ID)
+	assert.Nil(t, res[0].Item.Embedding)
+	assert.Greater(t, res[0].Score, res[1].Score)
+
+	res, err = KeywordSearch[RagChunk](ctx, storage, "xj-9000 vacuum", 1, SourceFilter{JobId: "job-2"})
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, 3, res[0].Item.ID)
+
+	_, err = KeywordSearch[RagContact](ctx, storage, "xj-9000", 10, SourceFilter{})
+	assert.Error(t, err)
+}
@@ -1,13 +1,13 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 
 	"github.com/ethanhosier/worker-node/utils"
 	supa "github.com/nedpals/supabase-go"
-	postgrest_go "github.com/nedpals/supabase-go/postgrest/pkg"
 )
 
 type SupabaseStorage struct {
@@ -21,9 +21,9 @@ func NewSupabaseStorage(supabaseUrl, supabaseServiceKey string) *SupabaseStorage
 	}
 }
 
-func (s *SupabaseStorage) store(table StorageTableName, data interface{}) (interface{}, error) {
+func (s *SupabaseStorage) store(ctx context.Context, table StorageTableName, data interface{}) (interface{}, error) {
 	var result []interface{}
-	err := s.client.DB.From(string(table)).Insert(data).Execute(&result)
+	err := s.client.DB.From(string(table)).Insert(data).ExecuteWithContext(ctx, &result)
 
 	if err != nil {
 		return nil, err
@@ -36,9 +36,9 @@ func (s *SupabaseStorage) store(table StorageTableName, data interface{}) (inter
 	return processEmbeddingField(result[0])
 }
 
-func (s *SupabaseStorage) storeAll(table StorageTableName, data []interface{}) ([]interface{}, error) {
+func (s *SupabaseStorage) storeAll(ctx context.Context, table StorageTableName, data []interface{}) ([]interface{}, error) {
 	var result []interface{}
-	err := s.client.DB.From(string(table)).Insert(data).Execute(&result)
+	err := s.client.DB.From(string(table)).Insert(data).ExecuteWithContext(ctx, &result)
 
 	if err != nil {
 		return nil, err
@@ -47,9 +47,9 @@ func (s *SupabaseStorage) storeAll(table StorageTableName, data []interface{}) (
 	return processAllEmbeddingFields(result)
 }
 
-func (s *SupabaseStorage) get(table StorageTableName, id string) (interface{}, error) {
+func (s *SupabaseStorage) update(ctx context.Context, table StorageTableName, id string, data interface{}) (interface{}, error) {
 	var result []interface{}
-	err := s.client.DB.From(string(table)).Select("*").Eq("id", id).Execute(&result)
+	err := s.client.DB.From(string(table)).Update(data).Eq("id", id).ExecuteWithContext(ctx, &result)
 
 	if err != nil {
 		return nil, err
@@ -62,19 +62,80 @@ func (s *SupabaseStorage) get(table StorageTableName, id string) (interface{}, e
 	return processEmbeddingField(result[0])
 }
 
-func (s *SupabaseStorage) getAll(table StorageTableName, matchingFields map[string]string) ([]interface{}, error) {
+// upsert sends the items to upsert_rows, which is defined in sql/upsert.sql, as PostgREST's upserts
+// can't be told which columns conflict
+func (s *SupabaseStorage) upsert(ctx context.Context, table StorageTableName, data []interface{}, conflictKeys []string) ([]interface{}, error) {
+	var result []interface{}
+	params := map[string]interface{}{"table_name": string(table), "rows": data, "conflict_keys": conflictKeys}
+	if err := s.client.DB.Rpc("upsert_rows", params).ExecuteWithContext(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result) != len(data) {
+		return nil, fmt.Errorf("upserted %d items into %s, wanted %d", len(result), table, len(data))
+	}
+	return processAllEmbeddingFields(result)
+}
+
+func (s *SupabaseStorage) deleteWhere(ctx context.Context, table StorageTableName, matchingFields map[string]string) error {
+	// PostgREST refuses to delete a whole table, and it's never what's meant
+	if len(matchingFields) == 0 {
+		return fmt.Errorf("deleting from %s needs at least one field to match", table)
+	}
+
+	query := s.client.DB.From(string(table)).Delete()
+	for k, v := range matchingFields {
+		query = query.Eq(k, v)
+	}
+	return query.ExecuteWithContext(ctx, nil)
+}
+
+// reserveIds takes ids from the table's id sequence with reserve_ids, which is defined in
+// sql/transaction.sql
+func (s *SupabaseStorage) reserveIds(ctx context.Context, table StorageTableName, count int) ([]int, error) {
+	var ids []int
+	params := map[string]interface{}{"table_name": string(table), "id_count": count}
+	if err := s.client.DB.Rpc("reserve_ids", params).ExecuteWithContext(ctx, &ids); err != nil {
+		return nil, err
+	}
+
+	if len(ids) != count {
+		return nil, fmt.Errorf("reserved %d ids for %s, wanted %d", len(ids), table, count)
+	}
+	return ids, nil
+}
+
+// apply sends a transaction's writes to apply_writes, which is defined in sql/transaction.sql. PostgREST
+// makes each request in its own transaction, so the writes are made in one call to be atomic.
+func (s *SupabaseStorage) apply(ctx context.Context, ops []op) error {
+	var result interface{}
+	return s.client.DB.Rpc("apply_writes", map[string]interface{}{"ops": ops}).ExecuteWithContext(ctx, &result)
+}
+
+func (s *SupabaseStorage) get(ctx context.Context, table StorageTableName, id string) (interface{}, error) {
+	var result []interface{}
+	err := s.client.DB.From(string(table)).Select("*").Eq("id", id).ExecuteWithContext(ctx, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New("no result returned from supabase")
+	}
+
+	return processEmbeddingField(result[0])
+}
+
+// getAll returns the items whose fields have the matching values, in order of id, as MemoryStorage does
+func (s *SupabaseStorage) getAll(ctx context.Context, table StorageTableName, matchingFields map[string]string) ([]interface{}, error) {
 	var results []interface{}
 
-	query := s.client.DB.From(string(table)).Select("*")
-	var filterQuery *postgrest_go.FilterRequestBuilder
+	query := s.client.DB.From(string(table)).Select("*").OrderBy("id", "asc")
 	for k, v := range matchingFields {
-		if filterQuery == nil {
-			filterQuery = query.Filter(k, "eq", v)
-		} else {
-			filterQuery = filterQuery.Filter(k, "eq", v)
-		}
+		query.Filter(k, "eq", v)
 	}
-	err := filterQuery.Execute(&results)
+	err := query.ExecuteWithContext(ctx, &results)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +143,73 @@ func (s *SupabaseStorage) getAll(table StorageTableName, matchingFields map[stri
 	return processAllEmbeddingFields(results)
 }
 
+// similaritySearch calls the table's pgvector match function, match_rag_chunks for rag_chunks and so
+// on, which are defined in sql/match.sql
+func (s *SupabaseStorage) similaritySearch(ctx context.Context, table StorageTableName, embedding []float32, count int, filter SourceFilter) ([]similarItem, error) {
+	params := filter.rpcParams()
+	params["query_embedding"] = embedding
+	params["match_count"] = count
+
+	results, err := s.rpcScored(ctx, "match_"+string(table), params, "similarity")
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]similarItem, len(results))
+	for i, result := range results {
+		items[i] = similarItem{data: result.data, similarity: result.score}
+	}
+	return items, nil
+}
+
+// keywordSearch calls the table's full text search function, keyword_match_rag_chunks for rag_chunks,
+// which is defined in sql/keyword_match.sql
+func (s *SupabaseStorage) keywordSearch(ctx context.Context, table StorageTableName, query string, count int, filter SourceFilter) ([]keywordItem, error) {
+	params := filter.rpcParams()
+	params["query_text"] = query
+	params["match_count"] = count
+
+	return s.rpcScored(ctx, "keyword_match_"+string(table), params, "score")
+}
+
+// rpcScored calls a search function whose results each have a score in scoreField
+func (s *SupabaseStorage) rpcScored(ctx context.Context, function string, params map[string]interface{}, scoreField string) ([]keywordItem, error) {
+	var results []map[string]interface{}
+	if err := s.client.DB.Rpc(function, params).ExecuteWithContext(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	items := make([]keywordItem, len(results))
+	for i, result := range results {
+		score, ok := result[scoreField].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s missing from %s result", scoreField, function)
+		}
+		delete(result, scoreField)
+
+		items[i] = keywordItem{data: result, score: score}
+	}
+	return items, nil
+}
+
+// rpcParams are the parameters the search functions filter sources by
+func (f SourceFilter) rpcParams() map[string]interface{} {
+	params := make(map[string]interface{})
+	if len(f.RagSourceIds) > 0 {
+		params["filter_rag_source_ids"] = f.RagSourceIds
+	}
+	if f.Url != "" {
+		params["filter_source_url"] = f.Url
+	}
+	if f.JobId != "" {
+		params["filter_job_id"] = f.JobId
+	}
+	if f.CreatedBy != "" {
+		params["filter_created_by"] = f.CreatedBy
+	}
+	return params
+}
+
 func processAllEmbeddingFields(data []interface{}) ([]interface{}, error) {
 	for i, d := range data {
 		processed, err := processEmbeddingField(d)
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -26,6 +27,14 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+func newTestSupabaseStorage(t *testing.T) *SupabaseStorage {
+	if os.Getenv("CICD") == "true" || os.Getenv("SUPABASE_URL") == "" {
+		t.Skip("Skipping test without a Supabase project")
+	}
+
+	return NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
+}
+
 func TestParseStringToFloat32Slice(t *testing.T) {
 	var embedding []float32
 	err := parseStringToFloat32Slice("[1.5,2.5,3.5]", &embedding)
@@ -87,7 +96,7 @@ func TestSupabaseStorageStore(t *testing.T) {
 
 	storage := NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
 
-	data, err := storage.store(StorageTableNameAgentRequests, NewAgentRequest("test", map[string]string{"test": "test"}))
+	data, err := storage.store(context.Background(), StorageTableNameAgentRequests, NewAgentRequest("test", map[string]string{"test": "test"}))
 	if err != nil {
 		t.Error("Error storing item in storage")
 	}
@@ -102,7 +111,7 @@ func TestStoreSupabaseStorageStore(t *testing.T) {
 
 	storage := NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
 
-	data, err := Store(storage, NewAgentRequest("test", map[string]string{"test": "test"}))
+	data, err := Store(context.Background(), storage, NewAgentRequest("test", map[string]string{"test": "test"}))
 	if err != nil {
 		t.Error("Error storing item in storage", err)
 	}
@@ -117,7 +126,7 @@ func TestSupabaseStorageGet(t *testing.T) {
 
 	storage := NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
 
-	data, err := storage.get(StorageTableNameAgentRequests, "00c4a620-d375-49ab-b5bd-0b67d4755fd8")
+	data, err := storage.get(context.Background(), StorageTableNameAgentRequests, "00c4a620-d375-49ab-b5bd-0b67d4755fd8")
 	if err != nil {
 		t.Error("Error getting item from storage")
 	}
@@ -132,7 +141,7 @@ func TestStoreSupabaseStorageStoreAgentEvent(t *testing.T) {
 
 	storage := NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
 
-	data, err := Store(storage, NewAgentEvent("00c4a620-d375-49ab-b5bd-0b67d4755fd8", "test", map[string]string{"test": "test"}))
+	data, err := Store(context.Background(), storage, NewAgentEvent("00c4a620-d375-49ab-b5bd-0b67d4755fd8", "test", map[string]string{"test": "test"}))
 	if err != nil {
 		t.Error("Error storing item in storage", err)
 	}
@@ -151,7 +160,7 @@ func TestSupabaseStorageTestStoreRagSource(t *testing.T) {
 		URL: "https://example.com",
 	}
 
-	_, err := Store(store, ragSource)
+	_, err := Store(context.Background(), store, ragSource)
 	if err != nil {
 		t.Error("Error storing item in storage", err)
 	}
@@ -165,7 +174,7 @@ func TestSupabaseStorageTestStoreRagChunk(t *testing.T) {
 	store := NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
 
 	ragger := ragger.NewRAGClient(modelPath, libraryPath, tokenizerPath)
-	embedding, err := ragger.EmbeddingsFor("Hello, world!")
+	embedding, err := ragger.EmbeddingsFor(context.Background(), "Hello, world!")
 	if err != nil {
 		t.Error("Error embedding text", err)
 	}
@@ -177,7 +186,7 @@ func TestSupabaseStorageTestStoreRagChunk(t *testing.T) {
 		RagSourceId: 75,
 	}
 
-	storedRagChunk, err := Store(store, ragChunk)
+	storedRagChunk, err := Store(context.Background(), store, ragChunk)
 	if err != nil {
 		t.Error("Error storing item in storage", err)
 	}
@@ -196,7 +205,7 @@ func TestSupabaseStorageTestStoreRagContacts(t *testing.T) {
 	store := NewSupabaseStorage(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_KEY"))
 
 	ragger := ragger.NewRAGClient(modelPath, libraryPath, tokenizerPath)
-	embedding, err := ragger.EmbeddingsFor("Hello, world!")
+	embedding, err := ragger.EmbeddingsFor(context.Background(), "Hello, world!")
 	if err != nil {
 		t.Error("Error embedding text", err)
 	}
@@ -210,7 +219,7 @@ func TestSupabaseStorageTestStoreRagContacts(t *testing.T) {
 		Embedding:   embedding,
 	}
 
-	storedRagContact, err := Store(store, ragContact)
+	storedRagContact, err := Store(context.Background(), store, ragContact)
 	if err != nil {
 		t.Error("Error storing item in storage", err)
 	}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// The kinds of write a transaction can stage
+const (
+	opKindInsert = "insert"
+	opKindUpdate = "update"
+	opKindDelete = "delete"
+)
+
+// op is a write staged by a transaction. The fields are exported so ops can be sent to Supabase's
+// apply_writes function as they are.
+type op struct {
+	Kind  string                   `json:"kind"`
+	Table StorageTableName         `json:"table"`
+	Rows  []map[string]interface{} `json:"rows,omitempty"`  // Insert
+	Id    string                   `json:"id,omitempty"`    // Update
+	Data  map[string]interface{}   `json:"data,omitempty"`  // Update
+	Match map[string]string        `json:"match,omitempty"` // Delete
+}
+
+var (
+	errNestedApply         = errors.New("a transaction's writes can only be applied by the storage it was started on")
+	errUpsertInTransaction = errors.New("upserts can't be made in a transaction, as the items they store aren't known until they are made")
+)
+
+// transaction is a Storage that stages writes until they are applied to the storage it was started on.
+// Reads go straight to that storage, so they don't see the transaction's own writes.
+type transaction struct {
+	storage Storage
+	ops     []op
+}
+
+// InTransaction runs fn with a Storage whose writes are staged rather than made, and applies them all
+// at once when fn returns, so either all of them are made or, if fn or applying them fails, none are.
+// Items stored in fn are given their ids straight away, so later writes can refer to them. Reads in
+// fn don't see its writes. Calling InTransaction within fn joins the outer transaction.
+func InTransaction(ctx context.Context, storage Storage, fn func(tx Storage) error) error {
+	if tx, ok := storage.(*transaction); ok {
+		return fn(tx)
+	}
+
+	tx := &transaction{storage: storage}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	if err := storage.apply(ctx, tx.ops); err != nil {
+		return fmt.Errorf("failed to apply transaction: %w", err)
+	}
+	return nil
+}
+
+func (t *transaction) store(ctx context.Context, table StorageTableName, data interface{}) (interface{}, error) {
+	stored, err := t.storeAll(ctx, table, []interface{}{data})
+	if err != nil {
+		return nil, err
+	}
+	return stored[0], nil
+}
+
+func (t *transaction) storeAll(ctx context.Context, table StorageTableName, data []interface{}) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]interface{}, len(data))
+	var needIds []int // Indexes of the rows that need a numeric id
+	for i, item := range data {
+		row, err := toMap(item)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+
+		if row["id"] != nil {
+			continue
+		}
+
+		if originalID, ok := getOriginalIDType(item); ok && isNumberType(originalID) {
+			needIds = append(needIds, i)
+		} else {
+			row["id"] = uuid.New().String()
+		}
+	}
+
+	if len(needIds) > 0 {
+		ids, err := t.storage.reserveIds(ctx, table, len(needIds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve ids: %w", err)
+		}
+		for i, index := range needIds {
+			rows[index]["id"] = ids[i]
+		}
+	}
+
+	t.ops = append(t.ops, op{Kind: opKindInsert, Table: table, Rows: rows})
+
+	result := make([]interface{}, len(rows))
+	for i, row := range rows {
+		result[i] = row
+	}
+	return result, nil
+}
+
+// upsert isn't staged, as which items it updates and the ids of those it stores aren't known until it
+// is made
+func (t *transaction) upsert(ctx context.Context, table StorageTableName, data []interface{}, conflictKeys []string) ([]interface{}, error) {
+	return nil, errUpsertInTransaction
+}
+
+// update stages an update, which returns data with the id, as the rest of the item isn't known until
+// it is applied
+func (t *transaction) update(ctx context.Context, table StorageTableName, id string, data interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dataMap, err := toMap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	t.ops = append(t.ops, op{Kind: opKindUpdate, Table: table, Id: id, Data: dataMap})
+
+	updated := make(map[string]interface{}, len(dataMap)+1)
+	for k, v := range dataMap {
+		updated[k] = v
+	}
+	if n, err := strconv.Atoi(id); err == nil {
+		updated["id"] = n
+	} else {
+		updated["id"] = id
+	}
+	return updated, nil
+}
+
+func (t *transaction) deleteWhere(ctx context.Context, table StorageTableName, matchingFields map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if len(matchingFields) == 0 {
+		return fmt.Errorf("deleting from %s needs at least one field to match", table)
+	}
+
+	t.ops = append(t.ops, op{Kind: opKindDelete, Table: table, Match: matchingFields})
+	return nil
+}
+
+func (t *transaction) get(ctx context.Context, table StorageTableName, id string) (interface{}, error) {
+	return t.storage.get(ctx, table, id)
+}
+
+func (t *transaction) getAll(ctx context.Context, table StorageTableName, matchingFields map[string]string) ([]interface{}, error) {
+	return t.storage.getAll(ctx, table, matchingFields)
+}
+
+func (t *transaction) similaritySearch(ctx context.Context, table StorageTableName, embedding []float32, count int, filter SourceFilter) ([]similarItem, error) {
+	return t.storage.similaritySearch(ctx, table, embedding, count, filter)
+}
+
+func (t *transaction) keywordSearch(ctx context.Context, table StorageTableName, query string, count int, filter SourceFilter) ([]keywordItem, error) {
+	return t.storage.keywordSearch(ctx, table, query, count, filter)
+}
+
+func (t *transaction) reserveIds(ctx context.Context, table StorageTableName, count int) ([]int, error) {
+	return t.storage.reserveIds(ctx, table, count)
+}
+
+func (t *transaction) apply(ctx context.Context, ops []op) error {
+	return errNestedApply
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInTransaction(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = NewMemoryStorage()
+	)
+
+	err := InTransaction(ctx, storage, func(tx Storage) error {
+		source, err := Store(ctx, tx, RagSource{URL: "https://example.com"})
+		if err != nil {
+			return err
+		}
+		assert.NotZero(t, source.ID)
+
+		_, err = StoreAll(ctx, tx,
+			RagChunk{RagSourceId: source.ID, Text: "Open daily"},
+			RagChunk{RagSourceId: source.ID, Text: "Closed on Sundays"},
+		)
+		if err != nil {
+			return err
+		}
+
+		// Nothing is stored until the transaction is applied
+		_, err = Get[RagSource](ctx, storage, strconv.Itoa(source.ID))
+		assert.Error(t, err)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	sources, err := GetAll[RagSource](ctx, storage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1)
+
+	chunks, err := GetAll[RagChunk](ctx, storage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	for _, chunk := range chunks {
+		assert.Equal(t, sources[0].ID, chunk.RagSourceId)
+	}
+
+	matches, err := KeywordSearch[RagChunk](ctx, storage, "sundays", 10, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+}
+
+func TestInTransactionFails(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = NewMemoryStorage()
+		fnErr   = errors.New("failed to embed")
+	)
+
+	_, err := Store(ctx, storage, RagChunk{ID: 1, RagSourceId: 1, Text: "Open daily"})
+	assert.NoError(t, err)
+
+	err = InTransaction(ctx, storage, func(tx Storage) error {
+		if err := DeleteWhere[RagChunk](ctx, tx, map[string]string{"rag_source_id": "1"}); err != nil {
+			return err
+		}
+		return fnErr
+	})
+	assert.ErrorIs(t, err, fnErr)
+
+	_, err = Get[RagChunk](ctx, storage, "1")
+	assert.NoError(t, err)
+}
+
+func TestInTransactionApplyFails(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = NewMemoryStorage()
+	)
+
+	_, err := Store(ctx, storage, RagChunk{ID: 1, RagSourceId: 1, Text: "Open daily"})
+	assert.NoError(t, err)
+
+	// The update fails when the writes are applied, so the delete and store before it are undone
+	err = InTransaction(ctx, storage, func(tx Storage) error {
+		if err := DeleteWhere[RagChunk](ctx, tx, map[string]string{"rag_source_id": "1"}); err != nil {
+			return err
+		}
+		if _, err := Store(ctx, tx, RagChunk{ID: 2, RagSourceId: 1, Text: "Closed on Sundays"}); err != nil {
+			return err
+		}
+		_, err := Update(ctx, tx, "3", RagChunk{RagSourceId: 1, Text: "Open late"})
+		return err
+	})
+	assert.Error(t, err)
+
+	chunks, err := GetAll[RagChunk](ctx, storage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, 1, chunks[0].ID)
+
+	matches, err := KeywordSearch[RagChunk](ctx, storage, "open sundays", 10, SourceFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, 1, matches[0].Item.ID)
+}
+
+func TestInTransactionNested(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = NewMemoryStorage()
+	)
+
+	err := InTransaction(ctx, storage, func(tx Storage) error {
+		return InTransaction(ctx, tx, func(inner Storage) error {
+			assert.Same(t, tx, inner)
+			_, err := Store(ctx, inner, RagSource{URL: "https://example.com"})
+			return err
+		})
+	})
+	assert.NoError(t, err)
+
+	sources, err := GetAll[RagSource](ctx, storage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1)
+}
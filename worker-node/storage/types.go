@@ -57,11 +57,29 @@ func (r RagChunk) TableName() StorageTableName {
 	return StorageTableNameRagChunks
 }
 
+// The types of RagSource, by the format of the page it was scraped from
+const (
+	RagSourceTypeWebsite  = "WEBSITE"
+	RagSourceTypePdf      = "PDF"
+	RagSourceTypeDocx     = "DOCX"
+	RagSourceTypeText     = "TEXT"
+	RagSourceTypeMarkdown = "MARKDOWN"
+)
+
+// RagSource is a page or document that chunks and contacts were stored from. ContentHash is the hash of
+// the content they were made from, and is stored along with them. ETag and LastModified are the
+// validators the page was served with, for fetching it again only if it changed. They are always
+// written, so a page that stops sending them doesn't keep stale ones.
 type RagSource struct {
-	ID   int    `json:"id,omitempty"`
-	URL  string `json:"url"`
-	Name string `json:"name"`
-	Type string `json:"type"`
+	ID           int    `json:"id,omitempty"`
+	URL          string `json:"url"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	JobId        string `json:"job_id,omitempty"`
+	CreatedBy    string `json:"created_by,omitempty"`
+	ContentHash  string `json:"content_hash"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
 }
 
 func (r RagSource) TableName() StorageTableName {
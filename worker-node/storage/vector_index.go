@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+)
+
+// vectorIndex holds the embeddings of a table's items in memory, normalized so that their cosine
+// similarity to a query is a dot product. Searches compare the query with every embedding, which is
+// exact, and fast enough for the number of chunks one node stores.
+type vectorIndex struct {
+	vectors map[string][]float32 // Item ID -> normalized embedding
+}
+
+func newVectorIndex() *vectorIndex {
+	return &vectorIndex{vectors: make(map[string][]float32)}
+}
+
+// add indexes embedding under id, replacing whatever id was indexed with before
+func (i *vectorIndex) add(id string, embedding []float32) {
+	i.vectors[id] = normalized(embedding)
+}
+
+func (i *vectorIndex) remove(id string) {
+	delete(i.vectors, id)
+}
+
+// search returns the cosine similarity to embedding of every indexed item
+func (i *vectorIndex) search(embedding []float32) (map[string]float64, error) {
+	query := normalized(embedding)
+
+	similarities := make(map[string]float64)
+	for id, vector := range i.vectors {
+		if len(vector) != len(query) {
+			return nil, fmt.Errorf("item %s has a %d dimensional embedding, searched for %d dimensions", id, len(vector), len(query))
+		}
+
+		var dot float64
+		for j := range vector {
+			dot += float64(vector[j]) * float64(query[j])
+		}
+		similarities[id] = dot
+	}
+	return similarities, nil
+}
+
+// normalized returns a copy of v scaled to length 1, or of zeros if v is all zeros
+func normalized(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	norm = math.Sqrt(norm)
+
+	ret := make([]float32, len(v))
+	if norm == 0 {
+		return ret
+	}
+	for i, x := range v {
+		ret[i] = float32(float64(x) / norm)
+	}
+	return ret
+}
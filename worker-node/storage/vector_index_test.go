@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVectorIndexSearch(t *testing.T) {
+	index := newVectorIndex()
+	index.add("1", []float32{2, 0})
+	index.add("2", []float32{1, 1})
+	index.add("3", []float32{0, 0})
+
+	similarities, err := index.search([]float32{1, 0})
+	assert.NoError(t, err)
+	assert.InDelta(t, 1, similarities["1"], 1e-6)
+	assert.InDelta(t, 0.7071, similarities["2"], 1e-4)
+	assert.Zero(t, similarities["3"])
+
+	_, err = index.search([]float32{1, 0, 0})
+	assert.Error(t, err)
+}
+
+func TestVectorIndexAddReplaces(t *testing.T) {
+	index := newVectorIndex()
+	index.add("1", []float32{1, 0})
+	index.add("1", []float32{0, 1})
+
+	similarities, err := index.search([]float32{0, 1})
+	assert.NoError(t, err)
+	assert.InDelta(t, 1, similarities["1"], 1e-6)
+
+	index.remove("1")
+	similarities, err = index.search([]float32{0, 1})
+	assert.NoError(t, err)
+	assert.Empty(t, similarities)
+}
@@ -80,6 +80,48 @@ func FormatUrl(uri string) (string, error) {
 	return parsedUrl.String(), nil
 }
 
+// ResolveLink resolves href against the page it was found on and returns an absolute http(s) url
+// without its fragment. ok is false for links that can't be crawled, e.g. mailto: or javascript:.
+func ResolveLink(pageUrl string, href string) (string, bool) {
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return "", false
+	}
+
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+
+	resolved := base.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return "", false
+	}
+
+	if resolved.Host == "" {
+		return "", false
+	}
+
+	resolved.Host = strings.ToLower(resolved.Host)
+	resolved.Fragment = ""
+	resolved.RawFragment = ""
+	if resolved.Path == "" {
+		resolved.Path = "/"
+	}
+
+	return resolved.String(), true
+}
+
+// Hostname returns the lowercased host of uri without its port, or "" if uri can't be parsed.
+func Hostname(uri string) string {
+	parsedUrl, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(parsedUrl.Hostname())
+}
+
 func HtmlToMarkdown(html *string) (string, error) {
 	// Create a new converter with default options
 	converter := md.NewConverter("", true, nil)
@@ -128,3 +128,67 @@ mitigating factors that may have impacted or disrupted your studies or exams, an
 
 	assert.Equal(t, cleaned, expected)
 }
+
+func TestResolveLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		pageUrl  string
+		href     string
+		expected string
+		ok       bool
+	}{
+		{
+			name:     "relative path",
+			pageUrl:  "https://example.com/blog/",
+			href:     "post-1",
+			expected: "https://example.com/blog/post-1",
+			ok:       true,
+		},
+		{
+			name:     "absolute path",
+			pageUrl:  "https://example.com/blog/",
+			href:     "/about",
+			expected: "https://example.com/about",
+			ok:       true,
+		},
+		{
+			name:     "strips fragment and lowercases host",
+			pageUrl:  "https://example.com",
+			href:     "https://EXAMPLE.com/contact#form",
+			expected: "https://example.com/contact",
+			ok:       true,
+		},
+		{
+			name:     "adds root path",
+			pageUrl:  "https://example.com",
+			href:     "https://example.com",
+			expected: "https://example.com/",
+			ok:       true,
+		},
+		{
+			name:    "mailto link",
+			pageUrl: "https://example.com",
+			href:    "mailto:john@example.com",
+			ok:      false,
+		},
+		{
+			name:    "javascript link",
+			pageUrl: "https://example.com",
+			href:    "javascript:void(0)",
+			ok:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := ResolveLink(tt.pageUrl, tt.href)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestHostname(t *testing.T) {
+	assert.Equal(t, "example.com", Hostname("https://Example.com:8080/path"))
+	assert.Equal(t, "", Hostname("::"))
+}
@@ -2,11 +2,18 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strconv"
 
-	"github.com/ethanhosier/worker-node/coordinator_client"
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/ethanhosier/worker-node/extractor"
 	"github.com/ethanhosier/worker-node/ragger"
+	"github.com/ethanhosier/worker-node/scraper"
 	"github.com/ethanhosier/worker-node/storage"
 	"github.com/ethanhosier/worker-node/utils"
 	"github.com/google/uuid"
@@ -17,17 +24,53 @@ type RagWorker struct {
 	ragClient         ragger.Ragger
 	coordinatorClient coordinator_client.CoordinatorClient
 	store             storage.Storage
+	blobStore         blob_store.BlobStore
+
+	// succeeded holds the ids of tasks that have executed successfully but not been cleaned up yet, whose
+	// payloads can be deleted once they are. A failed task keeps its payload for its retry.
+	succeeded map[string]bool
 }
 
 type RagWorkerParams struct {
 	Markdown  string `json:"markdown"`
 	Url       string `json:"url"`
 	InnerText string `json:"text"`
+
+	// SourceType is the type of the stored RagSource and defaults to storage.RagSourceTypeWebsite
+	SourceType string `json:"source_type,omitempty"`
+
+	// Uploaded documents are kept in the blob store rather than put on the task, so BlobKey is set
+	// instead of Markdown and InnerText. The document's format is worked out from ContentType and the
+	// extension of the key, and Name is the file name it was uploaded with.
+	BlobKey     string `json:"blob_key,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Name        string `json:"name,omitempty"`
+
+	// PayloadKey is set instead of Markdown and InnerText on pages too big to put on the task, and is the
+	// blob their ragPayload is kept in until the task succeeds
+	PayloadKey string `json:"payload_key,omitempty"`
+
+	// ETag and LastModified are the validators the page was served with, stored so that it is only
+	// fetched again if it has changed
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// ragPayload is the markdown and text of a page kept in the blob store rather than on its task
+type ragPayload struct {
+	Markdown  string `json:"markdown"`
+	InnerText string `json:"text"`
 }
 
 func NewRagWorker(ragClient ragger.Ragger, coordinatorClient coordinator_client.CoordinatorClient, store storage.Storage) *RagWorker {
 	id := uuid.New().String()
-	return &RagWorker{id: id, ragClient: ragClient, coordinatorClient: coordinatorClient, store: store}
+	return &RagWorker{id: id, ragClient: ragClient, coordinatorClient: coordinatorClient, store: store, succeeded: make(map[string]bool)}
+}
+
+// WithBlobStore sets the blob store the documents of upload tasks are read from
+func (w *RagWorker) WithBlobStore(blobStore blob_store.BlobStore) *RagWorker {
+	w.blobStore = blobStore
+	return w
 }
 
 func (w *RagWorker) WorkerType() WorkerType {
@@ -46,17 +89,71 @@ func (w *RagWorker) Execute(ctx context.Context, task *coordinator_client.Task)
 		return fmt.Errorf("invalid params %+v", task.Params)
 	}
 
-	storedRagSource, err := w.storeRagSource(ragParams.Url, "WEBSITE")
+	if err := w.rag(ctx, task, ragParams); err != nil {
+		// The task context may have timed out, the failure should still be recorded. The worker manager
+		// marks the url as failed if the task won't be retried.
+		setJobUrlProgress(context.WithoutCancel(ctx), w.coordinatorClient, task, ragParams.Url, coordinator_client.JobUrlStatusRetrying, err)
+		return err
+	}
+
+	setJobUrlProgress(ctx, w.coordinatorClient, task, ragParams.Url, coordinator_client.JobUrlStatusStored, nil)
+	w.succeeded[task.ID] = true
+	return nil
+}
+
+func (w *RagWorker) rag(ctx context.Context, task *coordinator_client.Task, ragParams *RagWorkerParams) error {
+	if ragParams.BlobKey != "" {
+		if err := w.extractBlob(ctx, ragParams); err != nil {
+			return err
+		}
+	}
+
+	if ragParams.PayloadKey != "" {
+		if err := w.loadPayload(ctx, ragParams); err != nil {
+			return err
+		}
+	}
+
+	sourceType := ragParams.SourceType
+	if sourceType == "" {
+		sourceType = storage.RagSourceTypeWebsite
+	}
+
+	var (
+		creator     = jobCreator(ctx, w.coordinatorClient, task)
+		contentHash = contentHashOf(ragParams)
+		ragSource   = storage.RagSource{
+			URL:          ragParams.Url,
+			Name:         ragParams.Name,
+			Type:         sourceType,
+			JobId:        task.JobId,
+			CreatedBy:    creator,
+			ETag:         ragParams.ETag,
+			LastModified: ragParams.LastModified,
+		}
+	)
+
+	existing, err := storedSource(ctx, w.store, ragParams.Url, creator)
 	if err != nil {
 		return err
 	}
 
-	chunks, err := w.ragClient.ChunksFrom(utils.CleanText(ragParams.InnerText))
+	// An unchanged page keeps its chunks and contacts, only its source is brought up to date
+	if existing != nil && existing.ContentHash == contentHash {
+		log.Printf("RAG: %s hasn't changed since it was stored", ragParams.Url)
+		ragSource.ContentHash = contentHash
+		if _, err := storage.Update(ctx, w.store, strconv.Itoa(existing.ID), ragSource); err != nil {
+			return fmt.Errorf("error updating rag source: %v", err)
+		}
+		return nil
+	}
+
+	chunks, err := w.ragClient.ChunksFrom(ctx, utils.CleanText(ragParams.InnerText))
 	if err != nil {
 		return fmt.Errorf("error extracting chunks: %v", err)
 	}
 
-	contacts, err := w.ragClient.ContactsFrom(utils.CleanText(ragParams.Markdown))
+	contacts, err := w.ragClient.ContactsFrom(ctx, utils.CleanText(ragParams.Markdown))
 	if err != nil {
 		return fmt.Errorf("error extracting contacts: %v", err)
 	}
@@ -69,31 +166,125 @@ func (w *RagWorker) Execute(ctx context.Context, task *coordinator_client.Task)
 	}
 
 	log.Printf("RAG: generating embeddings for %d chunks and %d contacts\n", len(chunks), len(contacts))
-	embeddings, err := w.ragClient.EmbeddingsForAll(newSlice)
+	embeddings, err := w.ragClient.EmbeddingsForAll(ctx, newSlice)
 	if err != nil {
 		return fmt.Errorf("error extracting embeddings: %v", err)
 	}
 
-	if err := w.storeChunks(chunks, embeddings, storedRagSource.ID); err != nil {
-		return fmt.Errorf("error storing chunks: %v", err)
+	// The source, chunks and contacts are stored together, so a page that fails part way through
+	// doesn't leave an orphaned source, or a source with only some of its chunks, behind
+	ragSource.ContentHash = contentHash
+	return storage.InTransaction(ctx, w.store, func(tx storage.Storage) error {
+		var (
+			storedRagSource *storage.RagSource
+			err             error
+		)
+		if existing != nil {
+			storedRagSource, err = w.clearRagSource(ctx, tx, existing.ID, ragSource)
+		} else {
+			storedRagSource, err = w.storeRagSource(ctx, tx, ragSource)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := w.storeChunks(ctx, tx, chunks, embeddings, storedRagSource.ID); err != nil {
+			return fmt.Errorf("error storing chunks: %v", err)
+		}
+
+		if err := w.storeContacts(ctx, tx, contacts, embeddings[len(chunks):], storedRagSource.ID); err != nil {
+			return fmt.Errorf("error storing contacts: %v", err)
+		}
+		return nil
+	})
+}
+
+// extractBlob reads the document a task references in the blob store and fills in the task's
+// markdown, text and source type from it
+func (w *RagWorker) extractBlob(ctx context.Context, ragParams *RagWorkerParams) error {
+	if w.blobStore == nil {
+		return fmt.Errorf("no blob store to read %s from", ragParams.BlobKey)
 	}
 
-	if err := w.storeContacts(contacts, embeddings[len(chunks):], storedRagSource.ID); err != nil {
-		return fmt.Errorf("error storing contacts: %v", err)
+	blob, err := w.blobStore.Get(ctx, ragParams.BlobKey)
+	if err != nil {
+		return fmt.Errorf("error getting blob %s: %w", ragParams.BlobKey, err)
+	}
+	defer blob.Close()
+
+	body, err := io.ReadAll(blob)
+	if err != nil {
+		return fmt.Errorf("error reading blob %s: %w", ragParams.BlobKey, err)
+	}
+
+	contentType := ragParams.ContentType
+	if contentType == "" {
+		contentType = blob.ContentType
+	}
+
+	format, err := extractor.FormatOf(contentType, ragParams.BlobKey, body)
+	if err != nil {
+		return err
+	}
+
+	content, err := contentFrom(&scraper.Page{Url: ragParams.Url, ContentType: contentType, Body: body}, format, extractor.Rule{})
+	if err != nil {
+		return err
+	}
+
+	ragParams.Markdown, ragParams.InnerText, ragParams.SourceType = content.markdown, content.text, content.sourceType
+	return nil
+}
+
+// loadPayload reads the markdown and text of a task from the blob store
+func (w *RagWorker) loadPayload(ctx context.Context, ragParams *RagWorkerParams) error {
+	if w.blobStore == nil {
+		return fmt.Errorf("no blob store to read %s from", ragParams.PayloadKey)
+	}
+
+	blob, err := w.blobStore.Get(ctx, ragParams.PayloadKey)
+	if err != nil {
+		return fmt.Errorf("error getting payload %s: %w", ragParams.PayloadKey, err)
+	}
+	defer blob.Close()
+
+	var payload ragPayload
+	if err := json.NewDecoder(blob).Decode(&payload); err != nil {
+		return fmt.Errorf("error reading payload %s: %w", ragParams.PayloadKey, err)
 	}
 
+	ragParams.Markdown, ragParams.InnerText = payload.Markdown, payload.InnerText
 	return nil
 }
 
-func (w *RagWorker) storeRagSource(url string, typ string) (*storage.RagSource, error) {
-	storedRagSource, err := storage.Store(w.store, storage.RagSource{URL: url, Type: typ})
+func (w *RagWorker) storeRagSource(ctx context.Context, store storage.Storage, ragSource storage.RagSource) (*storage.RagSource, error) {
+	storedRagSource, err := storage.Store(ctx, store, ragSource)
 	if err != nil {
 		return nil, fmt.Errorf("error storing rag source: %v", err)
 	}
 	return storedRagSource, nil
 }
 
-func (w *RagWorker) storeChunks(chunks []string, embeddings [][]float32, ragSourceId int) error {
+// clearRagSource deletes the chunks and contacts of a source that has changed, and updates the source
+// to ragSource
+func (w *RagWorker) clearRagSource(ctx context.Context, store storage.Storage, id int, ragSource storage.RagSource) (*storage.RagSource, error) {
+	updatedRagSource, err := storage.Update(ctx, store, strconv.Itoa(id), ragSource)
+	if err != nil {
+		return nil, fmt.Errorf("error updating rag source: %v", err)
+	}
+
+	matchingSource := map[string]string{"rag_source_id": strconv.Itoa(id)}
+	if err := storage.DeleteWhere[storage.RagChunk](ctx, store, matchingSource); err != nil {
+		return nil, fmt.Errorf("error deleting chunks: %v", err)
+	}
+	if err := storage.DeleteWhere[storage.RagContact](ctx, store, matchingSource); err != nil {
+		return nil, fmt.Errorf("error deleting contacts: %v", err)
+	}
+
+	return updatedRagSource, nil
+}
+
+func (w *RagWorker) storeChunks(ctx context.Context, store storage.Storage, chunks []string, embeddings [][]float32, ragSourceId int) error {
 
 	var rags []storage.RagChunk
 	for i, chunk := range chunks {
@@ -106,13 +297,13 @@ func (w *RagWorker) storeChunks(chunks []string, embeddings [][]float32, ragSour
 		})
 	}
 
-	if _, err := storage.StoreAll(w.store, rags...); err != nil {
+	if _, err := storage.StoreAll(ctx, store, rags...); err != nil {
 		return fmt.Errorf("error storing chunks: %v", err)
 	}
 	return nil
 }
 
-func (w *RagWorker) storeContacts(contacts []ragger.Contact, embeddings [][]float32, ragSourceId int) error {
+func (w *RagWorker) storeContacts(ctx context.Context, store storage.Storage, contacts []ragger.Contact, embeddings [][]float32, ragSourceId int) error {
 
 	var contactsToStore []storage.RagContact
 	for i, contact := range contacts {
@@ -127,12 +318,31 @@ func (w *RagWorker) storeContacts(contacts []ragger.Contact, embeddings [][]floa
 		})
 	}
 
-	if _, err := storage.StoreAll(w.store, contactsToStore...); err != nil {
+	if _, err := storage.StoreAll(ctx, store, contactsToStore...); err != nil {
 		return fmt.Errorf("error storing contacts: %v", err)
 	}
 	return nil
 }
 
 func (w *RagWorker) Cleanup(ctx context.Context, task *coordinator_client.Task) error {
-	return w.coordinatorClient.SetProcessed(ctx, coordinator_client.CoordinatorClientTaskTopicRag, task)
+	if err := w.coordinatorClient.SetProcessed(ctx, coordinator_client.CoordinatorClientTaskTopicRag, task); err != nil {
+		return err
+	}
+
+	succeeded := w.succeeded[task.ID]
+	delete(w.succeeded, task.ID)
+	if !succeeded {
+		return nil
+	}
+
+	ragParams, err := coordinator_client.CastParams[RagWorkerParams](task.Params)
+	if err != nil || ragParams.PayloadKey == "" || w.blobStore == nil {
+		return nil
+	}
+
+	// A payload left behind only wastes space, so failing to delete it doesn't fail the task
+	if err := w.blobStore.Delete(ctx, ragParams.PayloadKey); err != nil && !errors.Is(err, blob_store.ErrBlobNotFound) {
+		log.Printf("Failed to delete payload %s: %v", ragParams.PayloadKey, err)
+	}
+	return nil
 }
@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/ethanhosier/worker-node/coordinator_client"
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
 	"github.com/ethanhosier/worker-node/ragger"
 	"github.com/ethanhosier/worker-node/storage"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,13 +38,14 @@ func TestStoreWebsite(t *testing.T) {
 		url           = "https://example.com"
 	)
 
-	storedRagSource, err := ragWorker.storeRagSource(url, "WEBSITE")
+	storedRagSource, err := ragWorker.storeRagSource(context.Background(), memoryStorage, storage.RagSource{URL: url, Type: storage.RagSourceTypeWebsite, JobId: "job-1"})
 	if err != nil {
 		t.Errorf("Error storing rag source: %v", err)
 	}
 
 	assert.Equal(t, storedRagSource.URL, url)
 	assert.Equal(t, storedRagSource.Type, "WEBSITE")
+	assert.Equal(t, storedRagSource.JobId, "job-1")
 }
 
 func TestRagWorkerStoreChunks(t *testing.T) {
@@ -52,9 +56,9 @@ func TestRagWorkerStoreChunks(t *testing.T) {
 		embeddings    = [][]float32{{1.0, 2.0, 3.0}, {4.0, 5.0, 6.0}, {7.0, 8.0, 9.0}}
 	)
 
-	ragWorker.storeChunks(chunks, embeddings, 1)
+	ragWorker.storeChunks(context.Background(), memoryStorage, chunks, embeddings, 1)
 
-	rags, err := storage.GetAll[storage.RagChunk](memoryStorage, nil)
+	rags, err := storage.GetAll[storage.RagChunk](context.Background(), memoryStorage, nil)
 	if err != nil {
 		t.Errorf("Error getting chunks: %v", err)
 	}
@@ -72,11 +76,11 @@ func TestRagWorkerStoreContacts(t *testing.T) {
 		ragWorker     = NewRagWorker(nil, nil, memoryStorage)
 	)
 
-	ragWorker.storeContacts([]ragger.Contact{
+	ragWorker.storeContacts(context.Background(), memoryStorage, []ragger.Contact{
 		{Context: "Hello, world!", Value: "John Doe", Type: "person"},
 	}, [][]float32{{1.0, 2.0, 3.0}}, 1)
 
-	contacts, err := storage.GetAll[storage.RagContact](memoryStorage, nil)
+	contacts, err := storage.GetAll[storage.RagContact](context.Background(), memoryStorage, nil)
 	if err != nil {
 		t.Errorf("Error getting contacts: %v", err)
 	}
@@ -114,6 +118,8 @@ func TestRagWorkerExecute(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error creating task: %v", err)
 	}
+	task.JobId = "job-1"
+	assert.NoError(t, coordinatorClient.CreateJob(context.Background(), &coordinator_client.Job{ID: "job-1", CreatedBy: "user-1"}))
 
 	ragClient.SetChunksFor(markdown, chunks)
 	ragClient.SetContactsFor(markdown, contacts)
@@ -134,15 +140,19 @@ func TestRagWorkerExecute(t *testing.T) {
 	}
 
 	// then
-	ragSources, err := storage.GetAll[storage.RagSource](memoryStorage, nil)
+	ragSources, err := storage.GetAll[storage.RagSource](context.Background(), memoryStorage, nil)
 	if err != nil {
 		t.Errorf("Error getting rag sources: %v", err)
 	}
 
 	assert.Equal(t, len(ragSources), 1)
 	assert.Equal(t, ragSources[0].URL, websiteUrl)
+	assert.Equal(t, ragSources[0].JobId, "job-1")
+	assert.Equal(t, ragSources[0].CreatedBy, "user-1")
+	assert.Equal(t, ragSources[0].Type, storage.RagSourceTypeWebsite)
+	assert.Equal(t, coordinator_client.JobUrlStatusStored, coordinatorClient.JobUrlProgress("job-1", websiteUrl).Status)
 
-	rags, err := storage.GetAll[storage.RagChunk](memoryStorage, nil)
+	rags, err := storage.GetAll[storage.RagChunk](context.Background(), memoryStorage, nil)
 	if err != nil {
 		t.Errorf("Error getting chunks: %v", err)
 	}
@@ -152,7 +162,7 @@ func TestRagWorkerExecute(t *testing.T) {
 	assert.Equal(t, rags[0].Embedding, embeddings[0])
 	assert.Equal(t, rags[0].RagSourceId, ragSources[0].ID)
 
-	storedContacts, err := storage.GetAll[storage.RagContact](memoryStorage, nil)
+	storedContacts, err := storage.GetAll[storage.RagContact](context.Background(), memoryStorage, nil)
 	if err != nil {
 		t.Errorf("Error getting contacts: %v", err)
 	}
@@ -165,6 +175,254 @@ func TestRagWorkerExecute(t *testing.T) {
 	assert.Equal(t, storedContacts[0].RagSourceId, ragSources[0].ID)
 }
 
+func TestRagWorkerExecuteRecrawl(t *testing.T) {
+	// given
+	var (
+		ctx               = context.Background()
+		memoryStorage     = storage.NewMemoryStorage()
+		ragClient         = ragger.NewMockRagClient()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		ragWorker         = NewRagWorker(ragClient, coordinatorClient, memoryStorage)
+	)
+
+	execute := func(text string, etag string) {
+		ragClient.SetChunksFor(text, []string{text})
+		ragClient.SetContactsFor(text, nil)
+		ragClient.SetEmbeddingsForAll([]string{text}, [][]float32{{1, 2, 3}})
+
+		task, err := coordinator_client.NewTask(uuid.New().String(), "test", RagWorkerParams{
+			Markdown:  text,
+			Url:       "https://example.com",
+			InnerText: text,
+			ETag:      etag,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, ragWorker.Execute(ctx, task))
+	}
+
+	// when
+	execute("Open daily", `"v1"`)
+	execute("Open daily", `"v2"`)
+
+	// then the unchanged page only has its source updated
+	ragSources, err := storage.GetAll[storage.RagSource](ctx, memoryStorage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, ragSources, 1)
+	assert.Equal(t, `"v2"`, ragSources[0].ETag)
+	assert.NotEmpty(t, ragSources[0].ContentHash)
+	assert.Equal(t, 1, ragClient.EmbeddingsForAllCallCount)
+
+	// when
+	execute("Closed on Sundays", `"v3"`)
+
+	// then the changed page's chunks are replaced
+	ragSources, err = storage.GetAll[storage.RagSource](ctx, memoryStorage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, ragSources, 1)
+	assert.Equal(t, `"v3"`, ragSources[0].ETag)
+
+	chunks, err := storage.GetAll[storage.RagChunk](ctx, memoryStorage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, "Closed on Sundays", chunks[0].Text)
+	assert.Equal(t, ragSources[0].ID, chunks[0].RagSourceId)
+}
+
+func TestRagWorkerExecuteRecrawlWithoutValidators(t *testing.T) {
+	// given
+	var (
+		ctx               = context.Background()
+		memoryStorage     = storage.NewMemoryStorage()
+		ragClient         = ragger.NewMockRagClient()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		ragWorker         = NewRagWorker(ragClient, coordinatorClient, memoryStorage)
+	)
+
+	execute := func(text string, etag string, lastModified string) *storage.RagSource {
+		ragClient.SetChunksFor(text, []string{text})
+		ragClient.SetContactsFor(text, nil)
+		ragClient.SetEmbeddingsForAll([]string{text}, [][]float32{{1, 2, 3}})
+
+		task, err := coordinator_client.NewTask(uuid.New().String(), "test", RagWorkerParams{
+			Markdown:     text,
+			Url:          "https://example.com",
+			InnerText:    text,
+			ETag:         etag,
+			LastModified: lastModified,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, ragWorker.Execute(ctx, task))
+
+		ragSources, err := storage.GetAll[storage.RagSource](ctx, memoryStorage, nil)
+		assert.NoError(t, err)
+		assert.Len(t, ragSources, 1)
+		return &ragSources[0]
+	}
+
+	lastModified := "Wed, 21 Oct 2015 07:28:00 GMT"
+
+	// when the page stops sending validators, but hasn't changed
+	execute("Open daily", `"v1"`, lastModified)
+	ragSource := execute("Open daily", "", "")
+
+	// then the stale validators are cleared
+	assert.Empty(t, ragSource.ETag)
+	assert.Empty(t, ragSource.LastModified)
+
+	// when the page stops sending validators and has changed
+	execute("Open daily", `"v2"`, lastModified)
+	ragSource = execute("Closed on Sundays", "", "")
+
+	// then they are cleared too
+	assert.Empty(t, ragSource.ETag)
+	assert.Empty(t, ragSource.LastModified)
+}
+
+func TestRagWorkerExecuteRecrawlOtherUser(t *testing.T) {
+	var (
+		ctx               = context.Background()
+		memoryStorage     = storage.NewMemoryStorage()
+		ragClient         = ragger.NewMockRagClient()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		ragWorker         = NewRagWorker(ragClient, coordinatorClient, memoryStorage)
+	)
+
+	ragClient.SetChunksFor("Open daily", []string{"Open daily"})
+	ragClient.SetContactsFor("Open daily", nil)
+	ragClient.SetEmbeddingsForAll([]string{"Open daily"}, [][]float32{{1, 2, 3}})
+
+	// Each user has their own source for a page
+	for _, user := range []string{"user-1", "user-2"} {
+		job := &coordinator_client.Job{ID: uuid.New().String(), CreatedBy: user}
+		assert.NoError(t, coordinatorClient.CreateJob(ctx, job))
+
+		task, err := coordinator_client.NewTask(uuid.New().String(), "test", RagWorkerParams{Markdown: "Open daily", Url: "https://example.com", InnerText: "Open daily"})
+		assert.NoError(t, err)
+		task.JobId = job.ID
+		assert.NoError(t, ragWorker.Execute(ctx, task))
+	}
+
+	ragSources, err := storage.GetAll[storage.RagSource](ctx, memoryStorage, nil)
+	assert.NoError(t, err)
+	assert.Len(t, ragSources, 2)
+}
+
+func TestRagWorkerExecuteBlob(t *testing.T) {
+	// given
+	var (
+		ctx               = context.Background()
+		memoryStorage     = storage.NewMemoryStorage()
+		ragClient         = ragger.NewMockRagClient()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		blobStore         = blob_store.NewLocalBlobStore(t.TempDir())
+		ragWorker         = NewRagWorker(ragClient, coordinatorClient, memoryStorage).WithBlobStore(blobStore)
+
+		document = "Hello, world!"
+		blobUrl  = "blob://documents/1/notes.md"
+	)
+
+	err := blobStore.Put(ctx, "documents/1/notes.md", strings.NewReader(document), int64(len(document)), "")
+	assert.NoError(t, err)
+
+	task, err := coordinator_client.NewTask("1", "test", RagWorkerParams{
+		Url:     blobUrl,
+		BlobKey: "documents/1/notes.md",
+		Name:    "notes.md",
+	})
+	assert.NoError(t, err)
+	task.JobId = "job-1"
+
+	ragClient.SetChunksFor(document, []string{document})
+	ragClient.SetContactsFor(document, []ragger.Contact{})
+	ragClient.SetEmbeddingsForAll([]string{document}, [][]float32{{1.0, 2.0, 3.0}})
+
+	// when
+	err = ragWorker.Execute(ctx, task)
+	assert.NoError(t, err)
+
+	// then
+	ragSources, err := storage.GetAll[storage.RagSource](ctx, memoryStorage, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ragSources))
+	assert.Equal(t, blobUrl, ragSources[0].URL)
+	assert.Equal(t, "notes.md", ragSources[0].Name)
+	assert.Equal(t, storage.RagSourceTypeMarkdown, ragSources[0].Type)
+
+	rags, err := storage.GetAll[storage.RagChunk](ctx, memoryStorage, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rags))
+	assert.Equal(t, document, rags[0].Text)
+	assert.Equal(t, coordinator_client.JobUrlStatusStored, coordinatorClient.JobUrlProgress("job-1", blobUrl).Status)
+
+	// A missing blob fails the task
+	task, err = coordinator_client.NewTask("2", "test", RagWorkerParams{Url: "blob://documents/2/missing.pdf", BlobKey: "documents/2/missing.pdf"})
+	assert.NoError(t, err)
+
+	err = ragWorker.Execute(ctx, task)
+	assert.ErrorIs(t, err, blob_store.ErrBlobNotFound)
+}
+
+func TestRagWorkerPayload(t *testing.T) {
+	// given
+	var (
+		ctx               = context.Background()
+		memoryStorage     = storage.NewMemoryStorage()
+		ragClient         = ragger.NewMockRagClient()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		blobStore         = blob_store.NewLocalBlobStore(t.TempDir())
+		ragWorker         = NewRagWorker(ragClient, coordinatorClient, memoryStorage).WithBlobStore(blobStore)
+
+		text    = "Hello, world!"
+		payload = `{"markdown":"Hello, world!","text":"Hello, world!"}`
+	)
+
+	for _, key := range []string{"payloads/1.json", "payloads/2.json"} {
+		err := blobStore.Put(ctx, key, strings.NewReader(payload), int64(len(payload)), "application/json")
+		assert.NoError(t, err)
+	}
+
+	ragClient.SetChunksFor(text, []string{text})
+	ragClient.SetContactsFor(text, []ragger.Contact{})
+	ragClient.SetEmbeddingsForAll([]string{text}, [][]float32{{1.0, 2.0, 3.0}})
+
+	// processing takes a task off the queue as the worker manager would, so it can be cleaned up
+	processing := func(params RagWorkerParams) *coordinator_client.Task {
+		task, err := coordinator_client.NewTask(uuid.New().String(), "test", params)
+		assert.NoError(t, err)
+		assert.NoError(t, coordinatorClient.CreateTask(ctx, coordinator_client.CoordinatorClientTaskTopicRag, task))
+
+		task, err = coordinatorClient.GetTaskAndSetProcessing(ctx, 0, coordinator_client.CoordinatorClientTaskTopicRag)
+		assert.NoError(t, err)
+		return task
+	}
+
+	task := processing(RagWorkerParams{Url: "https://example.com", PayloadKey: "payloads/1.json"})
+
+	// when
+	assert.NoError(t, ragWorker.Execute(ctx, task))
+	assert.NoError(t, ragWorker.Cleanup(ctx, task))
+
+	// then
+	rags, err := storage.GetAll[storage.RagChunk](ctx, memoryStorage, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rags))
+	assert.Equal(t, text, rags[0].Text)
+
+	_, err = blobStore.Get(ctx, "payloads/1.json")
+	assert.ErrorIs(t, err, blob_store.ErrBlobNotFound, "the payload should be deleted once the task succeeds")
+
+	// A failed task keeps its payload for its retry
+	ragClient.ChunksError = fmt.Errorf("chunking failed")
+	task = processing(RagWorkerParams{Url: "https://example.com/2", PayloadKey: "payloads/2.json"})
+
+	assert.Error(t, ragWorker.Execute(ctx, task))
+	assert.NoError(t, ragWorker.Cleanup(ctx, task))
+
+	blob, err := blobStore.Get(ctx, "payloads/2.json")
+	assert.NoError(t, err)
+	blob.Close()
+}
+
 func TestRagWorkerCleanup(t *testing.T) {
 	// given
 	var (
@@ -1,32 +1,97 @@
 package worker
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"slices"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
-	coordinator_client "github.com/ethanhosier/worker-node/coordinator_client"
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	coordinator_client "github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/ethanhosier/worker-node/extractor"
 	"github.com/ethanhosier/worker-node/scraper"
+	"github.com/ethanhosier/worker-node/storage"
 	"github.com/ethanhosier/worker-node/utils"
 	"github.com/google/uuid"
 )
 
+// RenderMode chooses how a page is fetched. Static pages are fetched over plain http, browser pages
+// are rendered by a headless browser, and auto fetches them statically and renders them if too little
+// text came back.
+type RenderMode string
+
+const (
+	RenderModeAuto    RenderMode = "auto"
+	RenderModeStatic  RenderMode = "static"
+	RenderModeBrowser RenderMode = "browser"
+)
+
+// minStaticTextLength is the amount of text below which a statically fetched page is assumed to be
+// rendered client side
+const minStaticTextLength = 200
+
+// DefaultMaxInlinePayload is the size above which a page's markdown and text are put in the blob store
+// rather than on its rag task, if the worker has one
+const DefaultMaxInlinePayload = 64 << 10
+
+var formatSourceTypes = map[extractor.Format]string{
+	extractor.FormatHtml:     storage.RagSourceTypeWebsite,
+	extractor.FormatPdf:      storage.RagSourceTypePdf,
+	extractor.FormatDocx:     storage.RagSourceTypeDocx,
+	extractor.FormatText:     storage.RagSourceTypeText,
+	extractor.FormatMarkdown: storage.RagSourceTypeMarkdown,
+}
+
 type ScraperWorker struct {
 	scraper           scraper.Scraper
+	renderer          scraper.Renderer
 	coordinatorClient coordinator_client.CoordinatorClient
+	blobStore         blob_store.BlobStore
+	store             storage.Storage
+	maxInlinePayload  int
 	id                string
 }
 
 type ScraperWorkerParams struct {
-	Url string
+	Url string `json:"url"`
+
+	// Crawl settings. A task with MaxDepth > Depth enqueues the links it finds on the page back onto
+	// the urls topic. CrawlId groups the pages of a crawl and defaults to the root task's id.
+	Depth        int      `json:"depth,omitempty"`
+	MaxDepth     int      `json:"max_depth,omitempty"`
+	MaxPages     int      `json:"max_pages,omitempty"`
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	CrawlId      string   `json:"crawl_id,omitempty"`
+
+	// Rendering settings. RenderMode defaults to auto, and WaitSelector makes the browser wait for an
+	// element to be visible instead of for the network to go idle.
+	RenderMode   RenderMode `json:"render_mode,omitempty"`
+	WaitSelector string     `json:"wait_selector,omitempty"`
+
+	// Extraction settings. ContentSelectors replace the selectors of the extraction rule stored for the
+	// page's domain and ExcludeSelectors are added to its exclude selectors. Without any selectors the
+	// main content is found by scoring the page.
+	ContentSelectors []string `json:"content_selectors,omitempty"`
+	ExcludeSelectors []string `json:"exclude_selectors,omitempty"`
 }
 
 func (w *ScraperWorkerParams) WorkerType() WorkerType {
 	return WorkerTypeScraper
 }
 
+// pageContent is what is sent on to be embedded from a page
+type pageContent struct {
+	markdown   string
+	text       string
+	sourceType string
+}
+
 type ScraperWorkerResult struct {
 	Markdown string
 	Url      string
@@ -38,7 +103,35 @@ func (w *ScraperWorker) WorkerType() WorkerType {
 
 func NewScraperWorker(scraper scraper.Scraper, coordinatorClient coordinator_client.CoordinatorClient) *ScraperWorker {
 	id := uuid.New().String()
-	return &ScraperWorker{scraper: scraper, coordinatorClient: coordinatorClient, id: id}
+	return &ScraperWorker{scraper: scraper, coordinatorClient: coordinatorClient, maxInlinePayload: DefaultMaxInlinePayload, id: id}
+}
+
+// WithRenderer sets the headless browser used for pages that need rendering. Without one every page
+// is fetched statically and tasks that ask for browser rendering fail.
+func (w *ScraperWorker) WithRenderer(renderer scraper.Renderer) *ScraperWorker {
+	w.renderer = renderer
+	return w
+}
+
+// WithBlobStore sets the blob store that pages too big to put on a rag task are written to. Without
+// one every page is put on its task.
+func (w *ScraperWorker) WithBlobStore(blobStore blob_store.BlobStore) *ScraperWorker {
+	w.blobStore = blobStore
+	return w
+}
+
+// WithStore sets the storage that pages stored before are looked up in, so they are only fetched again
+// if they have changed. Without one every page is fetched in full.
+func (w *ScraperWorker) WithStore(store storage.Storage) *ScraperWorker {
+	w.store = store
+	return w
+}
+
+// WithMaxInlinePayload sets the size in bytes above which a page's markdown and text are written to
+// the blob store instead of being put on its rag task
+func (w *ScraperWorker) WithMaxInlinePayload(maxInlinePayload int) *ScraperWorker {
+	w.maxInlinePayload = maxInlinePayload
+	return w
 }
 
 func (w *ScraperWorker) Id() string {
@@ -55,45 +148,364 @@ func (w *ScraperWorker) Execute(ctx context.Context, task *coordinator_client.Ta
 		return fmt.Errorf("url is required")
 	}
 
-	md, text, err := w.mdAndTextFromUrl(scraperParams.Url)
+	setJobUrlProgress(ctx, w.coordinatorClient, task, scraperParams.Url, coordinator_client.JobUrlStatusScraping, nil)
+
+	status, err := w.scrape(ctx, task, scraperParams)
+	if errors.Is(err, scraper.ErrDisallowedByRobots) || errors.Is(err, extractor.ErrUnsupportedFormat) || errors.Is(err, scraper.ErrNotModified) || errors.Is(err, scraper.ErrClientError) {
+		log.Printf("Skipping %s: %v", scraperParams.Url, err)
+		status, err = coordinator_client.JobUrlStatusSkipped, nil
+	}
+
 	if err != nil {
+		// The task context may have timed out, the failure should still be recorded. The worker manager
+		// marks the url as failed if the task won't be retried.
+		setJobUrlProgress(context.WithoutCancel(ctx), w.coordinatorClient, task, scraperParams.Url, coordinator_client.JobUrlStatusRetrying, err)
 		return err
 	}
 
-	if md == "" {
+	setJobUrlProgress(ctx, w.coordinatorClient, task, scraperParams.Url, status, nil)
+	return nil
+}
+
+// scrape handles a url task and returns the job status the url has reached once it is done
+func (w *ScraperWorker) scrape(ctx context.Context, task *coordinator_client.Task, scraperParams *ScraperWorkerParams) (coordinator_client.JobUrlStatus, error) {
+	page, err := w.page(ctx, scraperParams, w.validators(ctx, task, scraperParams))
+	if err != nil {
+		return "", err
+	}
+
+	format, err := extractor.FormatOf(page.ContentType, page.Url, page.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if scraperParams.MaxDepth > 0 {
+		if err := w.crawlLinks(ctx, task, scraperParams, page, format); err != nil {
+			return "", fmt.Errorf("error crawling links for %s: %w", scraperParams.Url, err)
+		}
+	}
+
+	// Extraction rules are written against html, so documents don't need them
+	var rule extractor.Rule
+	if format == extractor.FormatHtml {
+		if rule, err = w.extractionRule(ctx, scraperParams); err != nil {
+			return "", err
+		}
+	}
+
+	content, err := contentFrom(page, format, rule)
+	if err != nil {
+		return "", err
+	}
+
+	if content.markdown == "" {
 		log.Printf("No markdown parsed for %s. No need to rag", scraperParams.Url)
-		return nil
+		return coordinator_client.JobUrlStatusSkipped, nil
 	}
 
-	ragParams := RagWorkerParams{Markdown: md, Url: scraperParams.Url, InnerText: text}
+	ragParams := RagWorkerParams{
+		Markdown:     content.markdown,
+		Url:          scraperParams.Url,
+		InnerText:    content.text,
+		SourceType:   content.sourceType,
+		ETag:         page.ETag,
+		LastModified: page.LastModified,
+	}
+
+	ragTaskId := uuid.New().String()
+	if err := w.checkPayload(ctx, ragTaskId, &ragParams); err != nil {
+		return "", err
+	}
 
-	ragTask, err := coordinator_client.NewTask(uuid.New().String(), w.id, ragParams)
+	ragTask, err := coordinator_client.NewTask(ragTaskId, w.id, ragParams)
+	if err != nil {
+		return "", err
+	}
+	ragTask.JobId = task.JobId
+
+	if err := w.coordinatorClient.CreateTask(ctx, coordinator_client.CoordinatorClientTaskTopicRag, ragTask); err != nil {
+		return "", err
+	}
+
+	return coordinator_client.JobUrlStatusEmbedding, nil
+}
+
+// checkPayload moves the markdown and text of a rag task into the blob store if they are too big to be
+// put on the task, leaving only a reference to them. The rag worker deletes the payload once the task
+// has succeeded.
+func (w *ScraperWorker) checkPayload(ctx context.Context, ragTaskId string, ragParams *RagWorkerParams) error {
+	if w.blobStore == nil || len(ragParams.Markdown)+len(ragParams.InnerText) <= w.maxInlinePayload {
+		return nil
+	}
+
+	payload, err := json.Marshal(ragPayload{Markdown: ragParams.Markdown, InnerText: ragParams.InnerText})
 	if err != nil {
 		return err
 	}
 
-	return w.coordinatorClient.CreateTask(ctx, coordinator_client.CoordinatorClientTaskTopicRag, ragTask)
+	payloadKey := "payloads/" + ragTaskId + ".json"
+	if err := w.blobStore.Put(ctx, payloadKey, bytes.NewReader(payload), int64(len(payload)), "application/json"); err != nil {
+		return fmt.Errorf("failed to store payload of %s: %w", ragParams.Url, err)
+	}
+
+	ragParams.Markdown, ragParams.InnerText, ragParams.PayloadKey = "", "", payloadKey
+	return nil
 }
 
 func (w *ScraperWorker) Cleanup(ctx context.Context, task *coordinator_client.Task) error {
 	return w.coordinatorClient.SetProcessed(ctx, coordinator_client.CoordinatorClientTaskTopicUrls, task)
 }
 
-func (w *ScraperWorker) mdAndTextFromUrl(url string) (string, string, error) {
-	html, err := w.scraper.HtmlFromTag(url, "main")
+// contentFrom extracts the markdown and text of a page, using rule to find the content of html pages
+func contentFrom(page *scraper.Page, format extractor.Format, rule extractor.Rule) (*pageContent, error) {
+	if format != extractor.FormatHtml {
+		doc, err := extractor.ExtractDocument(format, page.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s from %s: %w", format, page.Url, err)
+		}
+		return &pageContent{markdown: doc.Markdown, text: doc.Text, sourceType: formatSourceTypes[format]}, nil
+	}
+
+	content, err := extractor.Extract(string(page.Body), rule)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := goquery.NewDocumentFromReader(strings.NewReader(content))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	h, err := goquery.NewDocumentFromReader(strings.NewReader(*html))
+	md, err := utils.HtmlToMarkdown(&content)
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+
+	return &pageContent{markdown: md, text: h.Text(), sourceType: storage.RagSourceTypeWebsite}, nil
+}
+
+// extractionRule combines the task's selectors with the extraction rule stored for the page's host,
+// or failing that for the closest parent domain that has one
+func (w *ScraperWorker) extractionRule(ctx context.Context, params *ScraperWorkerParams) (extractor.Rule, error) {
+	rule := extractor.Rule{
+		Selectors:        params.ContentSelectors,
+		ExcludeSelectors: params.ExcludeSelectors,
+	}
+
+	pageUrl, ok := utils.ResolveLink(params.Url, params.Url)
+	if !ok {
+		return rule, nil
+	}
+
+	for _, domain := range ruleDomains(utils.Hostname(pageUrl)) {
+		stored, err := w.coordinatorClient.GetExtractionRule(ctx, domain)
+		if errors.Is(err, coordinator_client.ErrExtractionRuleNotFound) {
+			continue
+		}
+		if err != nil {
+			return rule, fmt.Errorf("failed to get extraction rule for %s: %w", domain, err)
+		}
+
+		if len(rule.Selectors) == 0 {
+			rule.Selectors = stored.Selectors
+		}
+		rule.ExcludeSelectors = append(slices.Clone(stored.ExcludeSelectors), rule.ExcludeSelectors...)
+		break
+	}
+
+	return rule, nil
+}
+
+// ruleDomains lists the domains whose extraction rules apply to host, most specific first. For
+// blog.example.com that is blog.example.com then example.com.
+func ruleDomains(host string) []string {
+	if host == "" {
+		return nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+
+	var domains []string
+	for domain := host; strings.Contains(domain, "."); _, domain, _ = strings.Cut(domain, ".") {
+		domains = append(domains, domain)
+	}
+	if len(domains) == 0 {
+		domains = append(domains, host)
+	}
+	return domains
+}
+
+func (w *ScraperWorker) crawlLinks(ctx context.Context, task *coordinator_client.Task, params *ScraperWorkerParams, page *scraper.Page, format extractor.Format) error {
+	pageUrl, ok := utils.ResolveLink(params.Url, params.Url)
+	if !ok {
+		return fmt.Errorf("invalid url %s", params.Url)
+	}
+
+	crawlId := params.CrawlId
+	if crawlId == "" {
+		crawlId = task.ID
+	}
+
+	allowedHosts := params.AllowedHosts
+	if len(allowedHosts) == 0 {
+		allowedHosts = []string{utils.Hostname(pageUrl)}
+	}
+
+	// The root page counts towards the page limit and must not be crawled again
+	if params.Depth == 0 {
+		if _, err := w.coordinatorClient.MarkVisited(ctx, crawlId, pageUrl, params.MaxPages); err != nil {
+			return err
+		}
+	}
+
+	// Links are only followed from html pages
+	if params.Depth >= params.MaxDepth || format != extractor.FormatHtml {
+		return nil
+	}
+
+	// Links are relative to where the page ended up, which a redirect can make differ from the task's url
+	links, err := linksFrom(page.Url, page.Body)
+	if err != nil {
+		return err
+	}
+
+	numEnqueued := 0
+	for _, link := range links {
+		if !slices.Contains(allowedHosts, utils.Hostname(link)) {
+			continue
+		}
+
+		visited, err := w.coordinatorClient.MarkVisited(ctx, crawlId, link, params.MaxPages)
+		if err != nil {
+			return err
+		}
+
+		if !visited {
+			continue
+		}
+
+		linkParams := ScraperWorkerParams{
+			Url:          link,
+			Depth:        params.Depth + 1,
+			MaxDepth:     params.MaxDepth,
+			MaxPages:     params.MaxPages,
+			AllowedHosts: allowedHosts,
+			CrawlId:      crawlId,
+			RenderMode:   params.RenderMode,
+			WaitSelector: params.WaitSelector,
+
+			ContentSelectors: params.ContentSelectors,
+			ExcludeSelectors: params.ExcludeSelectors,
+		}
+
+		linkTask, err := coordinator_client.NewTask(uuid.New().String(), w.id, linkParams)
+		if err != nil {
+			return err
+		}
+		linkTask.JobId = task.JobId
+
+		if err := w.coordinatorClient.CreateTask(ctx, coordinator_client.CoordinatorClientTaskTopicUrls, linkTask); err != nil {
+			return err
+		}
+		setJobUrlProgress(ctx, w.coordinatorClient, linkTask, link, coordinator_client.JobUrlStatusQueued, nil)
+		numEnqueued++
+	}
+
+	log.Printf("Crawl %s: enqueued %d of %d links found on %s", crawlId, numEnqueued, len(links), pageUrl)
+	return nil
+}
+
+func linksFrom(pageUrl string, html []byte) ([]string, error) {
+	h, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+	h.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		link, ok := utils.ResolveLink(pageUrl, href)
+		if !ok || seen[link] {
+			return
+		}
+
+		seen[link] = true
+		links = append(links, link)
+	})
+
+	return links, nil
+}
+
+// validators returns the validators of the task's page from when it was last stored, if it was. A
+// page whose links are to be crawled is always fetched in full, as they are read from its body.
+func (w *ScraperWorker) validators(ctx context.Context, task *coordinator_client.Task, params *ScraperWorkerParams) scraper.Validators {
+	if w.store == nil || params.Depth < params.MaxDepth {
+		return scraper.Validators{}
+	}
+
+	ragSource, err := storedSource(ctx, w.store, params.Url, jobCreator(ctx, w.coordinatorClient, task))
+	if err != nil {
+		log.Printf("Failed to look up stored source of %s, fetching it in full: %v", params.Url, err)
+		return scraper.Validators{}
+	}
+
+	// A source stored before content hashes were kept can't be told to be unchanged by the rag worker,
+	// so has to be fetched again
+	if ragSource == nil || ragSource.ContentHash == "" {
+		return scraper.Validators{}
+	}
+	return scraper.Validators{ETag: ragSource.ETag, LastModified: ragSource.LastModified}
+}
+
+// page fetches the task's page, rendering it in the browser if the task's render mode calls for it.
+// Static fetches are conditional on validators, and fail with scraper.ErrNotModified if the page hasn't
+// changed.
+func (w *ScraperWorker) page(ctx context.Context, params *ScraperWorkerParams, validators scraper.Validators) (*scraper.Page, error) {
+	switch params.RenderMode {
+	case RenderModeStatic:
+		return w.scraper.FetchIfModified(ctx, params.Url, validators)
+	case RenderModeBrowser:
+		return w.render(ctx, params)
+	case RenderModeAuto, "":
+	default:
+		return nil, fmt.Errorf("unknown render mode %s", params.RenderMode)
+	}
+
+	page, err := w.scraper.FetchIfModified(ctx, params.Url, validators)
+	if err != nil || w.renderer == nil {
+		return page, err
+	}
+
+	// Only html can be rendered client side
+	if format, err := extractor.FormatOf(page.ContentType, page.Url, page.Body); err != nil || format != extractor.FormatHtml {
+		return page, nil
+	}
+
+	h, err := goquery.NewDocumentFromReader(bytes.NewReader(page.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	h.Find("script, style, noscript, template").Remove()
+	if len(strings.TrimSpace(h.Text())) >= minStaticTextLength {
+		return page, nil
+	}
+
+	log.Printf("Static html of %s is nearly empty, rendering it", params.Url)
+	return w.render(ctx, params)
+}
+
+func (w *ScraperWorker) render(ctx context.Context, params *ScraperWorkerParams) (*scraper.Page, error) {
+	if w.renderer == nil {
+		return nil, fmt.Errorf("browser rendering is not enabled on this worker")
 	}
 
-	md, err := utils.HtmlToMarkdown(html)
+	html, err := w.renderer.Render(ctx, params.Url, "html", params.WaitSelector)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return md, h.Text(), nil
+	return &scraper.Page{Url: params.Url, ContentType: "text/html; charset=utf-8", Body: []byte(*html)}, nil
 }
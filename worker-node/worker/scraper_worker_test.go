@@ -2,20 +2,46 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/ethanhosier/worker-node/coordinator_client"
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/ethanhosier/worker-node/extractor"
 	"github.com/ethanhosier/worker-node/scraper"
+	"github.com/ethanhosier/worker-node/storage"
 	"github.com/stretchr/testify/assert"
 )
 
+// mdAndTextFromUrl fetches and extracts a page the way a scraper worker does, without extraction rules
+func mdAndTextFromUrl(worker *ScraperWorker, params *ScraperWorkerParams) (string, string, error) {
+	page, err := worker.page(context.Background(), params, scraper.Validators{})
+	if err != nil {
+		return "", "", err
+	}
+
+	format, err := extractor.FormatOf(page.ContentType, page.Url, page.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	content, err := contentFrom(page, format, extractor.Rule{})
+	if err != nil {
+		return "", "", err
+	}
+	return content.markdown, content.text, nil
+}
+
 func TestScraperWorkerMdAndTextFromUrl(t *testing.T) {
 	scraper := scraper.NewMockScraper()
 	scraper.SetHtmlContent("https://example.com", "<html><body><main>Hello, world!</main></body></html>")
 
 	worker := NewScraperWorker(scraper, nil)
-	md, text, err := worker.mdAndTextFromUrl("https://example.com")
+	md, text, err := mdAndTextFromUrl(worker, &ScraperWorkerParams{Url: "https://example.com"})
 	assert.NoError(t, err)
 	assert.Equal(t, md, "Hello, world!")
 	assert.Equal(t, text, "Hello, world!")
@@ -35,7 +61,7 @@ func TestScraperWorkerMdFromUrlContent(t *testing.T) {
 			</div>
 		</main></body></html>
 	`)
-	md, text, err := worker.mdAndTextFromUrl("https://nested.com")
+	md, text, err := mdAndTextFromUrl(worker, &ScraperWorkerParams{Url: "https://nested.com"})
 	assert.NoError(t, err)
 	assert.Contains(t, md, "Title")
 	assert.Contains(t, md, "Paragraph 1")
@@ -50,12 +76,86 @@ func TestScraperWorkerMdFromUrlContent(t *testing.T) {
 			<main>First main</main>
 		</body></html>
 	`)
-	md, text, err = worker.mdAndTextFromUrl("https://multiplemain.com")
+	md, text, err = mdAndTextFromUrl(worker, &ScraperWorkerParams{Url: "https://multiplemain.com"})
 	assert.NoError(t, err)
 	assert.Contains(t, md, "First main")
 	assert.Contains(t, text, "First main")
 }
 
+func TestScraperWorkerRenderModes(t *testing.T) {
+	var (
+		staticScraper = scraper.NewMockScraper()
+		renderer      = scraper.NewMockScraper()
+		worker        = NewScraperWorker(staticScraper, nil).WithRenderer(renderer)
+		article       = strings.Repeat("Server rendered article. ", 20)
+	)
+
+	staticScraper.SetHtmlContent("https://spa.com", "<html><body><main><script>render()</script></main></body></html>")
+	renderer.SetHtmlContent("https://spa.com", "<html><body><main>Client rendered</main></body></html>")
+	staticScraper.SetHtmlContent("https://static.com", "<html><body><main>"+article+"</main></body></html>")
+
+	// Auto falls back to rendering pages whose static html is nearly empty
+	_, text, err := mdAndTextFromUrl(worker, &ScraperWorkerParams{Url: "https://spa.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Client rendered", text)
+
+	_, text, err = mdAndTextFromUrl(worker, &ScraperWorkerParams{Url: "https://static.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, article, text)
+
+	// Scripts are never content, so the static html of a client rendered page is empty
+	_, text, err = mdAndTextFromUrl(worker, &ScraperWorkerParams{Url: "https://spa.com", RenderMode: RenderModeStatic})
+	assert.NoError(t, err)
+	assert.Empty(t, text)
+
+	_, _, err = mdAndTextFromUrl(worker, &ScraperWorkerParams{Url: "https://static.com", RenderMode: RenderModeBrowser})
+	assert.Error(t, err, "browser mode should not use the static scraper")
+
+	// Without a renderer auto mode uses the static html as it is
+	_, text, err = mdAndTextFromUrl(NewScraperWorker(staticScraper, nil), &ScraperWorkerParams{Url: "https://spa.com"})
+	assert.NoError(t, err)
+	assert.Empty(t, text)
+
+	_, _, err = mdAndTextFromUrl(NewScraperWorker(staticScraper, nil), &ScraperWorkerParams{Url: "https://spa.com", RenderMode: RenderModeBrowser})
+	assert.Error(t, err)
+}
+
+func TestScraperWorkerExtractionRule(t *testing.T) {
+	var (
+		ctx               = context.Background()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		worker            = NewScraperWorker(scraper.NewMockScraper(), coordinatorClient)
+	)
+
+	err := coordinatorClient.SetExtractionRule(ctx, &coordinator_client.ExtractionRule{
+		Domain:           "example.com",
+		Selectors:        []string{"article"},
+		ExcludeSelectors: []string{".share"},
+	})
+	assert.NoError(t, err)
+
+	// Subdomains use the rule of their parent domain
+	rule, err := worker.extractionRule(ctx, &ScraperWorkerParams{Url: "https://blog.example.com/post"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"article"}, rule.Selectors)
+	assert.Equal(t, []string{".share"}, rule.ExcludeSelectors)
+
+	// The task's selectors replace the rule's and its exclude selectors are added to the rule's
+	rule, err = worker.extractionRule(ctx, &ScraperWorkerParams{
+		Url:              "https://example.com",
+		ContentSelectors: []string{"#content"},
+		ExcludeSelectors: []string{".ads"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"#content"}, rule.Selectors)
+	assert.Equal(t, []string{".share", ".ads"}, rule.ExcludeSelectors)
+
+	rule, err = worker.extractionRule(ctx, &ScraperWorkerParams{Url: "https://other.com"})
+	assert.NoError(t, err)
+	assert.Empty(t, rule.Selectors)
+	assert.Empty(t, rule.ExcludeSelectors)
+}
+
 func TestScraperWorkerId(t *testing.T) {
 	scraper := scraper.NewMockScraper()
 	worker := NewScraperWorker(scraper, nil)
@@ -95,6 +195,142 @@ func TestScraperWorkerExecute(t *testing.T) {
 	assert.Equal(t, parsedRagParams.Url, "https://example.com")
 }
 
+func TestScraperWorkerExecuteLargePage(t *testing.T) {
+	var (
+		ctx                   = context.Background()
+		mockScraper           = scraper.NewMockScraper()
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		blobStore             = blob_store.NewLocalBlobStore(t.TempDir())
+		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient).WithBlobStore(blobStore).WithMaxInlinePayload(30)
+	)
+
+	mockScraper.SetHtmlContent("https://example.com", "<html><body><main>Hello, world!</main></body></html>")
+	mockScraper.SetHtmlContent("https://example.com/large", "<html><body><main>Hello, world! Hello, world!</main></body></html>")
+
+	// Small pages are still put on the task
+	mockUrlTask, err := coordinator_client.NewTask("small", "test", ScraperWorkerParams{Url: "https://example.com"})
+	assert.NoError(t, err)
+	assert.NoError(t, scraperWorker.Execute(ctx, mockUrlTask))
+
+	createdRagTask, err := mockCoordinatorClient.GetTask(ctx, 0, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+
+	parsedRagParams, err := coordinator_client.CastParams[RagWorkerParams](createdRagTask.Params)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!", parsedRagParams.Markdown)
+	assert.Empty(t, parsedRagParams.PayloadKey)
+
+	mockUrlTask, err = coordinator_client.NewTask("large", "test", ScraperWorkerParams{Url: "https://example.com/large"})
+	assert.NoError(t, err)
+	assert.NoError(t, scraperWorker.Execute(ctx, mockUrlTask))
+
+	createdRagTask, err = mockCoordinatorClient.GetTask(ctx, 0, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+
+	parsedRagParams, err = coordinator_client.CastParams[RagWorkerParams](createdRagTask.Params)
+	assert.NoError(t, err)
+	assert.Empty(t, parsedRagParams.Markdown)
+	assert.Empty(t, parsedRagParams.InnerText)
+	assert.Equal(t, "payloads/"+createdRagTask.ID+".json", parsedRagParams.PayloadKey)
+
+	blob, err := blobStore.Get(ctx, parsedRagParams.PayloadKey)
+	assert.NoError(t, err)
+	defer blob.Close()
+
+	var payload ragPayload
+	assert.NoError(t, json.NewDecoder(blob).Decode(&payload))
+	assert.Equal(t, "Hello, world! Hello, world!", payload.Markdown)
+	assert.Equal(t, "Hello, world! Hello, world!", payload.InnerText)
+}
+
+func TestScraperWorkerExecuteNotModified(t *testing.T) {
+	var (
+		ctx                   = context.Background()
+		mockScraper           = scraper.NewMockScraper()
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		memoryStorage         = storage.NewMemoryStorage()
+		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient).WithStore(memoryStorage)
+	)
+
+	mockScraper.SetHtmlContent("https://example.com", "<html><body><main>Hello, world!</main></body></html>")
+	mockScraper.SetValidators("https://example.com", `"v1"`, "")
+
+	_, err := storage.Store(ctx, memoryStorage, storage.RagSource{URL: "https://example.com", ContentHash: "abc", ETag: `"v1"`})
+	assert.NoError(t, err)
+
+	mockUrlTask, err := coordinator_client.NewTask("id", "test", ScraperWorkerParams{Url: "https://example.com"})
+	assert.NoError(t, err)
+	mockUrlTask.JobId = "job-1"
+
+	// when
+	assert.NoError(t, scraperWorker.Execute(ctx, mockUrlTask))
+
+	// then
+	_, err = mockCoordinatorClient.GetTask(ctx, time.Second*1, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.ErrorIs(t, err, coordinator_client.ErrNoTasksToComplete)
+	assert.Equal(t, coordinator_client.JobUrlStatusSkipped, mockCoordinatorClient.JobUrlProgress("job-1", "https://example.com").Status)
+
+	// A page that has changed is sent on with its new validators
+	mockScraper.SetValidators("https://example.com", `"v2"`, "")
+	assert.NoError(t, scraperWorker.Execute(ctx, mockUrlTask))
+
+	createdRagTask, err := mockCoordinatorClient.GetTask(ctx, time.Second*1, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+
+	parsedRagParams, err := coordinator_client.CastParams[RagWorkerParams](createdRagTask.Params)
+	assert.NoError(t, err)
+	assert.Equal(t, `"v2"`, parsedRagParams.ETag)
+}
+
+func TestScraperWorkerExecuteRecrawlFailed(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		status = http.StatusServiceUnavailable
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("<html><body><main>Sorry, we are down for maintenance</main></body></html>"))
+	}))
+	defer server.Close()
+
+	var (
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		memoryStorage         = storage.NewMemoryStorage()
+		scraperWorker         = NewScraperWorker(scraper.NewHttpScraper().WithHostInterval(0), mockCoordinatorClient).WithStore(memoryStorage)
+	)
+
+	source, err := storage.Store(ctx, memoryStorage, storage.RagSource{URL: server.URL, ContentHash: "abc", ETag: `"v1"`})
+	assert.NoError(t, err)
+	chunks, err := storage.StoreAll(ctx, memoryStorage, storage.RagChunk{RagSourceId: source.ID, Text: "Open daily"})
+	assert.NoError(t, err)
+
+	mockUrlTask, err := coordinator_client.NewTask("id", "test", ScraperWorkerParams{Url: server.URL})
+	assert.NoError(t, err)
+	mockUrlTask.JobId = "job-1"
+
+	// A page that is down for now fails, so it is retried, rather than being sent on to replace the
+	// stored chunks
+	assert.Error(t, scraperWorker.Execute(ctx, mockUrlTask))
+	assert.Equal(t, coordinator_client.JobUrlStatusRetrying, mockCoordinatorClient.JobUrlProgress("job-1", server.URL).Status)
+
+	// A page that is gone is skipped
+	status = http.StatusNotFound
+	assert.NoError(t, scraperWorker.Execute(ctx, mockUrlTask))
+	assert.Equal(t, coordinator_client.JobUrlStatusSkipped, mockCoordinatorClient.JobUrlProgress("job-1", server.URL).Status)
+
+	_, err = mockCoordinatorClient.GetTask(ctx, 0, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.ErrorIs(t, err, coordinator_client.ErrNoTasksToComplete)
+
+	stored, err := storage.GetAll[storage.RagChunk](ctx, memoryStorage, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, chunks, stored)
+}
+
 func TestScraperWorkerExecuteNoMarkdown(t *testing.T) {
 	var (
 		mockScraper           = scraper.NewMockScraper()
@@ -102,7 +338,7 @@ func TestScraperWorkerExecuteNoMarkdown(t *testing.T) {
 		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient)
 	)
 
-	mockScraper.SetHtmlContent("https://example.com", "<html><body>Hello, world!</body></html>")
+	mockScraper.SetHtmlContent("https://example.com", "<html><body><script>track()</script></body></html>")
 
 	mockUrlTask, err := coordinator_client.NewTask("id", "test", ScraperWorkerParams{Url: "https://example.com"})
 	if err != nil {
@@ -117,3 +353,228 @@ func TestScraperWorkerExecuteNoMarkdown(t *testing.T) {
 		t.Fatalf("Expected ErrNoTasksToComplete, got %v", err)
 	}
 }
+
+func TestScraperWorkerExecuteDocuments(t *testing.T) {
+	var (
+		mockScraper           = scraper.NewMockScraper()
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient)
+	)
+
+	mockScraper.SetContent("https://example.com/README.md", "text/plain", "# Readme\n\nHello, world!")
+	mockScraper.SetContent("https://example.com/logo", "image/png", "\x89PNG\r\n\x1a\n")
+
+	mockUrlTask, err := coordinator_client.NewTask("readme", "test", ScraperWorkerParams{Url: "https://example.com/README.md", MaxDepth: 1})
+	assert.NoError(t, err)
+	mockUrlTask.JobId = "job-1"
+
+	err = scraperWorker.Execute(context.Background(), mockUrlTask)
+	assert.NoError(t, err)
+
+	createdRagTask, err := mockCoordinatorClient.GetTask(context.Background(), 0, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+
+	parsedRagParams, err := coordinator_client.CastParams[RagWorkerParams](createdRagTask.Params)
+	assert.NoError(t, err)
+	assert.Equal(t, "# Readme\n\nHello, world!", parsedRagParams.Markdown)
+	assert.Equal(t, storage.RagSourceTypeMarkdown, parsedRagParams.SourceType)
+
+	// Links aren't followed out of documents
+	_, err = mockCoordinatorClient.GetTask(context.Background(), 0, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, coordinator_client.ErrNoTasksToComplete, err)
+
+	// Pages in formats that can't be extracted are skipped
+	mockUrlTask, err = coordinator_client.NewTask("logo", "test", ScraperWorkerParams{Url: "https://example.com/logo"})
+	assert.NoError(t, err)
+	mockUrlTask.JobId = "job-1"
+
+	err = scraperWorker.Execute(context.Background(), mockUrlTask)
+	assert.NoError(t, err)
+
+	progress := mockCoordinatorClient.JobUrlProgress("job-1", "https://example.com/logo")
+	assert.NotNil(t, progress)
+	assert.Equal(t, coordinator_client.JobUrlStatusSkipped, progress.Status)
+}
+
+func TestScraperWorkerExecuteCrawl(t *testing.T) {
+	var (
+		mockScraper           = scraper.NewMockScraper()
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient)
+	)
+
+	mockScraper.SetHtmlContent("https://example.com", `<html><body>
+		<nav><a href="/about">About</a><a href="https://other.com/">Other</a></nav>
+		<main>Hello, world! <a href="blog#top">Blog</a><a href="/about">About again</a><a href="mailto:john@example.com">Email</a></main>
+	</body></html>`)
+
+	mockUrlTask, err := coordinator_client.NewTask("root", "test", ScraperWorkerParams{Url: "https://example.com", MaxDepth: 1, MaxPages: 10})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	err = scraperWorker.Execute(context.Background(), mockUrlTask)
+	assert.NoError(t, err)
+
+	var crawledUrls []string
+	for {
+		urlTask, err := mockCoordinatorClient.GetTask(context.Background(), 0, coordinator_client.CoordinatorClientTaskTopicUrls)
+		if err == coordinator_client.ErrNoTasksToComplete {
+			break
+		}
+		assert.NoError(t, err)
+
+		params, err := coordinator_client.CastParams[ScraperWorkerParams](urlTask.Params)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, params.Depth)
+		assert.Equal(t, 1, params.MaxDepth)
+		assert.Equal(t, "root", params.CrawlId)
+		assert.Equal(t, []string{"example.com"}, params.AllowedHosts)
+
+		crawledUrls = append(crawledUrls, params.Url)
+	}
+
+	assert.ElementsMatch(t, []string{"https://example.com/about", "https://example.com/blog"}, crawledUrls)
+
+	_, err = mockCoordinatorClient.GetTask(context.Background(), 0, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+}
+
+func TestScraperWorkerExecuteCrawlRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.NotFound(w, r)
+		case "/docs":
+			http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte(`<html><body><main>Docs <a href="intro">Intro</a></main></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		scraperWorker         = NewScraperWorker(scraper.NewHttpScraper().WithHostInterval(0), mockCoordinatorClient)
+	)
+
+	mockUrlTask, err := coordinator_client.NewTask("root", "test", ScraperWorkerParams{Url: server.URL + "/docs", MaxDepth: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, scraperWorker.Execute(context.Background(), mockUrlTask))
+
+	// The link is relative to the page the redirect ended up at, not the url that was asked for
+	urlTask, err := mockCoordinatorClient.GetTask(context.Background(), 0, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+
+	params, err := coordinator_client.CastParams[ScraperWorkerParams](urlTask.Params)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/docs/intro", params.Url)
+}
+
+func TestScraperWorkerExecuteCrawlMaxDepth(t *testing.T) {
+	var (
+		mockScraper           = scraper.NewMockScraper()
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient)
+	)
+
+	mockScraper.SetHtmlContent("https://example.com/about", `<html><body><main><a href="/team">Team</a></main></body></html>`)
+
+	mockUrlTask, err := coordinator_client.NewTask("child", "test", ScraperWorkerParams{
+		Url:      "https://example.com/about",
+		Depth:    1,
+		MaxDepth: 1,
+		CrawlId:  "root",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	err = scraperWorker.Execute(context.Background(), mockUrlTask)
+	assert.NoError(t, err)
+
+	_, err = mockCoordinatorClient.GetTask(context.Background(), 0, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, coordinator_client.ErrNoTasksToComplete, err)
+}
+
+func TestScraperWorkerExecuteCrawlMaxPages(t *testing.T) {
+	var (
+		mockScraper           = scraper.NewMockScraper()
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient)
+	)
+
+	mockScraper.SetHtmlContent("https://example.com", `<html><body><main>
+		<a href="/1">1</a><a href="/2">2</a><a href="/3">3</a>
+	</main></body></html>`)
+
+	mockUrlTask, err := coordinator_client.NewTask("root", "test", ScraperWorkerParams{Url: "https://example.com", MaxDepth: 2, MaxPages: 2})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	err = scraperWorker.Execute(context.Background(), mockUrlTask)
+	assert.NoError(t, err)
+
+	numTasks := 0
+	for {
+		_, err := mockCoordinatorClient.GetTask(context.Background(), 0, coordinator_client.CoordinatorClientTaskTopicUrls)
+		if err == coordinator_client.ErrNoTasksToComplete {
+			break
+		}
+		assert.NoError(t, err)
+		numTasks++
+	}
+
+	// The root page counts towards the limit
+	assert.Equal(t, 1, numTasks)
+}
+
+func TestScraperWorkerExecuteJobProgress(t *testing.T) {
+	var (
+		mockScraper           = scraper.NewMockScraper()
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient)
+	)
+
+	mockScraper.SetHtmlContent("https://example.com", "<html><body><main>Hello, world!</main></body></html>")
+
+	mockUrlTask, err := coordinator_client.NewTask("id", "test", ScraperWorkerParams{Url: "https://example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	mockUrlTask.JobId = "job-1"
+
+	err = scraperWorker.Execute(context.Background(), mockUrlTask)
+	assert.NoError(t, err)
+
+	createdRagTask, err := mockCoordinatorClient.GetTask(context.Background(), 0, coordinator_client.CoordinatorClientTaskTopicRag)
+	assert.NoError(t, err)
+	assert.Equal(t, "job-1", createdRagTask.JobId)
+
+	progress := mockCoordinatorClient.JobUrlProgress("job-1", "https://example.com")
+	assert.NotNil(t, progress)
+	assert.Equal(t, coordinator_client.JobUrlStatusEmbedding, progress.Status)
+}
+
+func TestScraperWorkerExecuteJobProgressFailed(t *testing.T) {
+	var (
+		mockScraper           = scraper.NewMockScraper()
+		mockCoordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		scraperWorker         = NewScraperWorker(mockScraper, mockCoordinatorClient)
+	)
+
+	mockUrlTask, err := coordinator_client.NewTask("id", "test", ScraperWorkerParams{Url: "https://example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	mockUrlTask.JobId = "job-1"
+
+	err = scraperWorker.Execute(context.Background(), mockUrlTask)
+	assert.Error(t, err)
+
+	progress := mockCoordinatorClient.JobUrlProgress("job-1", "https://example.com")
+	assert.NotNil(t, progress)
+	assert.Equal(t, coordinator_client.JobUrlStatusRetrying, progress.Status)
+	assert.NotEmpty(t, progress.Error)
+}
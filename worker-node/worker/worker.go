@@ -2,8 +2,13 @@ package worker
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
 
-	coordinator_client "github.com/ethanhosier/worker-node/coordinator_client"
+	coordinator_client "github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/ethanhosier/worker-node/storage"
 )
 
 type WorkerType string
@@ -19,3 +24,62 @@ type Worker interface {
 	WorkerType() WorkerType
 	Id() string
 }
+
+// setJobUrlProgress records the status url has reached in the job the task belongs to. Progress is
+// only informational, so failing to record it is logged rather than failing the task.
+func setJobUrlProgress(ctx context.Context, coordinatorClient coordinator_client.CoordinatorClient, task *coordinator_client.Task, url string, status coordinator_client.JobUrlStatus, err error) {
+	if task.JobId == "" {
+		return
+	}
+
+	progress := coordinator_client.NewJobUrlProgress(status, err)
+	if err := coordinatorClient.SetJobUrlProgress(ctx, task.JobId, url, progress); err != nil {
+		log.Printf("Failed to set progress of %s in job %s to %s: %v", url, task.JobId, status, err)
+	}
+}
+
+// jobCreator returns the user that created a task's job, so its sources can be searched by user. Tasks
+// without a job, or whose job has expired, have no creator.
+func jobCreator(ctx context.Context, coordinatorClient coordinator_client.CoordinatorClient, task *coordinator_client.Task) string {
+	if task.JobId == "" {
+		return ""
+	}
+
+	job, err := coordinatorClient.GetJob(ctx, task.JobId)
+	if err != nil {
+		log.Printf("Failed to get job %s of task %s: %v", task.JobId, task.ID, err)
+		return ""
+	}
+	return job.CreatedBy
+}
+
+// storedSource returns the source stored for url by creator, or nil if there isn't one. Pages stored
+// before sources were re-crawled in place can have several, of which the latest is used. Sources
+// without a url are never the same as each other.
+func storedSource(ctx context.Context, store storage.Storage, url string, creator string) (*storage.RagSource, error) {
+	if url == "" {
+		return nil, nil
+	}
+
+	ragSources, err := storage.GetAll[storage.RagSource](ctx, store, map[string]string{"url": url})
+	if err != nil {
+		return nil, fmt.Errorf("error getting rag sources for %s: %v", url, err)
+	}
+
+	var latest *storage.RagSource
+	for i, ragSource := range ragSources {
+		if ragSource.CreatedBy == creator && (latest == nil || ragSource.ID > latest.ID) {
+			latest = &ragSources[i]
+		}
+	}
+	return latest, nil
+}
+
+// contentHashOf hashes the markdown and text a page's contacts and chunks are made from
+func contentHashOf(ragParams *RagWorkerParams) string {
+	hash := sha256.New()
+	hash.Write([]byte(ragParams.Markdown))
+	hash.Write([]byte{0})
+	hash.Write([]byte(ragParams.InnerText))
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,43 @@
+package worker_manager
+
+import (
+	"time"
+
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+)
+
+// RetryPolicy controls how a failed task is retried. A task is attempted at most MaxAttempts times,
+// waiting BackoffBase after the first failure and doubling each time up to BackoffCap.
+type RetryPolicy struct {
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffCap  time.Duration
+}
+
+var defaultRetryPolicies = map[WorkerConfigType]RetryPolicy{
+	WorkerConfigTypeScraper: {
+		MaxAttempts: coordinator_client.DefaultMaxAttempts,
+		BackoffBase: 30 * time.Second,
+		BackoffCap:  10 * time.Minute,
+	},
+	WorkerConfigTypeRag: {
+		MaxAttempts: coordinator_client.DefaultMaxAttempts,
+		BackoffBase: 10 * time.Second,
+		BackoffCap:  5 * time.Minute,
+	},
+}
+
+// Backoff returns how long to wait before retrying a task that has failed attempts times
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	backoff := p.BackoffBase
+	for i := 1; i < attempts && backoff < p.BackoffCap; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, p.BackoffCap)
+}
+
+// ShouldRetry reports whether a task that has failed attempts times has any attempts left
+func (p RetryPolicy) ShouldRetry(attempts int) bool {
+	return attempts < p.MaxAttempts
+}
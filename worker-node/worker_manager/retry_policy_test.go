@@ -0,0 +1,30 @@
+package worker_manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BackoffBase: time.Second,
+		BackoffCap:  5 * time.Second,
+	}
+
+	assert.Equal(t, 1*time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+	assert.Equal(t, 5*time.Second, policy.Backoff(4))
+	assert.Equal(t, 5*time.Second, policy.Backoff(100))
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+
+	assert.True(t, policy.ShouldRetry(1))
+	assert.True(t, policy.ShouldRetry(2))
+	assert.False(t, policy.ShouldRetry(3))
+}
@@ -2,11 +2,14 @@ package worker_manager
 
 import (
 	"context"
+	"time"
 
-	"github.com/ethanhosier/worker-node/coordinator_client"
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
 	"github.com/ethanhosier/worker-node/ragger"
 	"github.com/ethanhosier/worker-node/scraper"
 	"github.com/ethanhosier/worker-node/storage"
+	"github.com/ethanhosier/worker-node/worker"
 )
 
 type WorkerConfigType string
@@ -16,25 +19,43 @@ const (
 	WorkerConfigTypeRag     WorkerConfigType = "rag"
 )
 
+// defaultTaskTimeouts bound how long a single task may execute for. Embedding a large page is much
+// slower than fetching one, so rag tasks get longer.
+var defaultTaskTimeouts = map[WorkerConfigType]time.Duration{
+	WorkerConfigTypeScraper: 2 * time.Minute,
+	WorkerConfigTypeRag:     5 * time.Minute,
+}
+
 type WorkerConfig struct {
 	Type              WorkerConfigType
 	ctx               context.Context
 	coordinatorClient coordinator_client.CoordinatorClient
 	numWorkers        int
+	retryPolicy       RetryPolicy
+	taskTimeout       time.Duration
+
+	shutdownGracePeriod time.Duration
 
-	scraper scraper.Scraper
+	scraper          scraper.Scraper
+	renderer         scraper.Renderer
+	maxInlinePayload int
 
-	ragger ragger.Ragger
-	store  storage.Storage
+	ragger    ragger.Ragger
+	store     storage.Storage
+	blobStore blob_store.BlobStore
 }
 
 func NewScraperWorkerManager(ctx context.Context, coordinatorClient coordinator_client.CoordinatorClient, scraper scraper.Scraper, numWorkers int) *WorkerManager {
 	workerConfig := &WorkerConfig{
-		Type:              WorkerConfigTypeScraper,
-		ctx:               ctx,
-		coordinatorClient: coordinatorClient,
-		scraper:           scraper,
-		numWorkers:        numWorkers,
+		Type:                WorkerConfigTypeScraper,
+		ctx:                 ctx,
+		coordinatorClient:   coordinatorClient,
+		scraper:             scraper,
+		maxInlinePayload:    worker.DefaultMaxInlinePayload,
+		numWorkers:          numWorkers,
+		retryPolicy:         defaultRetryPolicies[WorkerConfigTypeScraper],
+		taskTimeout:         defaultTaskTimeouts[WorkerConfigTypeScraper],
+		shutdownGracePeriod: defaultShutdownGracePeriod,
 	}
 
 	return newWorkerManager(workerConfig)
@@ -42,12 +63,15 @@ func NewScraperWorkerManager(ctx context.Context, coordinatorClient coordinator_
 
 func NewRagWorkerManager(ctx context.Context, coordinatorClient coordinator_client.CoordinatorClient, ragger ragger.Ragger, store storage.Storage, numWorkers int) *WorkerManager {
 	workerConfig := &WorkerConfig{
-		Type:              WorkerConfigTypeRag,
-		ctx:               ctx,
-		coordinatorClient: coordinatorClient,
-		ragger:            ragger,
-		store:             store,
-		numWorkers:        numWorkers,
+		Type:                WorkerConfigTypeRag,
+		ctx:                 ctx,
+		coordinatorClient:   coordinatorClient,
+		ragger:              ragger,
+		store:               store,
+		numWorkers:          numWorkers,
+		retryPolicy:         defaultRetryPolicies[WorkerConfigTypeRag],
+		taskTimeout:         defaultTaskTimeouts[WorkerConfigTypeRag],
+		shutdownGracePeriod: defaultShutdownGracePeriod,
 	}
 
 	return newWorkerManager(workerConfig)
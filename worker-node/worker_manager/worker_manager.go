@@ -1,17 +1,31 @@
 package worker_manager
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/ethanhosier/worker-node/coordinator_client"
+	"github.com/ethanhosier/web-crawler-shared/blob_store"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
+	"github.com/ethanhosier/worker-node/scraper"
+	"github.com/ethanhosier/worker-node/storage"
 	"github.com/ethanhosier/worker-node/worker"
 )
 
 const (
 	getTaskTimeout = 3 * time.Second
+
+	// defaultShutdownGracePeriod leaves time to requeue unfinished tasks within ECS's default 30s
+	// stop timeout
+	defaultShutdownGracePeriod = 25 * time.Second
+
+	// MaxTaskTimeout is the longest a task can be given to execute. It is a minute inside the lease the
+	// task is held for, leaving time to clean the task up once it has timed out, so the reaper never
+	// hands out a task that is still running.
+	MaxTaskTimeout = coordinator_client.LeaseDuration - time.Minute
 )
 
 type WorkerManager struct {
@@ -24,40 +38,97 @@ func newWorkerManager(config *WorkerConfig) *WorkerManager {
 	}
 }
 
-func (w *WorkerManager) Start() (chan<- bool, <-chan error) {
-	workers := w.createWorkers()
+// Start fetches and executes tasks until ctx is done, then stops fetching and gives in-flight tasks
+// the shutdown grace period to finish. Tasks still executing after that are cancelled and put back
+// on their topic. Start returns once every goroutine has exited, with the error that stopped the
+// manager early if there was one.
+//
+// The context the manager was created with is used for everything else, so cancelling it stops the
+// manager without waiting.
+func (w *WorkerManager) Start(ctx context.Context) error {
+	if w.config.taskTimeout > MaxTaskTimeout {
+		return fmt.Errorf("task timeout of %s is longer than the %s a task can be given", w.config.taskTimeout, MaxTaskTimeout)
+	}
+
+	return w.run(ctx, w.createWorkers())
+}
+
+func (w *WorkerManager) run(ctx context.Context, workers []worker.Worker) error {
+	stopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	execCtx, cancelExec := context.WithCancel(w.config.ctx)
+	defer cancelExec()
 
-	errChan := make(chan error)
 	taskChan := make(chan *coordinator_client.Task)
-	doneChan := make(chan bool)
+
+	// The first error stops the manager and is returned once everything has exited
+	var (
+		errOnce sync.Once
+		runErr  error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { runErr = err })
+		stop()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1 + len(workers))
 
 	go func() {
-		err := w.TaskLoop(taskChan, doneChan)
-		if err != nil {
-			errChan <- err
-		}
+		defer wg.Done()
 
-		close(taskChan)
+		if err := w.TaskLoop(stopCtx, taskChan); err != nil {
+			fail(err)
+		}
 	}()
 
 	for _, worker := range workers {
-		go w.workerLoop(worker, taskChan, errChan)
+		go func() {
+			defer wg.Done()
+
+			if err := w.workerLoop(execCtx, worker, taskChan); err != nil {
+				fail(err)
+			}
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-stopCtx.Done():
+		log.Printf("%s Worker manager stopping, waiting up to %s for tasks to finish",
+			strings.ToUpper(string(w.config.Type)), w.config.shutdownGracePeriod)
+
+		select {
+		case <-finished:
+		case <-time.After(w.config.shutdownGracePeriod):
+			log.Printf("%s Worker manager grace period expired, cancelling unfinished tasks", strings.ToUpper(string(w.config.Type)))
+			cancelExec()
+			<-finished
+		}
 	}
 
-	return doneChan, errChan
+	return runErr
 }
 
-func (w *WorkerManager) TaskLoop(taskChan chan<- *coordinator_client.Task, doneCh <-chan bool) error {
+// TaskLoop fetches tasks and hands them to the workers on taskChan until ctx is done. It closes
+// taskChan when it returns, so the workers stop once they have finished their current task.
+func (w *WorkerManager) TaskLoop(ctx context.Context, taskChan chan<- *coordinator_client.Task) error {
+	defer close(taskChan)
+
 	for {
-		// Check if we should stop
-		select {
-		case <-doneCh:
+		if ctx.Err() != nil {
 			return nil
-		default:
-			// Continue with task fetching
 		}
 
-		// Try to get a task
+		// Fetching isn't cancelled with ctx, as a task taken just before the cancellation would
+		// otherwise be left in the processing list until it is reaped
 		task, err := w.config.coordinatorClient.GetTaskAndSetProcessing(w.config.ctx, getTaskTimeout, topicForWorkerConfigType(w.config.Type))
 
 		if err == coordinator_client.ErrNoTasksToComplete {
@@ -70,35 +141,174 @@ func (w *WorkerManager) TaskLoop(taskChan chan<- *coordinator_client.Task, doneC
 		}
 
 		log.Printf("%s Task Found: %s", strings.ToUpper(string(w.config.Type)), task.ID)
-		taskChan <- task
+
+		select {
+		case taskChan <- task:
+		case <-ctx.Done():
+			w.requeueTask(task)
+			return nil
+		}
 	}
 }
 
-func (w *WorkerManager) workerLoop(worker worker.Worker, taskChan <-chan *coordinator_client.Task, errorChan chan<- error) {
+// workerLoop executes tasks from taskChan until it is closed. Tasks are executed with execCtx, and a
+// task whose execution is cut short by it being cancelled is put back on its topic.
+func (w *WorkerManager) workerLoop(execCtx context.Context, worker worker.Worker, taskChan <-chan *coordinator_client.Task) error {
 	log.Printf("%s Worker %s starting", strings.ToUpper(string(w.config.Type)), worker.Id())
 
 	for task := range taskChan {
+		if execCtx.Err() != nil {
+			w.requeueTask(task)
+			continue
+		}
+
 		log.Printf("%s Worker %s executing task %s", strings.ToUpper(string(w.config.Type)), worker.Id(), task.ID)
-		err := worker.Execute(w.config.ctx, task)
-		if err != nil {
+		execErr := w.execute(execCtx, worker, task)
+		if execErr != nil && execCtx.Err() != nil {
+			log.Printf("%s Worker %s was stopped while executing task %s", strings.ToUpper(string(w.config.Type)), worker.Id(), task.ID)
+			w.requeueTask(task)
+			continue
+		}
+
+		if execErr != nil {
 			log.Printf("%s Worker %s failed to execute task %s: %v. Will store error and continue.",
-				strings.ToUpper(string(w.config.Type)), worker.Id(), task.ID, err)
+				strings.ToUpper(string(w.config.Type)), worker.Id(), task.ID, execErr)
 
-			err = w.config.coordinatorClient.StoreError(w.config.ctx, topicForWorkerConfigType(w.config.Type), task, err)
+			err := w.config.coordinatorClient.StoreError(w.config.ctx, topicForWorkerConfigType(w.config.Type), task, execErr)
 			if err != nil {
 				log.Printf("Failed to store error for task %s: %v", task.ID, err)
-				errorChan <- err
-				return
+				return err
+			}
+
+			if err := w.retryOrDeadLetter(task, execErr); err != nil {
+				log.Printf("Failed to retry task %s: %v", task.ID, err)
+				return err
 			}
 		}
 
 		log.Printf("%s Worker %s cleaning up task %s", strings.ToUpper(string(w.config.Type)), worker.Id(), task.ID)
-		err = worker.Cleanup(w.config.ctx, task)
+		err := worker.Cleanup(w.config.ctx, task)
 		if err != nil {
-			errorChan <- err
-			return
+			return err
 		}
 	}
+
+	log.Printf("%s Worker %s stopped", strings.ToUpper(string(w.config.Type)), worker.Id())
+	return nil
+}
+
+// execute runs a task with the configured task timeout. A task that fails because it ran out of time
+// has its error wrapped with ErrTaskTimedOut so it is recorded as a timeout.
+func (w *WorkerManager) execute(execCtx context.Context, worker worker.Worker, task *coordinator_client.Task) error {
+	taskCtx, cancel := context.WithTimeout(execCtx, w.config.taskTimeout)
+	defer cancel()
+
+	err := worker.Execute(taskCtx, task)
+	if err != nil && execCtx.Err() == nil && taskCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w after %s: %w", coordinator_client.ErrTaskTimedOut, w.config.taskTimeout, err)
+	}
+
+	return err
+}
+
+// requeueTask puts a task the manager took but won't finish back on its topic. If that fails the
+// task is left for the reaper once its lease expires.
+func (w *WorkerManager) requeueTask(task *coordinator_client.Task) {
+	if err := w.config.coordinatorClient.RequeueTask(w.config.ctx, topicForWorkerConfigType(w.config.Type), task); err != nil {
+		log.Printf("Failed to requeue task %s: %v", task.ID, err)
+		return
+	}
+
+	log.Printf("%s Task %s requeued", strings.ToUpper(string(w.config.Type)), task.ID)
+}
+
+// retryOrDeadLetter schedules a retry of a failed task, or dead letters it if it has no attempts left.
+// The retry is a copy of the task, so the original can still be cleaned up from the processing list.
+func (w *WorkerManager) retryOrDeadLetter(task *coordinator_client.Task, execErr error) error {
+	topic := topicForWorkerConfigType(w.config.Type)
+	attempts := task.Attempts + 1
+
+	if !w.config.retryPolicy.ShouldRetry(attempts) {
+		log.Printf("Task %s failed after %d attempts, dead lettering", task.ID, attempts)
+		if err := w.config.coordinatorClient.DeadLetterTask(w.config.ctx, topic, task, execErr); err != nil {
+			return err
+		}
+
+		w.setJobUrlFailed(task, execErr)
+		return nil
+	}
+
+	retryTask := *task
+	retryTask.Attempts = attempts
+	backoff := w.config.retryPolicy.Backoff(attempts)
+
+	log.Printf("Task %s failed on attempt %d, retrying in %s", task.ID, attempts, backoff)
+	return w.config.coordinatorClient.RetryTask(w.config.ctx, topic, &retryTask, time.Now().Add(backoff))
+}
+
+// setJobUrlFailed marks the url of a dead lettered task as failed in its job. Workers can only mark it
+// as retrying, as they don't know whether the task has attempts left.
+func (w *WorkerManager) setJobUrlFailed(task *coordinator_client.Task, execErr error) {
+	url, _ := task.Params["url"].(string)
+	if task.JobId == "" || url == "" {
+		return
+	}
+
+	progress := coordinator_client.NewJobUrlProgress(coordinator_client.JobUrlStatusFailed, execErr)
+	if err := w.config.coordinatorClient.SetJobUrlProgress(w.config.ctx, task.JobId, url, progress); err != nil {
+		log.Printf("Failed to set progress of %s in job %s to %s: %v", url, task.JobId, coordinator_client.JobUrlStatusFailed, err)
+	}
+}
+
+// WithShutdownGracePeriod sets how long in-flight tasks are given to finish once Start's context is done
+func (w *WorkerManager) WithShutdownGracePeriod(shutdownGracePeriod time.Duration) *WorkerManager {
+	w.config.shutdownGracePeriod = shutdownGracePeriod
+	return w
+}
+
+// WithTaskTimeout overrides how long a single task may execute for before it is cancelled. It can be
+// at most MaxTaskTimeout, or Start fails.
+func (w *WorkerManager) WithTaskTimeout(taskTimeout time.Duration) *WorkerManager {
+	w.config.taskTimeout = taskTimeout
+	return w
+}
+
+// WithRenderer gives scraper workers a headless browser for pages that are rendered client side
+func (w *WorkerManager) WithRenderer(renderer scraper.Renderer) *WorkerManager {
+	w.config.renderer = renderer
+	return w
+}
+
+// WithBlobStore gives workers the blob store that uploaded documents, and pages too big to put on rag
+// tasks, are kept in. Without one, scraper workers put every page on its rag task.
+func (w *WorkerManager) WithBlobStore(blobStore blob_store.BlobStore) *WorkerManager {
+	w.config.blobStore = blobStore
+	return w
+}
+
+// WithStore gives scraper workers the storage that pages stored before are looked up in, so they are
+// only fetched again if they have changed
+func (w *WorkerManager) WithStore(store storage.Storage) *WorkerManager {
+	w.config.store = store
+	return w
+}
+
+// WithMaxInlinePayload sets the size in bytes above which scraper workers put a page in the blob store
+// instead of on its rag task
+func (w *WorkerManager) WithMaxInlinePayload(maxInlinePayload int) *WorkerManager {
+	w.config.maxInlinePayload = maxInlinePayload
+	return w
+}
+
+// WithRetryPolicy overrides the default retry policy for the manager's topic
+func (w *WorkerManager) WithRetryPolicy(retryPolicy RetryPolicy) *WorkerManager {
+	w.config.retryPolicy = retryPolicy
+	return w
+}
+
+// RetryPolicy returns the retry policy for the manager's topic
+func (w *WorkerManager) RetryPolicy() RetryPolicy {
+	return w.config.retryPolicy
 }
 
 func (w *WorkerManager) createWorkers() []worker.Worker {
@@ -107,9 +317,13 @@ func (w *WorkerManager) createWorkers() []worker.Worker {
 	for i := 0; i < w.config.numWorkers; i++ {
 		switch w.config.Type {
 		case WorkerConfigTypeScraper:
-			workers[i] = worker.NewScraperWorker(w.config.scraper, w.config.coordinatorClient)
+			workers[i] = worker.NewScraperWorker(w.config.scraper, w.config.coordinatorClient).
+				WithRenderer(w.config.renderer).
+				WithBlobStore(w.config.blobStore).
+				WithStore(w.config.store).
+				WithMaxInlinePayload(w.config.maxInlinePayload)
 		case WorkerConfigTypeRag:
-			workers[i] = worker.NewRagWorker(w.config.ragger, w.config.coordinatorClient, w.config.store)
+			workers[i] = worker.NewRagWorker(w.config.ragger, w.config.coordinatorClient, w.config.store).WithBlobStore(w.config.blobStore)
 		}
 	}
 
@@ -2,12 +2,14 @@ package worker_manager
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
-	"github.com/ethanhosier/worker-node/coordinator_client"
+	"github.com/ethanhosier/web-crawler-shared/coordinator_client"
 	"github.com/ethanhosier/worker-node/ragger"
 	"github.com/ethanhosier/worker-node/scraper"
 	"github.com/ethanhosier/worker-node/storage"
@@ -45,16 +47,11 @@ func TestWorkerManagerScraper(t *testing.T) {
 		t.Fatalf("Error creating mock task: %v", err)
 	}
 
-	doneChan, errChan := scraperWorkerManager.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	time.Sleep(10 * time.Second)
-
-	doneChan <- true
-
-	select {
-	case err := <-errChan:
+	if err := scraperWorkerManager.Start(ctx); err != nil {
 		t.Fatalf("Error: %v", err)
-	default:
 	}
 
 	ragTask, err := coordinatorClient.GetTask(context.TODO(), 1*time.Second, coordinator_client.CoordinatorClientTaskTopicRag)
@@ -117,17 +114,12 @@ func TestWorkerManagerRag(t *testing.T) {
 	}
 
 	// when
-	doneChan, errChan := workerManager.Start()
-
-	time.Sleep(10 * time.Second)
-
-	doneChan <- true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	// then
-	select {
-	case err := <-errChan:
+	if err := workerManager.Start(ctx); err != nil {
 		t.Fatalf("Error: %v", err)
-	default:
 	}
 
 	_, err = coordinatorClient.GetTask(context.TODO(), 1*time.Second, coordinator_client.CoordinatorClientTaskTopicRag)
@@ -135,7 +127,7 @@ func TestWorkerManagerRag(t *testing.T) {
 		t.Fatal("There should be no tasks to complete error")
 	}
 
-	storedRagSources, err := storage.GetAll[storage.RagSource](store, nil)
+	storedRagSources, err := storage.GetAll[storage.RagSource](context.Background(), store, nil)
 	if err != nil {
 		t.Fatalf("Error getting stored rag source: %v", err)
 	}
@@ -143,7 +135,7 @@ func TestWorkerManagerRag(t *testing.T) {
 	assert.Equal(t, len(storedRagSources), 1)
 	assert.Equal(t, storedRagSources[0].URL, "https://example.com")
 
-	contacts, err := storage.GetAll[storage.RagContact](store, nil)
+	contacts, err := storage.GetAll[storage.RagContact](context.Background(), store, nil)
 	if err != nil {
 		t.Fatalf("Error getting contacts: %v", err)
 	}
@@ -155,7 +147,7 @@ func TestWorkerManagerRag(t *testing.T) {
 	assert.Equal(t, contacts[0].RagSourceId, storedRagSources[0].ID)
 	assert.Equal(t, contacts[0].Embedding, []float32{7.0, 8.0, 9.0})
 
-	rags, err := storage.GetAll[storage.RagChunk](store, nil)
+	rags, err := storage.GetAll[storage.RagChunk](context.Background(), store, nil)
 	if err != nil {
 		t.Fatalf("Error getting rags: %v", err)
 	}
@@ -169,6 +161,278 @@ func TestWorkerManagerRag(t *testing.T) {
 	assert.Equal(t, rags[1].RagSourceId, storedRagSources[0].ID)
 }
 
+// TestWorkerManagersInOneProcess runs a scraper and a rag worker manager together as the all-in-one
+// binary does, coordinated in memory and sharing a bbolt file
+func TestWorkerManagersInOneProcess(t *testing.T) {
+	store, err := storage.NewBoltStorage(filepath.Join(t.TempDir(), "rag.db"))
+	if err != nil {
+		t.Fatalf("Error opening bbolt storage: %v", err)
+	}
+	defer store.Close()
+
+	var (
+		mockScraper       = scraper.NewMockScraper()
+		ragClient         = ragger.NewMockRagClient()
+		coordinatorClient = coordinator_client.NewMemoryCoordinatorClient()
+
+		workerManagers = []*WorkerManager{
+			NewScraperWorkerManager(context.TODO(), coordinatorClient, mockScraper, 1).WithStore(store),
+			NewRagWorkerManager(context.TODO(), coordinatorClient, ragClient, store, 1),
+		}
+
+		chunks = []string{"Hello, World!"}
+	)
+
+	mockScraper.SetHtmlContent("https://example.com", "<html><body><main><h1>Hello, World!</h1></main></body></html>")
+	ragClient.SetChunksFor("Hello, World!", chunks)
+	ragClient.SetContactsFor("# Hello, World!", nil)
+	ragClient.SetEmbeddingsForAll(chunks, [][]float32{{1.0, 0.0, 0.0}})
+
+	task, err := coordinator_client.NewTask("1", "CREATED_BY", worker.ScraperWorkerParams{Url: "https://example.com"})
+	if err != nil {
+		t.Fatalf("Error creating mock task: %v", err)
+	}
+	if err := coordinatorClient.CreateTask(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls, task); err != nil {
+		t.Fatalf("Error creating mock task: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	errs := make(chan error, len(workerManagers))
+	for _, workerManager := range workerManagers {
+		go func() {
+			errs <- workerManager.Start(ctx)
+		}()
+	}
+
+	var stored []storage.RagChunk
+	for ctx.Err() == nil && len(stored) == 0 {
+		time.Sleep(50 * time.Millisecond)
+		if stored, err = storage.GetAll[storage.RagChunk](context.Background(), store, nil); err != nil {
+			t.Fatalf("Error getting chunks: %v", err)
+		}
+	}
+	cancel()
+
+	for range workerManagers {
+		assert.NoError(t, <-errs)
+	}
+
+	if assert.Len(t, stored, 1) {
+		assert.Equal(t, "Hello, World!", stored[0].Text)
+	}
+}
+
+func TestWorkerManagerRetryOrDeadLetter(t *testing.T) {
+	var (
+		scraper           = scraper.NewMockScraper()
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		workerManager     = NewScraperWorkerManager(context.TODO(), coordinatorClient, scraper, 1).WithRetryPolicy(RetryPolicy{
+			MaxAttempts: 2,
+			BackoffBase: time.Minute,
+			BackoffCap:  time.Hour,
+		})
+		mockTask1, err = coordinator_client.NewTask("1", "CREATED_BY", worker.ScraperWorkerParams{
+			Url: "https://example.com",
+		})
+	)
+
+	if err != nil {
+		t.Fatalf("Error creating mock task: %v", err)
+	}
+	mockTask1.JobId = "job-1"
+
+	err = workerManager.retryOrDeadLetter(mockTask1, fmt.Errorf("503 Service Unavailable"))
+	assert.NoError(t, err)
+	assert.Nil(t, coordinatorClient.JobUrlProgress("job-1", "https://example.com"), "the url isn't failed while it has attempts left")
+
+	delayedTasks := coordinatorClient.DelayedTasks(coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, 1, len(delayedTasks))
+	assert.Equal(t, "1", delayedTasks[0].ID)
+	assert.Equal(t, 1, delayedTasks[0].Attempts)
+	assert.Equal(t, 0, mockTask1.Attempts, "the original task should be left as is so it can be cleaned up")
+
+	err = workerManager.retryOrDeadLetter(delayedTasks[0], fmt.Errorf("503 Service Unavailable"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(coordinatorClient.DelayedTasks(coordinator_client.CoordinatorClientTaskTopicUrls)))
+
+	deadTasks := coordinatorClient.DeadTasks(coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, 1, len(deadTasks))
+	assert.Equal(t, "1", deadTasks[0].Task.ID)
+	assert.Equal(t, "503 Service Unavailable", deadTasks[0].Error)
+
+	progress := coordinatorClient.JobUrlProgress("job-1", "https://example.com")
+	if assert.NotNil(t, progress) {
+		assert.Equal(t, coordinator_client.JobUrlStatusFailed, progress.Status)
+		assert.Equal(t, "503 Service Unavailable", progress.Error)
+	}
+}
+
+// slowWorker takes delay to execute a task, giving up early if its context is cancelled
+type slowWorker struct {
+	coordinatorClient coordinator_client.CoordinatorClient
+	delay             time.Duration
+	cleanupErr        error
+}
+
+func (w *slowWorker) Execute(ctx context.Context, task *coordinator_client.Task) error {
+	select {
+	case <-time.After(w.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *slowWorker) Cleanup(ctx context.Context, task *coordinator_client.Task) error {
+	if w.cleanupErr != nil {
+		return w.cleanupErr
+	}
+	return w.coordinatorClient.SetProcessed(ctx, coordinator_client.CoordinatorClientTaskTopicUrls, task)
+}
+
+func (w *slowWorker) WorkerType() worker.WorkerType {
+	return worker.WorkerTypeScraper
+}
+
+func (w *slowWorker) Id() string {
+	return "slow-worker"
+}
+
+func createScraperTask(t *testing.T, coordinatorClient coordinator_client.CoordinatorClient) {
+	task, err := coordinator_client.NewTask("1", "CREATED_BY", worker.ScraperWorkerParams{
+		Url: "https://example.com",
+	})
+	if err != nil {
+		t.Fatalf("Error creating mock task: %v", err)
+	}
+
+	err = coordinatorClient.CreateTask(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls, task)
+	if err != nil {
+		t.Fatalf("Error creating mock task: %v", err)
+	}
+}
+
+// runUntilProcessing starts the manager with w and stops it once a task has been taken
+func runUntilProcessing(t *testing.T, workerManager *WorkerManager, coordinatorClient *coordinator_client.MockCoordinatorClient, w worker.Worker) error {
+	createScraperTask(t, coordinatorClient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- workerManager.run(ctx, []worker.Worker{w})
+	}()
+
+	assert.Eventually(t, func() bool {
+		numProcessingTasks, _ := coordinatorClient.NumProcessingTasks(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls)
+		return numProcessingTasks == 1
+	}, 15*time.Second, 10*time.Millisecond)
+
+	cancel()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Worker manager didn't stop")
+		return nil
+	}
+}
+
+func TestWorkerManagerShutdownFinishesInFlightTasks(t *testing.T) {
+	var (
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		workerManager     = NewScraperWorkerManager(context.TODO(), coordinatorClient, scraper.NewMockScraper(), 1).WithShutdownGracePeriod(5 * time.Second)
+	)
+
+	err := runUntilProcessing(t, workerManager, coordinatorClient, &slowWorker{coordinatorClient: coordinatorClient, delay: 200 * time.Millisecond})
+	assert.NoError(t, err)
+
+	numTasks, _ := coordinatorClient.NumTasks(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls)
+	numProcessingTasks, _ := coordinatorClient.NumProcessingTasks(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, 0, numTasks)
+	assert.Equal(t, 0, numProcessingTasks)
+}
+
+func TestWorkerManagerShutdownRequeuesUnfinishedTasks(t *testing.T) {
+	var (
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		workerManager     = NewScraperWorkerManager(context.TODO(), coordinatorClient, scraper.NewMockScraper(), 1).WithShutdownGracePeriod(100 * time.Millisecond)
+	)
+
+	err := runUntilProcessing(t, workerManager, coordinatorClient, &slowWorker{coordinatorClient: coordinatorClient, delay: time.Minute})
+	assert.NoError(t, err)
+
+	numProcessingTasks, _ := coordinatorClient.NumProcessingTasks(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, 0, numProcessingTasks)
+
+	requeued, err := coordinatorClient.GetTask(context.TODO(), 0, coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", requeued.ID)
+	assert.Equal(t, 0, requeued.Attempts)
+	assert.Empty(t, coordinatorClient.DeadTasks(coordinator_client.CoordinatorClientTaskTopicUrls))
+	assert.Empty(t, coordinatorClient.DelayedTasks(coordinator_client.CoordinatorClientTaskTopicUrls))
+}
+
+func TestWorkerManagerTaskTimeoutOutlastsLease(t *testing.T) {
+	var (
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		workerManager     = NewScraperWorkerManager(context.TODO(), coordinatorClient, scraper.NewMockScraper(), 1).WithTaskTimeout(coordinator_client.LeaseDuration)
+	)
+
+	createScraperTask(t, coordinatorClient)
+
+	err := workerManager.Start(context.Background())
+	assert.Error(t, err)
+
+	numTasks, _ := coordinatorClient.NumTasks(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, 1, numTasks)
+}
+
+func TestWorkerManagerTaskTimeout(t *testing.T) {
+	var (
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		workerManager     = NewScraperWorkerManager(context.TODO(), coordinatorClient, scraper.NewMockScraper(), 1).WithTaskTimeout(100 * time.Millisecond)
+	)
+
+	createScraperTask(t, coordinatorClient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- workerManager.run(ctx, []worker.Worker{&slowWorker{coordinatorClient: coordinatorClient, delay: time.Minute}})
+	}()
+
+	assert.Eventually(t, func() bool {
+		storedErrors, _ := coordinatorClient.GetErrors(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls)
+		return len(storedErrors) == 1
+	}, 15*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+
+	storedErrors, _ := coordinatorClient.GetErrors(context.TODO(), coordinator_client.CoordinatorClientTaskTopicUrls)
+	assert.Equal(t, coordinator_client.StoredErrorKindTimeout, storedErrors[0].Kind)
+	assert.Len(t, coordinatorClient.DelayedTasks(coordinator_client.CoordinatorClientTaskTopicUrls), 1)
+}
+
+func TestWorkerManagerStopsOnError(t *testing.T) {
+	var (
+		coordinatorClient = coordinator_client.NewMockCoordinatorClient()
+		workerManager     = NewScraperWorkerManager(context.TODO(), coordinatorClient, scraper.NewMockScraper(), 1)
+		failingWorker     = &slowWorker{coordinatorClient: coordinatorClient, cleanupErr: fmt.Errorf("cleanup failed")}
+	)
+
+	createScraperTask(t, coordinatorClient)
+
+	err := workerManager.run(context.Background(), []worker.Worker{failingWorker})
+	assert.EqualError(t, err, "cleanup failed")
+}
+
 func TestWorkerManagerRedis(t *testing.T) {
 	if os.Getenv("CICD") == "true" {
 		t.Skip("Skipping test because CICD is true")
@@ -180,16 +444,11 @@ func TestWorkerManagerRedis(t *testing.T) {
 		scraperWorkerManager = NewScraperWorkerManager(context.TODO(), redisClient, scraper, 1)
 	)
 
-	doneChan, errChan := scraperWorkerManager.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	time.Sleep(5 * time.Second)
-
-	doneChan <- true
-
-	select {
-	case err := <-errChan:
+	if err := scraperWorkerManager.Start(ctx); err != nil {
 		t.Fatalf("Error:  %v", err)
-	default:
 	}
 }
 
@@ -232,16 +491,11 @@ func TestRedisWorkerManager2(t *testing.T) {
 		t.Fatalf("Error creating mock task: %v", err)
 	}
 
-	doneChan, errChan := scraperWorkerManager.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	time.Sleep(10 * time.Second)
-
-	doneChan <- true
-
-	select {
-	case err := <-errChan:
+	if err := scraperWorkerManager.Start(ctx); err != nil {
 		t.Fatalf("Error: %v", err)
-	default:
 	}
 
 	ragTask, err := redisCoordinatorClient.GetTask(context.TODO(), 1*time.Second, coordinator_client.CoordinatorClientTaskTopicRag)